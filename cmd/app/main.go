@@ -10,7 +10,9 @@ import (
 
 	"github.com/olenka-91/DocsServer/internal/config"
 	"github.com/olenka-91/DocsServer/internal/handler"
+	"github.com/olenka-91/DocsServer/internal/notifier"
 	"github.com/olenka-91/DocsServer/internal/repository"
+	"github.com/olenka-91/DocsServer/internal/scanner"
 	"github.com/olenka-91/DocsServer/internal/service"
 	"github.com/olenka-91/DocsServer/internal/storage"
 	"github.com/olenka-91/DocsServer/pkg/httpserver"
@@ -44,14 +46,97 @@ func main() {
 	repos := repository.NewRepository(db)
 	log.Debug("Repositories created successfully")
 
+	if len(os.Args) > 1 && os.Args[1] == "reconcile" {
+		code := runReconcile(cfg, repos, os.Args[2:])
+		db.Close()
+		os.Exit(code)
+	}
+
+	log.Info("Loading encryption keys...")
+	keyring, err := storage.LoadKeyring(cfg.EncryptionKey, cfg.EncryptionKeyFile, cfg.EncryptionActiveKey)
+	if err != nil {
+		log.Fatalf("error loading encryption keys: %s", err.Error())
+	}
+	if keyring == nil {
+		log.Warn("Encryption at rest is disabled: no master key configured")
+	}
+
 	log.Info("Creating FileStorage...")
-	fs := storage.NewFileStorage(cfg.StorageAddr)
+	fs := storage.NewFileStorage(storage.StorageConfig{
+		BasePath: cfg.StorageAddr,
+		Remote:   cfg.StorageRemote,
+		Cache: storage.CacheConfig{
+			MaxBytes:    cfg.CacheMaxBytes,
+			MaxEntries:  cfg.CacheMaxEntries,
+			MaxFileSize: cfg.CacheMaxFileSize,
+			TTL:         cfg.CacheTTL,
+		},
+		DiskCache: storage.DiskCacheConfig{
+			Dir:         cfg.DiskCacheDir,
+			MaxBytes:    cfg.DiskCacheMaxBytes,
+			MaxFileSize: cfg.DiskCacheMaxFileSize,
+		},
+		Keyring: keyring,
+		Compression: storage.CompressionConfig{
+			Encoding:  cfg.Compression,
+			MimeTypes: cfg.CompressionMimeTypes,
+		},
+	})
 	log.Debug("FileStorage created successfully")
 
+	log.Info("Creating notifier...")
+	ntf, err := notifier.New(cfg.NotifierType, cfg.NotifierFile, cfg.NotifierDevMode)
+	if err != nil {
+		log.Fatalf("error creating notifier: %s", err.Error())
+	}
+	log.Debug("Notifier created successfully")
+
+	log.Info("Creating malware scanner...")
+	sc, err := scanner.New(cfg.Scanner, cfg.ClamdAddr, cfg.ScanTimeout)
+	if err != nil {
+		log.Fatalf("error creating scanner: %s", err.Error())
+	}
+
 	log.Info("Creating services...")
-	serv := service.NewService(repos, fs)
+	serv := service.NewService(repos, fs, cfg, ntf, sc)
 	log.Debug("Services created successfully")
 
+	bgCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+
+	log.Info("Starting token revocation sync...")
+	go serv.Revocation.Run(bgCtx)
+
+	log.Info("Starting cache janitor...")
+	go fs.RunCacheJanitor(bgCtx)
+
+	log.Info("Starting storage scrubber...")
+	go serv.Scrub.Run(bgCtx)
+
+	log.Info("Starting storage reconciliation...")
+	go serv.Reconciliation.Run(bgCtx)
+
+	log.Info("Starting malware scan queue...")
+	go serv.Scans.Run(bgCtx)
+
+	log.Info("Starting thumbnail generator...")
+	go serv.Thumbnails.Run(bgCtx)
+
+	log.Info("Starting metadata extraction...")
+	go serv.Metadata.Run(bgCtx)
+
+	log.Info("Starting document events...")
+	go serv.Events.Run(bgCtx)
+
+	log.Info("Starting webhook delivery...")
+	go serv.Webhooks.Run(bgCtx)
+
+	log.Info("Starting retention scheduler...")
+	go serv.Retention.Run(bgCtx)
+
+	log.Info("Starting document lock cleanup...")
+	go serv.Locks.Run(bgCtx)
+
 	log.Info("Creating handlers...")
 	handl := handler.NewHandler(serv)
 	log.Debug("Handlers created successfully")
@@ -80,9 +165,12 @@ func main() {
 	defer cancel()
 
 	log.Info("Shutting down the server...")
+	// Потоки событий не завершаются сами, Shutdown ждал бы их до таймаута
+	serv.Events.Close()
 	if err := server.Shutdown(ctx); err != nil {
 		log.Errorf("error occured on server shutting down: %s", err.Error())
 	}
+	stopBackground()
 
 	if err := db.Close(); err != nil {
 		log.Errorf("error occured on db connection close: %s", err.Error())
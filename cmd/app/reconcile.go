@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/olenka-91/DocsServer/internal/config"
+	"github.com/olenka-91/DocsServer/internal/entity"
+	"github.com/olenka-91/DocsServer/internal/repository"
+	"github.com/olenka-91/DocsServer/internal/service"
+	"github.com/olenka-91/DocsServer/internal/storage"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// runReconcile - подкоманда `app reconcile [-repair] [-grace 1h]`. Печатает отчет в JSON.
+// Код выхода: 0 - расхождений нет или все исправлены, 1 - ошибка, 2 - есть расхождения.
+func runReconcile(cfg *config.Config, repos *repository.Repository, args []string) int {
+	flags := flag.NewFlagSet("reconcile", flag.ContinueOnError)
+	repair := flags.Bool("repair", false, "remove orphaned files and stale uploads")
+	deleteMissing := flags.Bool("delete-missing", false, "with -repair, also delete documents whose files are missing")
+	grace := flags.Duration("grace", cfg.ReconcileGrace, "ignore files and uploads younger than this")
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	// Кеши не нужны: сверка работает напрямую с хранилищем
+	fs := storage.NewFileStorage(storage.StorageConfig{BasePath: cfg.StorageAddr})
+	opts := entity.ReconcileOptions{Repair: *repair, DeleteMissing: *deleteMissing}
+	reconciler := service.NewReconcileService(repos.Integrity, fs, 0, *grace, opts,
+		cfg.ReconcileMaxMissing, cfg.ReconcileMaxMissingRatio)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	report, err := reconciler.Reconcile(ctx, opts)
+	if err != nil {
+		log.Errorf("Reconciliation failed: %v", err)
+		return 1
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return 1
+	}
+
+	if report.Clean() || (*repair && allRepaired(report)) {
+		return 0
+	}
+	return 2
+}
+
+func allRepaired(report *entity.ReconcileReport) bool {
+	if len(report.Errors) > 0 {
+		return false
+	}
+	for _, issues := range [][]entity.ScrubIssue{report.OrphanedFiles, report.MissingFiles, report.StaleUploads} {
+		for _, issue := range issues {
+			if !issue.Repaired {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -1,6 +1,13 @@
 package config
 
-import "github.com/spf13/viper"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
 
 type Config struct {
 	HTTPPort    string
@@ -11,10 +18,106 @@ type Config struct {
 	DBName      string
 	SSLMode     string
 	StorageAddr string
+	// StorageRemote - каталог хранилища смонтирован по сети, перед ним нужен дисковый кеш
+	StorageRemote bool
+
+	PasswordHistorySize int
+	ResetTokenTTL       time.Duration
+	NotifierType        string
+	NotifierFile        string
+	// NotifierDevMode - уведомления содержат токены целиком (только для разработки)
+	NotifierDevMode bool
+
+	RevocationSyncInterval time.Duration
+
+	CacheMaxBytes    int64
+	CacheMaxEntries  int
+	CacheMaxFileSize int64
+	CacheTTL         time.Duration
+
+	DiskCacheDir         string
+	DiskCacheMaxBytes    int64
+	DiskCacheMaxFileSize int64
+
+	ScrubInterval    time.Duration
+	ScrubOrphanGrace time.Duration
+
+	ReconcileInterval time.Duration
+	ReconcileGrace    time.Duration
+	ReconcileRepair   bool
+	// ReconcileDeleteMissing - удалять записи документов без файла; без него Repair их не трогает
+	ReconcileDeleteMissing   bool
+	ReconcileMaxMissing      int
+	ReconcileMaxMissingRatio float64
+
+	EncryptionKey       string
+	EncryptionKeyFile   string
+	EncryptionActiveKey string
+
+	Compression          string
+	CompressionMimeTypes []string
+
+	QuotaDefaultBytes int64
+	QuotaDefaultDocs  int64
+
+	MaxFileSize       int64
+	MaxFileSizeByType map[string]int64
+
+	MimeMismatchPolicy string
+	MimeAllow          []string
+	MimeDeny           []string
+
+	Scanner      string
+	ClamdAddr    string
+	ScanTimeout  time.Duration
+	ScanInterval time.Duration
+
+	MetadataIncludeGPS   bool
+	MetadataScrub        string
+	MetadataKeepOriginal bool
+
+	EventsPollInterval time.Duration
+	EventsHeartbeat    time.Duration
+	EventsRetention    time.Duration
+
+	WebhookInterval     time.Duration
+	WebhookTimeout      time.Duration
+	WebhookMaxAttempts  int
+	WebhookAllowPrivate bool
+
+	RetentionInterval time.Duration
+
+	LockTTL    time.Duration
+	LockMaxTTL time.Duration
 }
 
 const (
-	defaultStorageAddr = "./storage"
+	defaultStorageAddr         = "./storage"
+	defaultPasswordHistorySize = 5
+	defaultResetTokenTTL       = 30 * time.Minute
+	defaultNotifierType        = "log"
+	defaultNotifierFile        = "./notifications.log"
+	defaultRevocationSync      = 10 * time.Second
+	defaultScrubInterval       = 24 * time.Hour
+	defaultScrubOrphanGrace    = time.Hour
+	defaultReconcileInterval   = time.Hour
+	defaultReconcileGrace      = time.Hour
+	defaultReconcileMaxMissing = 100
+	defaultReconcileMaxRatio   = 0.05
+	defaultMimeMismatchPolicy  = "override"
+	defaultScanner             = "none"
+	defaultScanTimeout         = 5 * time.Minute
+	defaultScanInterval        = time.Minute
+	defaultMetadataScrub       = "keep"
+	defaultEventsPollInterval  = time.Second
+	defaultEventsHeartbeat     = 15 * time.Second
+	defaultEventsRetention     = 7 * 24 * time.Hour
+	defaultWebhookInterval     = 5 * time.Second
+	defaultWebhookTimeout      = 10 * time.Second
+	defaultWebhookMaxAttempts  = 8
+	defaultRetentionInterval   = time.Hour
+	defaultLockTTL             = 30 * time.Minute
+	defaultLockMaxTTL          = 24 * time.Hour
 )
 
 func Load() (*Config, error) {
@@ -23,6 +126,34 @@ func Load() (*Config, error) {
 	if err := viper.ReadInConfig(); err != nil {
 		return nil, err
 	}
+
+	viper.SetDefault("STORAGE_PATH", defaultStorageAddr)
+	viper.SetDefault("PASSWORD_HISTORY_SIZE", defaultPasswordHistorySize)
+	viper.SetDefault("RESET_TOKEN_TTL", defaultResetTokenTTL)
+	viper.SetDefault("NOTIFIER", defaultNotifierType)
+	viper.SetDefault("NOTIFIER_FILE", defaultNotifierFile)
+	viper.SetDefault("REVOCATION_SYNC_INTERVAL", defaultRevocationSync)
+	viper.SetDefault("SCRUB_INTERVAL", defaultScrubInterval)
+	viper.SetDefault("SCRUB_ORPHAN_GRACE", defaultScrubOrphanGrace)
+	viper.SetDefault("RECONCILE_INTERVAL", defaultReconcileInterval)
+	viper.SetDefault("RECONCILE_GRACE", defaultReconcileGrace)
+	viper.SetDefault("RECONCILE_MAX_MISSING", defaultReconcileMaxMissing)
+	viper.SetDefault("RECONCILE_MAX_MISSING_RATIO", defaultReconcileMaxRatio)
+	viper.SetDefault("MIME_MISMATCH_POLICY", defaultMimeMismatchPolicy)
+	viper.SetDefault("SCANNER", defaultScanner)
+	viper.SetDefault("SCAN_TIMEOUT", defaultScanTimeout)
+	viper.SetDefault("SCAN_INTERVAL", defaultScanInterval)
+	viper.SetDefault("METADATA_SCRUB", defaultMetadataScrub)
+	viper.SetDefault("EVENTS_POLL_INTERVAL", defaultEventsPollInterval)
+	viper.SetDefault("EVENTS_HEARTBEAT", defaultEventsHeartbeat)
+	viper.SetDefault("EVENTS_RETENTION", defaultEventsRetention)
+	viper.SetDefault("WEBHOOK_INTERVAL", defaultWebhookInterval)
+	viper.SetDefault("WEBHOOK_TIMEOUT", defaultWebhookTimeout)
+	viper.SetDefault("WEBHOOK_MAX_ATTEMPTS", defaultWebhookMaxAttempts)
+	viper.SetDefault("RETENTION_INTERVAL", defaultRetentionInterval)
+	viper.SetDefault("LOCK_TTL", defaultLockTTL)
+	viper.SetDefault("LOCK_MAX_TTL", defaultLockMaxTTL)
+
 	cfg := &Config{
 		HTTPPort:    viper.GetString("APP_PORT"),
 		DBHost:      viper.GetString("DB_HOST"),
@@ -31,7 +162,139 @@ func Load() (*Config, error) {
 		DBPassword:  viper.GetString("DB_PASSWORD"),
 		DBName:      viper.GetString("DB_NAME"),
 		SSLMode:     viper.GetString("DB_SSLMODE"),
-		StorageAddr: defaultStorageAddr,
+		StorageAddr: viper.GetString("STORAGE_PATH"),
+
+		StorageRemote: viper.GetBool("STORAGE_REMOTE"),
+
+		PasswordHistorySize: viper.GetInt("PASSWORD_HISTORY_SIZE"),
+		ResetTokenTTL:       viper.GetDuration("RESET_TOKEN_TTL"),
+		NotifierType:        viper.GetString("NOTIFIER"),
+		NotifierFile:        viper.GetString("NOTIFIER_FILE"),
+		NotifierDevMode:     viper.GetBool("NOTIFIER_DEV_MODE"),
+
+		RevocationSyncInterval: viper.GetDuration("REVOCATION_SYNC_INTERVAL"),
+
+		// Нулевые значения означают ограничения кеша по умолчанию
+		CacheMaxBytes:    viper.GetInt64("CACHE_MAX_BYTES"),
+		CacheMaxEntries:  viper.GetInt("CACHE_MAX_ENTRIES"),
+		CacheMaxFileSize: viper.GetInt64("CACHE_MAX_FILE_SIZE"),
+		CacheTTL:         viper.GetDuration("CACHE_TTL"),
+
+		DiskCacheDir:         viper.GetString("DISK_CACHE_DIR"),
+		DiskCacheMaxBytes:    viper.GetInt64("DISK_CACHE_MAX_BYTES"),
+		DiskCacheMaxFileSize: viper.GetInt64("DISK_CACHE_MAX_FILE_SIZE"),
+
+		// SCRUB_INTERVAL=0 отключает проверку по расписанию
+		ScrubInterval:    viper.GetDuration("SCRUB_INTERVAL"),
+		ScrubOrphanGrace: viper.GetDuration("SCRUB_ORPHAN_GRACE"),
+
+		// По умолчанию сверка только сообщает о расхождениях
+		ReconcileInterval: viper.GetDuration("RECONCILE_INTERVAL"),
+		ReconcileGrace:    viper.GetDuration("RECONCILE_GRACE"),
+		ReconcileRepair:   viper.GetBool("RECONCILE_REPAIR"),
+
+		// Записи документов без файла удаляются только явно и не больше заданной доли:
+		// иначе отключенный том хранилища стер бы всю таблицу documents
+		ReconcileDeleteMissing:   viper.GetBool("RECONCILE_DELETE_MISSING"),
+		ReconcileMaxMissing:      viper.GetInt("RECONCILE_MAX_MISSING"),
+		ReconcileMaxMissingRatio: viper.GetFloat64("RECONCILE_MAX_MISSING_RATIO"),
+
+		// Пустые ENCRYPTION_KEY и ENCRYPTION_KEY_FILE - шифрование выключено
+		EncryptionKey:       viper.GetString("ENCRYPTION_KEY"),
+		EncryptionKeyFile:   viper.GetString("ENCRYPTION_KEY_FILE"),
+		EncryptionActiveKey: viper.GetString("ENCRYPTION_ACTIVE_KEY"),
+
+		// Пустой список типов - набор по умолчанию (текст, JSON, XML и т.п.)
+		Compression:          viper.GetString("COMPRESSION"),
+		CompressionMimeTypes: splitList(viper.GetString("COMPRESSION_MIME_TYPES")),
+
+		// Квота по умолчанию; 0 - без ограничения
+		QuotaDefaultBytes: viper.GetInt64("QUOTA_DEFAULT_BYTES"),
+		QuotaDefaultDocs:  viper.GetInt64("QUOTA_DEFAULT_DOCS"),
+
+		// MAX_FILE_SIZE=0 - размер файла ограничен только квотой
+		MaxFileSize: viper.GetInt64("MAX_FILE_SIZE"),
+
+		// Пустой MIME_ALLOW разрешает все типы, кроме MIME_DENY
+		MimeMismatchPolicy: strings.ToLower(viper.GetString("MIME_MISMATCH_POLICY")),
+		MimeAllow:          splitList(viper.GetString("MIME_ALLOW")),
+		MimeDeny:           splitList(viper.GetString("MIME_DENY")),
+
+		// SCANNER=none - файлы не проверяются и доступны сразу после загрузки
+		Scanner:      viper.GetString("SCANNER"),
+		ClamdAddr:    viper.GetString("CLAMD_ADDR"),
+		ScanTimeout:  viper.GetDuration("SCAN_TIMEOUT"),
+		ScanInterval: viper.GetDuration("SCAN_INTERVAL"),
+
+		// По умолчанию координаты съемки в извлеченные метаданные не попадают
+		MetadataIncludeGPS: viper.GetBool("METADATA_INCLUDE_GPS"),
+
+		// Удаление метаданных из изображений по умолчанию; пользователю и документу
+		// можно задать свой режим
+		MetadataScrub:        strings.ToLower(viper.GetString("METADATA_SCRUB")),
+		MetadataKeepOriginal: viper.GetBool("METADATA_KEEP_ORIGINAL"),
+
+		// EVENTS_RETENTION=0 - журнал событий не очищается
+		EventsPollInterval: viper.GetDuration("EVENTS_POLL_INTERVAL"),
+		EventsHeartbeat:    viper.GetDuration("EVENTS_HEARTBEAT"),
+		EventsRetention:    viper.GetDuration("EVENTS_RETENTION"),
+
+		// По умолчанию webhooks пользователей не могут обращаться к внутренним адресам
+		WebhookInterval:     viper.GetDuration("WEBHOOK_INTERVAL"),
+		WebhookTimeout:      viper.GetDuration("WEBHOOK_TIMEOUT"),
+		WebhookMaxAttempts:  viper.GetInt("WEBHOOK_MAX_ATTEMPTS"),
+		WebhookAllowPrivate: viper.GetBool("WEBHOOK_ALLOW_PRIVATE"),
+
+		RetentionInterval: viper.GetDuration("RETENTION_INTERVAL"),
+
+		// LOCK_TTL - срок блокировки документа, если клиент его не указал
+		LockTTL:    viper.GetDuration("LOCK_TTL"),
+		LockMaxTTL: viper.GetDuration("LOCK_MAX_TTL"),
+	}
+
+	switch cfg.MimeMismatchPolicy {
+	case "reject", "override", "warn":
+	default:
+		return nil, fmt.Errorf("MIME_MISMATCH_POLICY: unsupported value %q", cfg.MimeMismatchPolicy)
+	}
+
+	switch cfg.MetadataScrub {
+	case "keep", "gps", "all":
+	default:
+		return nil, fmt.Errorf("METADATA_SCRUB: unsupported value %q", cfg.MetadataScrub)
+	}
+
+	byType, err := parseSizeList(viper.GetString("MAX_FILE_SIZE_BY_TYPE"))
+	if err != nil {
+		return nil, fmt.Errorf("MAX_FILE_SIZE_BY_TYPE: %w", err)
 	}
+	cfg.MaxFileSizeByType = byType
 	return cfg, nil
 }
+
+// parseSizeList разбирает список вида "image/*=20971520,video/mp4=1073741824".
+func parseSizeList(value string) (map[string]int64, error) {
+	sizes := make(map[string]int64)
+	for _, item := range splitList(value) {
+		pattern, size, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid item %q", item)
+		}
+		n, err := strconv.ParseInt(strings.TrimSpace(size), 10, 64)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid size in %q", item)
+		}
+		sizes[strings.TrimSpace(pattern)] = n
+	}
+	return sizes, nil
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
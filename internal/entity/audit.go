@@ -0,0 +1,84 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Действия, записываемые в журнал аудита
+const (
+	AuditDocView      = "doc.view"
+	AuditDocDownload  = "doc.download"
+	AuditDocPreview   = "doc.preview"
+	AuditDocUpload    = "doc.upload"
+	AuditDocShare     = "doc.share"
+	AuditDocUpdate    = "doc.update"
+	AuditDocDelete    = "doc.delete"
+	AuditDocExpire    = "doc.expire"
+	AuditDocHold      = "doc.hold"
+	AuditDocRelease   = "doc.release"
+	AuditDocLock      = "doc.lock"
+	AuditDocUnlock    = "doc.unlock"
+	AuditDocTransfer  = "doc.transfer"
+	AuditDocAccept    = "doc.transfer_accept"
+	AuditDocDecline   = "doc.transfer_decline"
+	AuditDocCancel    = "doc.transfer_cancel"
+	AuditBulkTransfer = "user.transfer_docs"
+	AuditSignUp       = "auth.signup"
+	AuditSignIn       = "auth.login"
+	AuditRefresh      = "auth.refresh"
+	AuditLogout       = "auth.logout"
+	AuditPasswordChg  = "auth.password_change"
+	AuditResetRequest = "auth.password_reset_request"
+	AuditReset        = "auth.password_reset"
+	AuditUserDisable  = "auth.user_disable"
+	AuditUserEnable   = "auth.user_enable"
+	AuditUserDelete   = "user.delete"
+	AuditProfileEdit  = "user.profile_update"
+	AuditMemberAdd    = "group.member_add"
+	AuditMemberRemove = "group.member_remove"
+	AuditGroupDelete  = "group.delete"
+)
+
+// Результаты действия
+const (
+	AuditSuccess = "success"
+	AuditDenied  = "denied" // нет доступа или неверные учетные данные
+	AuditFailure = "failure"
+)
+
+// Типы объектов действия
+const (
+	AuditTargetDocument = "document"
+	AuditTargetUser     = "user"
+	AuditTargetGroup    = "group"
+)
+
+type AuditEntry struct {
+	ID         int64      `db:"id"          json:"id"`
+	Created    time.Time  `db:"created_at"  json:"created"`
+	ActorID    *uuid.UUID `db:"actor_id"    json:"actor_id,omitempty"`
+	ActorLogin string     `db:"actor_login" json:"actor,omitempty"`
+	Action     string     `db:"action"      json:"action"`
+	TargetType string     `db:"target_type" json:"target_type,omitempty"`
+	TargetID   string     `db:"target_id"   json:"target_id,omitempty"`
+	TargetName string     `db:"target_name" json:"target_name,omitempty"`
+	IP         string     `db:"ip"          json:"ip,omitempty"`
+	UserAgent  string     `db:"user_agent"  json:"user_agent,omitempty"`
+	Outcome    string     `db:"outcome"     json:"outcome"`
+	Details    JSONB      `db:"details"     json:"details,omitempty"`
+}
+
+// AuditFilter - условия выборки журнала; пустые поля не ограничивают выборку.
+type AuditFilter struct {
+	Actor      string
+	Action     string
+	TargetType string
+	TargetID   string
+	Outcome    string
+	From       time.Time
+	To         time.Time
+	BeforeID   int64 // следующая страница: записи старше указанной
+	Limit      int
+}
@@ -10,9 +10,10 @@ import (
 )
 
 type LimitedDocsListInput struct {
-	Token string `json:"token"`
+	Token  string    `json:"token"`
+	UserID uuid.UUID `json:"-"` //список ограничен документами, доступными пользователю
 	//	Login string `json:"login"` //опционально — если не указан — то список своих
-	Key   string `json:"key"`   //имя колонки для фильтрации
+	Key   string `json:"key"`   //имя колонки для фильтрации или metadata.<ключ>
 	Value string `json:"value"` //- значение фильтра
 	Limit int    `json:"limit"` //кол-во документов в списке
 }
@@ -39,16 +40,36 @@ func (j JSONB) Value() (driver.Value, error) {
 }
 
 type Document struct {
-	ID       uuid.UUID `db:"id"          json:"id"`
-	UserID   uuid.UUID `db:"user_id"    json:"-"`
-	Name     string    `db:"filename"    json:"name"`
-	Path     string    `db:"path"        json:"-"`
-	Mime     string    `db:"mime"        json:"mime"`
-	File     bool      `db:"has_file"    json:"file"`
-	Public   bool      `db:"is_public"   json:"public"`
-	Created  time.Time `db:"created_at"  json:"created"`
-	Grant    []string  `db:"grant"       json:"grant,omitempty"`
-	JSONData JSONB     `db:"json_data"   json:"json,omitempty"`
+	ID         uuid.UUID `db:"id"          json:"id"`
+	UserID     uuid.UUID `db:"user_id"    json:"-"`
+	Name       string    `db:"filename"    json:"name"`
+	Path       string    `db:"path"        json:"-"`
+	Mime       string    `db:"mime"        json:"mime"`
+	File       bool      `db:"has_file"    json:"file"`
+	Public     bool      `db:"is_public"   json:"public"`
+	Created    time.Time `db:"created_at"  json:"created"`
+	Updated    time.Time `db:"updated_at"  json:"updated"`
+	Checksum   string    `db:"sha256"      json:"sha256,omitempty"`
+	Size       int64     `db:"size"        json:"size,omitempty"`
+	KeyID      string    `db:"enc_key_id"  json:"-"`
+	WrappedKey []byte    `db:"enc_data_key" json:"-"`
+	Encoding   string    `db:"content_encoding" json:"-"`
+	ScanStatus string    `db:"scan_status" json:"scan_status,omitempty"`
+	Grant      []string  `db:"grant"       json:"grant,omitempty"`
+	Groups     []string  `db:"groups"      json:"grant_groups,omitempty"`
+	JSONData   JSONB     `db:"json_data"   json:"json,omitempty"`
+	Metadata   JSONB     `db:"extracted_metadata" json:"metadata,omitempty"` // извлечены из файла
+
+	// OriginalSize - размер оригинала, сохраненного до удаления метаданных
+	OriginalSize int64 `db:"original_size" json:"original_size,omitempty"`
+
+	// ExpiresAt - когда документ будет удален; RetentionLocked - до этого срока его нельзя удалить
+	ExpiresAt       *time.Time `db:"expires_at"       json:"expires_at,omitempty"`
+	RetentionLocked bool       `db:"retention_locked" json:"retention_locked,omitempty"`
+	LegalHold       bool       `db:"legal_hold"       json:"legal_hold,omitempty"`
+
+	// Lock - действующая блокировка на время редактирования
+	Lock *DocumentLock `db:"-" json:"lock,omitempty"`
 }
 
 // CREATE TABLE DOCUMENTS (
@@ -63,6 +84,21 @@ type Document struct {
 // 	ADD COLUMN HAS_FILE    BOOLEAN NOT NULL,
 // 	ADD COLUMN IS_PUBLIC   BOOLEAN NOT NULL;
 
+// ModTime - время последнего изменения для Last-Modified и условных запросов.
+func (d *Document) ModTime() time.Time {
+	if !d.Updated.IsZero() {
+		return d.Updated
+	}
+	return d.Created
+}
+
+// PendingUpload - загрузка, файл которой мог быть записан, а документ еще не создан.
+type PendingUpload struct {
+	DocID   uuid.UUID `db:"doc_id"`
+	Key     string    `db:"blob_key"`
+	Created time.Time `db:"created_at"`
+}
+
 type DocsData struct {
 	Docs []Document `json:"docs"`
 }
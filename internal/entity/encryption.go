@@ -0,0 +1,15 @@
+package entity
+
+type EncryptionStatus struct {
+	Enabled   bool   `json:"enabled"`
+	ActiveKey string `json:"active_key,omitempty"`
+	// Documents - число файлов по мастер-ключу, которым обернут их ключ данных
+	Documents map[string]int64 `json:"documents"`
+	Plaintext int64            `json:"plaintext"`
+}
+
+type KeyRotationResult struct {
+	ActiveKey string `json:"active_key"`
+	Rewrapped int64  `json:"rewrapped"`
+	Failed    int64  `json:"failed"`
+}
@@ -0,0 +1,65 @@
+package entity
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Типы событий документов
+const (
+	EventCreated = "created"
+	EventUpdated = "updated" // смена владельца, данных или доступа, результат антивирусной проверки
+	EventDeleted = "deleted"
+	EventShared  = "shared" // пользователь получил доступ через группу
+)
+
+// DocEvent - запись журнала изменений документов.
+type DocEvent struct {
+	ID        int64     `db:"id"         json:"id"`
+	TxID      uint64    `db:"txid"       json:"-"`
+	Type      string    `db:"event_type" json:"type"`
+	DocID     uuid.UUID `db:"doc_id"     json:"doc_id"`
+	Name      string    `db:"filename"   json:"name"`
+	Mime      string    `db:"mime"       json:"mime"`
+	Public    bool      `db:"is_public"  json:"public"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// EventCursor - позиция в потоке событий: транзакция, записавшая событие, и его id.
+// События отдаются по порядку (TxID, ID), а не по id: id выдается при вставке, и событие
+// с меньшим id может стать видимым позже события с большим.
+type EventCursor struct {
+	TxID uint64 `db:"txid"`
+	ID   int64  `db:"id"`
+}
+
+// Cursor - позиция сразу после события e.
+func (e *DocEvent) Cursor() EventCursor {
+	return EventCursor{TxID: e.TxID, ID: e.ID}
+}
+
+// String - значение поля id события SSE: "<txid>-<id>".
+func (c EventCursor) String() string {
+	return fmt.Sprintf("%d-%d", c.TxID, c.ID)
+}
+
+// ParseEventCursor разбирает значение, полученное от EventCursor.String.
+func ParseEventCursor(s string) (EventCursor, bool) {
+	tx, id, ok := strings.Cut(s, "-")
+	if !ok {
+		return EventCursor{}, false
+	}
+	var c EventCursor
+	var err error
+	if c.TxID, err = strconv.ParseUint(tx, 10, 64); err != nil {
+		return EventCursor{}, false
+	}
+	if c.ID, err = strconv.ParseInt(id, 10, 64); err != nil || c.ID < 0 {
+		return EventCursor{}, false
+	}
+	return c, true
+}
@@ -0,0 +1,28 @@
+package entity
+
+import "testing"
+
+func TestParseEventCursor(t *testing.T) {
+	tests := []struct {
+		in   string
+		want EventCursor
+		ok   bool
+	}{
+		{"812-43", EventCursor{TxID: 812, ID: 43}, true},
+		{"0-0", EventCursor{}, true},
+		{"18446744073709551615-1", EventCursor{TxID: 1<<64 - 1, ID: 1}, true},
+		{"43", EventCursor{}, false},
+		{"a-1", EventCursor{}, false},
+		{"1--1", EventCursor{}, false},
+		{"", EventCursor{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseEventCursor(tt.in)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("ParseEventCursor(%q) = %v, %t; want %v, %t", tt.in, got, ok, tt.want, tt.ok)
+		}
+		if ok && got.String() != tt.in {
+			t.Errorf("String() = %q, want %q", got.String(), tt.in)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Group struct {
+	ID      uuid.UUID `db:"id"          json:"id"`
+	Name    string    `db:"name"        json:"name"`
+	IsOwner bool      `db:"is_owner"    json:"is_owner"`
+	Created time.Time `db:"created_at"  json:"created"`
+}
+
+type GroupMember struct {
+	Login   string `db:"login"     json:"login"`
+	IsOwner bool   `db:"is_owner"  json:"is_owner"`
+}
+
+type GroupDetails struct {
+	Group
+	Members []GroupMember `json:"members"`
+}
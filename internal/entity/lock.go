@@ -0,0 +1,17 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DocumentLock - блокировка документа на время редактирования. Пока она действует,
+// изменять и удалять документ может только ее владелец.
+type DocumentLock struct {
+	DocID   uuid.UUID `db:"doc_id"     json:"doc_id"`
+	UserID  uuid.UUID `db:"user_id"    json:"-"`
+	Owner   string    `db:"login"      json:"owner"`
+	Created time.Time `db:"created_at" json:"created"`
+	Expires time.Time `db:"expires_at" json:"expires"`
+}
@@ -0,0 +1,56 @@
+package entity
+
+import "time"
+
+// Quota - ограничения пользователя; 0 - без ограничения.
+type Quota struct {
+	MaxBytes    int64 `json:"max_bytes"`
+	MaxDocs     int64 `json:"max_docs"`
+	MaxFileSize int64 `json:"max_file_size"`
+}
+
+// UserQuota - индивидуальная квота из users; nil - действует квота по умолчанию.
+type UserQuota struct {
+	MaxBytes    *int64 `db:"quota_bytes"`
+	MaxDocs     *int64 `db:"quota_docs"`
+	MaxFileSize *int64 `db:"max_file_size"`
+}
+
+type Usage struct {
+	BytesUsed int64     `db:"bytes_used" json:"bytes_used"`
+	DocsCount int64     `db:"docs_count" json:"docs_count"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+}
+
+type UsageResponse struct {
+	Usage
+	Quota Quota `json:"quota"`
+	// CustomQuota - у пользователя индивидуальная квота
+	CustomQuota bool `json:"custom_quota"`
+}
+
+// SetQuotaRequest - null или отсутствующее поле возвращает значение по умолчанию.
+type SetQuotaRequest struct {
+	MaxBytes    *int64 `json:"max_bytes"     binding:"omitempty,min=0"`
+	MaxDocs     *int64 `json:"max_docs"      binding:"omitempty,min=0"`
+	MaxFileSize *int64 `json:"max_file_size" binding:"omitempty,min=0"`
+}
+
+type RecomputeUsageResult struct {
+	SizesFilled int64 `json:"sizes_filled"`
+	Users       int64 `json:"users"`
+}
+
+// MimePolicy - шаблоны разрешенных и запрещенных типов файлов ("image/*", "*+xml",
+// точный тип); пустой Allow разрешает все типы, кроме Deny.
+type MimePolicy struct {
+	Allow []string `json:"allow"`
+	Deny  []string `json:"deny"`
+}
+
+// ScrubPolicy - удаление метаданных из изображений: режим keep, gps или all и
+// сохранение оригинала. nil - значение по умолчанию.
+type ScrubPolicy struct {
+	Mode         *string `json:"mode"`
+	KeepOriginal *bool   `json:"keep_original"`
+}
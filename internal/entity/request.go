@@ -46,3 +46,63 @@ func ValidatePasswordComplexity(fl validator.FieldLevel) bool {
 	logrus.Info("hasUpper=", hasUpper, " hasLower=", hasLower, " hasDigit=", hasDigit, " hasSpecial=", hasSpecial)
 	return hasUpper && hasLower && hasDigit && hasSpecial
 }
+
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,password_complexity,min=8"`
+}
+
+type PasswordResetRequest struct {
+	Name string `json:"name" binding:"required,min=2,max=100"`
+}
+
+type PasswordResetConfirmRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,password_complexity,min=8"`
+}
+
+// UpdateProfileRequest - PATCH: меняются только переданные поля.
+type UpdateProfileRequest struct {
+	DisplayName *string `json:"display_name" binding:"omitempty,max=100"`
+	Email       *string `json:"email" binding:"omitempty,email,max=254"`
+	Preferences JSONB   `json:"preferences"`
+}
+
+const (
+	DeleteModeDelete   = "delete"
+	DeleteModeTransfer = "transfer"
+)
+
+type DeleteAccountRequest struct {
+	Password   string `json:"password" binding:"required"`
+	Mode       string `json:"mode" binding:"required,oneof=delete transfer"`
+	TransferTo string `json:"transfer_to" binding:"required_if=Mode transfer"`
+}
+
+type CreateGroupRequest struct {
+	Name string `json:"name" binding:"required,min=2,max=100"`
+}
+
+type AddGroupMemberRequest struct {
+	Login string `json:"login" binding:"required"`
+	Owner bool   `json:"owner"`
+}
+
+// UpdateDocRequest - непереданные поля не меняются; grant и grant_groups заменяют
+// список доступа целиком.
+type UpdateDocRequest struct {
+	JSON   JSONB     `json:"json"`
+	Public *bool     `json:"public"`
+	Grant  *[]string `json:"grant"`
+	Groups *[]string `json:"grant_groups"`
+}
+
+type TransferDocRequest struct {
+	To            string `json:"to" binding:"required"`
+	RequireAccept bool   `json:"require_accept"`
+}
+
+type BulkTransferRequest struct {
+	From string `json:"from" binding:"required"`
+	To   string `json:"to" binding:"required"`
+}
@@ -1,14 +1,25 @@
 package entity
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
 
 type UploadMeta struct {
-	Name   string   `json:"name"`
-	File   bool     `json:"file"`
-	Public bool     `json:"public"`
-	Token  string   `json:"token"`
-	Mime   string   `json:"mime"`
-	Grant  []string `json:"grant"`
+	Name        string   `json:"name"`
+	File        bool     `json:"file"`
+	Public      bool     `json:"public"`
+	Token       string   `json:"token"`
+	Mime        string   `json:"mime"`
+	Grant       []string `json:"grant"`
+	GrantGroups []string `json:"grant_groups"`
+	// Scrub и KeepOriginal задают удаление метаданных для этого файла; режим
+	// не может быть мягче режима пользователя
+	Scrub        string `json:"scrub"`
+	KeepOriginal *bool  `json:"keep_original"`
+	// ExpiresAt - срок хранения; политика хранения с запретом удаления его переопределяет
+	ExpiresAt *time.Time `json:"expires_at"`
 }
 
 type DelResponse map[uuid.UUID]bool
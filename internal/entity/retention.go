@@ -0,0 +1,37 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RetentionPolicy задает срок хранения новых документов подходящего типа. Locked - документ
+// нельзя удалить раньше срока, в том числе владельцу; по истечении срока он удаляется.
+type RetentionPolicy struct {
+	ID      uuid.UUID `db:"id"         json:"id"`
+	Name    string    `db:"name"       json:"name"   binding:"required,max=200"`
+	Mime    string    `db:"mime"       json:"mime"   binding:"required"` // тип или шаблон: image/*, *
+	Years   int       `db:"years"      json:"years"  binding:"min=0,max=100"`
+	Days    int       `db:"days"       json:"days"   binding:"min=0,max=36500"`
+	Locked  bool      `db:"locked"     json:"locked"`
+	Created time.Time `db:"created_at" json:"created"`
+}
+
+// ExpiresAt - срок хранения документа, созданного в момент t.
+func (p *RetentionPolicy) ExpiresAt(t time.Time) time.Time {
+	return t.AddDate(p.Years, 0, p.Days)
+}
+
+// LegalHold запрещает удаление документа, пока блокировку не снимут.
+type LegalHold struct {
+	ID        uuid.UUID `db:"id"         json:"id"`
+	DocID     uuid.UUID `db:"doc_id"     json:"doc_id"`
+	Reason    string    `db:"reason"     json:"reason"`
+	CreatedBy string    `db:"created_by" json:"created_by"`
+	Created   time.Time `db:"created_at" json:"created"`
+}
+
+type CreateLegalHoldRequest struct {
+	Reason string `json:"reason" binding:"required,max=1000"`
+}
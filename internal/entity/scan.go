@@ -0,0 +1,29 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Статусы антивирусной проверки документа
+const (
+	ScanPending  = "pending"
+	ScanClean    = "clean"
+	ScanInfected = "infected"
+	ScanError    = "error"
+)
+
+// ScanResult - результат проверки документа для администратора.
+type ScanResult struct {
+	DocID     uuid.UUID  `db:"id"             json:"doc_id"`
+	Status    string     `db:"scan_status"    json:"status"`
+	Signature string     `db:"scan_signature" json:"signature,omitempty"`
+	ScannedAt *time.Time `db:"scanned_at"     json:"scanned_at,omitempty"`
+}
+
+type RescanRequest struct {
+	// Statuses - какие документы проверить заново: clean, infected, error или unscanned
+	// (загруженные до появления проверки); по умолчанию только с ошибкой проверки
+	Statuses []string `json:"statuses" binding:"omitempty,dive,oneof=clean infected error unscanned"`
+}
@@ -0,0 +1,65 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ScrubIssue - проблема, найденная при проверке хранилища.
+type ScrubIssue struct {
+	DocID    uuid.UUID `json:"doc_id,omitempty"`
+	Name     string    `json:"name,omitempty"`
+	Key      string    `json:"key,omitempty"`
+	Expected string    `json:"expected,omitempty"`
+	Actual   string    `json:"actual,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	Repaired bool      `json:"repaired,omitempty"`
+}
+
+// ScrubReport - результат одного прохода проверки целостности.
+type ScrubReport struct {
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Checked    int       `json:"checked"`
+	// Backfilled - документы без сохраненной суммы, для которых она посчитана в этом проходе
+	Backfilled int          `json:"backfilled"`
+	Corrupted  []ScrubIssue `json:"corrupted"`
+	Missing    []ScrubIssue `json:"missing"`
+	Orphaned   []ScrubIssue `json:"orphaned"`
+	Errors     []ScrubIssue `json:"errors"`
+	Aborted    bool         `json:"aborted,omitempty"`
+}
+
+type ScrubStatus struct {
+	Running bool         `json:"running"`
+	Last    *ScrubReport `json:"last,omitempty"`
+}
+
+// ReconcileOptions - что исправляет сверка. Repair удаляет лишние файлы и зависшие
+// загрузки; записи документов без файла удаляются, только если задан еще и DeleteMissing.
+type ReconcileOptions struct {
+	Repair        bool `json:"repair"`
+	DeleteMissing bool `json:"delete_missing"`
+}
+
+// ReconcileReport - расхождения между documents и хранилищем.
+type ReconcileReport struct {
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	ReconcileOptions
+	// MissingSkipped - почему записи документов без файла не удалены, хотя это запрошено
+	MissingSkipped string `json:"missing_skipped,omitempty"`
+	// OrphanedFiles - файлы без записи в documents
+	OrphanedFiles []ScrubIssue `json:"orphaned_files"`
+	// MissingFiles - документы, файл которых отсутствует
+	MissingFiles []ScrubIssue `json:"missing_files"`
+	// StaleUploads - загрузки, не завершившиеся за отведенное время
+	StaleUploads []ScrubIssue `json:"stale_uploads"`
+	Errors       []ScrubIssue `json:"errors"`
+}
+
+func (r *ReconcileReport) Clean() bool {
+	return len(r.OrphanedFiles) == 0 && len(r.MissingFiles) == 0 &&
+		len(r.StaleUploads) == 0 && len(r.Errors) == 0
+}
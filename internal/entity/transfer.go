@@ -0,0 +1,29 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	TransferPending   = "pending"
+	TransferCompleted = "completed"
+	TransferDeclined  = "declined"
+	TransferCancelled = "cancelled"
+)
+
+// DocumentTransfer - запись журнала передачи владения документом.
+type DocumentTransfer struct {
+	ID          uuid.UUID  `db:"id"            json:"id"`
+	DocID       uuid.UUID  `db:"doc_id"        json:"doc_id"`
+	DocName     string     `db:"filename"      json:"name"`
+	FromUserID  *uuid.UUID `db:"from_user_id"  json:"-"`
+	ToUserID    *uuid.UUID `db:"to_user_id"    json:"-"`
+	FromLogin   string     `db:"from_login"    json:"from"`
+	ToLogin     string     `db:"to_login"      json:"to"`
+	InitiatedBy string     `db:"initiated_by"  json:"initiated_by"`
+	Status      string     `db:"status"        json:"status"`
+	Created     time.Time  `db:"created_at"    json:"created"`
+	Resolved    *time.Time `db:"resolved_at"   json:"resolved,omitempty"`
+}
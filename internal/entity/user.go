@@ -1,10 +1,36 @@
 package entity
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
 
 type User struct {
 	ID       uuid.UUID `json:"-" db:"id"`
 	Login    string    `json:"login" db:"login" binding:"required"`
 	Password string    `json:"password" db:"password" binding:"required"`
 	Token    string    `json:"token" db:"token" binding:"required"`
+	Disabled bool      `json:"disabled" db:"disabled"`
+	IsAdmin  bool      `json:"is_admin" db:"is_admin"`
+}
+
+type RevokedToken struct {
+	JTI       uuid.UUID `db:"jti"`
+	UserID    uuid.UUID `db:"user_id"`
+	ExpiresAt time.Time `db:"expires_at"`
+}
+
+// UserTokenCutoff - все токены пользователя, выпущенные раньше ValidAfter, недействительны.
+type UserTokenCutoff struct {
+	UserID     uuid.UUID `db:"user_id"`
+	ValidAfter time.Time `db:"valid_after"`
+}
+
+type UserProfile struct {
+	Login       string    `json:"login"        db:"login"`
+	DisplayName string    `json:"display_name" db:"display_name"`
+	Email       string    `json:"email"        db:"email"`
+	Preferences JSONB     `json:"preferences"  db:"preferences"`
+	Created     time.Time `json:"created"      db:"created_at"`
 }
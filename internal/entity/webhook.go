@@ -0,0 +1,73 @@
+package entity
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Статусы доставки webhook
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead" // попытки исчерпаны, доставить можно только вручную
+)
+
+type Webhook struct {
+	ID     uuid.UUID  `json:"id"`
+	UserID *uuid.UUID `json:"-"`
+	URL    string     `json:"url"`
+	// Secret - ключ подписи HMAC, отдается только при создании
+	Secret  string    `json:"secret,omitempty"`
+	Events  []string  `json:"events"`
+	Created time.Time `json:"created"`
+}
+
+type CreateWebhookRequest struct {
+	URL string `json:"url" binding:"required,url,max=2048"`
+	// Events - типы событий; пустой список - все
+	Events []string `json:"events" binding:"omitempty,dive,oneof=created updated deleted shared"`
+	// Secret - ключ подписи; если не задан, создается случайный
+	Secret string `json:"secret" binding:"omitempty,min=16,max=256"`
+}
+
+// RawJSON - JSON, который хранится и отправляется как есть, без повторной сериализации.
+type RawJSON []byte
+
+func (j *RawJSON) Scan(value interface{}) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		return fmt.Errorf("failed to scan JSON: %v", value)
+	}
+	*j = append((*j)[:0], bytes...)
+	return nil
+}
+
+func (j RawJSON) MarshalJSON() ([]byte, error) {
+	if len(j) == 0 {
+		return []byte("null"), nil
+	}
+	return j, nil
+}
+
+// WebhookDelivery - доставка одного события на webhook.
+type WebhookDelivery struct {
+	ID             int64      `db:"id"               json:"id"`
+	WebhookID      uuid.UUID  `db:"webhook_id"       json:"webhook_id"`
+	EventID        int64      `db:"event_id"         json:"event_id"`
+	EventType      string     `db:"event_type"       json:"event_type"`
+	Payload        RawJSON    `db:"payload"          json:"payload"`
+	Status         string     `db:"status"           json:"status"`
+	Attempts       int        `db:"attempts"         json:"attempts"`
+	NextAttemptAt  time.Time  `db:"next_attempt_at"  json:"next_attempt_at"`
+	LastStatusCode *int       `db:"last_status_code" json:"last_status_code,omitempty"`
+	LastError      *string    `db:"last_error"       json:"last_error,omitempty"`
+	Created        time.Time  `db:"created_at"       json:"created"`
+	DeliveredAt    *time.Time `db:"delivered_at"     json:"delivered_at,omitempty"`
+
+	// Для отправки: адрес и ключ webhook
+	URL    string     `db:"url"     json:"-"`
+	Secret string     `db:"secret"  json:"-"`
+	UserID *uuid.UUID `db:"user_id" json:"-"`
+}
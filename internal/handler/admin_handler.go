@@ -0,0 +1,298 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/olenka-91/DocsServer/internal/entity"
+	"github.com/olenka-91/DocsServer/internal/service"
+)
+
+func (h *Handler) disableUser(ctx *gin.Context) {
+	err := h.services.Authorization.DisableUser(ctx, ctx.Param("login"))
+	h.respondUserStatus(ctx, err, "User disabled successfully")
+}
+
+func (h *Handler) enableUser(ctx *gin.Context) {
+	err := h.services.Authorization.EnableUser(ctx, ctx.Param("login"))
+	h.respondUserStatus(ctx, err, "User enabled successfully")
+}
+
+func (h *Handler) getCacheStats(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, entity.SuccessResponse{
+		Message: "Cache stats fetched successfully",
+		Data:    h.services.Docs.CacheStats(),
+	})
+}
+
+func (h *Handler) startScrub(ctx *gin.Context) {
+	if err := h.services.Scrub.Start(); err != nil {
+		ctx.JSON(http.StatusConflict, entity.ErrorResponse{
+			Message: "Scrub is already running",
+			Error:   err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusAccepted, entity.SuccessResponse{
+		Message: "Scrub started",
+	})
+}
+
+func (h *Handler) getScrubStatus(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, entity.SuccessResponse{
+		Message: "Scrub status fetched successfully",
+		Data:    h.services.Scrub.Status(),
+	})
+}
+
+func (h *Handler) reconcileStorage(ctx *gin.Context) {
+	opts := entity.ReconcileOptions{
+		Repair:        ctx.Query("repair") == "true",
+		DeleteMissing: ctx.Query("delete_missing") == "true",
+	}
+	report, err := h.services.Reconciliation.Reconcile(ctx, opts)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, entity.ErrorResponse{
+			Message: "Internal Server Error",
+			Error:   err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, entity.SuccessResponse{
+		Message: "Reconciliation finished",
+		Data:    report,
+	})
+}
+
+func (h *Handler) getEncryptionStatus(ctx *gin.Context) {
+	status, err := h.services.Encryption.Status()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, entity.ErrorResponse{
+			Message: "Internal Server Error",
+			Error:   err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, entity.SuccessResponse{
+		Message: "Encryption status fetched successfully",
+		Data:    status,
+	})
+}
+
+func (h *Handler) rotateKeys(ctx *gin.Context) {
+	result, err := h.services.Encryption.RotateKeys()
+	switch err {
+	case nil:
+		ctx.JSON(http.StatusOK, entity.SuccessResponse{
+			Message: "Data keys rewrapped",
+			Data:    result,
+		})
+	case service.ErrEncryptionDisabled:
+		ctx.JSON(http.StatusConflict, entity.ErrorResponse{
+			Message: "Conflict",
+			Error:   err.Error(),
+		})
+	default:
+		ctx.JSON(http.StatusInternalServerError, entity.ErrorResponse{
+			Message: "Internal Server Error",
+			Error:   err.Error(),
+		})
+	}
+}
+
+func (h *Handler) setUserQuota(ctx *gin.Context) {
+	var req entity.SetQuotaRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, entity.ErrorResponse{
+			Message: "Invalid request",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	usage, err := h.services.Quotas.SetQuota(ctx.Param("login"), req)
+	if err != nil {
+		h.respondUserStatus(ctx, err, "")
+		return
+	}
+	ctx.JSON(http.StatusOK, entity.SuccessResponse{
+		Message: "Quota updated successfully",
+		Data:    usage,
+	})
+}
+
+func (h *Handler) setUserMimePolicy(ctx *gin.Context) {
+	var req entity.MimePolicy
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, entity.ErrorResponse{
+			Message: "Invalid request",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	policy, err := h.services.MimePolicies.SetMimePolicy(ctx.Param("login"), req)
+	switch err {
+	case nil:
+		ctx.JSON(http.StatusOK, entity.SuccessResponse{
+			Message: "MIME policy updated successfully",
+			Data:    policy,
+		})
+	case service.ErrBadRequest:
+		ctx.JSON(http.StatusBadRequest, entity.ErrorResponse{
+			Message: "Invalid MIME type pattern",
+			Error:   err.Error(),
+		})
+	default:
+		h.respondUserStatus(ctx, err, "")
+	}
+}
+
+func (h *Handler) setUserScrubPolicy(ctx *gin.Context) {
+	var req entity.ScrubPolicy
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, entity.ErrorResponse{
+			Message: "Invalid request",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	policy, err := h.services.ScrubPolicies.SetScrubPolicy(ctx.Param("login"), req)
+	switch err {
+	case nil:
+		ctx.JSON(http.StatusOK, entity.SuccessResponse{
+			Message: "Scrub policy updated successfully",
+			Data:    policy,
+		})
+	case service.ErrBadRequest:
+		ctx.JSON(http.StatusBadRequest, entity.ErrorResponse{
+			Message: "Invalid scrub mode",
+			Error:   "mode must be keep, gps or all",
+		})
+	default:
+		h.respondUserStatus(ctx, err, "")
+	}
+}
+
+func (h *Handler) recomputeUsage(ctx *gin.Context) {
+	result, err := h.services.Quotas.RecomputeUsage(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, entity.ErrorResponse{
+			Message: "Internal Server Error",
+			Error:   err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, entity.SuccessResponse{
+		Message: "Usage recomputed",
+		Data:    result,
+	})
+}
+
+func (h *Handler) getScanResult(ctx *gin.Context) {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, entity.ErrorResponse{
+			Message: "Invalid document id",
+			Error:   err.Error(),
+		})
+		return
+	}
+	result, err := h.services.Scans.GetScanResult(id)
+	h.respondScanResult(ctx, result, err, "Scan result fetched successfully")
+}
+
+func (h *Handler) rescanDoc(ctx *gin.Context) {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, entity.ErrorResponse{
+			Message: "Invalid document id",
+			Error:   err.Error(),
+		})
+		return
+	}
+	result, err := h.services.Scans.Rescan(id)
+	h.respondScanResult(ctx, result, err, "Document queued for scan")
+}
+
+func (h *Handler) respondScanResult(ctx *gin.Context, result *entity.ScanResult, err error, message string) {
+	switch err {
+	case nil:
+		ctx.JSON(http.StatusOK, entity.SuccessResponse{
+			Message: message,
+			Data:    result,
+		})
+	case service.ErrNotFound:
+		ctx.JSON(http.StatusNotFound, entity.ErrorResponse{
+			Message: "Not found",
+			Error:   err.Error(),
+		})
+	default:
+		ctx.JSON(http.StatusInternalServerError, entity.ErrorResponse{
+			Message: "Internal Server Error",
+			Error:   err.Error(),
+		})
+	}
+}
+
+func (h *Handler) rescanDocs(ctx *gin.Context) {
+	var req entity.RescanRequest
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, entity.ErrorResponse{
+				Message: "Invalid request",
+				Error:   err.Error(),
+			})
+			return
+		}
+	}
+
+	n, err := h.services.Scans.RescanAll(req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, entity.ErrorResponse{
+			Message: "Internal Server Error",
+			Error:   err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusAccepted, entity.SuccessResponse{
+		Message: "Documents queued for scan",
+		Data:    map[string]int64{"queued": n},
+	})
+}
+
+func (h *Handler) respondUserStatus(ctx *gin.Context, err error, message string) {
+	switch err {
+	case nil:
+		ctx.JSON(http.StatusOK, entity.SuccessResponse{
+			Message: message,
+		})
+	case service.ErrUserNotFound:
+		ctx.JSON(http.StatusNotFound, entity.ErrorResponse{
+			Message: "Not found",
+			Error:   err.Error(),
+		})
+	default:
+		ctx.JSON(http.StatusInternalServerError, entity.ErrorResponse{
+			Message: "Internal Server Error",
+			Error:   err.Error(),
+		})
+	}
+}
+
+func (h *Handler) extractMetadata(ctx *gin.Context) {
+	n, err := h.services.Metadata.ExtractMissing(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, entity.ErrorResponse{
+			Message: "Internal Server Error",
+			Error:   err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, entity.SuccessResponse{
+		Message: "Metadata extracted",
+		Data:    map[string]int64{"processed": n},
+	})
+}
@@ -0,0 +1,154 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/olenka-91/DocsServer/internal/entity"
+	"github.com/olenka-91/DocsServer/internal/service"
+	"github.com/sirupsen/logrus"
+)
+
+// parsePage читает параметры страницы журнала before_id и limit.
+func parsePage(ctx *gin.Context) (beforeID int64, limit int, ok bool) {
+	var err error
+	if v := ctx.Query("before_id"); v != "" {
+		if beforeID, err = strconv.ParseInt(v, 10, 64); err != nil || beforeID < 0 {
+			return 0, 0, false
+		}
+	}
+	if v := ctx.Query("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 0 {
+			return 0, 0, false
+		}
+	}
+	return beforeID, limit, true
+}
+
+// parseAuditFilter читает фильтр журнала из параметров запроса; время - в RFC 3339.
+func parseAuditFilter(ctx *gin.Context) (entity.AuditFilter, bool) {
+	filter := entity.AuditFilter{
+		Actor:      ctx.Query("actor"),
+		Action:     ctx.Query("action"),
+		TargetType: ctx.Query("target_type"),
+		TargetID:   ctx.Query("target_id"),
+		Outcome:    ctx.Query("outcome"),
+	}
+	switch filter.Outcome {
+	case "", entity.AuditSuccess, entity.AuditDenied, entity.AuditFailure:
+	default:
+		return filter, false
+	}
+
+	var err error
+	if v := ctx.Query("from"); v != "" {
+		if filter.From, err = time.Parse(time.RFC3339, v); err != nil {
+			return filter, false
+		}
+	}
+	if v := ctx.Query("to"); v != "" {
+		if filter.To, err = time.Parse(time.RFC3339, v); err != nil {
+			return filter, false
+		}
+	}
+
+	var ok bool
+	filter.BeforeID, filter.Limit, ok = parsePage(ctx)
+	return filter, ok
+}
+
+func (h *Handler) getAuditLog(ctx *gin.Context) {
+	filter, ok := parseAuditFilter(ctx)
+	if !ok {
+		ctx.JSON(http.StatusBadRequest, entity.ErrorResponse{
+			Message: "Invalid audit filter",
+		})
+		return
+	}
+
+	entries, err := h.services.Audit.GetAuditLog(filter)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, entity.ErrorResponse{
+			Message: "Internal Server Error",
+			Error:   err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, entity.SuccessResponse{
+		Message: "Audit log fetched successfully",
+		Data:    entries,
+	})
+}
+
+func (h *Handler) exportAuditLog(ctx *gin.Context) {
+	filter, ok := parseAuditFilter(ctx)
+	if !ok {
+		ctx.JSON(http.StatusBadRequest, entity.ErrorResponse{
+			Message: "Invalid audit filter",
+		})
+		return
+	}
+
+	err := h.services.Audit.ExportAuditLog(ctx, filter, ctx.DefaultQuery("format", "jsonl"))
+	switch err {
+	case nil:
+	case service.ErrBadRequest:
+		ctx.JSON(http.StatusBadRequest, entity.ErrorResponse{
+			Message: "Invalid export format",
+			Error:   "format must be csv or jsonl",
+		})
+	default:
+		ctx.JSON(http.StatusInternalServerError, entity.ErrorResponse{
+			Message: "Internal Server Error",
+			Error:   err.Error(),
+		})
+	}
+}
+
+func (h *Handler) getDocHistory(ctx *gin.Context) {
+	logrus.Debug("Entering getDocHistory handler")
+
+	login, exists := ctx.Get("login")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, entity.ErrorResponse{
+			Message: "Unauthorized",
+		})
+		return
+	}
+	id, _ := uuid.Parse(ctx.Param("id"))
+	beforeID, limit, ok := parsePage(ctx)
+	if !ok {
+		ctx.JSON(http.StatusBadRequest, entity.ErrorResponse{
+			Message: "Bad Request",
+			Error:   "before_id and limit must be non-negative integers",
+		})
+		return
+	}
+
+	entries, err := h.services.GetDocHistory(ctx, id, login.(string), beforeID, limit)
+	switch err {
+	case nil:
+		ctx.JSON(http.StatusOK, entity.SuccessResponse{
+			Message: "Document history fetched successfully",
+			Data:    entries,
+		})
+	case service.ErrNotFound:
+		ctx.JSON(http.StatusNotFound, entity.ErrorResponse{
+			Message: "Not found",
+			Error:   err.Error(),
+		})
+	case service.ErrForbidden:
+		ctx.JSON(http.StatusForbidden, entity.ErrorResponse{
+			Message: "Acess Forbidden",
+			Error:   err.Error(),
+		})
+	default:
+		ctx.JSON(http.StatusInternalServerError, entity.ErrorResponse{
+			Message: "Internal Server Error",
+			Error:   err.Error(),
+		})
+	}
+}
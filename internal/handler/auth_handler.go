@@ -6,6 +6,8 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/olenka-91/DocsServer/internal/entity"
+	"github.com/olenka-91/DocsServer/internal/service"
+	"github.com/olenka-91/DocsServer/internal/utils"
 )
 
 func (h *Handler) signUp(c *gin.Context) {
@@ -19,7 +21,7 @@ func (h *Handler) signUp(c *gin.Context) {
 		return
 	}
 
-	tokens, err := h.services.Authorization.SignUp(req.Name, req.Password)
+	tokens, err := h.services.Authorization.SignUp(c, req.Name, req.Password)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, entity.ErrorResponse{
 			Message: "Couldnt create user",
@@ -45,7 +47,7 @@ func (h *Handler) signIn(c *gin.Context) {
 		return
 	}
 
-	tokens, err := h.services.Authorization.SignIn(req.Name, req.Password)
+	tokens, err := h.services.Authorization.SignIn(c, req.Name, req.Password)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, entity.ErrorResponse{
 			Message: "Authentication failed",
@@ -62,16 +64,16 @@ func (h *Handler) signIn(c *gin.Context) {
 }
 
 func (h *Handler) logout(c *gin.Context) {
-	userID, exists := c.Get("user_id")
+	claims, exists := c.Get("claims")
 	if !exists {
 		c.JSON(http.StatusBadRequest, entity.ErrorResponse{
 			Message: "Invalid request",
-			Error:   "Parameter userID not found",
+			Error:   "Token claims not found",
 		})
 		return
 	}
 
-	err := h.services.Authorization.Logout(userID.(uuid.UUID))
+	err := h.services.Authorization.Logout(c, claims.(*utils.JwtClaim))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, entity.ErrorResponse{
 			Message: "Failed to logout",
@@ -96,7 +98,7 @@ func (h *Handler) refreshToken(c *gin.Context) {
 		return
 	}
 
-	tokens, err := h.services.Authorization.RefreshToken(req.RefreshToken)
+	tokens, err := h.services.Authorization.RefreshToken(c, req.RefreshToken)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, entity.ErrorResponse{
 			Message: "Failed to refresh token",
@@ -111,3 +113,100 @@ func (h *Handler) refreshToken(c *gin.Context) {
 	})
 	return
 }
+
+func (h *Handler) changePassword(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusBadRequest, entity.ErrorResponse{
+			Message: "Invalid request",
+			Error:   "Parameter userID not found",
+		})
+		return
+	}
+
+	var req entity.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, entity.ErrorResponse{
+			Message: "Invalid request",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	tokens, err := h.services.Authorization.ChangePassword(c, userID.(uuid.UUID), req.OldPassword, req.NewPassword)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, entity.SuccessResponse{
+			Message: "Password changed successfully",
+			Data:    tokens,
+		})
+	case service.ErrInvalidCredentials:
+		c.JSON(http.StatusUnauthorized, entity.ErrorResponse{
+			Message: "Wrong old password",
+			Error:   err.Error(),
+		})
+	case service.ErrPasswordReused:
+		c.JSON(http.StatusBadRequest, entity.ErrorResponse{
+			Message: "Password can not be reused",
+			Error:   err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, entity.ErrorResponse{
+			Message: "Failed to change password",
+			Error:   err.Error(),
+		})
+	}
+}
+
+func (h *Handler) requestPasswordReset(c *gin.Context) {
+	var req entity.PasswordResetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, entity.ErrorResponse{
+			Message: "Invalid request",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := h.services.Authorization.RequestPasswordReset(c, req.Name); err != nil {
+		c.JSON(http.StatusInternalServerError, entity.ErrorResponse{
+			Message: "Failed to request password reset",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	// Ответ одинаковый для существующих и несуществующих логинов
+	c.JSON(http.StatusOK, entity.SuccessResponse{
+		Message: "If the user exists, reset instructions have been sent",
+	})
+}
+
+func (h *Handler) confirmPasswordReset(c *gin.Context) {
+	var req entity.PasswordResetConfirmRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, entity.ErrorResponse{
+			Message: "Invalid request",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	err := h.services.Authorization.ResetPassword(c, req.Token, req.NewPassword)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, entity.SuccessResponse{
+			Message: "Password reset successfully",
+		})
+	case service.ErrInvalidResetToken, service.ErrPasswordReused:
+		c.JSON(http.StatusBadRequest, entity.ErrorResponse{
+			Message: "Failed to reset password",
+			Error:   err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, entity.ErrorResponse{
+			Message: "Failed to reset password",
+			Error:   err.Error(),
+		})
+	}
+}
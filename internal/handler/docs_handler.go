@@ -2,15 +2,19 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
 	"mime/multipart"
 	"net/http"
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/olenka-91/DocsServer/internal/entity"
 	"github.com/olenka-91/DocsServer/internal/service"
+	"github.com/olenka-91/DocsServer/internal/thumbnail"
 	"github.com/sirupsen/logrus"
 )
 
@@ -23,6 +27,10 @@ func (h *Handler) getDocsList(ctx *gin.Context) {
 		Value: ctx.Query("value"),
 	}
 
+	if userID, exists := ctx.Get("user_id"); exists {
+		input.UserID = userID.(uuid.UUID)
+	}
+
 	limitInt, err := strconv.Atoi(ctx.Query("limit"))
 	if err != nil {
 		limitInt = 0
@@ -46,6 +54,14 @@ func (h *Handler) getDocsList(ctx *gin.Context) {
 			})
 			return
 		}
+	case service.ErrUnauthorized:
+		{
+			ctx.JSON(http.StatusUnauthorized, entity.ErrorResponse{
+				Message: "Unauthorized",
+				Error:   err.Error(),
+			})
+			return
+		}
 	case service.ErrForbidden:
 		{
 			ctx.JSON(http.StatusForbidden, entity.ErrorResponse{
@@ -125,6 +141,23 @@ func (h *Handler) getDoc(ctx *gin.Context) {
 			})
 			return
 		}
+	case service.ErrQuarantined:
+		{
+			ctx.JSON(http.StatusForbidden, entity.ErrorResponse{
+				Message: "File is quarantined",
+				Error:   err.Error(),
+			})
+			return
+		}
+	case service.ErrNotScanned:
+		{
+			ctx.Header("Retry-After", "30")
+			ctx.JSON(http.StatusConflict, entity.ErrorResponse{
+				Message: "File is being scanned",
+				Error:   err.Error(),
+			})
+			return
+		}
 	default:
 		{
 			ctx.JSON(http.StatusInternalServerError, entity.ErrorResponse{
@@ -136,13 +169,8 @@ func (h *Handler) getDoc(ctx *gin.Context) {
 	}
 }
 
-type UploadResponse struct {
-	JSON interface{} `json:"json"`
-	File string      `json:"file"`
-}
-
-func (h *Handler) postDoc(ctx *gin.Context) {
-	logrus.Debug("Entering postDoc handler")
+func (h *Handler) getThumbnail(ctx *gin.Context) {
+	logrus.Debug("Entering getThumbnail handler")
 
 	login, exists := ctx.Get("login")
 	if !exists {
@@ -151,51 +179,261 @@ func (h *Handler) postDoc(ctx *gin.Context) {
 		})
 		return
 	}
-	logrus.Debug("MultipartForm")
-
-	form, err := ctx.MultipartForm()
-	if err != nil {
+	id, _ := uuid.Parse(ctx.Param("id"))
+	size, ok := thumbnail.ParseSize(ctx.Query("size"))
+	if !ok {
 		ctx.JSON(http.StatusBadRequest, entity.ErrorResponse{
-			Message: "Failed to parse multipart form",
-			Error:   err.Error(),
+			Message: "Bad Request",
+			Error:   "size must be small, medium or large",
 		})
 		return
 	}
-	defer form.RemoveAll()
-	logrus.Debug("metaValues")
-	// Обработка meta-части
-	metaValues := form.Value["meta"]
-	if len(metaValues) == 0 {
-		ctx.JSON(http.StatusBadRequest, entity.ErrorResponse{
-			Message: "Missing meta field",
+
+	err := h.services.GetThumbnail(ctx, id, login.(string), size)
+	switch err {
+	case nil:
+	case service.ErrPreviewPending:
+		// Заглушка того же размера, клиент повторяет запрос позже
+		ctx.Header("Retry-After", "5")
+		ctx.Header("Cache-Control", "no-store")
+		ctx.Data(http.StatusAccepted, "image/png", thumbnail.Placeholder(size))
+	case service.ErrNotFound, service.ErrPreviewUnavailable:
+		ctx.JSON(http.StatusNotFound, entity.ErrorResponse{
+			Message: "Not found",
+			Error:   err.Error(),
+		})
+	case service.ErrForbidden:
+		ctx.JSON(http.StatusForbidden, entity.ErrorResponse{
+			Message: "Acess Forbidden",
+			Error:   err.Error(),
+		})
+	case service.ErrQuarantined:
+		ctx.JSON(http.StatusForbidden, entity.ErrorResponse{
+			Message: "File is quarantined",
+			Error:   err.Error(),
+		})
+	case service.ErrNotScanned:
+		ctx.JSON(http.StatusConflict, entity.ErrorResponse{
+			Message: "File has not passed malware scan",
+			Error:   err.Error(),
+		})
+	default:
+		ctx.JSON(http.StatusInternalServerError, entity.ErrorResponse{
+			Message: "Internal Server Error",
 			Error:   err.Error(),
 		})
+	}
+}
+
+func (h *Handler) getOriginal(ctx *gin.Context) {
+	logrus.Debug("Entering getOriginal handler")
+
+	login, exists := ctx.Get("login")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, entity.ErrorResponse{
+			Message: "Unauthorized",
+		})
 		return
 	}
+	id, _ := uuid.Parse(ctx.Param("id"))
 
-	logrus.Debug("Unmarshal meta handler")
-	var meta entity.UploadMeta
-	if err := json.Unmarshal([]byte(metaValues[0]), &meta); err != nil {
-		ctx.JSON(http.StatusBadRequest, entity.ErrorResponse{
-			Message: "Invalid meta format",
+	err := h.services.GetOriginal(ctx, id, login.(string))
+	switch err {
+	case nil:
+	case service.ErrNotFound:
+		ctx.JSON(http.StatusNotFound, entity.ErrorResponse{
+			Message: "Not found",
+			Error:   err.Error(),
+		})
+	case service.ErrForbidden:
+		ctx.JSON(http.StatusForbidden, entity.ErrorResponse{
+			Message: "Acess Forbidden",
+			Error:   err.Error(),
+		})
+	case service.ErrQuarantined:
+		ctx.JSON(http.StatusForbidden, entity.ErrorResponse{
+			Message: "File is quarantined",
+			Error:   err.Error(),
+		})
+	case service.ErrNotScanned:
+		ctx.JSON(http.StatusConflict, entity.ErrorResponse{
+			Message: "File has not passed malware scan",
+			Error:   err.Error(),
+		})
+	default:
+		ctx.JSON(http.StatusInternalServerError, entity.ErrorResponse{
+			Message: "Internal Server Error",
 			Error:   err.Error(),
 		})
-		return
 	}
+}
 
-	logrus.Debug("jsonData handler")
-	var jsonData entity.JSONB
-	if jsonValues, exists := form.Value["json"]; exists && len(jsonValues) > 0 {
+// maxFormValueSize - наибольший размер части формы, кроме файла.
+const maxFormValueSize = 1 << 20
+
+var errFormValueTooLarge = errors.New("form value is too large")
+
+func readFormValue(part *multipart.Part) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(part, maxFormValueSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxFormValueSize {
+		return nil, errFormValueTooLarge
+	}
+	return data, nil
+}
+
+func readFormJSON(part *multipart.Part, v interface{}) error {
+	data, err := readFormValue(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func respondFormError(ctx *gin.Context, message string, err error) {
+	status := http.StatusBadRequest
+	if err == errFormValueTooLarge {
+		status = http.StatusRequestEntityTooLarge
+	}
+	ctx.JSON(status, entity.ErrorResponse{
+		Message: message,
+		Error:   err.Error(),
+	})
+}
+
+// uploadIdleTimeout - сколько ждать следующих данных тела загрузки. Общие ReadTimeout
+// и WriteTimeout сервера оборвали бы долгую загрузку, поэтому сроки чтения тела и записи
+// ответа сдвигаются, пока данные приходят.
+const uploadIdleTimeout = 30 * time.Second
+
+// deadlineBody продлевает сроки чтения и записи соединения при чтении тела запроса.
+type deadlineBody struct {
+	io.ReadCloser
+	rc       *http.ResponseController
+	extended time.Time
+}
+
+func (b *deadlineBody) Read(p []byte) (int, error) {
+	// Срок сдвигается не чаще раза в секунду, а не на каждом чтении
+	if now := time.Now(); now.Sub(b.extended) >= time.Second {
+		b.extended = now
+		if err := b.rc.SetReadDeadline(now.Add(uploadIdleTimeout)); err != nil &&
+			!errors.Is(err, http.ErrNotSupported) {
+			return 0, err
+		}
+		if err := b.rc.SetWriteDeadline(now.Add(uploadIdleTimeout)); err != nil &&
+			!errors.Is(err, http.ErrNotSupported) {
+			return 0, err
+		}
+	}
+	return b.ReadCloser.Read(p)
+}
+
+// filePart - часть file тела запроса. Дочитав файл, проверяет оставшиеся части: meta
+// и json после файла уже не применить, и загрузка отклоняется, а не теряет их молча.
+type filePart struct {
+	part   *multipart.Part
+	reader *multipart.Reader
+}
+
+func (f *filePart) Read(p []byte) (int, error) {
+	n, err := f.part.Read(p)
+	if err != io.EOF {
+		return n, err
+	}
+	for {
+		part, err := f.reader.NextPart()
+		if err == io.EOF {
+			return n, io.EOF
+		}
+		if err != nil {
+			return n, err
+		}
+		switch part.FormName() {
+		case "meta", "json":
+			return n, service.ErrPartAfterFile
+		}
+		if _, err := readFormValue(part); err != nil {
+			return n, err
+		}
+	}
+}
+
+func (h *Handler) postDoc(ctx *gin.Context) {
+	logrus.Debug("Entering postDoc handler")
+
+	login, exists := ctx.Get("login")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, entity.ErrorResponse{
+			Message: "Unauthorized",
+		})
+		return
+	}
+	// Тело разбирается потоком: части meta и json должны идти до file, а файл
+	// передается в хранилище прямо из запроса, без временных файлов.
+	ctx.Request.Body = &deadlineBody{ReadCloser: ctx.Request.Body, rc: http.NewResponseController(ctx.Writer)}
+	reader, err := ctx.Request.MultipartReader()
+	if err != nil {
 		ctx.JSON(http.StatusBadRequest, entity.ErrorResponse{
-			Message: "Invalid JSON format",
+			Message: "Failed to parse multipart form",
 			Error:   err.Error(),
 		})
 		return
 	}
 
-	var fileHeader *multipart.FileHeader
-	if fileHeaders, exists := form.File["file"]; exists && len(fileHeaders) > 0 {
-		fileHeader = fileHeaders[0]
+	var meta entity.UploadMeta
+	var jsonData entity.JSONB
+	var file *service.UploadFile
+	hasMeta := false
+	for file == nil {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, entity.ErrorResponse{
+				Message: "Failed to parse multipart form",
+				Error:   err.Error(),
+			})
+			return
+		}
+
+		switch part.FormName() {
+		case "file":
+			if !hasMeta {
+				ctx.JSON(http.StatusBadRequest, entity.ErrorResponse{
+					Message: "Missing meta field",
+					Error:   "meta part must precede file",
+				})
+				return
+			}
+			file = &service.UploadFile{Body: &filePart{part: part, reader: reader}, Header: part.Header}
+		case "meta":
+			if err := readFormJSON(part, &meta); err != nil {
+				respondFormError(ctx, "Invalid meta format", err)
+				return
+			}
+			hasMeta = true
+		case "json":
+			if err := readFormJSON(part, &jsonData); err != nil {
+				respondFormError(ctx, "Invalid JSON format", err)
+				return
+			}
+		default:
+			if _, err := readFormValue(part); err != nil {
+				respondFormError(ctx, "Failed to parse multipart form", err)
+				return
+			}
+		}
+	}
+
+	if !hasMeta {
+		ctx.JSON(http.StatusBadRequest, entity.ErrorResponse{
+			Message: "Missing meta field",
+		})
+		return
 	}
 
 	// Вызываем сервисный слой
@@ -204,10 +442,40 @@ func (h *Handler) postDoc(ctx *gin.Context) {
 		login.(string),
 		meta,
 		jsonData,
-		fileHeader,
+		file,
 	)
 
-	if err != nil {
+	switch err {
+	case nil:
+	case service.ErrBadRequest, service.ErrDigestMismatch, service.ErrPartAfterFile, service.ErrInvalidGroup:
+		ctx.JSON(http.StatusBadRequest, entity.ErrorResponse{
+			Message: "Bad Request",
+			Error:   err.Error(),
+		})
+		return
+	case service.ErrMimeMismatch, service.ErrMimeNotAllowed:
+		ctx.Header("Connection", "close")
+		ctx.JSON(http.StatusUnsupportedMediaType, entity.ErrorResponse{
+			Message: "Unsupported file type",
+			Error:   err.Error(),
+		})
+		return
+	case service.ErrFileTooLarge:
+		// Остаток тела не читается, соединение закрывается после ответа
+		ctx.Header("Connection", "close")
+		ctx.JSON(http.StatusRequestEntityTooLarge, entity.ErrorResponse{
+			Message: "File too large",
+			Error:   err.Error(),
+		})
+		return
+	case service.ErrQuotaExceeded:
+		ctx.Header("Connection", "close")
+		ctx.JSON(http.StatusInsufficientStorage, entity.ErrorResponse{
+			Message: "Storage quota exceeded",
+			Error:   err.Error(),
+		})
+		return
+	default:
 		logrus.Errorf("Upload document error: %v", err)
 
 		ctx.JSON(http.StatusInternalServerError, entity.ErrorResponse{
@@ -217,12 +485,6 @@ func (h *Handler) postDoc(ctx *gin.Context) {
 		return
 	}
 
-	data := UploadResponse{}
-	data.JSON = jsonData
-	if meta.File {
-		data.File = meta.Name
-	}
-
 	ctx.JSON(http.StatusCreated, entity.SuccessResponse{
 		Message: "Doc uploaded successfully",
 	})
@@ -275,6 +537,22 @@ func (h *Handler) deleteDoc(ctx *gin.Context) {
 			})
 			return
 		}
+	case service.ErrRetained:
+		{
+			ctx.JSON(http.StatusConflict, entity.ErrorResponse{
+				Message: "Document can not be deleted",
+				Error:   err.Error(),
+			})
+			return
+		}
+	case service.ErrLocked:
+		{
+			ctx.JSON(http.StatusLocked, entity.ErrorResponse{
+				Message: "Document is locked by another user",
+				Error:   err.Error(),
+			})
+			return
+		}
 	default:
 		{
 			ctx.JSON(http.StatusInternalServerError, entity.ErrorResponse{
@@ -285,3 +563,42 @@ func (h *Handler) deleteDoc(ctx *gin.Context) {
 		}
 	}
 }
+
+// updateDoc меняет json-данные документа, публичность и список доступа.
+func (h *Handler) updateDoc(ctx *gin.Context) {
+	logrus.Debug("Entering updateDoc handler")
+
+	login, exists := ctx.Get("login")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, entity.ErrorResponse{
+			Message: "Unauthorized",
+		})
+		return
+	}
+	docID, _ := uuid.Parse(ctx.Param("id"))
+
+	var req entity.UpdateDocRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, entity.ErrorResponse{
+			Message: "Invalid request",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	doc, err := h.services.UpdateDoc(ctx, docID, login.(string), req)
+	switch err {
+	case nil:
+		ctx.JSON(http.StatusOK, entity.SuccessResponse{
+			Message: "Doc updated successfully",
+			Data:    doc,
+		})
+	case service.ErrInvalidGroup:
+		ctx.JSON(http.StatusBadRequest, entity.ErrorResponse{
+			Message: "Bad Request",
+			Error:   err.Error(),
+		})
+	default:
+		h.respondLockError(ctx, err)
+	}
+}
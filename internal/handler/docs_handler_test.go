@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/olenka-91/DocsServer/internal/entity"
+	"github.com/olenka-91/DocsServer/internal/service"
+)
+
+// stubDocs отвечает на изменение документа ошибкой err.
+type stubDocs struct {
+	service.Docs
+	err error
+}
+
+func (s *stubDocs) UpdateDoc(*gin.Context, uuid.UUID, string, entity.UpdateDocRequest) (*entity.Document, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &entity.Document{}, nil
+}
+
+func TestUpdateDocStatus(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name string
+		body string
+		err  error
+		want int
+	}{
+		{"updated", `{"json": {"a": 1}}`, nil, http.StatusOK},
+		{"locked by other user", `{"json": {"a": 1}}`, service.ErrLocked, http.StatusLocked},
+		{"forbidden", `{"public": true}`, service.ErrForbidden, http.StatusForbidden},
+		{"invalid group", `{"grant_groups": ["x"]}`, service.ErrInvalidGroup, http.StatusBadRequest},
+		{"not found", `{}`, service.ErrNotFound, http.StatusNotFound},
+		{"invalid body", `{"public": "yes"}`, nil, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHandler(&service.Service{Docs: &stubDocs{err: tt.err}})
+			w := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(w)
+			ctx.Request = httptest.NewRequest(http.MethodPatch, "/api/docs/"+uuid.NewString(), strings.NewReader(tt.body))
+			ctx.Request.Header.Set("Content-Type", "application/json")
+			ctx.Set("login", "alice")
+
+			h.updateDoc(ctx)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
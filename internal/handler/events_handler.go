@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/olenka-91/DocsServer/internal/entity"
+	"github.com/olenka-91/DocsServer/internal/service"
+	"github.com/olenka-91/DocsServer/internal/utils"
+	"github.com/sirupsen/logrus"
+)
+
+func (h *Handler) getEvents(ctx *gin.Context) {
+	logrus.Debug("Entering getEvents handler")
+
+	claims, exists := ctx.Get("claims")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, entity.ErrorResponse{
+			Message: "Unauthorized",
+		})
+		return
+	}
+
+	// Браузер передает Last-Event-ID при переподключении сам, остальным клиентам
+	// удобнее параметр запроса
+	lastEventID := ctx.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = ctx.Query("last_event_id")
+	}
+
+	err := h.services.Events.Stream(ctx, claims.(*utils.JwtClaim), lastEventID)
+	switch err {
+	case nil:
+	case service.ErrBadRequest:
+		ctx.JSON(http.StatusBadRequest, entity.ErrorResponse{
+			Message: "Invalid Last-Event-ID",
+			Error:   err.Error(),
+		})
+	default:
+		ctx.JSON(http.StatusInternalServerError, entity.ErrorResponse{
+			Message: "Internal Server Error",
+			Error:   err.Error(),
+		})
+	}
+}
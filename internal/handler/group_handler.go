@@ -0,0 +1,177 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/olenka-91/DocsServer/internal/entity"
+	"github.com/olenka-91/DocsServer/internal/service"
+)
+
+func (h *Handler) createGroup(ctx *gin.Context) {
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, entity.ErrorResponse{
+			Message: "Unauthorized",
+		})
+		return
+	}
+
+	var req entity.CreateGroupRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, entity.ErrorResponse{
+			Message: "Invalid request",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	group, err := h.services.Groups.CreateGroup(userID.(uuid.UUID), req.Name)
+	if err != nil {
+		h.respondGroupError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, entity.SuccessResponse{
+		Message: "Group created successfully",
+		Data:    group,
+	})
+}
+
+func (h *Handler) getGroups(ctx *gin.Context) {
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, entity.ErrorResponse{
+			Message: "Unauthorized",
+		})
+		return
+	}
+
+	groups, err := h.services.Groups.GetUserGroups(userID.(uuid.UUID))
+	if err != nil {
+		h.respondGroupError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, entity.SuccessResponse{
+		Message: "Groups fetched successfully",
+		Data:    groups,
+	})
+}
+
+func (h *Handler) getGroup(ctx *gin.Context) {
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, entity.ErrorResponse{
+			Message: "Unauthorized",
+		})
+		return
+	}
+	groupID, _ := uuid.Parse(ctx.Param("id"))
+
+	group, err := h.services.Groups.GetGroup(userID.(uuid.UUID), groupID)
+	if err != nil {
+		h.respondGroupError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, entity.SuccessResponse{
+		Message: "Group fetched successfully",
+		Data:    group,
+	})
+}
+
+func (h *Handler) addGroupMember(ctx *gin.Context) {
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, entity.ErrorResponse{
+			Message: "Unauthorized",
+		})
+		return
+	}
+	groupID, _ := uuid.Parse(ctx.Param("id"))
+
+	var req entity.AddGroupMemberRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, entity.ErrorResponse{
+			Message: "Invalid request",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := h.services.Groups.AddMember(ctx, userID.(uuid.UUID), groupID, req); err != nil {
+		h.respondGroupError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, entity.SuccessResponse{
+		Message: "Member added successfully",
+	})
+}
+
+func (h *Handler) removeGroupMember(ctx *gin.Context) {
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, entity.ErrorResponse{
+			Message: "Unauthorized",
+		})
+		return
+	}
+	groupID, _ := uuid.Parse(ctx.Param("id"))
+
+	if err := h.services.Groups.RemoveMember(ctx, userID.(uuid.UUID), groupID, ctx.Param("login")); err != nil {
+		h.respondGroupError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, entity.SuccessResponse{
+		Message: "Member removed successfully",
+	})
+}
+
+func (h *Handler) deleteGroup(ctx *gin.Context) {
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, entity.ErrorResponse{
+			Message: "Unauthorized",
+		})
+		return
+	}
+	groupID, _ := uuid.Parse(ctx.Param("id"))
+
+	if err := h.services.Groups.DeleteGroup(ctx, userID.(uuid.UUID), groupID); err != nil {
+		h.respondGroupError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, entity.SuccessResponse{
+		Message: "Group deleted successfully",
+	})
+}
+
+func (h *Handler) respondGroupError(ctx *gin.Context, err error) {
+	switch err {
+	case service.ErrNotFound, service.ErrUserNotFound:
+		ctx.JSON(http.StatusNotFound, entity.ErrorResponse{
+			Message: "Not found",
+			Error:   err.Error(),
+		})
+	case service.ErrForbidden:
+		ctx.JSON(http.StatusForbidden, entity.ErrorResponse{
+			Message: "Only group owners can do this",
+			Error:   err.Error(),
+		})
+	case service.ErrConflict:
+		ctx.JSON(http.StatusConflict, entity.ErrorResponse{
+			Message: "Conflict",
+			Error:   err.Error(),
+		})
+	default:
+		ctx.JSON(http.StatusInternalServerError, entity.ErrorResponse{
+			Message: "Internal Server Error",
+			Error:   err.Error(),
+		})
+	}
+}
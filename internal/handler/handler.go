@@ -45,23 +45,110 @@ func (h *Handler) InitRoutes() *gin.Engine {
 		g.POST("/auth", h.signIn)
 		g.POST("/register", h.signUp)
 		g.POST("/refresh", h.refreshToken)
+		g.POST("/password/reset", h.requestPasswordReset)
+		g.POST("/password/reset/confirm", h.confirmPasswordReset)
 	}
 
 	private := router.Group("/api")
-	private.Use(middleware.AuthMiddleware())
+	private.Use(middleware.AuthMiddleware(h.services.Revocation))
 	{
 		private.POST("/logout", h.logout)
+		private.POST("/password", h.changePassword)
+		private.GET("/me", h.getMe)
+		private.GET("/me/usage", h.getMyUsage)
+		private.PATCH("/me", h.updateMe)
+		private.DELETE("/me", h.deleteMe)
 	}
 
 	private = router.Group("/api/docs")
-	private.Use(middleware.AuthMiddleware())
+	private.Use(middleware.AuthMiddleware(h.services.Revocation))
 	{
 		private.GET("", h.getDocsList)
 		private.HEAD("", h.getDocsList)
 		private.GET("/:id", h.getDoc)
 		private.HEAD("/:id", h.getDoc)
+		private.GET("/:id/thumbnail", h.getThumbnail)
+		private.GET("/:id/original", h.getOriginal)
+		private.GET("/:id/audit", h.getDocHistory)
 		private.POST("", h.postDoc)
+		private.PATCH("/:id", h.updateDoc)
 		private.DELETE("/:id", h.deleteDoc)
+		private.POST("/:id/transfer", h.transferDoc)
+		private.GET("/:id/transfers", h.getDocTransfers)
+		private.POST("/:id/lock", h.lockDoc)
+		private.DELETE("/:id/lock", h.unlockDoc)
+	}
+
+	private = router.Group("/api/transfers")
+	private.Use(middleware.AuthMiddleware(h.services.Revocation))
+	{
+		private.GET("", h.getIncomingTransfers)
+		private.POST("/:id/accept", h.acceptTransfer)
+		private.POST("/:id/decline", h.declineTransfer)
+		private.DELETE("/:id", h.cancelTransfer)
+	}
+
+	private = router.Group("/api/groups")
+	private.Use(middleware.AuthMiddleware(h.services.Revocation))
+	{
+		private.GET("", h.getGroups)
+		private.POST("", h.createGroup)
+		private.GET("/:id", h.getGroup)
+		private.DELETE("/:id", h.deleteGroup)
+		private.POST("/:id/members", h.addGroupMember)
+		private.DELETE("/:id/members/:login", h.removeGroupMember)
+	}
+
+	private = router.Group("/api/events")
+	private.Use(middleware.AuthMiddleware(h.services.Revocation))
+	{
+		private.GET("", h.getEvents)
+	}
+
+	private = router.Group("/api/webhooks")
+	private.Use(middleware.AuthMiddleware(h.services.Revocation))
+	{
+		private.POST("", h.createWebhook)
+		private.GET("", h.getWebhooks)
+		private.DELETE("/:id", h.deleteWebhook)
+		private.GET("/:id/deliveries", h.getWebhookDeliveries)
+		private.POST("/:id/deliveries/:delivery_id/redeliver", h.redeliverWebhook)
+	}
+
+	admin := router.Group("/api/admin")
+	admin.Use(middleware.AuthMiddleware(h.services.Revocation), middleware.AdminMiddleware(h.services.Authorization))
+	{
+		admin.POST("/users/:login/disable", h.disableUser)
+		admin.POST("/users/:login/enable", h.enableUser)
+		admin.POST("/transfers", h.bulkTransfer)
+		admin.GET("/cache/stats", h.getCacheStats)
+		admin.GET("/scrub", h.getScrubStatus)
+		admin.POST("/scrub", h.startScrub)
+		admin.POST("/reconcile", h.reconcileStorage)
+		admin.GET("/encryption", h.getEncryptionStatus)
+		admin.POST("/encryption/rotate", h.rotateKeys)
+		admin.PUT("/users/:login/quota", h.setUserQuota)
+		admin.PUT("/users/:login/mime-policy", h.setUserMimePolicy)
+		admin.PUT("/users/:login/scrub-policy", h.setUserScrubPolicy)
+		admin.POST("/usage/recompute", h.recomputeUsage)
+		admin.GET("/docs/:id/scan", h.getScanResult)
+		admin.POST("/docs/:id/scan", h.rescanDoc)
+		admin.POST("/scan", h.rescanDocs)
+		admin.POST("/metadata/extract", h.extractMetadata)
+		admin.POST("/webhooks", h.createWebhook)
+		admin.GET("/webhooks", h.getWebhooks)
+		admin.DELETE("/webhooks/:id", h.deleteWebhook)
+		admin.GET("/webhooks/:id/deliveries", h.getWebhookDeliveries)
+		admin.POST("/webhooks/:id/deliveries/:delivery_id/redeliver", h.redeliverWebhook)
+		admin.GET("/audit", h.getAuditLog)
+		admin.GET("/audit/export", h.exportAuditLog)
+		admin.POST("/retention/policies", h.createRetentionPolicy)
+		admin.GET("/retention/policies", h.getRetentionPolicies)
+		admin.DELETE("/retention/policies/:id", h.deleteRetentionPolicy)
+		admin.POST("/docs/:id/holds", h.placeLegalHold)
+		admin.GET("/docs/:id/holds", h.getLegalHolds)
+		admin.DELETE("/docs/:id/holds/:hold_id", h.releaseLegalHold)
+		admin.DELETE("/docs/:id/lock", h.breakDocLock)
 	}
 
 	//	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/olenka-91/DocsServer/internal/entity"
+	"github.com/olenka-91/DocsServer/internal/service"
+	"github.com/sirupsen/logrus"
+)
+
+// lockDoc открывает документ на редактирование. Срок блокировки - ttl в секундах,
+// по умолчанию LOCK_TTL.
+func (h *Handler) lockDoc(ctx *gin.Context) {
+	logrus.Debug("Entering lockDoc handler")
+
+	login, exists := ctx.Get("login")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, entity.ErrorResponse{
+			Message: "Unauthorized",
+		})
+		return
+	}
+	id, _ := uuid.Parse(ctx.Param("id"))
+
+	var ttl time.Duration
+	if v := ctx.Query("ttl"); v != "" {
+		seconds, err := strconv.Atoi(v)
+		if err != nil || seconds <= 0 {
+			ctx.JSON(http.StatusBadRequest, entity.ErrorResponse{
+				Message: "Bad Request",
+				Error:   "ttl must be a positive number of seconds",
+			})
+			return
+		}
+		ttl = time.Duration(seconds) * time.Second
+	}
+
+	lock, err := h.services.LockDoc(ctx, id, login.(string), ttl)
+	if err != nil {
+		h.respondLockError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, entity.SuccessResponse{
+		Message: "Document locked successfully",
+		Data:    lock,
+	})
+}
+
+// unlockDoc снимает свою блокировку; владелец документа с force=true снимает любую.
+func (h *Handler) unlockDoc(ctx *gin.Context) {
+	logrus.Debug("Entering unlockDoc handler")
+
+	login, exists := ctx.Get("login")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, entity.ErrorResponse{
+			Message: "Unauthorized",
+		})
+		return
+	}
+	id, _ := uuid.Parse(ctx.Param("id"))
+	force := ctx.Query("force") == "true"
+
+	if err := h.services.UnlockDoc(ctx, id, login.(string), force); err != nil {
+		h.respondLockError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, entity.SuccessResponse{
+		Message: "Document unlocked successfully",
+	})
+}
+
+func (h *Handler) breakDocLock(ctx *gin.Context) {
+	id, _ := uuid.Parse(ctx.Param("id"))
+
+	if err := h.services.BreakDocLock(ctx, id); err != nil {
+		h.respondLockError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, entity.SuccessResponse{
+		Message: "Document lock broken successfully",
+	})
+}
+
+// respondLockError отвечает на ошибку блокировки. Владелец и срок чужой блокировки
+// уже добавлены в заголовки X-Lock-Owner и X-Lock-Expires.
+func (h *Handler) respondLockError(ctx *gin.Context, err error) {
+	switch err {
+	case service.ErrUnauthorized:
+		ctx.JSON(http.StatusUnauthorized, entity.ErrorResponse{
+			Message: "Unauthorized",
+		})
+	case service.ErrNotFound:
+		ctx.JSON(http.StatusNotFound, entity.ErrorResponse{
+			Message: "Not found",
+			Error:   err.Error(),
+		})
+	case service.ErrForbidden:
+		ctx.JSON(http.StatusForbidden, entity.ErrorResponse{
+			Message: "Acess Forbidden",
+			Error:   err.Error(),
+		})
+	case service.ErrLocked:
+		ctx.JSON(http.StatusLocked, entity.ErrorResponse{
+			Message: "Document is locked by another user",
+			Error:   err.Error(),
+		})
+	default:
+		ctx.JSON(http.StatusInternalServerError, entity.ErrorResponse{
+			Message: "Internal Server Error",
+			Error:   err.Error(),
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/olenka-91/DocsServer/internal/entity"
+)
+
+type AdminChecker interface {
+	IsAdmin(userID uuid.UUID) (bool, error)
+}
+
+// AdminMiddleware должен идти после AuthMiddleware: пропускает только администраторов.
+func AdminMiddleware(checker AdminChecker) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, exists := ctx.Get("user_id")
+		if !exists {
+			ctx.JSON(http.StatusUnauthorized, entity.ErrorResponse{
+				Message: "Unauthorized",
+			})
+			ctx.Abort()
+			return
+		}
+
+		isAdmin, err := checker.IsAdmin(userID.(uuid.UUID))
+		if err != nil || !isAdmin {
+			ctx.JSON(http.StatusForbidden, entity.ErrorResponse{
+				Message: "Admin rights required",
+			})
+			ctx.Abort()
+			return
+		}
+
+		ctx.Next()
+	}
+}
@@ -9,7 +9,12 @@ import (
 	"github.com/olenka-91/DocsServer/internal/utils"
 )
 
-func AuthMiddleware() gin.HandlerFunc {
+// TokenChecker сообщает, отозван ли токен (logout, смена пароля, блокировка пользователя).
+type TokenChecker interface {
+	IsRevoked(claims *utils.JwtClaim) bool
+}
+
+func AuthMiddleware(checker TokenChecker) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		header := ctx.GetHeader("Authorization")
 		if header == "" {
@@ -48,6 +53,15 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		if checker.IsRevoked(claims) {
+			ctx.JSON(http.StatusUnauthorized, entity.ErrorResponse{
+				Message: "Token has been revoked",
+			})
+			ctx.Abort()
+			return
+		}
+
+		ctx.Set("claims", claims)
 		ctx.Set("user_id", claims.UserID)
 		ctx.Set("login", claims.Login)
 		ctx.Next()
@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/olenka-91/DocsServer/internal/entity"
+	"github.com/olenka-91/DocsServer/internal/service"
+)
+
+func (h *Handler) createRetentionPolicy(ctx *gin.Context) {
+	var req entity.RetentionPolicy
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, entity.ErrorResponse{
+			Message: "Invalid request",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	policy, err := h.services.Retention.CreateRetentionPolicy(req)
+	switch err {
+	case nil:
+		ctx.JSON(http.StatusCreated, entity.SuccessResponse{
+			Message: "Retention policy created successfully",
+			Data:    policy,
+		})
+	case service.ErrBadRequest:
+		ctx.JSON(http.StatusBadRequest, entity.ErrorResponse{
+			Message: "Invalid retention policy",
+			Error:   "mime must be a type or pattern and the period must not be empty",
+		})
+	default:
+		h.respondRetentionError(ctx, err)
+	}
+}
+
+func (h *Handler) getRetentionPolicies(ctx *gin.Context) {
+	policies, err := h.services.Retention.GetRetentionPolicies()
+	if err != nil {
+		h.respondRetentionError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, entity.SuccessResponse{
+		Message: "Retention policies fetched successfully",
+		Data:    policies,
+	})
+}
+
+func (h *Handler) deleteRetentionPolicy(ctx *gin.Context) {
+	id, _ := uuid.Parse(ctx.Param("id"))
+	if err := h.services.Retention.DeleteRetentionPolicy(id); err != nil {
+		h.respondRetentionError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, entity.SuccessResponse{
+		Message: "Retention policy deleted successfully",
+	})
+}
+
+func (h *Handler) placeLegalHold(ctx *gin.Context) {
+	id, _ := uuid.Parse(ctx.Param("id"))
+	var req entity.CreateLegalHoldRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, entity.ErrorResponse{
+			Message: "Invalid request",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	hold, err := h.services.Retention.PlaceLegalHold(ctx, id, req)
+	if err != nil {
+		h.respondRetentionError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, entity.SuccessResponse{
+		Message: "Legal hold placed successfully",
+		Data:    hold,
+	})
+}
+
+func (h *Handler) getLegalHolds(ctx *gin.Context) {
+	id, _ := uuid.Parse(ctx.Param("id"))
+	holds, err := h.services.Retention.GetLegalHolds(id)
+	if err != nil {
+		h.respondRetentionError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, entity.SuccessResponse{
+		Message: "Legal holds fetched successfully",
+		Data:    holds,
+	})
+}
+
+func (h *Handler) releaseLegalHold(ctx *gin.Context) {
+	id, _ := uuid.Parse(ctx.Param("id"))
+	holdID, _ := uuid.Parse(ctx.Param("hold_id"))
+	if err := h.services.Retention.ReleaseLegalHold(ctx, id, holdID); err != nil {
+		h.respondRetentionError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, entity.SuccessResponse{
+		Message: "Legal hold released successfully",
+	})
+}
+
+func (h *Handler) respondRetentionError(ctx *gin.Context, err error) {
+	switch err {
+	case service.ErrNotFound:
+		ctx.JSON(http.StatusNotFound, entity.ErrorResponse{
+			Message: "Not found",
+			Error:   err.Error(),
+		})
+	default:
+		ctx.JSON(http.StatusInternalServerError, entity.ErrorResponse{
+			Message: "Internal Server Error",
+			Error:   err.Error(),
+		})
+	}
+}
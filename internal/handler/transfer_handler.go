@@ -0,0 +1,185 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/olenka-91/DocsServer/internal/entity"
+	"github.com/olenka-91/DocsServer/internal/service"
+)
+
+func (h *Handler) transferDoc(ctx *gin.Context) {
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, entity.ErrorResponse{
+			Message: "Unauthorized",
+		})
+		return
+	}
+	docID, _ := uuid.Parse(ctx.Param("id"))
+
+	var req entity.TransferDocRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, entity.ErrorResponse{
+			Message: "Invalid request",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	transfer, err := h.services.Transfers.TransferDoc(ctx, docID, userID.(uuid.UUID), req)
+	if err != nil {
+		h.respondTransferError(ctx, err)
+		return
+	}
+
+	status := http.StatusOK
+	if transfer.Status == entity.TransferPending {
+		status = http.StatusAccepted
+	}
+	ctx.JSON(status, entity.SuccessResponse{
+		Message: "Doc transfer " + transfer.Status,
+		Data:    transfer,
+	})
+}
+
+func (h *Handler) getDocTransfers(ctx *gin.Context) {
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, entity.ErrorResponse{
+			Message: "Unauthorized",
+		})
+		return
+	}
+	docID, _ := uuid.Parse(ctx.Param("id"))
+
+	transfers, err := h.services.Transfers.GetDocTransfers(ctx, docID, userID.(uuid.UUID))
+	if err != nil {
+		h.respondTransferError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, entity.SuccessResponse{
+		Message: "Transfers fetched successfully",
+		Data:    transfers,
+	})
+}
+
+func (h *Handler) getIncomingTransfers(ctx *gin.Context) {
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, entity.ErrorResponse{
+			Message: "Unauthorized",
+		})
+		return
+	}
+
+	transfers, err := h.services.Transfers.GetIncomingTransfers(userID.(uuid.UUID))
+	if err != nil {
+		h.respondTransferError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, entity.SuccessResponse{
+		Message: "Transfers fetched successfully",
+		Data:    transfers,
+	})
+}
+
+func (h *Handler) acceptTransfer(ctx *gin.Context) {
+	h.resolveTransfer(ctx, h.services.Transfers.AcceptTransfer, "Transfer accepted")
+}
+
+func (h *Handler) declineTransfer(ctx *gin.Context) {
+	h.resolveTransfer(ctx, h.services.Transfers.DeclineTransfer, "Transfer declined")
+}
+
+func (h *Handler) cancelTransfer(ctx *gin.Context) {
+	h.resolveTransfer(ctx, h.services.Transfers.CancelTransfer, "Transfer cancelled")
+}
+
+func (h *Handler) resolveTransfer(ctx *gin.Context, resolve func(ctx *gin.Context, userID, transferID uuid.UUID) error, message string) {
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, entity.ErrorResponse{
+			Message: "Unauthorized",
+		})
+		return
+	}
+	transferID, _ := uuid.Parse(ctx.Param("id"))
+
+	if err := resolve(ctx, userID.(uuid.UUID), transferID); err != nil {
+		h.respondTransferError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, entity.SuccessResponse{
+		Message: message,
+	})
+}
+
+func (h *Handler) bulkTransfer(ctx *gin.Context) {
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, entity.ErrorResponse{
+			Message: "Unauthorized",
+		})
+		return
+	}
+
+	var req entity.BulkTransferRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, entity.ErrorResponse{
+			Message: "Invalid request",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	n, err := h.services.Transfers.BulkTransfer(ctx, userID.(uuid.UUID), req)
+	if err != nil {
+		h.respondTransferError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, entity.SuccessResponse{
+		Message: "Docs transferred successfully",
+		Data:    map[string]int64{"transferred": n},
+	})
+}
+
+func (h *Handler) respondTransferError(ctx *gin.Context, err error) {
+	switch err {
+	case service.ErrNotFound, service.ErrUserNotFound:
+		ctx.JSON(http.StatusNotFound, entity.ErrorResponse{
+			Message: "Not found",
+			Error:   err.Error(),
+		})
+	case service.ErrForbidden:
+		ctx.JSON(http.StatusForbidden, entity.ErrorResponse{
+			Message: "Only the owner can transfer the doc",
+			Error:   err.Error(),
+		})
+	case service.ErrBadRequest:
+		ctx.JSON(http.StatusBadRequest, entity.ErrorResponse{
+			Message: "Bad request",
+			Error:   err.Error(),
+		})
+	case service.ErrConflict:
+		ctx.JSON(http.StatusConflict, entity.ErrorResponse{
+			Message: "Transfer is no longer possible",
+			Error:   err.Error(),
+		})
+	case service.ErrLocked:
+		ctx.JSON(http.StatusLocked, entity.ErrorResponse{
+			Message: "Document is locked by another user",
+			Error:   err.Error(),
+		})
+	default:
+		ctx.JSON(http.StatusInternalServerError, entity.ErrorResponse{
+			Message: "Internal Server Error",
+			Error:   err.Error(),
+		})
+	}
+}
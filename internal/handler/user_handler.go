@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/olenka-91/DocsServer/internal/entity"
+	"github.com/olenka-91/DocsServer/internal/service"
+	"github.com/olenka-91/DocsServer/internal/utils"
+)
+
+func (h *Handler) getMe(ctx *gin.Context) {
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, entity.ErrorResponse{
+			Message: "Unauthorized",
+		})
+		return
+	}
+
+	profile, err := h.services.Users.GetProfile(userID.(uuid.UUID))
+	if err != nil {
+		h.respondUserError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, entity.SuccessResponse{
+		Message: "Profile fetched successfully",
+		Data:    profile,
+	})
+}
+
+func (h *Handler) getMyUsage(ctx *gin.Context) {
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, entity.ErrorResponse{
+			Message: "Unauthorized",
+		})
+		return
+	}
+
+	usage, err := h.services.Quotas.GetUsage(userID.(uuid.UUID))
+	if err != nil {
+		h.respondUserError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, entity.SuccessResponse{
+		Message: "Usage fetched successfully",
+		Data:    usage,
+	})
+}
+
+func (h *Handler) updateMe(ctx *gin.Context) {
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, entity.ErrorResponse{
+			Message: "Unauthorized",
+		})
+		return
+	}
+
+	var req entity.UpdateProfileRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, entity.ErrorResponse{
+			Message: "Invalid request",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	profile, err := h.services.Users.UpdateProfile(ctx, userID.(uuid.UUID), req)
+	if err != nil {
+		h.respondUserError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, entity.SuccessResponse{
+		Message: "Profile updated successfully",
+		Data:    profile,
+	})
+}
+
+func (h *Handler) deleteMe(ctx *gin.Context) {
+	claims, exists := ctx.Get("claims")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, entity.ErrorResponse{
+			Message: "Unauthorized",
+		})
+		return
+	}
+
+	var req entity.DeleteAccountRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, entity.ErrorResponse{
+			Message: "Invalid request",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := h.services.Users.DeleteAccount(ctx, claims.(*utils.JwtClaim), req); err != nil {
+		h.respondUserError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, entity.SuccessResponse{
+		Message: "Account deleted successfully",
+	})
+}
+
+func (h *Handler) respondUserError(ctx *gin.Context, err error) {
+	switch err {
+	case service.ErrUserNotFound:
+		ctx.JSON(http.StatusNotFound, entity.ErrorResponse{
+			Message: "Not found",
+			Error:   err.Error(),
+		})
+	case service.ErrInvalidCredentials:
+		ctx.JSON(http.StatusUnauthorized, entity.ErrorResponse{
+			Message: "Wrong password",
+			Error:   err.Error(),
+		})
+	case service.ErrBadRequest:
+		ctx.JSON(http.StatusBadRequest, entity.ErrorResponse{
+			Message: "Bad request",
+			Error:   err.Error(),
+		})
+	case service.ErrConflict:
+		ctx.JSON(http.StatusConflict, entity.ErrorResponse{
+			Message: "Email is already in use",
+			Error:   err.Error(),
+		})
+	case service.ErrRetained:
+		ctx.JSON(http.StatusConflict, entity.ErrorResponse{
+			Message: "Some documents can not be deleted, transfer them instead",
+			Error:   err.Error(),
+		})
+	default:
+		ctx.JSON(http.StatusInternalServerError, entity.ErrorResponse{
+			Message: "Internal Server Error",
+			Error:   err.Error(),
+		})
+	}
+}
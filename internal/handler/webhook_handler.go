@@ -0,0 +1,154 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/olenka-91/DocsServer/internal/entity"
+	"github.com/olenka-91/DocsServer/internal/service"
+)
+
+// webhookOwner - чьи webhooks затрагивает запрос: пользователя или, на маршрутах
+// администратора, webhooks администратора (nil).
+func webhookOwner(ctx *gin.Context) (*uuid.UUID, bool) {
+	if strings.HasPrefix(ctx.FullPath(), "/api/admin/") {
+		return nil, true
+	}
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, entity.ErrorResponse{
+			Message: "Unauthorized",
+		})
+		return nil, false
+	}
+	id := userID.(uuid.UUID)
+	return &id, true
+}
+
+func (h *Handler) createWebhook(ctx *gin.Context) {
+	owner, ok := webhookOwner(ctx)
+	if !ok {
+		return
+	}
+
+	var req entity.CreateWebhookRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, entity.ErrorResponse{
+			Message: "Invalid request",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	webhook, err := h.services.Webhooks.CreateWebhook(owner, req)
+	if err != nil {
+		h.respondWebhookError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, entity.SuccessResponse{
+		Message: "Webhook created successfully",
+		Data:    webhook,
+	})
+}
+
+func (h *Handler) getWebhooks(ctx *gin.Context) {
+	owner, ok := webhookOwner(ctx)
+	if !ok {
+		return
+	}
+
+	webhooks, err := h.services.Webhooks.GetWebhooks(owner)
+	if err != nil {
+		h.respondWebhookError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, entity.SuccessResponse{
+		Message: "Webhooks fetched successfully",
+		Data:    webhooks,
+	})
+}
+
+func (h *Handler) deleteWebhook(ctx *gin.Context) {
+	owner, ok := webhookOwner(ctx)
+	if !ok {
+		return
+	}
+	id, _ := uuid.Parse(ctx.Param("id"))
+
+	if err := h.services.Webhooks.DeleteWebhook(owner, id); err != nil {
+		h.respondWebhookError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, entity.SuccessResponse{
+		Message: "Webhook deleted successfully",
+	})
+}
+
+func (h *Handler) getWebhookDeliveries(ctx *gin.Context) {
+	owner, ok := webhookOwner(ctx)
+	if !ok {
+		return
+	}
+	id, _ := uuid.Parse(ctx.Param("id"))
+
+	deliveries, err := h.services.Webhooks.GetWebhookDeliveries(owner, id)
+	if err != nil {
+		h.respondWebhookError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, entity.SuccessResponse{
+		Message: "Webhook deliveries fetched successfully",
+		Data:    deliveries,
+	})
+}
+
+func (h *Handler) redeliverWebhook(ctx *gin.Context) {
+	owner, ok := webhookOwner(ctx)
+	if !ok {
+		return
+	}
+	id, _ := uuid.Parse(ctx.Param("id"))
+	deliveryID, err := strconv.ParseInt(ctx.Param("delivery_id"), 10, 64)
+	if err != nil {
+		h.respondWebhookError(ctx, service.ErrNotFound)
+		return
+	}
+
+	delivery, err := h.services.Webhooks.RedeliverWebhook(owner, id, deliveryID)
+	if err != nil {
+		h.respondWebhookError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusAccepted, entity.SuccessResponse{
+		Message: "Webhook delivery queued",
+		Data:    delivery,
+	})
+}
+
+func (h *Handler) respondWebhookError(ctx *gin.Context, err error) {
+	switch err {
+	case service.ErrNotFound:
+		ctx.JSON(http.StatusNotFound, entity.ErrorResponse{
+			Message: "Not found",
+			Error:   err.Error(),
+		})
+	case service.ErrBadRequest:
+		ctx.JSON(http.StatusBadRequest, entity.ErrorResponse{
+			Message: "Invalid webhook URL",
+			Error:   "url must be a public http or https address",
+		})
+	default:
+		ctx.JSON(http.StatusInternalServerError, entity.ErrorResponse{
+			Message: "Internal Server Error",
+			Error:   err.Error(),
+		})
+	}
+}
@@ -0,0 +1,329 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	_ "golang.org/x/image/tiff"
+)
+
+var errMalformedTIFF = errors.New("malformed TIFF/EXIF data")
+
+// Теги TIFF/EXIF
+const (
+	tagDescription = 0x010E
+	tagMake        = 0x010F
+	tagModel       = 0x0110
+	tagOrientation = 0x0112
+	tagSoftware    = 0x0131
+	tagDateTime    = 0x0132
+	tagArtist      = 0x013B
+	tagCopyright   = 0x8298
+	tagExifIFD     = 0x8769
+	tagGPSIFD      = 0x8825
+
+	tagDateTimeOriginal = 0x9003
+	tagLensModel        = 0xA434
+
+	tagGPSLatitudeRef  = 0x0001
+	tagGPSLatitude     = 0x0002
+	tagGPSLongitudeRef = 0x0003
+	tagGPSLongitude    = 0x0004
+	tagGPSAltitudeRef  = 0x0005
+	tagGPSAltitude     = 0x0006
+)
+
+// Типы значений TIFF
+const (
+	typeByte      = 1
+	typeASCII     = 2
+	typeShort     = 3
+	typeLong      = 4
+	typeRational  = 5
+	typeUndefined = 7
+)
+
+const maxIFDEntries = 1000
+
+var typeSizes = map[uint16]int64{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8}
+
+type tiffReader struct {
+	r     io.ReaderAt
+	order binary.ByteOrder
+}
+
+type ifdEntry struct {
+	typ   uint16
+	count uint32
+	value []byte
+}
+
+// extractTIFF разбирает заголовок TIFF и каталоги IFD0, EXIF и GPS. Тот же формат
+// лежит внутри сегмента APP1 в JPEG и чанка eXIf в PNG.
+func extractTIFF(r io.ReaderAt, meta map[string]interface{}, opts Options) error {
+	header := make([]byte, 8)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return errMalformedTIFF
+	}
+	t := &tiffReader{r: r}
+	switch string(header[:2]) {
+	case "II":
+		t.order = binary.LittleEndian
+	case "MM":
+		t.order = binary.BigEndian
+	default:
+		return errMalformedTIFF
+	}
+	if t.order.Uint16(header[2:]) != 42 {
+		return errMalformedTIFF
+	}
+
+	ifd0, err := t.readIFD(int64(t.order.Uint32(header[4:])))
+	if err != nil {
+		return err
+	}
+	setString(meta, KeyDescription, ifd0[tagDescription].str())
+	setString(meta, "camera_make", ifd0[tagMake].str())
+	setString(meta, "camera_model", ifd0[tagModel].str())
+	setString(meta, "software", ifd0[tagSoftware].str())
+	setString(meta, KeyAuthor, ifd0[tagArtist].str())
+	setString(meta, "copyright", ifd0[tagCopyright].str())
+	setString(meta, KeyModified, exifDate(ifd0[tagDateTime].str()))
+	if v, ok := ifd0[tagOrientation].uint(t.order); ok {
+		meta["orientation"] = v
+	}
+
+	if offset, ok := ifd0[tagExifIFD].uint(t.order); ok {
+		exif, err := t.readIFD(int64(offset))
+		if err != nil {
+			return err
+		}
+		setString(meta, KeyDateTaken, exifDate(exif[tagDateTimeOriginal].str()))
+		setString(meta, "lens_model", exif[tagLensModel].str())
+	}
+
+	if offset, ok := ifd0[tagGPSIFD].uint(t.order); ok && opts.IncludeGPS {
+		gps, err := t.readIFD(int64(offset))
+		if err != nil {
+			return err
+		}
+		if lat, ok := gps[tagGPSLatitude].coordinate(t.order, gps[tagGPSLatitudeRef].str(), "S"); ok {
+			meta["gps_latitude"] = lat
+		}
+		if lon, ok := gps[tagGPSLongitude].coordinate(t.order, gps[tagGPSLongitudeRef].str(), "W"); ok {
+			meta["gps_longitude"] = lon
+		}
+		if alt, ok := gps[tagGPSAltitude].rational(t.order, 0); ok {
+			if ref := gps[tagGPSAltitudeRef]; ref != nil && len(ref.value) > 0 && ref.value[0] == 1 {
+				alt = -alt
+			}
+			meta["gps_altitude"] = alt
+		}
+	}
+	return nil
+}
+
+// readIFD читает каталог по смещению от начала TIFF. Значения длиннее четырех байт
+// лежат отдельно, по смещению из записи.
+func (t *tiffReader) readIFD(offset int64) (map[uint16]*ifdEntry, error) {
+	buf := make([]byte, 2)
+	if _, err := t.r.ReadAt(buf, offset); err != nil {
+		return nil, errMalformedTIFF
+	}
+	n := int64(t.order.Uint16(buf))
+	if n > maxIFDEntries {
+		return nil, errMalformedTIFF
+	}
+	data := make([]byte, n*12)
+	if _, err := t.r.ReadAt(data, offset+2); err != nil {
+		return nil, errMalformedTIFF
+	}
+
+	entries := make(map[uint16]*ifdEntry, n)
+	for i := int64(0); i < n; i++ {
+		raw := data[i*12 : i*12+12]
+		e := &ifdEntry{typ: t.order.Uint16(raw[2:]), count: t.order.Uint32(raw[4:])}
+		size := typeSizes[e.typ] * int64(e.count)
+		switch {
+		case size == 0 || size > maxPartSize:
+			continue
+		case size <= 4:
+			e.value = raw[8 : 8+size]
+		default:
+			e.value = make([]byte, size)
+			if _, err := t.r.ReadAt(e.value, int64(t.order.Uint32(raw[8:]))); err != nil {
+				continue
+			}
+		}
+		entries[t.order.Uint16(raw)] = e
+	}
+	return entries, nil
+}
+
+func (e *ifdEntry) str() string {
+	if e == nil || (e.typ != typeASCII && e.typ != typeUndefined && e.typ != typeByte) {
+		return ""
+	}
+	s, _, _ := strings.Cut(string(e.value), "\x00")
+	return s
+}
+
+func (e *ifdEntry) uint(order binary.ByteOrder) (uint32, bool) {
+	if e == nil || e.count == 0 {
+		return 0, false
+	}
+	switch e.typ {
+	case typeShort:
+		return uint32(order.Uint16(e.value)), true
+	case typeLong:
+		return order.Uint32(e.value), true
+	}
+	return 0, false
+}
+
+func (e *ifdEntry) rational(order binary.ByteOrder, i int) (float64, bool) {
+	if e == nil || e.typ != typeRational || int(e.count) <= i {
+		return 0, false
+	}
+	num, den := order.Uint32(e.value[i*8:]), order.Uint32(e.value[i*8+4:])
+	if den == 0 {
+		return 0, false
+	}
+	return float64(num) / float64(den), true
+}
+
+// coordinate переводит градусы, минуты и секунды в десятичные градусы.
+func (e *ifdEntry) coordinate(order binary.ByteOrder, ref, negative string) (float64, bool) {
+	var parts [3]float64
+	for i := range parts {
+		v, ok := e.rational(order, i)
+		if !ok {
+			return 0, false
+		}
+		parts[i] = v
+	}
+	value := parts[0] + parts[1]/60 + parts[2]/3600
+	if strings.EqualFold(strings.TrimSpace(ref), negative) {
+		value = -value
+	}
+	return math.Round(value*1e6) / 1e6, true
+}
+
+// exifDate приводит дату EXIF "2006:01:02 15:04:05" к виду "2006-01-02T15:04:05",
+// по которому удобно искать и сортировать. Пустые и нулевые даты отбрасываются.
+func exifDate(value string) string {
+	t, err := time.Parse("2006:01:02 15:04:05", strings.TrimSpace(value))
+	if err != nil {
+		return ""
+	}
+	return t.Format("2006-01-02T15:04:05")
+}
+
+func setDimensions(r io.ReaderAt, size int64, meta map[string]interface{}) {
+	cfg, _, err := image.DecodeConfig(io.NewSectionReader(r, 0, size))
+	if err == nil {
+		meta[KeyWidth] = cfg.Width
+		meta[KeyHeight] = cfg.Height
+	}
+}
+
+// extractJPEG ищет EXIF в сегменте APP1 до начала данных изображения.
+func extractJPEG(r io.ReaderAt, size int64, meta map[string]interface{}, opts Options) error {
+	setDimensions(r, size, meta)
+
+	header := make([]byte, 4)
+	if _, err := r.ReadAt(header[:2], 0); err != nil || header[0] != 0xFF || header[1] != 0xD8 {
+		return errors.New("malformed JPEG")
+	}
+	for offset := int64(2); offset+4 <= size; {
+		if _, err := r.ReadAt(header, offset); err != nil {
+			return err
+		}
+		if header[0] != 0xFF {
+			return errors.New("malformed JPEG")
+		}
+		marker := header[1]
+		switch {
+		case marker == 0xFF:
+			// Заполняющий байт
+			offset++
+			continue
+		case marker == 0x01 || (marker >= 0xD0 && marker <= 0xD8):
+			offset += 2
+			continue
+		case marker == 0xDA || marker == 0xD9:
+			return nil
+		}
+
+		length := int64(binary.BigEndian.Uint16(header[2:]))
+		if length < 2 {
+			return errors.New("malformed JPEG")
+		}
+		if marker == 0xE1 && length-2 > 6 && length-2 <= maxPartSize {
+			segment := make([]byte, length-2)
+			if _, err := r.ReadAt(segment, offset+4); err != nil {
+				return err
+			}
+			if tiff, ok := bytes.CutPrefix(segment, []byte("Exif\x00\x00")); ok {
+				return extractTIFF(bytes.NewReader(tiff), meta, opts)
+			}
+		}
+		offset += 2 + length
+	}
+	return nil
+}
+
+// Ключевые слова текстовых чанков PNG
+var pngTextKeys = map[string]string{
+	"Title":         KeyTitle,
+	"Author":        KeyAuthor,
+	"Description":   KeyDescription,
+	"Copyright":     "copyright",
+	"Creation Time": KeyCreated,
+	"Software":      "software",
+}
+
+// extractPNG читает EXIF из чанка eXIf и стандартные ключи из чанков tEXt.
+func extractPNG(r io.ReaderAt, size int64, meta map[string]interface{}, opts Options) error {
+	setDimensions(r, size, meta)
+
+	header := make([]byte, 8)
+	if _, err := r.ReadAt(header, 0); err != nil || string(header) != "\x89PNG\r\n\x1a\n" {
+		return errors.New("malformed PNG")
+	}
+	for offset := int64(8); offset+8 <= size; {
+		if _, err := r.ReadAt(header, offset); err != nil {
+			return err
+		}
+		length := int64(binary.BigEndian.Uint32(header))
+		chunk := string(header[4:])
+		if chunk == "IEND" {
+			return nil
+		}
+		if (chunk == "eXIf" || chunk == "tEXt") && length <= maxPartSize {
+			data := make([]byte, length)
+			if _, err := r.ReadAt(data, offset+8); err != nil {
+				return err
+			}
+			if chunk == "eXIf" {
+				if err := extractTIFF(bytes.NewReader(data), meta, opts); err != nil {
+					return err
+				}
+			} else if keyword, text, ok := bytes.Cut(data, []byte{0}); ok {
+				if key, known := pngTextKeys[string(keyword)]; known {
+					setString(meta, key, string(text))
+				}
+			}
+		}
+		offset += 12 + length
+	}
+	return nil
+}
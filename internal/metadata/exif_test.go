@@ -0,0 +1,210 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+type testOrder interface {
+	binary.ByteOrder
+	binary.AppendByteOrder
+}
+
+var testLE testOrder = binary.LittleEndian
+
+type testEntry struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	data  []byte
+	// offset - смещение значения вместо data, для ссылок за пределы файла
+	offset uint32
+}
+
+func ascii(tag uint16, s string) testEntry {
+	return testEntry{tag: tag, typ: typeASCII, count: uint32(len(s) + 1), data: []byte(s + "\x00")}
+}
+
+func short(order testOrder, tag, v uint16) testEntry {
+	return testEntry{tag: tag, typ: typeShort, count: 1, data: order.AppendUint16(nil, v)}
+}
+
+func rationals(order testOrder, tag uint16, values ...uint32) testEntry {
+	var data []byte
+	for _, v := range values {
+		data = order.AppendUint32(data, v)
+		data = order.AppendUint32(data, 1)
+	}
+	return testEntry{tag: tag, typ: typeRational, count: uint32(len(values)), data: data}
+}
+
+// makeTIFF собирает TIFF из IFD0 и, если gps не nil, каталога GPS. Длинные значения
+// лежат после каталогов.
+func makeTIFF(order testOrder, ifd0, gps []testEntry) []byte {
+	ifd0 = slices.Clone(ifd0)
+	ifdSize := func(n int) int { return 2 + 12*n + 4 }
+	if gps != nil {
+		ifd0 = append(ifd0, testEntry{tag: tagGPSIFD, typ: typeLong, count: 1})
+	}
+	gpsOffset := 8 + ifdSize(len(ifd0))
+	dataOffset := gpsOffset
+	if gps != nil {
+		ifd0[len(ifd0)-1].data = order.AppendUint32(nil, uint32(gpsOffset))
+		dataOffset += ifdSize(len(gps))
+	}
+
+	var values []byte
+	writeIFD := func(buf []byte, entries []testEntry) []byte {
+		buf = order.AppendUint16(buf, uint16(len(entries)))
+		for _, e := range entries {
+			buf = order.AppendUint16(buf, e.tag)
+			buf = order.AppendUint16(buf, e.typ)
+			buf = order.AppendUint32(buf, e.count)
+			switch {
+			case e.offset != 0:
+				buf = order.AppendUint32(buf, e.offset)
+			case len(e.data) <= 4:
+				buf = append(buf, e.data...)
+				buf = append(buf, make([]byte, 4-len(e.data))...)
+			default:
+				buf = order.AppendUint32(buf, uint32(dataOffset+len(values)))
+				values = append(values, e.data...)
+			}
+		}
+		return order.AppendUint32(buf, 0)
+	}
+
+	buf := []byte("II")
+	if order == binary.BigEndian {
+		buf = []byte("MM")
+	}
+	buf = order.AppendUint16(buf, 42)
+	buf = order.AppendUint32(buf, 8)
+	buf = writeIFD(buf, ifd0)
+	if gps != nil {
+		buf = writeIFD(buf, gps)
+	}
+	return append(buf, values...)
+}
+
+func TestExtractTIFF(t *testing.T) {
+	le, be := binary.LittleEndian, binary.BigEndian
+	camera := func(order testOrder) []testEntry {
+		return []testEntry{
+			ascii(tagMake, "Canon"),
+			ascii(tagModel, "EOS 5D"),
+			short(order, tagOrientation, 6),
+			ascii(tagDateTime, "2021:07:15 10:20:30"),
+		}
+	}
+	cameraMeta := map[string]interface{}{
+		"camera_make":  "Canon",
+		"camera_model": "EOS 5D",
+		"orientation":  uint32(6),
+		KeyModified:    "2021-07-15T10:20:30",
+	}
+	gps := func(latRef, lonRef string, altRef byte) []testEntry {
+		return []testEntry{
+			ascii(tagGPSLatitudeRef, latRef),
+			rationals(le, tagGPSLatitude, 55, 45, 21),
+			ascii(tagGPSLongitudeRef, lonRef),
+			rationals(le, tagGPSLongitude, 37, 37, 4),
+			{tag: tagGPSAltitudeRef, typ: typeByte, count: 1, data: []byte{altRef}},
+			rationals(le, tagGPSAltitude, 150),
+		}
+	}
+	withGPS := Options{IncludeGPS: true}
+
+	// IFD0 с числом записей, которых в файле нет
+	truncatedIFD := makeTIFF(le, camera(le), nil)
+	truncatedIFD = truncatedIFD[:8+2+12]
+	// Число записей больше допустимого
+	tooManyEntries := le.AppendUint16([]byte("II*\x00\x08\x00\x00\x00"), maxIFDEntries+1)
+	tooManyEntries = append(tooManyEntries, make([]byte, 12*(maxIFDEntries+1))...)
+	// Каталог GPS за пределами файла
+	gpsOutside := makeTIFF(le, []testEntry{{tag: tagGPSIFD, typ: typeLong, count: 1, data: le.AppendUint32(nil, 1<<20)}}, nil)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		opts    Options
+		want    map[string]interface{}
+		wantErr bool
+	}{
+		{name: "little endian", data: makeTIFF(le, camera(le), nil), want: cameraMeta},
+		{name: "big endian", data: makeTIFF(be, camera(be), nil), want: cameraMeta},
+		{
+			name: "gps north east",
+			data: makeTIFF(le, nil, gps("N", "E", 0)),
+			opts: withGPS,
+			want: map[string]interface{}{"gps_latitude": 55.755833, "gps_longitude": 37.617778, "gps_altitude": 150.0},
+		},
+		{
+			name: "gps south west below sea level",
+			data: makeTIFF(le, nil, gps("S", "W", 1)),
+			opts: withGPS,
+			want: map[string]interface{}{"gps_latitude": -55.755833, "gps_longitude": -37.617778, "gps_altitude": -150.0},
+		},
+		{name: "gps not included", data: makeTIFF(le, nil, gps("S", "W", 1)), want: map[string]interface{}{}},
+		{
+			name: "oversized value skipped",
+			data: makeTIFF(le, []testEntry{
+				{tag: tagArtist, typ: typeASCII, count: maxPartSize + 1, offset: 8},
+				ascii(tagMake, "Canon"),
+			}, nil),
+			want: map[string]interface{}{"camera_make": "Canon"},
+		},
+		{
+			name: "value outside file skipped",
+			data: makeTIFF(le, []testEntry{
+				{tag: tagArtist, typ: typeASCII, count: 16, offset: 1 << 20},
+				ascii(tagMake, "Canon"),
+			}, nil),
+			want: map[string]interface{}{"camera_make": "Canon"},
+		},
+		{name: "truncated header", data: []byte("II*\x00"), wantErr: true},
+		{name: "bad byte order", data: []byte("XX*\x00\x08\x00\x00\x00"), wantErr: true},
+		{name: "bad magic", data: []byte("II\x2B\x00\x08\x00\x00\x00"), wantErr: true},
+		{name: "ifd outside file", data: []byte("II*\x00\x00\x00\x10\x00"), wantErr: true},
+		{name: "truncated ifd", data: truncatedIFD, wantErr: true},
+		{name: "too many entries", data: tooManyEntries, wantErr: true},
+		{name: "gps ifd outside file", data: gpsOutside, opts: withGPS, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta := make(map[string]interface{})
+			err := extractTIFF(bytes.NewReader(tt.data), meta, tt.opts)
+			if tt.wantErr {
+				if !errors.Is(err, errMalformedTIFF) {
+					t.Fatalf("extractTIFF error = %v, want errMalformedTIFF", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("extractTIFF: %v", err)
+			}
+			if !reflect.DeepEqual(meta, tt.want) {
+				t.Errorf("extractTIFF = %v, want %v", meta, tt.want)
+			}
+		})
+	}
+}
+
+func TestExifDate(t *testing.T) {
+	tests := map[string]string{
+		"2021:07:15 10:20:30":   "2021-07-15T10:20:30",
+		" 2021:07:15 10:20:30 ": "2021-07-15T10:20:30",
+		"0000:00:00 00:00:00":   "",
+		"":                      "",
+		"2021-07-15":            "",
+	}
+	for value, want := range tests {
+		if got := exifDate(value); got != want {
+			t.Errorf("exifDate(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
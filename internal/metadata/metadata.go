@@ -0,0 +1,136 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Options - настройки извлечения.
+type Options struct {
+	// IncludeGPS - сохранять координаты съемки из EXIF
+	IncludeGPS bool
+}
+
+// Общие ключи для всех форматов, чтобы по ним можно было искать независимо от типа файла
+const (
+	KeyTitle       = "title"
+	KeyAuthor      = "author"
+	KeySubject     = "subject"
+	KeyKeywords    = "keywords"
+	KeyDescription = "description"
+	KeyCreated     = "created"
+	KeyModified    = "modified"
+	KeyDateTaken   = "date_taken"
+	KeyPages       = "pages"
+	KeyWidth       = "width"
+	KeyHeight      = "height"
+)
+
+// maxPartSize ограничивает размер читаемых блоков метаданных (сегмент EXIF,
+// текстовый чанк PNG, docProps/core.xml).
+const maxPartSize = 1 << 20
+
+// maxReadBytes ограничивает объем, прочитанный из файла при разборе. Метаданные занимают
+// небольшую часть файла; испорченный PDF с зацикленными ссылками без ограничения читал бы
+// файл бесконечно.
+const maxReadBytes = 64 << 20
+
+var ErrBudgetExceeded = errors.New("metadata extraction read budget exceeded")
+
+const officePrefix = "application/vnd.openxmlformats-officedocument."
+
+// Supported - из файлов этого типа извлекаются метаданные.
+func Supported(mimeType string) bool {
+	switch mimeType {
+	case "image/jpeg", "image/png", "image/tiff", "application/pdf":
+		return true
+	}
+	return strings.HasPrefix(mimeType, officePrefix)
+}
+
+// Extract читает встроенные метаданные файла: EXIF изображений, словарь Info и число
+// страниц PDF, core properties документов Office. Читаются только нужные части файла,
+// не больше maxReadBytes; после отмены ctx чтение прерывается. Пустой результат -
+// метаданных нет.
+func Extract(ctx context.Context, mimeType string, rs io.ReadSeeker, opts Options) (meta map[string]interface{}, err error) {
+	r := &readerAt{ctx: ctx, rs: rs}
+	// Разбор недоверенных файлов: паника парсера не должна ронять загрузку. Прерванное
+	// чтение важнее ошибки, которой парсер его заменил
+	defer func() {
+		if p := recover(); p != nil {
+			meta, err = nil, fmt.Errorf("malformed file: %v", p)
+		}
+		if r.err != nil {
+			meta, err = nil, r.err
+		}
+	}()
+
+	size, err := rs.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	meta = make(map[string]interface{})
+	switch {
+	case mimeType == "image/jpeg":
+		err = extractJPEG(r, size, meta, opts)
+	case mimeType == "image/png":
+		err = extractPNG(r, size, meta, opts)
+	case mimeType == "image/tiff":
+		err = extractTIFF(r, meta, opts)
+	case mimeType == "application/pdf":
+		err = extractPDF(r, size, meta)
+	case strings.HasPrefix(mimeType, officePrefix):
+		err = extractOffice(r, size, meta)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return meta, nil
+}
+
+// readerAt читает с произвольных позиций через Seek. Парсеры PDF и ZIP требуют
+// io.ReaderAt, а хранилище отдает расшифрованный и распакованный поток с Seek.
+// Чтение прекращается после отмены ctx или исчерпания maxReadBytes; причина
+// остается в err, даже если парсер заменил ошибку своей.
+type readerAt struct {
+	ctx  context.Context
+	mu   sync.Mutex
+	rs   io.ReadSeeker
+	read int64
+	err  error
+}
+
+func (r *readerAt) ReadAt(p []byte, off int64) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.err == nil {
+		if err := r.ctx.Err(); err != nil {
+			r.err = err
+		} else if r.read += int64(len(p)); r.read > maxReadBytes {
+			r.err = ErrBudgetExceeded
+		}
+	}
+	if r.err != nil {
+		return 0, r.err
+	}
+	if _, err := r.rs.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, err := io.ReadFull(r.rs, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func setString(meta map[string]interface{}, key, value string) {
+	if value = strings.TrimSpace(strings.Trim(value, "\x00")); value != "" {
+		meta[key] = value
+	}
+}
@@ -0,0 +1,89 @@
+package metadata
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// cyclicPDF - PDF, в котором trailer ссылается через /Prev на собственную таблицу
+// xref: без ограничения чтения разбор такого файла не завершается.
+func cyclicPDF() []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	obj1 := buf.Len()
+	buf.WriteString("1 0 obj << /Type /Catalog /Pages 2 0 R >> endobj\n")
+	obj2 := buf.Len()
+	buf.WriteString("2 0 obj << /Type /Pages /Kids [] /Count 0 >> endobj\n")
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 3\n0000000000 65535 f \n%010d 00000 n \n%010d 00000 n \n", obj1, obj2)
+	fmt.Fprintf(&buf, "trailer << /Size 3 /Root 1 0 R /Prev %d >>\nstartxref\n%d\n%%%%EOF\n", xref, xref)
+	return buf.Bytes()
+}
+
+func TestExtractStops(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name     string
+		mimeType string
+		data     []byte
+		ctx      func() (context.Context, context.CancelFunc)
+		want     error
+	}{
+		{
+			name:     "cancelled",
+			mimeType: "image/tiff",
+			data:     makeTIFF(testLE, []testEntry{ascii(tagMake, "Canon")}, nil),
+			ctx:      func() (context.Context, context.CancelFunc) { return cancelled, func() {} },
+			want:     context.Canceled,
+		},
+		{
+			name:     "pdf xref cycle",
+			mimeType: "application/pdf",
+			data:     cyclicPDF(),
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 200*time.Millisecond)
+			},
+			want: context.DeadlineExceeded,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := tt.ctx()
+			defer cancel()
+
+			done := make(chan error, 1)
+			go func() {
+				_, err := Extract(ctx, tt.mimeType, bytes.NewReader(tt.data), Options{})
+				done <- err
+			}()
+			select {
+			case err := <-done:
+				// Цикл xref может упереться и в объем чтения, и во время
+				if !errors.Is(err, tt.want) && !errors.Is(err, ErrBudgetExceeded) {
+					t.Errorf("Extract error = %v, want %v", err, tt.want)
+				}
+			case <-time.After(10 * time.Second):
+				t.Fatal("Extract did not stop")
+			}
+		})
+	}
+}
+
+func TestReaderAtBudget(t *testing.T) {
+	r := &readerAt{ctx: context.Background(), rs: bytes.NewReader(make([]byte, 1<<20))}
+	p := make([]byte, 1<<20)
+	for read := int64(0); read < maxReadBytes; read += int64(len(p)) {
+		if _, err := r.ReadAt(p, 0); err != nil {
+			t.Fatalf("ReadAt after %d bytes: %v", read, err)
+		}
+	}
+	if _, err := r.ReadAt(p[:1], 0); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("ReadAt over budget error = %v, want ErrBudgetExceeded", err)
+	}
+}
@@ -0,0 +1,62 @@
+package metadata
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+// coreProperties - docProps/core.xml документов Office Open XML (DOCX, XLSX, PPTX).
+// Пространства имен dc, cp и dcterms не проверяются.
+type coreProperties struct {
+	Title          string `xml:"title"`
+	Subject        string `xml:"subject"`
+	Creator        string `xml:"creator"`
+	Keywords       string `xml:"keywords"`
+	Description    string `xml:"description"`
+	LastModifiedBy string `xml:"lastModifiedBy"`
+	Created        string `xml:"created"`
+	Modified       string `xml:"modified"`
+}
+
+func extractOffice(r io.ReaderAt, size int64, meta map[string]interface{}) error {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return err
+	}
+	for _, file := range archive.File {
+		if file.Name != "docProps/core.xml" {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+
+		var props coreProperties
+		if err := xml.NewDecoder(io.LimitReader(rc, maxPartSize)).Decode(&props); err != nil {
+			return err
+		}
+		setString(meta, KeyTitle, props.Title)
+		setString(meta, KeySubject, props.Subject)
+		setString(meta, KeyAuthor, props.Creator)
+		setString(meta, KeyKeywords, props.Keywords)
+		setString(meta, KeyDescription, props.Description)
+		setString(meta, "last_modified_by", props.LastModifiedBy)
+		setString(meta, KeyCreated, officeDate(props.Created))
+		setString(meta, KeyModified, officeDate(props.Modified))
+		return nil
+	}
+	return nil
+}
+
+// officeDate приводит дату W3CDTF ("2006-01-02T15:04:05Z") к виду "2006-01-02T15:04:05".
+func officeDate(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) > len("2006-01-02T15:04:05") {
+		value = value[:len("2006-01-02T15:04:05")]
+	}
+	return value
+}
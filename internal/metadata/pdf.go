@@ -0,0 +1,60 @@
+package metadata
+
+import (
+	"io"
+	"strings"
+	"time"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// Поля словаря Info
+var pdfInfoKeys = map[string]string{
+	"Title":    KeyTitle,
+	"Author":   KeyAuthor,
+	"Subject":  KeySubject,
+	"Keywords": KeyKeywords,
+	"Creator":  "creator",
+	"Producer": "producer",
+}
+
+// extractPDF читает словарь Info из trailer и число страниц из дерева страниц.
+// Содержимое страниц не разбирается.
+func extractPDF(r io.ReaderAt, size int64, meta map[string]interface{}) error {
+	reader, err := pdf.NewReader(r, size)
+	if err != nil {
+		return err
+	}
+	meta[KeyPages] = reader.NumPage()
+
+	info := reader.Trailer().Key("Info")
+	if info.IsNull() {
+		return nil
+	}
+	for key, name := range pdfInfoKeys {
+		setString(meta, name, info.Key(key).Text())
+	}
+	setString(meta, KeyCreated, pdfDate(info.Key("CreationDate").Text()))
+	setString(meta, KeyModified, pdfDate(info.Key("ModDate").Text()))
+	return nil
+}
+
+// pdfDate приводит дату PDF "D:20060102150405+07'00'" к виду "2006-01-02T15:04:05".
+// Часовой пояс отбрасывается, как и в датах EXIF.
+func pdfDate(value string) string {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "D:")
+	if len(value) < 4 {
+		return ""
+	}
+	// Месяц, день и время могут быть опущены
+	layouts := []string{"20060102150405", "200601021504", "2006010215", "20060102", "200601", "2006"}
+	for _, layout := range layouts {
+		if len(value) < len(layout) {
+			continue
+		}
+		if t, err := time.Parse(layout, value[:len(layout)]); err == nil {
+			return t.Format("2006-01-02T15:04:05")
+		}
+	}
+	return ""
+}
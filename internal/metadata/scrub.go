@@ -0,0 +1,359 @@
+package metadata
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+)
+
+// Режимы удаления метаданных из изображений
+const (
+	ScrubKeep = "keep" // файл сохраняется как есть
+	ScrubGPS  = "gps"  // удаляются координаты съемки и XMP
+	ScrubAll  = "all"  // удаляются EXIF (кроме ориентации), XMP, IPTC, комментарии и текстовые чанки PNG
+)
+
+var ErrMalformed = errors.New("malformed image, metadata cannot be removed")
+
+var scrubRanks = map[string]int{"": 0, ScrubKeep: 0, ScrubGPS: 1, ScrubAll: 2}
+
+func ValidScrubMode(mode string) bool {
+	_, ok := scrubRanks[mode]
+	return ok && mode != ""
+}
+
+// StricterScrub выбирает режим, удаляющий больше метаданных.
+func StricterScrub(a, b string) string {
+	if scrubRanks[b] > scrubRanks[a] {
+		return b
+	}
+	if a == "" {
+		return ScrubKeep
+	}
+	return a
+}
+
+// Scrubbable - из файлов этого типа можно удалить метаданные.
+func Scrubbable(mimeType string) bool {
+	return mimeType == "image/jpeg" || mimeType == "image/png"
+}
+
+// Scrubber возвращает преобразование потока, удаляющее метаданные, или nil, если
+// файл сохраняется как есть. Изображение не перекодируется: удаляются и переписываются
+// только блоки метаданных, данные пикселей копируются без изменений.
+func Scrubber(mimeType, mode string) func(io.Reader) io.Reader {
+	if scrubRanks[mode] == 0 || !Scrubbable(mimeType) {
+		return nil
+	}
+	return func(r io.Reader) io.Reader {
+		s := &scrubReader{br: bufio.NewReader(r), mode: mode}
+		if mimeType == "image/jpeg" {
+			s.next = s.nextJPEGSegment
+		} else {
+			s.next = s.nextPNGChunk
+		}
+		return s
+	}
+}
+
+// scrubReader разбирает файл по блокам: next кладет сохраняемые байты блока в out
+// или задает pass - сколько байт передать без изменений (-1 - до конца файла).
+type scrubReader struct {
+	br      *bufio.Reader
+	mode    string
+	next    func() error
+	started bool
+	out     []byte
+	pass    int64
+	err     error
+}
+
+func (s *scrubReader) Read(p []byte) (int, error) {
+	for {
+		if len(s.out) > 0 {
+			n := copy(p, s.out)
+			s.out = s.out[n:]
+			return n, nil
+		}
+		if s.pass != 0 {
+			if s.pass > 0 && int64(len(p)) > s.pass {
+				p = p[:s.pass]
+			}
+			n, err := s.br.Read(p)
+			if s.pass > 0 {
+				s.pass -= int64(n)
+				if err == io.EOF && s.pass > 0 {
+					err = ErrMalformed
+				}
+			}
+			return n, err
+		}
+		if s.err != nil {
+			return 0, s.err
+		}
+		// Конец файла обрабатывается через pass, поэтому EOF внутри next - обрезанный файл
+		if s.err = s.next(); s.err == io.ErrUnexpectedEOF || s.err == io.EOF {
+			s.err = ErrMalformed
+		}
+	}
+}
+
+func (s *scrubReader) nextJPEGSegment() error {
+	if !s.started {
+		s.started = true
+		soi := make([]byte, 2)
+		if _, err := io.ReadFull(s.br, soi); err != nil || soi[0] != 0xFF || soi[1] != 0xD8 {
+			return ErrMalformed
+		}
+		s.out = soi
+		return nil
+	}
+
+	b, err := s.br.ReadByte()
+	if err != nil {
+		return ErrMalformed
+	}
+	if b != 0xFF {
+		return ErrMalformed
+	}
+	marker, err := s.br.ReadByte()
+	if err != nil {
+		return ErrMalformed
+	}
+	switch {
+	case marker == 0xFF:
+		// Заполняющий байт: следующий 0xFF начинает маркер
+		return s.br.UnreadByte()
+	case marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7):
+		s.out = []byte{0xFF, marker}
+		return nil
+	case marker == 0xDA || marker == 0xD9:
+		// Начало данных изображения: дальше метаданных нет
+		s.out = []byte{0xFF, marker}
+		s.pass = -1
+		return nil
+	}
+
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(s.br, header); err != nil {
+		return err
+	}
+	length := int(binary.BigEndian.Uint16(header))
+	if length < 2 {
+		return ErrMalformed
+	}
+	payload := make([]byte, length-2)
+	if _, err := io.ReadFull(s.br, payload); err != nil {
+		return err
+	}
+
+	switch {
+	case marker == 0xE1:
+		// APP1: EXIF или XMP. XMP может содержать координаты и удаляется в обоих режимах
+		tiff, ok := bytes.CutPrefix(payload, []byte("Exif\x00\x00"))
+		if !ok {
+			return nil
+		}
+		if tiff = s.scrubTIFF(tiff); tiff == nil {
+			return nil
+		}
+		payload = append([]byte("Exif\x00\x00"), tiff...)
+		if len(payload)+2 > 0xFFFF {
+			return ErrMalformed
+		}
+	case (marker == 0xED || marker == 0xFE) && s.mode == ScrubAll:
+		// APP13 (IPTC) и комментарии
+		return nil
+	}
+
+	s.out = make([]byte, 0, len(payload)+4)
+	s.out = append(s.out, 0xFF, marker)
+	s.out = binary.BigEndian.AppendUint16(s.out, uint16(len(payload)+2))
+	s.out = append(s.out, payload...)
+	return nil
+}
+
+// scrubTIFF возвращает EXIF без удаляемых данных; nil - блок удаляется целиком.
+// EXIF, который не удалось разобрать, тоже удаляется.
+func (s *scrubReader) scrubTIFF(tiff []byte) []byte {
+	if s.mode == ScrubGPS {
+		stripped, err := stripGPS(tiff)
+		if err != nil {
+			return nil
+		}
+		return stripped
+	}
+	// Ориентация сохраняется, иначе снимок будет показан повернутым
+	orientation, err := exifOrientation(tiff)
+	if err != nil || orientation <= 1 {
+		return nil
+	}
+	minimal := []byte("MM\x00\x2a\x00\x00\x00\x08\x00\x01")
+	minimal = binary.BigEndian.AppendUint16(minimal, tagOrientation)
+	minimal = binary.BigEndian.AppendUint16(minimal, typeShort)
+	minimal = binary.BigEndian.AppendUint32(minimal, 1)
+	minimal = binary.BigEndian.AppendUint16(minimal, uint16(orientation))
+	return append(minimal, 0, 0, 0, 0, 0, 0)
+}
+
+func exifOrientation(tiff []byte) (uint32, error) {
+	t, ifd0, err := parseIFD0(tiff)
+	if err != nil {
+		return 0, err
+	}
+	entries, err := t.readIFD(int64(ifd0))
+	if err != nil {
+		return 0, err
+	}
+	orientation, _ := entries[tagOrientation].uint(t.order)
+	return orientation, nil
+}
+
+func parseIFD0(tiff []byte) (*tiffReader, uint32, error) {
+	if len(tiff) < 8 {
+		return nil, 0, ErrMalformed
+	}
+	t := &tiffReader{r: bytes.NewReader(tiff)}
+	switch string(tiff[:2]) {
+	case "II":
+		t.order = binary.LittleEndian
+	case "MM":
+		t.order = binary.BigEndian
+	default:
+		return nil, 0, ErrMalformed
+	}
+	if t.order.Uint16(tiff[2:]) != 42 {
+		return nil, 0, ErrMalformed
+	}
+	return t, t.order.Uint32(tiff[4:]), nil
+}
+
+// stripGPS удаляет из IFD0 ссылку на каталог GPS и затирает сам каталог со значениями.
+// Остальные смещения не меняются, поэтому прочие теги остаются корректными.
+func stripGPS(src []byte) ([]byte, error) {
+	tiff := bytes.Clone(src)
+	t, ifd0, err := parseIFD0(tiff)
+	if err != nil {
+		return nil, err
+	}
+	order := t.order
+
+	if int64(ifd0)+2 > int64(len(tiff)) {
+		return nil, ErrMalformed
+	}
+	n := int64(order.Uint16(tiff[ifd0:]))
+	end := int64(ifd0) + 2 + n*12 + 4
+	if n > maxIFDEntries || end > int64(len(tiff)) {
+		return nil, ErrMalformed
+	}
+
+	for i := int64(0); i < n; i++ {
+		entry := int64(ifd0) + 2 + i*12
+		if order.Uint16(tiff[entry:]) != tagGPSIFD {
+			continue
+		}
+		if err := zeroIFD(tiff, order, int64(order.Uint32(tiff[entry+8:]))); err != nil {
+			return nil, err
+		}
+		// Последующие записи и ссылка на следующий каталог сдвигаются на место удаленной
+		copy(tiff[entry:], tiff[entry+12:end])
+		clear(tiff[end-12 : end])
+		order.PutUint16(tiff[ifd0:], uint16(n-1))
+		break
+	}
+	return tiff, nil
+}
+
+func zeroIFD(tiff []byte, order binary.ByteOrder, offset int64) error {
+	if offset+2 > int64(len(tiff)) {
+		return ErrMalformed
+	}
+	n := int64(order.Uint16(tiff[offset:]))
+	end := offset + 2 + n*12 + 4
+	if n > maxIFDEntries || end > int64(len(tiff)) {
+		return ErrMalformed
+	}
+	for i := int64(0); i < n; i++ {
+		entry := tiff[offset+2+i*12:]
+		size := typeSizes[order.Uint16(entry[2:])] * int64(order.Uint32(entry[4:]))
+		if size > 4 {
+			valueOffset := int64(order.Uint32(entry[8:]))
+			if valueOffset+size <= int64(len(tiff)) {
+				clear(tiff[valueOffset : valueOffset+size])
+			}
+		}
+	}
+	clear(tiff[offset:end])
+	return nil
+}
+
+// Чанки PNG с текстовыми метаданными
+var pngTextChunks = map[string]bool{"tEXt": true, "zTXt": true, "iTXt": true, "tIME": true}
+
+func (s *scrubReader) nextPNGChunk() error {
+	if !s.started {
+		s.started = true
+		signature := make([]byte, 8)
+		if _, err := io.ReadFull(s.br, signature); err != nil || string(signature) != "\x89PNG\r\n\x1a\n" {
+			return ErrMalformed
+		}
+		s.out = signature
+		return nil
+	}
+
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(s.br, header); err != nil {
+		return err
+	}
+	length := int64(binary.BigEndian.Uint32(header))
+	chunk := string(header[4:])
+
+	drop := false
+	switch {
+	case chunk == "eXIf":
+		if length > maxPartSize {
+			drop = true
+			break
+		}
+		return s.rewritePNGExif(length)
+	case chunk == "iTXt" && s.mode == ScrubGPS:
+		// XMP хранится в iTXt с ключом XML:com.adobe.xmp
+		keyword, _ := s.br.Peek(len("XML:com.adobe.xmp") + 1)
+		drop = string(keyword) == "XML:com.adobe.xmp\x00"
+	case pngTextChunks[chunk]:
+		drop = s.mode == ScrubAll
+	}
+
+	if drop {
+		if _, err := io.CopyN(io.Discard, s.br, length+4); err != nil {
+			return ErrMalformed
+		}
+		return nil
+	}
+	s.out = header
+	s.pass = length + 4
+	if chunk == "IEND" {
+		s.pass = -1
+	}
+	return nil
+}
+
+func (s *scrubReader) rewritePNGExif(length int64) error {
+	data := make([]byte, length+4)
+	if _, err := io.ReadFull(s.br, data); err != nil {
+		return err
+	}
+	tiff := s.scrubTIFF(data[:length])
+	if tiff == nil {
+		return nil
+	}
+
+	s.out = binary.BigEndian.AppendUint32(nil, uint32(len(tiff)))
+	s.out = append(s.out, "eXIf"...)
+	s.out = append(s.out, tiff...)
+	s.out = binary.BigEndian.AppendUint32(s.out, crc32.ChecksumIEEE(s.out[4:]))
+	return nil
+}
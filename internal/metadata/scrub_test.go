@@ -0,0 +1,254 @@
+package metadata
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"testing"
+)
+
+const testXMP = `<x:xmpmeta xmlns:x="adobe:ns:meta/"><exif:GPSLatitude>55,45N</exif:GPSLatitude></x:xmpmeta>`
+
+func testImage() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 16, 8))
+	for x := 0; x < 16; x++ {
+		for y := 0; y < 8; y++ {
+			img.Set(x, y, color.RGBA{uint8(x * 16), uint8(y * 32), 0, 255})
+		}
+	}
+	return img
+}
+
+// testExif - EXIF со сведениями о камере, ориентацией и координатами съемки.
+func testExif(orientation uint16) []byte {
+	return makeTIFF(testLE, []testEntry{
+		ascii(tagMake, "Canon"),
+		short(testLE, tagOrientation, orientation),
+		ascii(tagArtist, "Ivan Petrov"),
+	}, []testEntry{
+		ascii(tagGPSLatitudeRef, "N"),
+		rationals(testLE, tagGPSLatitude, 55, 45, 21),
+		ascii(tagGPSLongitudeRef, "E"),
+		rationals(testLE, tagGPSLongitude, 37, 37, 4),
+	})
+}
+
+func jpegSegment(marker byte, payload []byte) []byte {
+	segment := binary.BigEndian.AppendUint16([]byte{0xFF, marker}, uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+// testJPEG - JPEG с EXIF, XMP, IPTC и комментарием перед данными изображения.
+func testJPEG(t *testing.T, orientation uint16) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, testImage(), nil); err != nil {
+		t.Fatal(err)
+	}
+	encoded := buf.Bytes()
+
+	out := []byte{0xFF, 0xD8}
+	out = append(out, jpegSegment(0xE1, append([]byte("Exif\x00\x00"), testExif(orientation)...))...)
+	out = append(out, jpegSegment(0xE1, []byte("http://ns.adobe.com/xap/1.0/\x00"+testXMP))...)
+	out = append(out, jpegSegment(0xED, []byte("Photoshop 3.0\x008BIM iptc"))...)
+	out = append(out, jpegSegment(0xFE, []byte("taken at home"))...)
+	return append(out, encoded[2:]...)
+}
+
+func pngChunk(typ string, data []byte) []byte {
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	chunk = append(chunk, typ...)
+	chunk = append(chunk, data...)
+	return binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+}
+
+// testPNG - PNG с eXIf, текстовыми чанками и XMP после IHDR.
+func testPNG(t *testing.T, orientation uint16) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, testImage()); err != nil {
+		t.Fatal(err)
+	}
+	encoded := buf.Bytes()
+	// Сигнатура и IHDR: 8 + 4 + 4 + 13 + 4 байт
+	ihdrEnd := 8 + 25
+
+	out := bytes.Clone(encoded[:ihdrEnd])
+	out = append(out, pngChunk("eXIf", testExif(orientation))...)
+	out = append(out, pngChunk("tEXt", []byte("Author\x00Ivan Petrov"))...)
+	out = append(out, pngChunk("iTXt", []byte("XML:com.adobe.xmp\x00\x00\x00\x00\x00"+testXMP))...)
+	return append(out, encoded[ihdrEnd:]...)
+}
+
+func scrub(t *testing.T, mimeType, mode string, data []byte) ([]byte, error) {
+	t.Helper()
+	return io.ReadAll(Scrubber(mimeType, mode)(bytes.NewReader(data)))
+}
+
+// checkPNGChunks проверяет CRC всех чанков.
+func checkPNGChunks(t *testing.T, data []byte) {
+	t.Helper()
+	for offset := 8; offset < len(data); {
+		length := int(binary.BigEndian.Uint32(data[offset:]))
+		chunk := data[offset+4 : offset+8+length]
+		if crc := binary.BigEndian.Uint32(data[offset+8+length:]); crc != crc32.ChecksumIEEE(chunk) {
+			t.Errorf("chunk %s has invalid CRC", chunk[:4])
+		}
+		offset += 12 + length
+	}
+}
+
+func TestScrubImages(t *testing.T) {
+	tests := []struct {
+		name        string
+		mimeType    string
+		data        func(t *testing.T, orientation uint16) []byte
+		mode        string
+		orientation uint16
+		// want - метаданные после очистки, gone - удаленные ключи; kept и removed -
+		// строки, которые должны остаться в файле или исчезнуть из него
+		want    map[string]interface{}
+		gone    []string
+		kept    []string
+		removed []string
+	}{
+		{
+			name: "jpeg gps", mimeType: "image/jpeg", data: testJPEG, mode: ScrubGPS, orientation: 6,
+			want:    map[string]interface{}{"camera_make": "Canon", KeyAuthor: "Ivan Petrov", "orientation": uint32(6)},
+			gone:    []string{"gps_latitude", "gps_longitude"},
+			kept:    []string{"taken at home", "8BIM"},
+			removed: []string{"xmpmeta"},
+		},
+		{
+			name: "jpeg all", mimeType: "image/jpeg", data: testJPEG, mode: ScrubAll, orientation: 6,
+			want:    map[string]interface{}{"orientation": uint32(6)},
+			gone:    []string{"camera_make", KeyAuthor, "gps_latitude"},
+			removed: []string{"xmpmeta", "taken at home", "8BIM", "Canon"},
+		},
+		{
+			name: "jpeg all without rotation", mimeType: "image/jpeg", data: testJPEG, mode: ScrubAll, orientation: 1,
+			want:    map[string]interface{}{},
+			gone:    []string{"orientation", "camera_make"},
+			removed: []string{"Exif\x00\x00"},
+		},
+		{
+			name: "png gps", mimeType: "image/png", data: testPNG, mode: ScrubGPS, orientation: 6,
+			want:    map[string]interface{}{"camera_make": "Canon", KeyAuthor: "Ivan Petrov", "orientation": uint32(6)},
+			gone:    []string{"gps_latitude", "gps_longitude"},
+			removed: []string{"xmpmeta"},
+		},
+		{
+			name: "png all", mimeType: "image/png", data: testPNG, mode: ScrubAll, orientation: 6,
+			want:    map[string]interface{}{"orientation": uint32(6)},
+			gone:    []string{"camera_make", KeyAuthor, "gps_latitude"},
+			removed: []string{"xmpmeta", "Ivan Petrov", "Canon"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := tt.data(t, tt.orientation)
+			got, err := scrub(t, tt.mimeType, tt.mode, original)
+			if err != nil {
+				t.Fatalf("scrub: %v", err)
+			}
+
+			// Изображение не перекодируется и остается читаемым
+			img, _, err := image.Decode(bytes.NewReader(got))
+			if err != nil {
+				t.Fatalf("decode scrubbed image: %v", err)
+			}
+			if img.Bounds() != testImage().Bounds() {
+				t.Errorf("bounds = %v", img.Bounds())
+			}
+			if tt.mimeType == "image/png" {
+				checkPNGChunks(t, got)
+			}
+
+			meta, err := Extract(context.Background(), tt.mimeType, bytes.NewReader(got), Options{IncludeGPS: true})
+			if err != nil {
+				t.Fatalf("Extract: %v", err)
+			}
+			for key, want := range tt.want {
+				if meta[key] != want {
+					t.Errorf("metadata %s = %v, want %v", key, meta[key], want)
+				}
+			}
+			for _, key := range tt.gone {
+				if v, ok := meta[key]; ok {
+					t.Errorf("metadata %s = %v is not removed", key, v)
+				}
+			}
+			for _, s := range tt.kept {
+				if !bytes.Contains(got, []byte(s)) {
+					t.Errorf("%q is removed", s)
+				}
+			}
+			for _, s := range tt.removed {
+				if bytes.Contains(got, []byte(s)) {
+					t.Errorf("%q is not removed", s)
+				}
+			}
+		})
+	}
+}
+
+func TestScrubMalformed(t *testing.T) {
+	jpegData := testJPEG(t, 6)
+	pngData := testPNG(t, 6)
+	// Конец первого сегмента APP1 и начало IDAT
+	app1End := 4 + int(binary.BigEndian.Uint16(jpegData[4:]))
+	idat := bytes.Index(pngData, []byte("IDAT")) - 4
+
+	tests := []struct {
+		name     string
+		mimeType string
+		data     []byte
+	}{
+		{"not a jpeg", "image/jpeg", []byte("GIF89a")},
+		{"jpeg truncated inside segment", "image/jpeg", jpegData[:app1End-10]},
+		{"jpeg truncated after marker", "image/jpeg", jpegData[:app1End+2]},
+		{"jpeg truncated at segment boundary", "image/jpeg", jpegData[:app1End]},
+		{"jpeg without marker", "image/jpeg", append(bytes.Clone(jpegData[:app1End]), 0x00, 0x01)},
+		{"not a png", "image/png", []byte("\x89PNX\r\n\x1a\n")},
+		{"png truncated inside eXIf", "image/png", pngData[:8+25+20]},
+		{"png truncated inside chunk header", "image/png", pngData[:idat+3]},
+		{"png truncated inside IDAT", "image/png", pngData[:idat+20]},
+		{"png without IEND", "image/png", pngData[:idat]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, mode := range []string{ScrubGPS, ScrubAll} {
+				if _, err := scrub(t, tt.mimeType, mode, tt.data); !errors.Is(err, ErrMalformed) {
+					t.Errorf("scrub %s error = %v, want ErrMalformed", mode, err)
+				}
+			}
+		})
+	}
+}
+
+func TestStricterScrub(t *testing.T) {
+	tests := []struct {
+		a, b, want string
+	}{
+		{"", "", ScrubKeep},
+		{"", ScrubGPS, ScrubGPS},
+		{ScrubAll, ScrubGPS, ScrubAll},
+		{ScrubKeep, ScrubAll, ScrubAll},
+		{ScrubGPS, ScrubKeep, ScrubGPS},
+	}
+	for _, tt := range tests {
+		if got := StricterScrub(tt.a, tt.b); got != tt.want {
+			t.Errorf("StricterScrub(%q, %q) = %q, want %q", tt.a, tt.b, got, tt.want)
+		}
+	}
+	if Scrubber("image/jpeg", ScrubKeep) != nil || Scrubber("application/pdf", ScrubAll) != nil {
+		t.Error("Scrubber is not nil for keep mode or unsupported type")
+	}
+}
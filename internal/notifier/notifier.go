@@ -0,0 +1,87 @@
+package notifier
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Notifier доставляет пользователю служебные сообщения (например, токен сброса пароля).
+type Notifier interface {
+	SendPasswordReset(login, token string, expiresAt time.Time) error
+}
+
+// New возвращает реализацию по имени из конфигурации: "log" или "file". Токены пишутся
+// целиком только при devMode, иначе - лишь их начало.
+func New(kind, filePath string, devMode bool) (Notifier, error) {
+	switch kind {
+	case "", "log":
+		return NewLogNotifier(devMode), nil
+	case "file":
+		return NewFileNotifier(filePath, devMode), nil
+	default:
+		return nil, fmt.Errorf("unknown notifier type: %s", kind)
+	}
+}
+
+// tokenHintLen - сколько первых символов токена видно вне режима разработки: достаточно,
+// чтобы сопоставить запись с запросом, но не чтобы воспользоваться токеном.
+const tokenHintLen = 4
+
+// redactToken возвращает токен целиком в режиме разработки, иначе - его начало.
+func redactToken(token string, devMode bool) string {
+	if devMode {
+		return token
+	}
+	if len(token) <= tokenHintLen {
+		return "..."
+	}
+	return token[:tokenHintLen] + "..."
+}
+
+// LogNotifier пишет сообщения в лог приложения. Подходит для локальной разработки.
+type LogNotifier struct {
+	devMode bool
+}
+
+func NewLogNotifier(devMode bool) *LogNotifier {
+	return &LogNotifier{devMode: devMode}
+}
+
+func (n *LogNotifier) SendPasswordReset(login, token string, expiresAt time.Time) error {
+	logrus.WithFields(logrus.Fields{
+		"login":      login,
+		"token":      redactToken(token, n.devMode),
+		"expires_at": expiresAt.Format(time.RFC3339),
+	}).Info("Password reset requested")
+	return nil
+}
+
+// FileNotifier дописывает сообщения в текстовый файл.
+type FileNotifier struct {
+	mu      sync.Mutex
+	path    string
+	devMode bool
+}
+
+func NewFileNotifier(path string, devMode bool) *FileNotifier {
+	return &FileNotifier{path: path, devMode: devMode}
+}
+
+func (n *FileNotifier) SendPasswordReset(login, token string, expiresAt time.Time) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	f, err := os.OpenFile(n.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open notification file: %w", err)
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, "%s\tpassword_reset\tlogin=%s\ttoken=%s\texpires_at=%s\n",
+		time.Now().Format(time.RFC3339), login, redactToken(token, n.devMode), expiresAt.Format(time.RFC3339))
+	return err
+}
@@ -0,0 +1,42 @@
+package notifier
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileNotifierRedactsToken(t *testing.T) {
+	const token = "s3cr3t-reset-token"
+	tests := []struct {
+		devMode bool
+		want    string
+	}{
+		{false, "token=s3cr...\t"},
+		{true, "token=" + token + "\t"},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "notifications.log")
+		if err := NewFileNotifier(path, tt.devMode).SendPasswordReset("alice", token, time.Now()); err != nil {
+			t.Fatalf("SendPasswordReset: %v", err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(data), tt.want) {
+			t.Errorf("devMode=%v: notification %q does not contain %q", tt.devMode, data, tt.want)
+		}
+		if !tt.devMode && strings.Contains(string(data), token) {
+			t.Errorf("notification contains the raw token: %q", data)
+		}
+	}
+}
+
+func TestRedactToken(t *testing.T) {
+	if got := redactToken("abc", false); got != "..." {
+		t.Errorf("redactToken(short) = %q, want %q", got, "...")
+	}
+}
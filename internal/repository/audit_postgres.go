@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/olenka-91/DocsServer/internal/entity"
+)
+
+type AuditPostgres struct {
+	db *sqlx.DB
+}
+
+func NewAuditPostgres(db *sqlx.DB) *AuditPostgres {
+	return &AuditPostgres{db: db}
+}
+
+const auditColumns = `id, created_at, actor_id, actor_login, action, target_type, target_id, target_name,
+	ip, user_agent, outcome, details`
+
+func (r *AuditPostgres) InsertAuditEntry(e *entity.AuditEntry) error {
+	query := `INSERT INTO audit_log (actor_id, actor_login, action, target_type, target_id, target_name,
+					ip, user_agent, outcome, details)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+				RETURNING id, created_at`
+	return r.db.QueryRow(query, e.ActorID, e.ActorLogin, e.Action, e.TargetType, e.TargetID, e.TargetName,
+		e.IP, e.UserAgent, e.Outcome, e.Details).Scan(&e.ID, &e.Created)
+}
+
+// auditWhere строит условие выборки по фильтру.
+func auditWhere(f entity.AuditFilter) (string, []interface{}) {
+	var conds []string
+	var args []interface{}
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+
+	if f.Actor != "" {
+		add("actor_login = $%d", strings.ToLower(f.Actor))
+	}
+	if f.Action != "" {
+		add("action = $%d", f.Action)
+	}
+	if f.TargetType != "" {
+		add("target_type = $%d", f.TargetType)
+	}
+	if f.TargetID != "" {
+		add("target_id = $%d", f.TargetID)
+	}
+	if f.Outcome != "" {
+		add("outcome = $%d", f.Outcome)
+	}
+	if !f.From.IsZero() {
+		add("created_at >= $%d", f.From)
+	}
+	if !f.To.IsZero() {
+		add("created_at < $%d", f.To)
+	}
+	if f.BeforeID > 0 {
+		add("id < $%d", f.BeforeID)
+	}
+
+	if len(conds) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// GetAuditEntries возвращает записи журнала, начиная с новых.
+func (r *AuditPostgres) GetAuditEntries(f entity.AuditFilter) ([]entity.AuditEntry, error) {
+	where, args := auditWhere(f)
+	args = append(args, f.Limit)
+	query := "SELECT " + auditColumns + " FROM audit_log" + where +
+		fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(args))
+
+	entries := make([]entity.AuditEntry, 0)
+	err := r.db.Select(&entries, query, args...)
+	return entries, err
+}
+
+// ExportAuditEntries передает fn записи журнала по порядку, не загружая их в память целиком.
+func (r *AuditPostgres) ExportAuditEntries(f entity.AuditFilter, fn func(*entity.AuditEntry) error) error {
+	where, args := auditWhere(f)
+	rows, err := r.db.Queryx("SELECT "+auditColumns+" FROM audit_log"+where+" ORDER BY id", args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e entity.AuditEntry
+		if err := rows.StructScan(&e); err != nil {
+			return err
+		}
+		if err := fn(&e); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
@@ -28,7 +29,7 @@ func (r *AuthPostgres) CreateUser(login, password string) (uuid.UUID, error) {
 
 func (r *AuthPostgres) GetUser(login, password string) (*entity.User, error) {
 	var user entity.User
-	query := fmt.Sprintf("SELECT id, login, password, token FROM users where login=$1 AND password=$2")
+	query := fmt.Sprintf("SELECT id, login, password, token, disabled, is_admin FROM users where login=$1 AND password=$2")
 	err := r.db.Get(&user, query, login, password)
 
 	return &user, err
@@ -36,7 +37,7 @@ func (r *AuthPostgres) GetUser(login, password string) (*entity.User, error) {
 
 func (r *AuthPostgres) GetUserByLogin(login string) (*entity.User, error) {
 	var user entity.User
-	query := fmt.Sprintf("SELECT id, login, password, token FROM users where login=$1")
+	query := fmt.Sprintf("SELECT id, login, password, token, disabled, is_admin FROM users where login=$1")
 	err := r.db.Get(&user, query, login)
 
 	return &user, err
@@ -44,7 +45,7 @@ func (r *AuthPostgres) GetUserByLogin(login string) (*entity.User, error) {
 
 func (r *AuthPostgres) GetUserByID(id uuid.UUID) (*entity.User, error) {
 	var user entity.User
-	query := fmt.Sprintf("SELECT id, login, password, token FROM users where id=$1")
+	query := fmt.Sprintf("SELECT id, login, password, token, disabled, is_admin FROM users where id=$1")
 	err := r.db.Get(&user, query, id)
 
 	return &user, err
@@ -55,3 +56,89 @@ func (r *AuthPostgres) UpdateUserToken(uuid uuid.UUID, token string) error {
 	_, err := r.db.Exec(query, token, uuid)
 	return err
 }
+
+// UpdateUserPassword в одной транзакции меняет пароль и отзывает токены пользователя,
+// выпущенные до validAfter.
+func (r *AuthPostgres) UpdateUserPassword(id uuid.UUID, password string, validAfter time.Time) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := setPasswordTx(tx, id, password); err != nil {
+		return err
+	}
+	if err := revokeUserTokensTx(tx, id, validAfter); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// setPasswordTx меняет пароль и добавляет его в историю паролей.
+func setPasswordTx(tx execer, id uuid.UUID, password string) error {
+	// Сбрасываем refresh токен, чтобы завершить остальные сессии
+	if _, err := tx.Exec("UPDATE users SET password=$1, token='' WHERE id=$2", password, id); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec("INSERT INTO password_history (user_id, password) VALUES ($1, $2)", id, password)
+	return err
+}
+
+func (r *AuthPostgres) GetPasswordHistory(id uuid.UUID, limit int) ([]string, error) {
+	var hashes []string
+	query := "SELECT password FROM password_history WHERE user_id=$1 ORDER BY created_at DESC LIMIT $2"
+	err := r.db.Select(&hashes, query, id, limit)
+
+	return hashes, err
+}
+
+func (r *AuthPostgres) CreatePasswordReset(userID uuid.UUID, tokenHash string, expiresAt time.Time) error {
+	query := "INSERT INTO password_resets (token_hash, user_id, expires_at) VALUES ($1, $2, $3)"
+	_, err := r.db.Exec(query, tokenHash, userID, expiresAt)
+	return err
+}
+
+// GetPasswordResetUser возвращает владельца действующего (неиспользованного и непросроченного) токена.
+func (r *AuthPostgres) GetPasswordResetUser(tokenHash string) (uuid.UUID, error) {
+	var userID uuid.UUID
+	query := "SELECT user_id FROM password_resets WHERE token_hash=$1 AND used_at IS NULL AND expires_at > now()"
+	err := r.db.Get(&userID, query, tokenHash)
+
+	return userID, err
+}
+
+// ResetPassword в одной транзакции помечает токен сброса использованным, меняет пароль его
+// владельца и отзывает токены, выпущенные до validAfter. sql.ErrNoRows - токен недействителен.
+func (r *AuthPostgres) ResetPassword(tokenHash, password string, validAfter time.Time) (uuid.UUID, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return uuid.Nil, err
+	}
+	defer tx.Rollback()
+
+	var userID uuid.UUID
+	query := `UPDATE password_resets SET used_at=now()
+				WHERE token_hash=$1 AND used_at IS NULL AND expires_at > now()
+				RETURNING user_id`
+	if err := tx.QueryRow(query, tokenHash).Scan(&userID); err != nil {
+		return uuid.Nil, err
+	}
+
+	if err := setPasswordTx(tx, userID, password); err != nil {
+		return uuid.Nil, err
+	}
+	if err := revokeUserTokensTx(tx, userID, validAfter); err != nil {
+		return uuid.Nil, err
+	}
+	return userID, tx.Commit()
+}
+
+func (r *AuthPostgres) SetUserDisabled(login string, disabled bool) (uuid.UUID, error) {
+	var id uuid.UUID
+	query := "UPDATE users SET disabled=$1 WHERE login=$2 RETURNING id"
+	err := r.db.QueryRow(query, disabled, login).Scan(&id)
+
+	return id, err
+}
@@ -2,15 +2,27 @@ package repository
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"github.com/olenka-91/DocsServer/internal/entity"
 	"github.com/sirupsen/logrus"
 )
 
+// metadataKeyPrefix - фильтр списка по ключу извлеченных метаданных
+const metadataKeyPrefix = "metadata."
+
+var ErrDocRetained = errors.New("document is under legal hold or retention")
+
+// ErrInvalidGroupGrant - доступ выдается группе, которой нет или в которой не состоит
+// владелец документа.
+var ErrInvalidGroupGrant = errors.New("group does not exist or user is not its member")
+
 type DocsPostgres struct {
 	db *sqlx.DB
 }
@@ -44,6 +56,39 @@ func (r *DocsPostgres) GetGrantListByDocID(ctx *gin.Context, docID uuid.UUID) ([
 	return grantList, nil
 }
 
+func (r *DocsPostgres) GetGroupGrantListByDocID(ctx *gin.Context, docID uuid.UUID) ([]string, error) {
+	queryString := `SELECT g.name FROM groups g
+				INNER JOIN document_group_grants gg ON gg.group_id = g.id
+				WHERE gg.doc_id=$1
+	`
+	var groups []string
+	if err := r.db.SelectContext(ctx, &groups, queryString, docID); err != nil {
+		logrus.Error("DBError:", err.Error())
+		return nil, err
+	}
+
+	return groups, nil
+}
+
+// accessCondition - документ d доступен пользователю с id из параметра $n:
+// публичный, свой, выдан лично или через группу.
+func accessCondition(n int) string {
+	return fmt.Sprintf(`(d.is_public
+		OR d.user_id = $%[1]d
+		OR EXISTS (SELECT 1 FROM document_grants g WHERE g.doc_id = d.id AND g.user_id = $%[1]d)
+		OR EXISTS (SELECT 1 FROM document_group_grants gg
+			INNER JOIN group_members m ON m.group_id = gg.group_id
+			WHERE gg.doc_id = d.id AND m.user_id = $%[1]d))`, n)
+}
+
+func (r *DocsPostgres) HasAccess(ctx *gin.Context, docID uuid.UUID, userID uuid.UUID) (bool, error) {
+	queryString := "SELECT EXISTS (SELECT 1 FROM documents d WHERE d.id = $1 AND " + accessCondition(2) + ")"
+
+	var ok bool
+	err := r.db.QueryRowContext(ctx, queryString, docID, userID).Scan(&ok)
+	return ok, err
+}
+
 func (r *DocsPostgres) GetLoginByUserID(ctx *gin.Context, userID uuid.UUID) string {
 	queryString := `SELECT login FROM users u				
 				WHERE u.id = $1					
@@ -83,14 +128,30 @@ func (r *DocsPostgres) GetDocsList(ctx *gin.Context, s entity.LimitedDocsListInp
 		d.MIME AS MIME,
 		d.HAS_FILE AS FILE,
 		d.IS_PUBLIC AS PUBLIC,
-		d.CREATED_AT AS CREATED    
+		d.CREATED_AT AS CREATED,
+		COALESCE(d.UPDATED_AT, d.CREATED_AT) AS UPDATED,
+		COALESCE(d.SHA256, '') AS SHA256,
+		COALESCE(d.SIZE, 0) AS SIZE,
+		COALESCE(d.SCAN_STATUS, '') AS SCAN_STATUS,
+		d.EXTRACTED_METADATA
 	FROM DOCUMENTS d `
 	//--grant
 	args := make([]interface{}, 0)
 	argCount := 1
 
-	if s.Key != "" && s.Value != "" {
-		queryString += fmt.Sprintf(" WHERE d.%s LIKE $%d ", s.Key, argCount)
+	queryString += " WHERE " + accessCondition(argCount)
+	// Непроверенные и зараженные файлы видит только владелец
+	queryString += fmt.Sprintf(" AND (d.SCAN_STATUS IS NULL OR d.SCAN_STATUS = 'clean' OR d.USER_ID = $%d)", argCount)
+	args = append(args, s.UserID)
+	argCount++
+
+	if metaKey, ok := strings.CutPrefix(s.Key, metadataKeyPrefix); ok && s.Value != "" {
+		// Поиск по метаданным файла без учета регистра: metadata.author, metadata.date_taken
+		queryString += fmt.Sprintf(" AND d.EXTRACTED_METADATA->>$%d ILIKE $%d ", argCount, argCount+1)
+		args = append(args, metaKey, "%"+s.Value+"%")
+		argCount += 2
+	} else if s.Key != "" && s.Value != "" {
+		queryString += fmt.Sprintf(" AND d.%s LIKE $%d ", s.Key, argCount)
 		args = append(args, "%"+s.Value+"%")
 		argCount++
 	}
@@ -112,7 +173,8 @@ func (r *DocsPostgres) GetDocsList(ctx *gin.Context, s entity.LimitedDocsListInp
 	var docsList []entity.Document
 	for rows.Next() {
 		var d entity.Document
-		if err := rows.Scan(&d.ID, &d.Name, &d.Mime, &d.File, &d.Public, &d.Created); err != nil {
+		if err := rows.Scan(&d.ID, &d.Name, &d.Mime, &d.File, &d.Public, &d.Created,
+			&d.Updated, &d.Checksum, &d.Size, &d.ScanStatus, &d.Metadata); err != nil {
 			logrus.Println("Error scanning row:", err)
 			continue
 		}
@@ -121,6 +183,11 @@ func (r *DocsPostgres) GetDocsList(ctx *gin.Context, s entity.LimitedDocsListInp
 			logrus.Println("Error getting grant:", err)
 			continue
 		}
+		d.Groups, err = r.GetGroupGrantListByDocID(ctx, d.ID)
+		if err != nil {
+			logrus.Println("Error getting group grant:", err)
+			continue
+		}
 		docsList = append(docsList, d)
 	}
 
@@ -131,7 +198,19 @@ func (r *DocsPostgres) GetDocsList(ctx *gin.Context, s entity.LimitedDocsListInp
 func (r *DocsPostgres) GetDoc(ctx *gin.Context, docID uuid.UUID) (*entity.Document, error) {
 
 	queryString := `
-	SELECT id,user_id,filename,path,mime,has_file,is_public,created_at,json_data
+	SELECT id,user_id,filename,path,mime,has_file,is_public,created_at,json_data,
+		COALESCE(updated_at, created_at) AS updated_at,
+		COALESCE(sha256, '') AS sha256,
+		COALESCE(size, 0) AS size,
+		COALESCE(enc_key_id, '') AS enc_key_id,
+		enc_data_key,
+		COALESCE(content_encoding, '') AS content_encoding,
+		COALESCE(scan_status, '') AS scan_status,
+		extracted_metadata,
+		COALESCE(original_size, 0) AS original_size,
+		expires_at,
+		retention_locked,
+		EXISTS (SELECT 1 FROM legal_holds h WHERE h.doc_id = documents.id) AS legal_hold
                    FROM documents WHERE id=$1 `
 
 	var doc entity.Document
@@ -145,11 +224,24 @@ func (r *DocsPostgres) GetDoc(ctx *gin.Context, docID uuid.UUID) (*entity.Docume
 		return nil, err
 	}
 
+	doc.Groups, err = r.GetGroupGrantListByDocID(ctx, doc.ID)
+	if err != nil {
+		return nil, err
+	}
+
 	return &doc, nil
 }
 
-func (r *DocsPostgres) CreateDocument(ctx *gin.Context, doc *entity.Document) error {
+func (r *DocsPostgres) CreateDocument(ctx *gin.Context, doc *entity.Document, quota entity.Quota) error {
 	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := reserveUsageTx(tx, doc.UserID, doc.Size+doc.OriginalSize, quota); err != nil {
+		tx.Rollback()
+		return err
+	}
 
 	queryString := `
 	INSERT INTO documents (
@@ -160,8 +252,19 @@ func (r *DocsPostgres) CreateDocument(ctx *gin.Context, doc *entity.Document) er
 		mime, 
 		has_file, 
 		is_public, 		
-		json_data
-	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+		json_data,
+		sha256,
+		size,
+		enc_key_id,
+		enc_data_key,
+		content_encoding,
+		scan_status,
+		extracted_metadata,
+		original_size,
+		expires_at,
+		retention_locked
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''), $10, NULLIF($11, ''), $12, NULLIF($13, ''), NULLIF($14, ''), $15,
+		NULLIF($16::bigint, 0), $17, $18)`
 
 	_, err = tx.ExecContext(ctx, queryString,
 		doc.ID,
@@ -172,6 +275,16 @@ func (r *DocsPostgres) CreateDocument(ctx *gin.Context, doc *entity.Document) er
 		doc.File,
 		doc.Public,
 		doc.JSONData,
+		doc.Checksum,
+		doc.Size,
+		doc.KeyID,
+		doc.WrappedKey,
+		doc.Encoding,
+		doc.ScanStatus,
+		doc.Metadata,
+		doc.OriginalSize,
+		doc.ExpiresAt,
+		doc.RetentionLocked,
 	)
 	if err != nil {
 		tx.Rollback()
@@ -216,33 +329,252 @@ func (r *DocsPostgres) CreateDocument(ctx *gin.Context, doc *entity.Document) er
 		}
 		logrus.Debugf("Granted access to %s", grantLogin)
 	}
-	tx.Commit()
+
+	// Выдать доступ можно только группе, в которой состоит владелец
+	queryString = `
+		INSERT INTO document_group_grants (doc_id, group_id)
+		SELECT $1, g.id
+		FROM groups g
+		INNER JOIN group_members m ON m.group_id = g.id AND m.user_id = $3
+		WHERE g.name = $2
+		ON CONFLICT (doc_id, group_id) DO NOTHING`
+
+	granted := make(map[string]bool, len(doc.Groups))
+	for _, groupName := range doc.Groups {
+		if granted[groupName] {
+			continue
+		}
+		granted[groupName] = true
+
+		result, err := tx.ExecContext(ctx, queryString, doc.ID, groupName, doc.UserID)
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to grant access for group %s: %w", groupName, err)
+		}
+		if n, err := result.RowsAffected(); err != nil {
+			tx.Rollback()
+			return err
+		} else if n == 0 {
+			tx.Rollback()
+			return fmt.Errorf("%w: %s", ErrInvalidGroupGrant, groupName)
+		}
+		logrus.Debugf("Granted access to group %s", groupName)
+	}
+
+	// Документ сохранен - загрузка больше не считается незавершенной
+	_, err = tx.ExecContext(ctx, "DELETE FROM pending_uploads WHERE doc_id = $1", doc.ID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := recordDocEventsTx(tx, entity.EventCreated, uuid.Nil, "d.id = $3", doc.ID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// CheckGroupGrants проверяет, что группы groups существуют и userID в них состоит,
+// иначе возвращает ErrInvalidGroupGrant с именами остальных групп.
+func (r *DocsPostgres) CheckGroupGrants(ctx *gin.Context, userID uuid.UUID, groups []string) error {
+	if len(groups) == 0 {
+		return nil
+	}
+
+	var invalid []string
+	query := `SELECT n.name FROM unnest($2::text[]) AS n(name)
+				WHERE NOT EXISTS (SELECT 1 FROM groups g
+					INNER JOIN group_members m ON m.group_id = g.id AND m.user_id = $1
+					WHERE g.name = n.name)`
+	if err := r.db.SelectContext(ctx, &invalid, query, userID, pq.Array(groups)); err != nil {
+		return err
+	}
+	if len(invalid) > 0 {
+		return fmt.Errorf("%w: %s", ErrInvalidGroupGrant, strings.Join(invalid, ", "))
+	}
+	return nil
+}
+
+func (r *DocsPostgres) CreatePendingUpload(ctx *gin.Context, docID uuid.UUID, key string) error {
+	_, err := r.db.ExecContext(ctx, "INSERT INTO pending_uploads (doc_id, blob_key) VALUES ($1, $2)", docID, key)
+	return err
+}
+
+func (r *DocsPostgres) CancelPendingUpload(ctx *gin.Context, docID uuid.UUID) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM pending_uploads WHERE doc_id = $1", docID)
+	return err
+}
+
+func (r *DocsPostgres) UpdateDocChecksum(ctx *gin.Context, docID uuid.UUID, checksum string, size int64) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE documents SET sha256=$1, size=$2 WHERE id=$3", checksum, size, docID)
 	return err
 }
 
-func (r *DocsPostgres) DeleteDoc(ctx *gin.Context, docID uuid.UUID) (bool, error) {
+// docProtected - документ d нельзя удалить: он под юридической блокировкой или срок его
+// обязательного хранения еще не истек.
+const docProtected = `(d.retention_locked AND d.expires_at > NOW()
+	OR EXISTS (SELECT 1 FROM legal_holds h WHERE h.doc_id = d.id))`
+
+type rowQueryer interface {
+	execer
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// deleteDocTx удаляет документ id, если выполняется условие cond по documents d (параметры
+// args в cond начинаются с $2): пишет событие удаления и уменьшает занятое место владельца.
+// Общий путь удаления документа владельцем, сверкой и по сроку хранения. Защищенный документ
+// не удаляется - ErrDocRetained; sql.ErrNoRows - документа нет или cond не выполнено.
+func deleteDocTx(tx rowQueryer, id uuid.UUID, cond string, args ...interface{}) error {
+	if err := recordDocEventsTx(tx, entity.EventDeleted, uuid.Nil, "d.id = $3", id); err != nil {
+		return err
+	}
+
+	var ownerID uuid.UUID
+	var size int64
+	err := tx.QueryRow(`DELETE FROM documents d WHERE d.id = $1 AND `+cond+` AND NOT `+docProtected+`
+				RETURNING d.user_id, COALESCE(d.size, 0) + COALESCE(d.original_size, 0)`,
+		append([]interface{}{id}, args...)...).Scan(&ownerID, &size)
+	if errors.Is(err, sql.ErrNoRows) {
+		var retained bool
+		query := "SELECT EXISTS (SELECT 1 FROM documents d WHERE d.id = $1 AND " + docProtected + ")"
+		if err := tx.QueryRow(query, id).Scan(&retained); err != nil {
+			return err
+		}
+		if retained {
+			return ErrDocRetained
+		}
+		return sql.ErrNoRows
+	}
+	if err != nil {
+		return err
+	}
+
+	// История передач остается, но ожидающие заявки на удаленный документ закрываются
+	query := "UPDATE document_transfers SET status=$1, resolved_at=NOW() WHERE doc_id=$2 AND status=$3"
+	if _, err := tx.Exec(query, entity.TransferCancelled, id, entity.TransferPending); err != nil {
+		return err
+	}
+
+	return addUsageTx(tx, ownerID, -size, -1)
+}
+
+// DeleteDoc удаляет документ от имени userID. Документ, заблокированный другим
+// пользователем, не удаляется - ErrDocLocked.
+func (r *DocsPostgres) DeleteDoc(ctx *gin.Context, docID, userID uuid.UUID) (bool, error) {
 	tx, err := r.db.Begin()
 	if err != nil {
 		return false, err
 	}
 	defer tx.Rollback()
 
-	result, err := tx.ExecContext(ctx,
-		"DELETE FROM documents WHERE id = $1",
-		docID,
-	)
+	err = deleteDocTx(tx, docID, "NOT "+docLockedByOther("$2"), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		locked, lerr := lockedByOtherTx(tx, docID, userID)
+		if lerr != nil {
+			return false, lerr
+		}
+		if locked {
+			return false, ErrDocLocked
+		}
+	}
 	if err != nil {
 		return false, err
 	}
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
+	if err := tx.Commit(); err != nil {
 		return false, err
 	}
-	if rowsAffected == 0 {
-		return false, sql.ErrNoRows
+	return true, nil
+}
+
+// UpdateDoc меняет данные и доступ к документу от имени userID. Документ, заблокированный
+// другим пользователем, не меняется - ErrDocLocked.
+func (r *DocsPostgres) UpdateDoc(ctx *gin.Context, docID, userID uuid.UUID, input entity.UpdateDocRequest) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
 	}
+	defer tx.Rollback()
 
-	tx.Commit()
-	return true, nil
+	if err := updateDocTx(tx, docID, userID, input); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// updateDocTx меняет документ id, если он не заблокирован другим пользователем, и пишет
+// событие изменения; sql.ErrNoRows - документа нет.
+func updateDocTx(tx rowQueryer, id, userID uuid.UUID, input entity.UpdateDocRequest) error {
+	var ownerID uuid.UUID
+	err := tx.QueryRow(`UPDATE documents d SET json_data = COALESCE($3, d.json_data),
+				is_public = COALESCE($4, d.is_public), updated_at = NOW()
+				WHERE d.id = $1 AND NOT `+docLockedByOther("$2")+` RETURNING d.user_id`,
+		id, userID, input.JSON, input.Public).Scan(&ownerID)
+	if errors.Is(err, sql.ErrNoRows) {
+		locked, lerr := lockedByOtherTx(tx, id, userID)
+		if lerr != nil {
+			return lerr
+		}
+		if locked {
+			return ErrDocLocked
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if input.Grant != nil {
+		// Доступ владельца хранится в той же таблице и не отзывается
+		if _, err := tx.Exec("DELETE FROM document_grants WHERE doc_id = $1 AND user_id <> $2", id, ownerID); err != nil {
+			return err
+		}
+		query := `INSERT INTO document_grants (doc_id, user_id)
+				SELECT $1, u.id FROM users u WHERE u.login = ANY($2::text[])
+				ON CONFLICT (doc_id, user_id) DO NOTHING`
+		if _, err := tx.Exec(query, id, pq.Array(*input.Grant)); err != nil {
+			return err
+		}
+	}
+
+	if input.Groups != nil {
+		if err := setGroupGrantsTx(tx, id, ownerID, *input.Groups); err != nil {
+			return err
+		}
+	}
+
+	return recordDocEventsTx(tx, entity.EventUpdated, uuid.Nil, "d.id = $3", id)
+}
+
+// setGroupGrantsTx заменяет группы с доступом к документу. Выдать доступ можно только
+// группе, в которой состоит владелец, иначе - ErrInvalidGroupGrant.
+func setGroupGrantsTx(tx execer, docID, ownerID uuid.UUID, groups []string) error {
+	if _, err := tx.Exec("DELETE FROM document_group_grants WHERE doc_id = $1", docID); err != nil {
+		return err
+	}
+
+	names := make(map[string]bool, len(groups))
+	for _, name := range groups {
+		names[name] = true
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	query := `INSERT INTO document_group_grants (doc_id, group_id)
+			SELECT $1, g.id FROM groups g
+			INNER JOIN group_members m ON m.group_id = g.id AND m.user_id = $3
+			WHERE g.name = ANY($2::text[])`
+	result, err := tx.Exec(query, docID, pq.Array(groups), ownerID)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n < int64(len(names)) {
+		return fmt.Errorf("%w: %s", ErrInvalidGroupGrant, strings.Join(groups, ", "))
+	}
+	return nil
 }
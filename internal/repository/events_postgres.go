@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/olenka-91/DocsServer/internal/entity"
+)
+
+type EventsPostgres struct {
+	db *sqlx.DB
+}
+
+func NewEventsPostgres(db *sqlx.DB) *EventsPostgres {
+	return &EventsPostgres{db: db}
+}
+
+// docRecipients - все, у кого есть доступ к документу d: владелец, личные доступы и группы.
+const docRecipients = `SELECT d.user_id
+	UNION SELECT g.user_id FROM document_grants g WHERE g.doc_id = d.id
+	UNION SELECT m.user_id FROM document_group_grants gg
+		INNER JOIN group_members m ON m.group_id = gg.group_id
+		WHERE gg.doc_id = d.id`
+
+// recordDocEventsTx пишет событие eventType по документам, выбранным условием cond по
+// таблице documents d (параметры условия начинаются с $3). Получатели - все, у кого есть
+// доступ к документу в момент события, и also, если задан (например, прежний владелец).
+// Вызывается в той же транзакции, что меняет документ, до удаления записи.
+func recordDocEventsTx(tx execer, eventType string, also uuid.UUID, cond string, args ...interface{}) error {
+	rows := `SELECT $1, d.id, d.filename, d.mime, d.is_public,
+					array_remove(ARRAY(` + docRecipients + ` UNION SELECT $2::uuid), NULL)
+				FROM documents d WHERE ` + cond
+	var alsoArg interface{}
+	if also != uuid.Nil {
+		alsoArg = also
+	}
+	return insertDocEventsTx(tx, rows, append([]interface{}{eventType, alsoArg}, args...)...)
+}
+
+// insertDocEventsTx добавляет в журнал события, выбранные запросом rows (event_type, doc_id,
+// filename, mime, is_public, recipients), и тем же запросом ставит их в очередь доставки
+// подходящим webhooks: событие не потеряется и не уйдет, если транзакция откатится.
+// Webhook пользователя получает события документов, к которым у него есть доступ,
+// webhook администратора - все события.
+func insertDocEventsTx(tx execer, rows string, args ...interface{}) error {
+	query := `WITH events AS (
+				INSERT INTO document_events (event_type, doc_id, filename, mime, is_public, recipients)
+				` + rows + `
+				RETURNING id, event_type, doc_id, filename, mime, is_public, recipients, created_at
+			)
+			INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, payload)
+			SELECT w.id, e.id, e.event_type, jsonb_build_object(
+					'id', e.id, 'type', e.event_type, 'doc_id', e.doc_id, 'name', e.filename,
+					'mime', e.mime, 'public', e.is_public, 'created_at', e.created_at)
+			FROM events e
+			INNER JOIN webhooks w ON (w.user_id IS NULL OR w.user_id = ANY(e.recipients))
+				AND (cardinality(w.events) = 0 OR e.event_type = ANY(w.events))`
+	_, err := tx.Exec(query, args...)
+	return err
+}
+
+// eventsCommitted - событие записано транзакцией, которая уже завершилась, как и все
+// транзакции до нее: новые события с меньшей позицией появиться уже не могут.
+const eventsCommitted = `txid < pg_snapshot_xmin(pg_current_snapshot())`
+
+// GetEvents возвращает события после позиции after по документам, доступным пользователю.
+// Долгая транзакция в базе задерживает выдачу событий, но не приводит к их потере.
+func (r *EventsPostgres) GetEvents(userID uuid.UUID, after entity.EventCursor, limit int) ([]entity.DocEvent, error) {
+	events := make([]entity.DocEvent, 0)
+	query := `SELECT id, txid, event_type, doc_id, filename, mime, is_public, created_at
+				FROM document_events
+				WHERE (txid, id) > ($2::xid8, $3) AND ` + eventsCommitted + `
+					AND (is_public OR $1 = ANY(recipients))
+				ORDER BY txid, id LIMIT $4`
+	err := r.db.Select(&events, query, userID, strconv.FormatUint(after.TxID, 10), after.ID, limit)
+	return events, err
+}
+
+// GetEventCursor возвращает позицию события id; sql.ErrNoRows - события нет.
+func (r *EventsPostgres) GetEventCursor(id int64) (entity.EventCursor, error) {
+	var c entity.EventCursor
+	err := r.db.Get(&c, "SELECT txid, id FROM document_events WHERE id = $1", id)
+	return c, err
+}
+
+// GetEventsHead возвращает позицию, после которой идут только еще не завершенные
+// и будущие события.
+func (r *EventsPostgres) GetEventsHead() (entity.EventCursor, error) {
+	var c entity.EventCursor
+	err := r.db.Get(&c, "SELECT pg_snapshot_xmin(pg_current_snapshot()) AS txid, 0 AS id")
+	return c, err
+}
+
+// GetEventsWatermark возвращает наибольшую транзакцию среди событий, доступных для выдачи.
+// Она растет, как только появляются новые такие события.
+func (r *EventsPostgres) GetEventsWatermark() (uint64, error) {
+	var txid uint64
+	err := r.db.Get(&txid, `SELECT txid FROM document_events WHERE `+eventsCommitted+`
+				ORDER BY txid DESC LIMIT 1`)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return txid, err
+}
+
+func (r *EventsPostgres) DeleteEventsBefore(t time.Time) (int64, error) {
+	result, err := r.db.Exec("DELETE FROM document_events WHERE created_at < $1", t)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/olenka-91/DocsServer/internal/entity"
+)
+
+// События из транзакции, зафиксированной позже события с большим id, не теряются.
+func TestGetEventsCommitOrder(t *testing.T) {
+	db := testDB(t)
+	r := NewEventsPostgres(db)
+	docID := uuid.New()
+	t.Cleanup(func() { db.Exec("DELETE FROM document_events WHERE doc_id = $1", docID) })
+
+	head, err := r.GetEventsHead()
+	if err != nil {
+		t.Fatalf("head: %v", err)
+	}
+
+	insert := func(tx *sqlx.Tx) int64 {
+		var id int64
+		err := tx.Get(&id, `INSERT INTO document_events (event_type, doc_id, is_public, recipients)
+					VALUES ('created', $1, TRUE, '{}') RETURNING id`, docID)
+		if err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+		return id
+	}
+	events := func(after entity.EventCursor) []entity.DocEvent {
+		all, err := r.GetEvents(uuid.New(), after, 100)
+		if err != nil {
+			t.Fatalf("get events: %v", err)
+		}
+		var own []entity.DocEvent
+		for _, e := range all {
+			if e.DocID == docID {
+				own = append(own, e)
+			}
+		}
+		return own
+	}
+
+	slow, _ := db.Beginx()
+	defer slow.Rollback()
+	slowID := insert(slow)
+
+	fast, _ := db.Beginx()
+	fastID := insert(fast)
+	if err := fast.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	if fastID < slowID {
+		t.Fatalf("ids %d, %d: want the slow transaction to get the lower id", slowID, fastID)
+	}
+
+	// Пока медленная транзакция не завершена, событие быстрой не отдается
+	if got := events(head); len(got) != 0 {
+		t.Fatalf("got %d events before the slow transaction committed, want 0", len(got))
+	}
+
+	if err := slow.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	got := events(head)
+	if len(got) != 2 {
+		t.Fatalf("got %d events, want 2", len(got))
+	}
+	if got[0].ID != slowID || got[1].ID != fastID {
+		t.Fatalf("events %d, %d; want %d, %d", got[0].ID, got[1].ID, slowID, fastID)
+	}
+	if rest := events(got[1].Cursor()); len(rest) != 0 {
+		t.Fatalf("got %d events after the last cursor, want 0", len(rest))
+	}
+}
@@ -0,0 +1,175 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/olenka-91/DocsServer/internal/entity"
+)
+
+var ErrLastGroupOwner = errors.New("group must have at least one owner")
+
+// lockGroupTx блокирует группу до конца транзакции, чтобы параллельные изменения
+// участников не оставили ее без владельца.
+func lockGroupTx(tx *sqlx.Tx, groupID uuid.UUID) error {
+	var id uuid.UUID
+	return tx.Get(&id, "SELECT id FROM groups WHERE id = $1 FOR UPDATE", groupID)
+}
+
+// checkOwnersTx возвращает ErrLastGroupOwner, если в группе не осталось владельцев.
+func checkOwnersTx(tx *sqlx.Tx, groupID uuid.UUID) error {
+	var owners int
+	if err := tx.Get(&owners, "SELECT COUNT(*) FROM group_members WHERE group_id = $1 AND is_owner", groupID); err != nil {
+		return err
+	}
+	if owners == 0 {
+		return ErrLastGroupOwner
+	}
+	return nil
+}
+
+type GroupsPostgres struct {
+	db *sqlx.DB
+}
+
+func NewGroupsPostgres(db *sqlx.DB) *GroupsPostgres {
+	return &GroupsPostgres{db: db}
+}
+
+// CreateGroup создает группу, создатель становится ее владельцем.
+func (r *GroupsPostgres) CreateGroup(name string, ownerID uuid.UUID) (*entity.Group, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	group := entity.Group{ID: uuid.New(), Name: name, IsOwner: true}
+	query := "INSERT INTO groups (id, name, created_by) VALUES ($1, $2, $3) RETURNING created_at"
+	if err := tx.QueryRow(query, group.ID, name, ownerID).Scan(&group.Created); err != nil {
+		return nil, err
+	}
+
+	query = "INSERT INTO group_members (group_id, user_id, is_owner) VALUES ($1, $2, TRUE)"
+	if _, err := tx.Exec(query, group.ID, ownerID); err != nil {
+		return nil, err
+	}
+
+	return &group, tx.Commit()
+}
+
+func (r *GroupsPostgres) GetUserGroups(userID uuid.UUID) ([]entity.Group, error) {
+	groups := make([]entity.Group, 0)
+	query := `SELECT g.id, g.name, m.is_owner, g.created_at FROM groups g
+				INNER JOIN group_members m ON m.group_id = g.id
+				WHERE m.user_id = $1
+				ORDER BY g.name`
+	err := r.db.Select(&groups, query, userID)
+
+	return groups, err
+}
+
+func (r *GroupsPostgres) GetGroup(groupID uuid.UUID) (*entity.Group, error) {
+	var group entity.Group
+	query := "SELECT id, name, FALSE AS is_owner, created_at FROM groups WHERE id = $1"
+	err := r.db.Get(&group, query, groupID)
+
+	return &group, err
+}
+
+func (r *GroupsPostgres) GetMembers(groupID uuid.UUID) ([]entity.GroupMember, error) {
+	members := make([]entity.GroupMember, 0)
+	query := `SELECT u.login, m.is_owner FROM group_members m
+				INNER JOIN users u ON u.id = m.user_id
+				WHERE m.group_id = $1
+				ORDER BY u.login`
+	err := r.db.Select(&members, query, groupID)
+
+	return members, err
+}
+
+// GetMembership возвращает (член группы, владелец группы).
+func (r *GroupsPostgres) GetMembership(groupID, userID uuid.UUID) (bool, bool, error) {
+	var isOwner bool
+	query := "SELECT is_owner FROM group_members WHERE group_id = $1 AND user_id = $2"
+	err := r.db.QueryRow(query, groupID, userID).Scan(&isOwner)
+	if err == sql.ErrNoRows {
+		return false, false, nil
+	}
+	if err != nil {
+		return false, false, err
+	}
+	return true, isOwner, nil
+}
+
+// AddMember добавляет участника или меняет его роль. Новый участник получает событие
+// shared по документам группы, к которым у него еще не было доступа. Снять роль
+// с последнего владельца нельзя - ErrLastGroupOwner.
+func (r *GroupsPostgres) AddMember(groupID, userID uuid.UUID, isOwner bool) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := lockGroupTx(tx, groupID); err != nil {
+		return err
+	}
+
+	rows := `SELECT $3, d.id, d.filename, d.mime, d.is_public, ARRAY[$2::uuid]
+				FROM documents d
+				INNER JOIN document_group_grants gg ON gg.doc_id = d.id AND gg.group_id = $1
+				WHERE NOT EXISTS (SELECT 1 FROM group_members WHERE group_id = $1 AND user_id = $2)
+					AND NOT ` + accessCondition(2)
+	if err := insertDocEventsTx(tx, rows, groupID, userID, entity.EventShared); err != nil {
+		return err
+	}
+
+	query := `INSERT INTO group_members (group_id, user_id, is_owner) VALUES ($1, $2, $3)
+				ON CONFLICT (group_id, user_id) DO UPDATE SET is_owner = EXCLUDED.is_owner`
+	if _, err := tx.Exec(query, groupID, userID, isOwner); err != nil {
+		return err
+	}
+	if !isOwner {
+		if err := checkOwnersTx(tx, groupID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *GroupsPostgres) RemoveMember(groupID, userID uuid.UUID) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := lockGroupTx(tx, groupID); err != nil {
+		return err
+	}
+
+	result, err := tx.Exec("DELETE FROM group_members WHERE group_id = $1 AND user_id = $2", groupID, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	// У группы всегда должен оставаться хотя бы один владелец
+	if err := checkOwnersTx(tx, groupID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *GroupsPostgres) DeleteGroup(groupID uuid.UUID) error {
+	_, err := r.db.Exec("DELETE FROM groups WHERE id = $1", groupID)
+	return err
+}
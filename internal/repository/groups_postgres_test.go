@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// Последнего владельца нельзя сделать обычным участником, как и удалить из группы.
+func TestAddMemberKeepsLastOwner(t *testing.T) {
+	db := testDB(t)
+	r := NewGroupsPostgres(db)
+
+	ownerID := uuid.New()
+	if _, err := db.Exec("INSERT INTO users (id, login, password) VALUES ($1, $2, 'x')",
+		ownerID, "test-"+ownerID.String()); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	t.Cleanup(func() { db.Exec("DELETE FROM users WHERE id = $1", ownerID) })
+
+	group, err := r.CreateGroup("test-"+uuid.NewString(), ownerID)
+	if err != nil {
+		t.Fatalf("create group: %v", err)
+	}
+	t.Cleanup(func() { db.Exec("DELETE FROM groups WHERE id = $1", group.ID) })
+
+	if err := r.AddMember(group.ID, ownerID, false); !errors.Is(err, ErrLastGroupOwner) {
+		t.Fatalf("demote last owner: %v, want ErrLastGroupOwner", err)
+	}
+	if err := r.RemoveMember(group.ID, ownerID); !errors.Is(err, ErrLastGroupOwner) {
+		t.Fatalf("remove last owner: %v, want ErrLastGroupOwner", err)
+	}
+	if _, isOwner, err := r.GetMembership(group.ID, ownerID); err != nil || !isOwner {
+		t.Fatalf("membership after rejected changes: owner %v, %v; want owner", isOwner, err)
+	}
+}
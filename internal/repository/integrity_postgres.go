@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/olenka-91/DocsServer/internal/entity"
+)
+
+type IntegrityPostgres struct {
+	db *sqlx.DB
+}
+
+func NewIntegrityPostgres(db *sqlx.DB) *IntegrityPostgres {
+	return &IntegrityPostgres{db: db}
+}
+
+func (r *IntegrityPostgres) GetFileDocs() ([]entity.Document, error) {
+	var docs []entity.Document
+	query := `SELECT id, filename, COALESCE(sha256, '') AS sha256, COALESCE(size, 0) AS size,
+				COALESCE(enc_key_id, '') AS enc_key_id, enc_data_key,
+				COALESCE(content_encoding, '') AS content_encoding
+				FROM documents WHERE has_file ORDER BY id`
+	err := r.db.Select(&docs, query)
+	return docs, err
+}
+
+func (r *IntegrityPostgres) DocExists(id uuid.UUID) (bool, error) {
+	var ok bool
+	err := r.db.Get(&ok, "SELECT EXISTS (SELECT 1 FROM documents WHERE id=$1)", id)
+	return ok, err
+}
+
+func (r *IntegrityPostgres) SetDocChecksum(id uuid.UUID, checksum string, size int64) error {
+	_, err := r.db.Exec("UPDATE documents SET sha256=$2, size=$3 WHERE id=$1 AND sha256 IS NULL",
+		id, checksum, size)
+	return err
+}
+
+func (r *IntegrityPostgres) GetPendingUploads() ([]entity.PendingUpload, error) {
+	var uploads []entity.PendingUpload
+	err := r.db.Select(&uploads, "SELECT doc_id, blob_key, created_at FROM pending_uploads")
+	return uploads, err
+}
+
+func (r *IntegrityPostgres) DeletePendingUpload(docID uuid.UUID) error {
+	_, err := r.db.Exec("DELETE FROM pending_uploads WHERE doc_id=$1", docID)
+	return err
+}
+
+// DeleteDocRecord удаляет запись документа вместе с доступами (ON DELETE CASCADE).
+func (r *IntegrityPostgres) DeleteDocRecord(id uuid.UUID) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = deleteDocTx(tx, id, "TRUE")
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetDocKeysNotWrappedWith возвращает зашифрованные документы, ключ данных которых
+// обернут не ключом keyID, постранично по id.
+func (r *IntegrityPostgres) GetDocKeysNotWrappedWith(keyID string, after uuid.UUID, limit int) ([]entity.Document, error) {
+	var docs []entity.Document
+	query := `SELECT id, enc_key_id, enc_data_key FROM documents
+				WHERE enc_key_id IS NOT NULL AND enc_key_id <> $1 AND id > $2
+				ORDER BY id LIMIT $3`
+	err := r.db.Select(&docs, query, keyID, after, limit)
+	return docs, err
+}
+
+// UpdateDocKey заменяет обернутый ключ, если его не изменили параллельно.
+func (r *IntegrityPostgres) UpdateDocKey(id uuid.UUID, oldKeyID, newKeyID string, wrapped []byte) (bool, error) {
+	result, err := r.db.Exec(`UPDATE documents SET enc_key_id=$3, enc_data_key=$4
+				WHERE id=$1 AND enc_key_id=$2`, id, oldKeyID, newKeyID, wrapped)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+func (r *IntegrityPostgres) CountDocsByKey() (map[string]int64, error) {
+	rows, err := r.db.Query(`SELECT COALESCE(enc_key_id, ''), COUNT(*) FROM documents
+				WHERE has_file GROUP BY 1`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int64)
+	for rows.Next() {
+		var keyID string
+		var n int64
+		if err := rows.Scan(&keyID, &n); err != nil {
+			return nil, err
+		}
+		counts[keyID] = n
+	}
+	return counts, rows.Err()
+}
@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/olenka-91/DocsServer/internal/entity"
+)
+
+var ErrDocLocked = errors.New("document is locked by another user")
+
+// docLockedByOther - документ d заблокирован не пользователем из параметра запроса
+// userParam (например, "$2"). Проверяется в самом изменяющем запросе, чтобы блокировку
+// нельзя было взять между проверкой и изменением.
+func docLockedByOther(userParam string) string {
+	return `EXISTS (SELECT 1 FROM document_locks l WHERE l.doc_id = d.id AND l.user_id <> ` + userParam + `
+		AND l.expires_at > NOW())`
+}
+
+// lockedByOtherTx сообщает, что документ docID заблокирован не пользователем userID.
+// Нужна, чтобы объяснить, почему изменяющий запрос не затронул ни одной строки.
+func lockedByOtherTx(tx rowQueryer, docID, userID uuid.UUID) (bool, error) {
+	var locked bool
+	query := "SELECT EXISTS (SELECT 1 FROM documents d WHERE d.id = $1 AND " + docLockedByOther("$2") + ")"
+	err := tx.QueryRow(query, docID, userID).Scan(&locked)
+	return locked, err
+}
+
+type LocksPostgres struct {
+	db *sqlx.DB
+}
+
+func NewLocksPostgres(db *sqlx.DB) *LocksPostgres {
+	return &LocksPostgres{db: db}
+}
+
+// AcquireLock блокирует документ за пользователем на ttl. Своя блокировка продлевается,
+// истекшая чужая - перезаписывается; sql.ErrNoRows - документ заблокирован другим.
+func (r *LocksPostgres) AcquireLock(docID, userID uuid.UUID, ttl time.Duration) (*entity.DocumentLock, error) {
+	query := `WITH l AS (
+				INSERT INTO document_locks (doc_id, user_id, expires_at)
+				VALUES ($1, $2, NOW() + make_interval(secs => $3))
+				ON CONFLICT (doc_id) DO UPDATE SET
+					user_id = EXCLUDED.user_id,
+					created_at = CASE WHEN document_locks.user_id = EXCLUDED.user_id
+						AND document_locks.expires_at > NOW() THEN document_locks.created_at ELSE NOW() END,
+					expires_at = EXCLUDED.expires_at
+				WHERE document_locks.user_id = EXCLUDED.user_id OR document_locks.expires_at <= NOW()
+				RETURNING doc_id, user_id, created_at, expires_at)
+			SELECT l.doc_id, l.user_id, u.login, l.created_at, l.expires_at FROM l JOIN users u ON u.id = l.user_id`
+
+	var lock entity.DocumentLock
+	if err := r.db.Get(&lock, query, docID, userID, ttl.Seconds()); err != nil {
+		return nil, err
+	}
+	return &lock, nil
+}
+
+// GetLock возвращает действующую блокировку документа или nil.
+func (r *LocksPostgres) GetLock(docID uuid.UUID) (*entity.DocumentLock, error) {
+	var lock entity.DocumentLock
+	err := r.db.Get(&lock, `SELECT l.doc_id, l.user_id, u.login, l.created_at, l.expires_at
+				FROM document_locks l JOIN users u ON u.id = l.user_id
+				WHERE l.doc_id = $1 AND l.expires_at > NOW()`, docID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &lock, nil
+}
+
+// ReleaseLock снимает действующую блокировку документа: userID - только свою,
+// nil - любую. sql.ErrNoRows - такой блокировки нет.
+func (r *LocksPostgres) ReleaseLock(docID uuid.UUID, userID *uuid.UUID) (*entity.DocumentLock, error) {
+	var lock entity.DocumentLock
+	err := r.db.Get(&lock, `DELETE FROM document_locks l USING users u
+				WHERE l.doc_id = $1 AND l.expires_at > NOW() AND ($2::uuid IS NULL OR l.user_id = $2)
+					AND u.id = l.user_id
+				RETURNING l.doc_id, l.user_id, u.login, l.created_at, l.expires_at`, docID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &lock, nil
+}
+
+func (r *LocksPostgres) DeleteExpiredLocks() (int64, error) {
+	result, err := r.db.Exec("DELETE FROM document_locks WHERE expires_at <= NOW()")
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/olenka-91/DocsServer/internal/entity"
+)
+
+// testDoc создает в tx документ пользователя userID без файла.
+func testDoc(t *testing.T, tx *sqlx.Tx, userID uuid.UUID) uuid.UUID {
+	t.Helper()
+	docID := uuid.New()
+	if _, err := tx.Exec(`INSERT INTO documents (id, user_id, filename, path, mime, has_file, is_public)
+				VALUES ($1, $2, 'doc.txt', '', 'text/plain', FALSE, FALSE)`, docID, userID); err != nil {
+		t.Fatalf("create doc: %v", err)
+	}
+	return docID
+}
+
+// testUser создает в tx еще одного пользователя.
+func testUser(t *testing.T, tx *sqlx.Tx) uuid.UUID {
+	t.Helper()
+	userID := uuid.New()
+	if _, err := tx.Exec("INSERT INTO users (id, login, password) VALUES ($1, $2, 'x')",
+		userID, "test-"+userID.String()); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return userID
+}
+
+func testLock(t *testing.T, tx *sqlx.Tx, docID, userID uuid.UUID) {
+	t.Helper()
+	if _, err := tx.Exec(`INSERT INTO document_locks (doc_id, user_id, expires_at)
+				VALUES ($1, $2, NOW() + INTERVAL '1 hour')`, docID, userID); err != nil {
+		t.Fatalf("lock doc: %v", err)
+	}
+}
+
+// Чужая блокировка проверяется в самом запросе удаления, своя удалению не мешает.
+func TestDeleteLockedDoc(t *testing.T) {
+	tx, ownerID := testTx(t, testDB(t))
+	editorID := testUser(t, tx)
+
+	docID := testDoc(t, tx, ownerID)
+	testLock(t, tx, docID, editorID)
+	err := deleteDocTx(tx, docID, "NOT "+docLockedByOther("$2"), ownerID)
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("delete locked doc: %v, want sql.ErrNoRows", err)
+	}
+	if locked, err := lockedByOtherTx(tx, docID, ownerID); err != nil || !locked {
+		t.Fatalf("lockedByOtherTx = %v, %v; want true", locked, err)
+	}
+
+	ownDocID := testDoc(t, tx, ownerID)
+	testLock(t, tx, ownDocID, ownerID)
+	if err := deleteDocTx(tx, ownDocID, "NOT "+docLockedByOther("$2"), ownerID); err != nil {
+		t.Fatalf("delete doc locked by owner: %v", err)
+	}
+}
+
+func TestMoveLockedDoc(t *testing.T) {
+	tx, ownerID := testTx(t, testDB(t))
+	editorID := testUser(t, tx)
+	recipientID := testUser(t, tx)
+
+	docID := testDoc(t, tx, ownerID)
+	testLock(t, tx, docID, editorID)
+	if err := moveDocTx(tx, docID, ownerID, recipientID, true); !errors.Is(err, ErrDocLocked) {
+		t.Fatalf("move locked doc: %v, want ErrDocLocked", err)
+	}
+	// Передача всех документов пользователя блокировки не учитывает
+	if err := moveDocTx(tx, docID, ownerID, recipientID, false); err != nil {
+		t.Fatalf("move locked doc ignoring locks: %v", err)
+	}
+}
+
+// Изменение документа проверяет блокировку так же, как удаление.
+func TestUpdateLockedDoc(t *testing.T) {
+	tx, ownerID := testTx(t, testDB(t))
+	editorID := testUser(t, tx)
+
+	docID := testDoc(t, tx, ownerID)
+	testLock(t, tx, docID, editorID)
+	public := true
+	update := entity.UpdateDocRequest{JSON: entity.JSONB{"status": "draft"}, Public: &public}
+	if err := updateDocTx(tx, docID, ownerID, update); !errors.Is(err, ErrDocLocked) {
+		t.Fatalf("update doc locked by other user: %v, want ErrDocLocked", err)
+	}
+
+	if err := updateDocTx(tx, docID, editorID, update); err != nil {
+		t.Fatalf("update doc by lock owner: %v", err)
+	}
+	var isPublic bool
+	if err := tx.Get(&isPublic, "SELECT is_public FROM documents WHERE id = $1", docID); err != nil || !isPublic {
+		t.Fatalf("is_public after update = %t, %v", isPublic, err)
+	}
+
+	if err := updateDocTx(tx, uuid.New(), ownerID, update); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("update unknown doc: %v, want sql.ErrNoRows", err)
+	}
+}
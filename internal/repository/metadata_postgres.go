@@ -0,0 +1,34 @@
+package repository
+
+import (
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/olenka-91/DocsServer/internal/entity"
+)
+
+type MetadataPostgres struct {
+	db *sqlx.DB
+}
+
+func NewMetadataPostgres(db *sqlx.DB) *MetadataPostgres {
+	return &MetadataPostgres{db: db}
+}
+
+// GetDocsWithoutMetadata возвращает документы с файлом, из которого метаданные еще не
+// извлекались: проверенные антивирусом или загруженные до появления проверки.
+func (r *MetadataPostgres) GetDocsWithoutMetadata(limit int) ([]entity.Document, error) {
+	var docs []entity.Document
+	query := `SELECT id, filename, mime, has_file, COALESCE(size, 0) AS size,
+				COALESCE(enc_key_id, '') AS enc_key_id, enc_data_key,
+				COALESCE(content_encoding, '') AS content_encoding
+				FROM documents WHERE has_file AND extracted_metadata IS NULL
+				AND COALESCE(scan_status, '') IN ('', 'clean')
+				ORDER BY created_at LIMIT $1`
+	err := r.db.Select(&docs, query, limit)
+	return docs, err
+}
+
+func (r *MetadataPostgres) SetDocMetadata(id uuid.UUID, metadata entity.JSONB) error {
+	_, err := r.db.Exec(`UPDATE documents SET extracted_metadata=$2 WHERE id=$1`, id, metadata)
+	return err
+}
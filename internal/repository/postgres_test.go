@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+)
+
+// testDB подключается к тестовой базе из TEST_DATABASE_URL и применяет миграции.
+// Без переменной тесты репозитория пропускаются.
+func testDB(t *testing.T) *sqlx.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	db, err := sqlx.Connect("postgres", dsn)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	driver, err := postgres.WithInstance(db.DB, &postgres.Config{})
+	if err != nil {
+		t.Fatalf("migrate driver: %v", err)
+	}
+	m, err := migrate.NewWithDatabaseInstance("file://../../migrations", "postgres", driver)
+	if err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		t.Fatalf("migrate up: %v", err)
+	}
+	return db
+}
+
+// testTx открывает транзакцию, которая откатывается после теста, и создает в ней пользователя.
+func testTx(t *testing.T, db *sqlx.DB) (*sqlx.Tx, uuid.UUID) {
+	t.Helper()
+	tx, err := db.Beginx()
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	t.Cleanup(func() { tx.Rollback() })
+
+	userID := uuid.New()
+	if _, err := tx.Exec("INSERT INTO users (id, login, password) VALUES ($1, $2, 'x')",
+		userID, "test-"+userID.String()); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return tx, userID
+}
@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/olenka-91/DocsServer/internal/entity"
+)
+
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// addUsageTx изменяет учет места без проверки квоты - при удалении и передаче документов.
+//
+// Параметры рядом с целыми литералами Postgres считал бы integer, и размеры больше 2 ГиБ
+// не проходили бы - поэтому тип указан явно.
+func addUsageTx(tx execer, userID uuid.UUID, bytes, docs int64) error {
+	query := `INSERT INTO user_usage (user_id, bytes_used, docs_count)
+				VALUES ($1, GREATEST($2::bigint, 0), GREATEST($3::bigint, 0))
+				ON CONFLICT (user_id) DO UPDATE SET
+					bytes_used = GREATEST(user_usage.bytes_used + $2::bigint, 0),
+					docs_count = GREATEST(user_usage.docs_count + $3::bigint, 0),
+					updated_at = NOW()`
+	_, err := tx.Exec(query, userID, bytes, docs)
+	return err
+}
+
+// reserveUsageTx учитывает новый документ, если он помещается в квоту. Строка
+// user_usage блокируется до конца транзакции, так что параллельные загрузки
+// одного пользователя не превысят квоту вместе.
+func reserveUsageTx(tx execer, userID uuid.UUID, bytes int64, quota entity.Quota) error {
+	if _, err := tx.Exec(`INSERT INTO user_usage (user_id) VALUES ($1) ON CONFLICT (user_id) DO NOTHING`, userID); err != nil {
+		return err
+	}
+
+	query := `UPDATE user_usage SET bytes_used = bytes_used + $2::bigint, docs_count = docs_count + 1, updated_at = NOW()
+				WHERE user_id = $1
+					AND ($3::bigint = 0 OR bytes_used + $2::bigint <= $3::bigint)
+					AND ($4::bigint = 0 OR docs_count + 1 <= $4::bigint)`
+	result, err := tx.Exec(query, userID, bytes, quota.MaxBytes, quota.MaxDocs)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+type QuotaPostgres struct {
+	db *sqlx.DB
+}
+
+func NewQuotaPostgres(db *sqlx.DB) *QuotaPostgres {
+	return &QuotaPostgres{db: db}
+}
+
+func (r *QuotaPostgres) GetUsage(userID uuid.UUID) (*entity.Usage, error) {
+	var usage entity.Usage
+	err := r.db.Get(&usage, "SELECT bytes_used, docs_count, updated_at FROM user_usage WHERE user_id=$1", userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &entity.Usage{}, nil
+	}
+	return &usage, err
+}
+
+func (r *QuotaPostgres) GetUserQuota(userID uuid.UUID) (*entity.UserQuota, error) {
+	var quota entity.UserQuota
+	err := r.db.Get(&quota, "SELECT quota_bytes, quota_docs, max_file_size FROM users WHERE id=$1", userID)
+	return &quota, err
+}
+
+func (r *QuotaPostgres) SetUserQuota(login string, quota entity.UserQuota) (uuid.UUID, error) {
+	var id uuid.UUID
+	err := r.db.Get(&id, "UPDATE users SET quota_bytes=$2, quota_docs=$3, max_file_size=$4 WHERE login=$1 RETURNING id",
+		login, quota.MaxBytes, quota.MaxDocs, quota.MaxFileSize)
+	return id, err
+}
+
+// GetDocsWithoutSize возвращает документы с файлом, загруженные до учета размера.
+func (r *QuotaPostgres) GetDocsWithoutSize() ([]entity.Document, error) {
+	var docs []entity.Document
+	query := `SELECT id, filename, COALESCE(enc_key_id, '') AS enc_key_id, enc_data_key,
+				COALESCE(content_encoding, '') AS content_encoding
+				FROM documents WHERE has_file AND size IS NULL ORDER BY id`
+	err := r.db.Select(&docs, query)
+	return docs, err
+}
+
+func (r *QuotaPostgres) SetDocSize(docID uuid.UUID, size int64) (bool, error) {
+	result, err := r.db.Exec("UPDATE documents SET size=$2 WHERE id=$1 AND size IS NULL", docID, size)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// RecomputeUsage пересчитывает учет по таблице documents. Таблица user_usage
+// блокируется, чтобы загрузки и удаления, идущие параллельно, применились поверх
+// пересчитанных значений, а не потерялись.
+func (r *QuotaPostgres) RecomputeUsage() (int64, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("LOCK TABLE user_usage IN EXCLUSIVE MODE"); err != nil {
+		return 0, err
+	}
+
+	query := `INSERT INTO user_usage (user_id, bytes_used, docs_count, updated_at)
+				SELECT u.id, COALESCE(SUM(COALESCE(d.size, 0) + COALESCE(d.original_size, 0)), 0), COUNT(d.id), NOW()
+				FROM users u LEFT JOIN documents d ON d.user_id = u.id
+				GROUP BY u.id
+				ON CONFLICT (user_id) DO UPDATE SET
+					bytes_used = EXCLUDED.bytes_used,
+					docs_count = EXCLUDED.docs_count,
+					updated_at = EXCLUDED.updated_at`
+	result, err := tx.Exec(query)
+	if err != nil {
+		return 0, err
+	}
+	users, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return users, tx.Commit()
+}
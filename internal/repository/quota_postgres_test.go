@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/olenka-91/DocsServer/internal/entity"
+)
+
+func TestUsageAbove2GiB(t *testing.T) {
+	db := testDB(t)
+	tx, userID := testTx(t, db)
+
+	const gib = int64(1) << 30
+	quota := entity.Quota{MaxBytes: 5 * gib, MaxDocs: 3_000_000_000}
+
+	if err := reserveUsageTx(tx, userID, 3*gib, quota); err != nil {
+		t.Fatalf("reserve 3 GiB: %v", err)
+	}
+	if err := reserveUsageTx(tx, userID, 3*gib, quota); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("reserve over quota: got %v, want ErrQuotaExceeded", err)
+	}
+	if err := addUsageTx(tx, userID, 2*gib+1, 1); err != nil {
+		t.Fatalf("add 2 GiB: %v", err)
+	}
+
+	var usage entity.Usage
+	if err := tx.Get(&usage, "SELECT bytes_used, docs_count, updated_at FROM user_usage WHERE user_id=$1", userID); err != nil {
+		t.Fatalf("get usage: %v", err)
+	}
+	if usage.BytesUsed != 5*gib+1 || usage.DocsCount != 2 {
+		t.Fatalf("usage = %d bytes, %d docs; want %d bytes, 2 docs", usage.BytesUsed, usage.DocsCount, 5*gib+1)
+	}
+
+	if err := addUsageTx(tx, userID, -(5*gib + 1), -2); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	if err := tx.Get(&usage, "SELECT bytes_used, docs_count, updated_at FROM user_usage WHERE user_id=$1", userID); err != nil {
+		t.Fatalf("get usage: %v", err)
+	}
+	if usage.BytesUsed != 0 || usage.DocsCount != 0 {
+		t.Fatalf("usage after release = %d bytes, %d docs; want 0", usage.BytesUsed, usage.DocsCount)
+	}
+}
+
+// PUT квоты заменяет ее целиком: поле, не переданное при следующем обновлении, сбрасывается.
+func TestSetUserQuota(t *testing.T) {
+	db := testDB(t)
+	r := NewQuotaPostgres(db)
+
+	userID := uuid.New()
+	login := "test-" + userID.String()
+	if _, err := db.Exec("INSERT INTO users (id, login, password) VALUES ($1, $2, 'x')", userID, login); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	t.Cleanup(func() { db.Exec("DELETE FROM users WHERE id = $1", userID) })
+
+	maxBytes, maxFileSize := int64(1<<30), int64(50<<20)
+	id, err := r.SetUserQuota(login, entity.UserQuota{MaxBytes: &maxBytes, MaxFileSize: &maxFileSize})
+	if err != nil || id != userID {
+		t.Fatalf("SetUserQuota = %s, %v", id, err)
+	}
+	quota, err := r.GetUserQuota(userID)
+	if err != nil {
+		t.Fatalf("GetUserQuota: %v", err)
+	}
+	if quota.MaxBytes == nil || *quota.MaxBytes != maxBytes || quota.MaxDocs != nil ||
+		quota.MaxFileSize == nil || *quota.MaxFileSize != maxFileSize {
+		t.Fatalf("quota = %+v", quota)
+	}
+
+	if _, err := r.SetUserQuota(login, entity.UserQuota{MaxBytes: &maxBytes}); err != nil {
+		t.Fatalf("SetUserQuota without max_file_size: %v", err)
+	}
+	if quota, err = r.GetUserQuota(userID); err != nil || quota.MaxFileSize != nil {
+		t.Fatalf("max_file_size after update without it = %v, %v; want NULL", quota.MaxFileSize, err)
+	}
+
+	if _, err := r.SetUserQuota("test-"+uuid.NewString(), entity.UserQuota{}); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("SetUserQuota for unknown login: %v, want sql.ErrNoRows", err)
+	}
+}
@@ -1,6 +1,8 @@
 package repository
 
 import (
+	"time"
+
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
@@ -13,23 +15,186 @@ type Authorization interface {
 	GetUserByLogin(login string) (*entity.User, error)
 	GetUserByID(id uuid.UUID) (*entity.User, error)
 	UpdateUserToken(uuid uuid.UUID, token string) error
+	UpdateUserPassword(id uuid.UUID, password string, validAfter time.Time) error
+	GetPasswordHistory(id uuid.UUID, limit int) ([]string, error)
+	CreatePasswordReset(userID uuid.UUID, tokenHash string, expiresAt time.Time) error
+	GetPasswordResetUser(tokenHash string) (uuid.UUID, error)
+	ResetPassword(tokenHash, password string, validAfter time.Time) (uuid.UUID, error)
+	SetUserDisabled(login string, disabled bool) (uuid.UUID, error)
+}
+
+type Revocation interface {
+	RevokeToken(jti, userID uuid.UUID, expiresAt time.Time) error
+	RevokeUserTokens(userID uuid.UUID, validAfter time.Time) error
+	GetRevokedTokensSince(since time.Time) ([]entity.RevokedToken, error)
+	GetUserCutoffsSince(since time.Time) ([]entity.UserTokenCutoff, error)
+	DeleteExpiredTokens() (int64, error)
 }
 
 type Docs interface {
 	GetDocsList(ctx *gin.Context, s entity.LimitedDocsListInput) ([]entity.Document, error)
 	GetDoc(ctx *gin.Context, docID uuid.UUID) (*entity.Document, error)
-	CreateDocument(ctx *gin.Context, doc *entity.Document) error
-	DeleteDoc(ctx *gin.Context, docID uuid.UUID) (bool, error)
+	CreateDocument(ctx *gin.Context, doc *entity.Document, quota entity.Quota) error
+	CheckGroupGrants(ctx *gin.Context, userID uuid.UUID, groups []string) error
+	DeleteDoc(ctx *gin.Context, docID, userID uuid.UUID) (bool, error)
+	UpdateDoc(ctx *gin.Context, docID, userID uuid.UUID, input entity.UpdateDocRequest) error
 	GetLoginByUserID(ctx *gin.Context, userID uuid.UUID) string
 	GetUserIDByLogin(ctx *gin.Context, login string) uuid.UUID
+	HasAccess(ctx *gin.Context, docID uuid.UUID, userID uuid.UUID) (bool, error)
+	UpdateDocChecksum(ctx *gin.Context, docID uuid.UUID, checksum string, size int64) error
+	CreatePendingUpload(ctx *gin.Context, docID uuid.UUID, key string) error
+	CancelPendingUpload(ctx *gin.Context, docID uuid.UUID) error
+}
+
+type Users interface {
+	GetProfile(id uuid.UUID) (*entity.UserProfile, error)
+	UpdateProfile(id uuid.UUID, input entity.UpdateProfileRequest) error
+	GetOwnedDocs(id uuid.UUID) ([]entity.Document, error)
+	DeleteUser(id uuid.UUID, validAfter time.Time) error
+	TransferDocsAndDeleteUser(id, newOwnerID uuid.UUID, validAfter time.Time) error
+}
+
+type Groups interface {
+	CreateGroup(name string, ownerID uuid.UUID) (*entity.Group, error)
+	GetUserGroups(userID uuid.UUID) ([]entity.Group, error)
+	GetGroup(groupID uuid.UUID) (*entity.Group, error)
+	GetMembers(groupID uuid.UUID) ([]entity.GroupMember, error)
+	GetMembership(groupID, userID uuid.UUID) (bool, bool, error)
+	AddMember(groupID, userID uuid.UUID, isOwner bool) error
+	RemoveMember(groupID, userID uuid.UUID) error
+	DeleteGroup(groupID uuid.UUID) error
+}
+
+type Transfers interface {
+	TransferDoc(docID, fromID, toID uuid.UUID, initiatedBy string) error
+	TransferAllDocs(fromID, toID uuid.UUID, initiatedBy string) (int64, error)
+	CreatePendingTransfer(docID, fromID, toID uuid.UUID) (*entity.DocumentTransfer, error)
+	GetTransfer(id uuid.UUID) (*entity.DocumentTransfer, error)
+	GetIncomingTransfers(userID uuid.UUID) ([]entity.DocumentTransfer, error)
+	GetDocTransfers(docID uuid.UUID) ([]entity.DocumentTransfer, error)
+	AcceptTransfer(id uuid.UUID) error
+	ResolveTransfer(id uuid.UUID, status string) error
+}
+
+type Integrity interface {
+	GetFileDocs() ([]entity.Document, error)
+	DocExists(id uuid.UUID) (bool, error)
+	SetDocChecksum(id uuid.UUID, checksum string, size int64) error
+	GetPendingUploads() ([]entity.PendingUpload, error)
+	DeletePendingUpload(docID uuid.UUID) error
+	DeleteDocRecord(id uuid.UUID) error
+	GetDocKeysNotWrappedWith(keyID string, after uuid.UUID, limit int) ([]entity.Document, error)
+	UpdateDocKey(id uuid.UUID, oldKeyID, newKeyID string, wrapped []byte) (bool, error)
+	CountDocsByKey() (map[string]int64, error)
+}
+
+type Quotas interface {
+	GetUsage(userID uuid.UUID) (*entity.Usage, error)
+	GetUserQuota(userID uuid.UUID) (*entity.UserQuota, error)
+	SetUserQuota(login string, quota entity.UserQuota) (uuid.UUID, error)
+	GetDocsWithoutSize() ([]entity.Document, error)
+	SetDocSize(docID uuid.UUID, size int64) (bool, error)
+	RecomputeUsage() (int64, error)
+}
+
+type UploadPolicies interface {
+	GetUserMimePolicy(userID uuid.UUID) (*entity.MimePolicy, error)
+	SetUserMimePolicy(login string, policy entity.MimePolicy) (uuid.UUID, error)
+	GetUserScrubPolicy(userID uuid.UUID) (*entity.ScrubPolicy, error)
+	SetUserScrubPolicy(login string, policy entity.ScrubPolicy) (uuid.UUID, error)
+}
+
+type Scans interface {
+	GetPendingScans(limit int) ([]entity.Document, error)
+	SetScanResult(id uuid.UUID, status, signature string) error
+	GetScanResult(id uuid.UUID) (*entity.ScanResult, error)
+	RequeueScan(id uuid.UUID) (bool, error)
+	RequeueScans(statuses []string) (int64, error)
+}
+
+type Metadata interface {
+	GetDocsWithoutMetadata(limit int) ([]entity.Document, error)
+	SetDocMetadata(id uuid.UUID, metadata entity.JSONB) error
+}
+
+type Events interface {
+	GetEvents(userID uuid.UUID, after entity.EventCursor, limit int) ([]entity.DocEvent, error)
+	GetEventCursor(id int64) (entity.EventCursor, error)
+	GetEventsHead() (entity.EventCursor, error)
+	GetEventsWatermark() (uint64, error)
+	DeleteEventsBefore(t time.Time) (int64, error)
+}
+
+type Webhooks interface {
+	CreateWebhook(w *entity.Webhook) error
+	GetWebhooks(userID *uuid.UUID) ([]entity.Webhook, error)
+	GetWebhook(id uuid.UUID) (*entity.Webhook, error)
+	DeleteWebhook(id uuid.UUID) error
+	GetDeliveries(webhookID uuid.UUID, limit int) ([]entity.WebhookDelivery, error)
+	ClaimDeliveries(limit int, lease time.Duration) ([]entity.WebhookDelivery, error)
+	MarkDelivered(id int64, statusCode int) error
+	MarkFailed(id int64, statusCode int, errText string, next time.Time, dead bool) error
+	Redeliver(webhookID uuid.UUID, id int64) (*entity.WebhookDelivery, error)
+}
+
+type Audit interface {
+	InsertAuditEntry(e *entity.AuditEntry) error
+	GetAuditEntries(f entity.AuditFilter) ([]entity.AuditEntry, error)
+	ExportAuditEntries(f entity.AuditFilter, fn func(*entity.AuditEntry) error) error
+}
+
+type Retention interface {
+	CreateRetentionPolicy(p *entity.RetentionPolicy) error
+	GetRetentionPolicies() ([]entity.RetentionPolicy, error)
+	DeleteRetentionPolicy(id uuid.UUID) (bool, error)
+	CreateLegalHold(h *entity.LegalHold) error
+	GetLegalHolds(docID uuid.UUID) ([]entity.LegalHold, error)
+	ReleaseLegalHold(docID, id uuid.UUID) (*entity.LegalHold, error)
+	GetExpiredDocs(limit int) ([]entity.Document, error)
+	ExpireDoc(id uuid.UUID) (bool, error)
+}
+
+type Locks interface {
+	AcquireLock(docID, userID uuid.UUID, ttl time.Duration) (*entity.DocumentLock, error)
+	GetLock(docID uuid.UUID) (*entity.DocumentLock, error)
+	ReleaseLock(docID uuid.UUID, userID *uuid.UUID) (*entity.DocumentLock, error)
+	DeleteExpiredLocks() (int64, error)
 }
 
 type Repository struct {
 	Docs
 	Authorization
+	Revocation
+	Users
+	Groups
+	Transfers
+	Integrity
+	Quotas
+	UploadPolicies
+	Scans
+	Metadata
+	Events
+	Webhooks
+	Audit
+	Retention
+	Locks
 }
 
 func NewRepository(db *sqlx.DB) *Repository {
 	return &Repository{Docs: NewDocsPostgres(db),
-		Authorization: NewAuthPostgres(db)}
+		Authorization:  NewAuthPostgres(db),
+		Revocation:     NewRevocationPostgres(db),
+		Users:          NewUsersPostgres(db),
+		Groups:         NewGroupsPostgres(db),
+		Transfers:      NewTransfersPostgres(db),
+		Integrity:      NewIntegrityPostgres(db),
+		Quotas:         NewQuotaPostgres(db),
+		UploadPolicies: NewUploadPolicyPostgres(db),
+		Scans:          NewScanPostgres(db),
+		Metadata:       NewMetadataPostgres(db),
+		Events:         NewEventsPostgres(db),
+		Webhooks:       NewWebhooksPostgres(db),
+		Audit:          NewAuditPostgres(db),
+		Retention:      NewRetentionPostgres(db),
+		Locks:          NewLocksPostgres(db)}
 }
@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/olenka-91/DocsServer/internal/entity"
+)
+
+type RetentionPostgres struct {
+	db *sqlx.DB
+}
+
+func NewRetentionPostgres(db *sqlx.DB) *RetentionPostgres {
+	return &RetentionPostgres{db: db}
+}
+
+func (r *RetentionPostgres) CreateRetentionPolicy(p *entity.RetentionPolicy) error {
+	query := `INSERT INTO retention_policies (id, name, mime, years, days, locked) VALUES ($1, $2, $3, $4, $5, $6)
+				RETURNING created_at`
+	return r.db.QueryRow(query, p.ID, p.Name, p.Mime, p.Years, p.Days, p.Locked).Scan(&p.Created)
+}
+
+func (r *RetentionPostgres) GetRetentionPolicies() ([]entity.RetentionPolicy, error) {
+	policies := make([]entity.RetentionPolicy, 0)
+	err := r.db.Select(&policies, `SELECT id, name, mime, years, days, locked, created_at
+				FROM retention_policies ORDER BY created_at`)
+	return policies, err
+}
+
+func (r *RetentionPostgres) DeleteRetentionPolicy(id uuid.UUID) (bool, error) {
+	result, err := r.db.Exec("DELETE FROM retention_policies WHERE id=$1", id)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+func (r *RetentionPostgres) CreateLegalHold(h *entity.LegalHold) error {
+	query := `INSERT INTO legal_holds (id, doc_id, reason, created_by) VALUES ($1, $2, $3, $4)
+				RETURNING created_at`
+	return r.db.QueryRow(query, h.ID, h.DocID, h.Reason, h.CreatedBy).Scan(&h.Created)
+}
+
+func (r *RetentionPostgres) GetLegalHolds(docID uuid.UUID) ([]entity.LegalHold, error) {
+	holds := make([]entity.LegalHold, 0)
+	err := r.db.Select(&holds, `SELECT id, doc_id, reason, created_by, created_at
+				FROM legal_holds WHERE doc_id=$1 ORDER BY created_at`, docID)
+	return holds, err
+}
+
+// ReleaseLegalHold снимает блокировку и возвращает ее; sql.ErrNoRows - такой блокировки нет.
+func (r *RetentionPostgres) ReleaseLegalHold(docID, id uuid.UUID) (*entity.LegalHold, error) {
+	var h entity.LegalHold
+	err := r.db.Get(&h, `DELETE FROM legal_holds WHERE doc_id=$1 AND id=$2
+				RETURNING id, doc_id, reason, created_by, created_at`, docID, id)
+	if err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+// GetExpiredDocs возвращает документы с истекшим сроком хранения, кроме заблокированных.
+// Блокировка на время редактирования удалению по сроку не мешает.
+func (r *RetentionPostgres) GetExpiredDocs(limit int) ([]entity.Document, error) {
+	docs := make([]entity.Document, 0)
+	err := r.db.Select(&docs, `SELECT d.id, d.user_id, d.filename, d.has_file, d.expires_at FROM documents d
+				WHERE d.expires_at <= NOW() AND NOT `+docProtected+`
+				ORDER BY d.expires_at LIMIT $1`, limit)
+	return docs, err
+}
+
+// ExpireDoc удаляет документ, если срок его хранения истек и он не заблокирован. Блокировка
+// на время редактирования удаляется вместе с документом.
+func (r *RetentionPostgres) ExpireDoc(id uuid.UUID) (bool, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	err = deleteDocTx(tx, id, "d.expires_at <= NOW()")
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, ErrDocRetained) {
+		// Срок продлен или документ заблокирован после выборки
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
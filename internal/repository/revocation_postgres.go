@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/olenka-91/DocsServer/internal/entity"
+)
+
+type RevocationPostgres struct {
+	db *sqlx.DB
+}
+
+func NewRevocationPostgres(db *sqlx.DB) *RevocationPostgres {
+	return &RevocationPostgres{db: db}
+}
+
+func (r *RevocationPostgres) RevokeToken(jti, userID uuid.UUID, expiresAt time.Time) error {
+	query := `INSERT INTO revoked_tokens (jti, user_id, expires_at) VALUES ($1, $2, $3)
+				ON CONFLICT (jti) DO NOTHING`
+	_, err := r.db.Exec(query, jti, userID, expiresAt)
+	return err
+}
+
+// revokeUserTokensTx отзывает все токены пользователя, выпущенные до validAfter. Отметка
+// хранится отдельно от users и переживает удаление пользователя.
+func revokeUserTokensTx(tx execer, userID uuid.UUID, validAfter time.Time) error {
+	query := `INSERT INTO user_token_cutoffs (user_id, valid_after) VALUES ($1, $2)
+				ON CONFLICT (user_id) DO UPDATE SET valid_after = EXCLUDED.valid_after`
+	if _, err := tx.Exec(query, userID, validAfter); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec("UPDATE users SET token='' WHERE id=$1", userID)
+	return err
+}
+
+func (r *RevocationPostgres) RevokeUserTokens(userID uuid.UUID, validAfter time.Time) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := revokeUserTokensTx(tx, userID, validAfter); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *RevocationPostgres) GetRevokedTokensSince(since time.Time) ([]entity.RevokedToken, error) {
+	var tokens []entity.RevokedToken
+	query := "SELECT jti, user_id, expires_at FROM revoked_tokens WHERE created_at >= $1 AND expires_at > now()"
+	err := r.db.Select(&tokens, query, since)
+
+	return tokens, err
+}
+
+func (r *RevocationPostgres) GetUserCutoffsSince(since time.Time) ([]entity.UserTokenCutoff, error) {
+	var cutoffs []entity.UserTokenCutoff
+	query := "SELECT user_id, valid_after FROM user_token_cutoffs WHERE valid_after >= $1"
+	err := r.db.Select(&cutoffs, query, since)
+
+	return cutoffs, err
+}
+
+func (r *RevocationPostgres) DeleteExpiredTokens() (int64, error) {
+	result, err := r.db.Exec("DELETE FROM revoked_tokens WHERE expires_at <= now()")
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
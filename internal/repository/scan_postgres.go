@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/olenka-91/DocsServer/internal/entity"
+)
+
+type ScanPostgres struct {
+	db *sqlx.DB
+}
+
+func NewScanPostgres(db *sqlx.DB) *ScanPostgres {
+	return &ScanPostgres{db: db}
+}
+
+// GetPendingScans возвращает документы, ожидающие проверки, начиная с самых старых.
+func (r *ScanPostgres) GetPendingScans(limit int) ([]entity.Document, error) {
+	var docs []entity.Document
+	query := `SELECT id, filename, mime, has_file, COALESCE(size, 0) AS size,
+				COALESCE(enc_key_id, '') AS enc_key_id, enc_data_key,
+				COALESCE(content_encoding, '') AS content_encoding
+				FROM documents WHERE scan_status = 'pending' ORDER BY created_at LIMIT $1`
+	err := r.db.Select(&docs, query, limit)
+	return docs, err
+}
+
+// SetScanResult сохраняет результат, только если документ все еще ждет проверки.
+func (r *ScanPostgres) SetScanResult(id uuid.UUID, status, signature string) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE documents SET scan_status=$2, scan_signature=NULLIF($3, ''), scanned_at=NOW()
+				WHERE id=$1 AND scan_status='pending'`
+	result, err := tx.Exec(query, id, status, signature)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return err
+	}
+
+	if err := recordDocEventsTx(tx, entity.EventUpdated, uuid.Nil, "d.id = $3", id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *ScanPostgres) GetScanResult(id uuid.UUID) (*entity.ScanResult, error) {
+	var result entity.ScanResult
+	query := `SELECT id, COALESCE(scan_status, '') AS scan_status,
+				COALESCE(scan_signature, '') AS scan_signature, scanned_at
+				FROM documents WHERE id=$1 AND has_file`
+	err := r.db.Get(&result, query, id)
+	return &result, err
+}
+
+func (r *ScanPostgres) RequeueScan(id uuid.UUID) (bool, error) {
+	result, err := r.db.Exec("UPDATE documents SET scan_status='pending' WHERE id=$1 AND has_file", id)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// RequeueScans отправляет на повторную проверку документы с указанными статусами;
+// пустая строка в statuses - документы, не проверявшиеся ни разу.
+func (r *ScanPostgres) RequeueScans(statuses []string) (int64, error) {
+	query := `UPDATE documents SET scan_status='pending'
+				WHERE has_file AND COALESCE(scan_status, '') = ANY($1) AND COALESCE(scan_status, '') <> 'pending'`
+	result, err := r.db.Exec(query, pq.Array(statuses))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,250 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/olenka-91/DocsServer/internal/entity"
+)
+
+var ErrOwnerChanged = errors.New("document owner has changed")
+
+type TransfersPostgres struct {
+	db *sqlx.DB
+}
+
+func NewTransfersPostgres(db *sqlx.DB) *TransfersPostgres {
+	return &TransfersPostgres{db: db}
+}
+
+const transferColumns = `t.id, t.doc_id, COALESCE(d.filename, '') AS filename, t.from_user_id, t.to_user_id,
+	t.from_login, t.to_login, t.initiated_by, t.status, t.created_at, t.resolved_at`
+
+// moveDocTx меняет владельца документа и переносит личный доступ. Запись в журнал передач
+// добавляет вызывающий: новую или закрывая принятую заявку. Если документ уже не принадлежит fromID, возвращает ErrOwnerChanged. При honorLock документ,
+// заблокированный не владельцем, не передается - ErrDocLocked.
+func moveDocTx(tx *sqlx.Tx, docID, fromID, toID uuid.UUID, honorLock bool) error {
+	query := "UPDATE documents d SET user_id=$1 WHERE d.id=$2 AND d.user_id=$3"
+	if honorLock {
+		query += " AND NOT " + docLockedByOther("$3")
+	}
+	var size int64
+	err := tx.Get(&size, query+" RETURNING COALESCE(d.size, 0) + COALESCE(d.original_size, 0)", toID, docID, fromID)
+	if errors.Is(err, sql.ErrNoRows) {
+		if honorLock {
+			locked, err := lockedByOtherTx(tx, docID, fromID)
+			if err != nil {
+				return err
+			}
+			if locked {
+				return ErrDocLocked
+			}
+		}
+		return ErrOwnerChanged
+	}
+	if err != nil {
+		return err
+	}
+
+	// Занятое место переходит к новому владельцу без проверки его квоты
+	if err := addUsageTx(tx, fromID, -size, -1); err != nil {
+		return err
+	}
+	if err := addUsageTx(tx, toID, size, 1); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM document_grants WHERE doc_id=$1 AND user_id=$2", docID, fromID); err != nil {
+		return err
+	}
+
+	// Владелец всегда присутствует в document_grants, как при создании документа
+	query = `INSERT INTO document_grants (doc_id, user_id) VALUES ($1, $2)
+				ON CONFLICT (doc_id, user_id) DO NOTHING`
+	if _, err := tx.Exec(query, docID, toID); err != nil {
+		return err
+	}
+
+	// Прежний владелец тоже узнает, что документ у него больше не в собственности
+	return recordDocEventsTx(tx, entity.EventUpdated, fromID, "d.id = $3", docID)
+}
+
+// recordTransferTx добавляет в журнал завершенную передачу документа.
+func recordTransferTx(tx *sqlx.Tx, docID, fromID, toID uuid.UUID, initiatedBy string) error {
+	query := `INSERT INTO document_transfers
+				(id, doc_id, from_user_id, to_user_id, from_login, to_login, initiated_by, status, resolved_at)
+				VALUES ($1, $2, $3, $4,
+					(SELECT login FROM users WHERE id=$3),
+					(SELECT login FROM users WHERE id=$4),
+					$5, $6, now())`
+	_, err := tx.Exec(query, uuid.New(), docID, fromID, toID, initiatedBy, entity.TransferCompleted)
+	return err
+}
+
+// moveAllDocsTx передает все документы fromID пользователю toID. Блокировки не мешают
+// передаче: так же, как удаление учетной записи, она выполняется для всех документов.
+func moveAllDocsTx(tx *sqlx.Tx, fromID, toID uuid.UUID, initiatedBy string) (int64, error) {
+	var docIDs []uuid.UUID
+	if err := tx.Select(&docIDs, "SELECT id FROM documents WHERE user_id=$1 FOR UPDATE", fromID); err != nil {
+		return 0, err
+	}
+
+	for _, docID := range docIDs {
+		if err := moveDocTx(tx, docID, fromID, toID, false); err != nil {
+			return 0, err
+		}
+		if err := recordTransferTx(tx, docID, fromID, toID, initiatedBy); err != nil {
+			return 0, err
+		}
+	}
+	return int64(len(docIDs)), nil
+}
+
+func (r *TransfersPostgres) TransferDoc(docID, fromID, toID uuid.UUID, initiatedBy string) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := moveDocTx(tx, docID, fromID, toID, true); err != nil {
+		return err
+	}
+	if err := recordTransferTx(tx, docID, fromID, toID, initiatedBy); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *TransfersPostgres) TransferAllDocs(fromID, toID uuid.UUID, initiatedBy string) (int64, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	n, err := moveAllDocsTx(tx, fromID, toID, initiatedBy)
+	if err != nil {
+		return 0, err
+	}
+	return n, tx.Commit()
+}
+
+// CreatePendingTransfer создает заявку на передачу. Документ, заблокированный не владельцем,
+// передать нельзя - ErrDocLocked.
+func (r *TransfersPostgres) CreatePendingTransfer(docID, fromID, toID uuid.UUID) (*entity.DocumentTransfer, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	id := uuid.New()
+	query := `INSERT INTO document_transfers
+				(id, doc_id, from_user_id, to_user_id, from_login, to_login, initiated_by, status)
+				SELECT $1, d.id, $3, $4,
+					(SELECT login FROM users WHERE id=$3),
+					(SELECT login FROM users WHERE id=$4),
+					(SELECT login FROM users WHERE id=$3), $5
+				FROM documents d WHERE d.id=$2 AND d.user_id=$3 AND NOT ` + docLockedByOther("$3")
+	result, err := tx.Exec(query, id, docID, fromID, toID, entity.TransferPending)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		locked, err := lockedByOtherTx(tx, docID, fromID)
+		if err != nil {
+			return nil, err
+		}
+		if locked {
+			return nil, ErrDocLocked
+		}
+		return nil, ErrOwnerChanged
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return r.GetTransfer(id)
+}
+
+func (r *TransfersPostgres) GetTransfer(id uuid.UUID) (*entity.DocumentTransfer, error) {
+	var t entity.DocumentTransfer
+	query := "SELECT " + transferColumns + ` FROM document_transfers t
+				LEFT JOIN documents d ON d.id = t.doc_id
+				WHERE t.id=$1`
+	err := r.db.Get(&t, query, id)
+
+	return &t, err
+}
+
+func (r *TransfersPostgres) GetIncomingTransfers(userID uuid.UUID) ([]entity.DocumentTransfer, error) {
+	transfers := make([]entity.DocumentTransfer, 0)
+	query := "SELECT " + transferColumns + ` FROM document_transfers t
+				JOIN documents d ON d.id = t.doc_id
+				WHERE t.to_user_id=$1 AND t.status=$2
+				ORDER BY t.created_at`
+	err := r.db.Select(&transfers, query, userID, entity.TransferPending)
+
+	return transfers, err
+}
+
+func (r *TransfersPostgres) GetDocTransfers(docID uuid.UUID) ([]entity.DocumentTransfer, error) {
+	transfers := make([]entity.DocumentTransfer, 0)
+	query := "SELECT " + transferColumns + ` FROM document_transfers t
+				LEFT JOIN documents d ON d.id = t.doc_id
+				WHERE t.doc_id=$1
+				ORDER BY t.created_at`
+	err := r.db.Select(&transfers, query, docID)
+
+	return transfers, err
+}
+
+// AcceptTransfer выполняет ожидающую передачу от имени получателя.
+func (r *TransfersPostgres) AcceptTransfer(id uuid.UUID) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var t entity.DocumentTransfer
+	query := `SELECT id, doc_id, from_user_id, to_user_id FROM document_transfers
+				WHERE id=$1 AND status=$2 FOR UPDATE`
+	if err := tx.QueryRowx(query, id, entity.TransferPending).StructScan(&t); err != nil {
+		return err
+	}
+	if t.FromUserID == nil || t.ToUserID == nil {
+		return sql.ErrNoRows
+	}
+
+	if err := moveDocTx(tx, t.DocID, *t.FromUserID, *t.ToUserID, true); err != nil {
+		return err
+	}
+
+	// Заявка и есть запись журнала об этой передаче
+	query = "UPDATE document_transfers SET status=$1, resolved_at=now() WHERE id=$2"
+	if _, err := tx.Exec(query, entity.TransferCompleted, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ResolveTransfer закрывает ожидающую передачу без смены владельца (отказ или отмена).
+func (r *TransfersPostgres) ResolveTransfer(id uuid.UUID, status string) error {
+	query := "UPDATE document_transfers SET status=$1, resolved_at=now() WHERE id=$2 AND status=$3"
+	result, err := r.db.Exec(query, status, id, entity.TransferPending)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/olenka-91/DocsServer/internal/entity"
+)
+
+// История передач не дублируется и переживает удаление документа; ожидающая заявка
+// на удаленный документ закрывается.
+func TestTransferHistory(t *testing.T) {
+	tx, ownerID := testTx(t, testDB(t))
+	recipientID := testUser(t, tx)
+	docID := testDoc(t, tx, ownerID)
+
+	if err := moveDocTx(tx, docID, ownerID, recipientID, true); err != nil {
+		t.Fatalf("move: %v", err)
+	}
+	if err := recordTransferTx(tx, docID, ownerID, recipientID, "owner"); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	pendingID := uuid.New()
+	if _, err := tx.Exec(`INSERT INTO document_transfers
+				(id, doc_id, from_user_id, to_user_id, from_login, to_login, initiated_by, status)
+				VALUES ($1, $2, $3, $4, 'a', 'b', 'a', $5)`,
+		pendingID, docID, recipientID, ownerID, entity.TransferPending); err != nil {
+		t.Fatalf("create pending transfer: %v", err)
+	}
+
+	if err := deleteDocTx(tx, docID, "TRUE"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	var statuses []string
+	if err := tx.Select(&statuses, "SELECT status FROM document_transfers WHERE doc_id=$1 ORDER BY status",
+		docID); err != nil {
+		t.Fatalf("history: %v", err)
+	}
+	want := []string{entity.TransferCancelled, entity.TransferCompleted}
+	if len(statuses) != len(want) {
+		t.Fatalf("history = %v, want %v", statuses, want)
+	}
+	for i := range want {
+		if statuses[i] != want[i] {
+			t.Fatalf("history = %v, want %v", statuses, want)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/olenka-91/DocsServer/internal/entity"
+)
+
+type UploadPolicyPostgres struct {
+	db *sqlx.DB
+}
+
+func NewUploadPolicyPostgres(db *sqlx.DB) *UploadPolicyPostgres {
+	return &UploadPolicyPostgres{db: db}
+}
+
+func (r *UploadPolicyPostgres) GetUserMimePolicy(userID uuid.UUID) (*entity.MimePolicy, error) {
+	var policy entity.MimePolicy
+	err := r.db.QueryRow("SELECT mime_allow, mime_deny FROM users WHERE id=$1", userID).
+		Scan(pq.Array(&policy.Allow), pq.Array(&policy.Deny))
+	return &policy, err
+}
+
+// SetUserMimePolicy заменяет списки пользователя; пустые списки сохраняются как NULL.
+func (r *UploadPolicyPostgres) SetUserMimePolicy(login string, policy entity.MimePolicy) (uuid.UUID, error) {
+	var id uuid.UUID
+	query := `UPDATE users SET mime_allow=NULLIF($2::text[], '{}'), mime_deny=NULLIF($3::text[], '{}')
+				WHERE login=$1 RETURNING id`
+	err := r.db.Get(&id, query, login, pq.Array(policy.Allow), pq.Array(policy.Deny))
+	return id, err
+}
+
+func (r *UploadPolicyPostgres) GetUserScrubPolicy(userID uuid.UUID) (*entity.ScrubPolicy, error) {
+	var policy entity.ScrubPolicy
+	err := r.db.QueryRow("SELECT scrub_mode, keep_original FROM users WHERE id=$1", userID).
+		Scan(&policy.Mode, &policy.KeepOriginal)
+	return &policy, err
+}
+
+func (r *UploadPolicyPostgres) SetUserScrubPolicy(login string, policy entity.ScrubPolicy) (uuid.UUID, error) {
+	var id uuid.UUID
+	query := `UPDATE users SET scrub_mode=$2, keep_original=$3 WHERE login=$1 RETURNING id`
+	err := r.db.Get(&id, query, login, policy.Mode, policy.KeepOriginal)
+	return id, err
+}
@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/olenka-91/DocsServer/internal/entity"
+)
+
+type UsersPostgres struct {
+	db *sqlx.DB
+}
+
+func NewUsersPostgres(db *sqlx.DB) *UsersPostgres {
+	return &UsersPostgres{db: db}
+}
+
+func (r *UsersPostgres) GetProfile(id uuid.UUID) (*entity.UserProfile, error) {
+	var profile entity.UserProfile
+	query := `SELECT login, COALESCE(display_name, '') AS display_name, COALESCE(email, '') AS email,
+				preferences, created_at FROM users WHERE id=$1`
+	err := r.db.Get(&profile, query, id)
+
+	return &profile, err
+}
+
+func (r *UsersPostgres) UpdateProfile(id uuid.UUID, input entity.UpdateProfileRequest) error {
+	setValues := make([]string, 0)
+	args := make([]interface{}, 0)
+	argCount := 1
+
+	if input.DisplayName != nil {
+		setValues = append(setValues, fmt.Sprintf("display_name=$%d", argCount))
+		args = append(args, *input.DisplayName)
+		argCount++
+	}
+
+	if input.Email != nil {
+		setValues = append(setValues, fmt.Sprintf("email=NULLIF($%d, '')", argCount))
+		args = append(args, strings.ToLower(*input.Email))
+		argCount++
+	}
+
+	if input.Preferences != nil {
+		setValues = append(setValues, fmt.Sprintf("preferences=$%d", argCount))
+		args = append(args, input.Preferences)
+		argCount++
+	}
+
+	if len(setValues) == 0 {
+		return nil
+	}
+
+	query := fmt.Sprintf("UPDATE users SET %s WHERE id=$%d", strings.Join(setValues, ", "), argCount)
+	args = append(args, id)
+
+	_, err := r.db.Exec(query, args...)
+	return err
+}
+
+func (r *UsersPostgres) GetOwnedDocs(id uuid.UUID) ([]entity.Document, error) {
+	var docs []entity.Document
+	query := `SELECT id, user_id, filename, path, mime, has_file, is_public, created_at, json_data
+				FROM documents WHERE user_id=$1`
+	err := r.db.Select(&docs, query, id)
+
+	return docs, err
+}
+
+// DeleteUser удаляет пользователя; его документы и выданные ему доступы удаляются каскадно.
+// В той же транзакции отзываются токены пользователя, выпущенные до validAfter.
+func (r *UsersPostgres) DeleteUser(id uuid.UUID, validAfter time.Time) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var retained bool
+	query := "SELECT EXISTS (SELECT 1 FROM documents d WHERE d.user_id = $1 AND " + docProtected + ")"
+	if err := tx.Get(&retained, query, id); err != nil {
+		return err
+	}
+	if retained {
+		return ErrDocRetained
+	}
+
+	if err := recordDocEventsTx(tx, entity.EventDeleted, uuid.Nil, "d.user_id = $3", id); err != nil {
+		return err
+	}
+	if err := revokeUserTokensTx(tx, id, validAfter); err != nil {
+		return err
+	}
+
+	result, err := tx.Exec("DELETE FROM users WHERE id=$1", id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("user %s not found", id)
+	}
+	return tx.Commit()
+}
+
+// TransferDocsAndDeleteUser передает все документы пользователя newOwnerID, удаляет
+// пользователя и отзывает его токены, выпущенные до validAfter.
+func (r *UsersPostgres) TransferDocsAndDeleteUser(id, newOwnerID uuid.UUID, validAfter time.Time) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var login string
+	if err := tx.Get(&login, "SELECT login FROM users WHERE id=$1", id); err != nil {
+		return err
+	}
+
+	if _, err := moveAllDocsTx(tx, id, newOwnerID, login); err != nil {
+		return err
+	}
+	if err := revokeUserTokensTx(tx, id, validAfter); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM users WHERE id=$1", id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
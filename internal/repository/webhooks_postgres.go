@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/olenka-91/DocsServer/internal/entity"
+)
+
+type WebhooksPostgres struct {
+	db *sqlx.DB
+}
+
+func NewWebhooksPostgres(db *sqlx.DB) *WebhooksPostgres {
+	return &WebhooksPostgres{db: db}
+}
+
+const deliveryColumns = `dl.id, dl.webhook_id, dl.event_id, dl.event_type, dl.payload, dl.status, dl.attempts,
+	dl.next_attempt_at, dl.last_status_code, dl.last_error, dl.created_at, dl.delivered_at`
+
+func (r *WebhooksPostgres) CreateWebhook(w *entity.Webhook) error {
+	query := `INSERT INTO webhooks (id, user_id, url, secret, events) VALUES ($1, $2, $3, $4, $5)
+				RETURNING created_at`
+	return r.db.QueryRow(query, w.ID, w.UserID, w.URL, w.Secret, pq.Array(w.Events)).Scan(&w.Created)
+}
+
+// GetWebhooks возвращает webhooks пользователя; userID == nil - webhooks администратора.
+func (r *WebhooksPostgres) GetWebhooks(userID *uuid.UUID) ([]entity.Webhook, error) {
+	rows, err := r.db.Query(`SELECT id, user_id, url, events, created_at FROM webhooks
+				WHERE user_id IS NOT DISTINCT FROM $1 ORDER BY created_at`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := make([]entity.Webhook, 0)
+	for rows.Next() {
+		var w entity.Webhook
+		if err := rows.Scan(&w.ID, &w.UserID, &w.URL, pq.Array(&w.Events), &w.Created); err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, w)
+	}
+	return webhooks, rows.Err()
+}
+
+func (r *WebhooksPostgres) GetWebhook(id uuid.UUID) (*entity.Webhook, error) {
+	var w entity.Webhook
+	err := r.db.QueryRow("SELECT id, user_id, url, events, created_at FROM webhooks WHERE id=$1", id).
+		Scan(&w.ID, &w.UserID, &w.URL, pq.Array(&w.Events), &w.Created)
+	if err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+func (r *WebhooksPostgres) DeleteWebhook(id uuid.UUID) error {
+	_, err := r.db.Exec("DELETE FROM webhooks WHERE id=$1", id)
+	return err
+}
+
+// GetDeliveries возвращает последние доставки webhook, начиная с новых.
+func (r *WebhooksPostgres) GetDeliveries(webhookID uuid.UUID, limit int) ([]entity.WebhookDelivery, error) {
+	deliveries := make([]entity.WebhookDelivery, 0)
+	query := "SELECT " + deliveryColumns + ` FROM webhook_deliveries dl
+				WHERE dl.webhook_id=$1 ORDER BY dl.id DESC LIMIT $2`
+	err := r.db.Select(&deliveries, query, webhookID, limit)
+	return deliveries, err
+}
+
+// ClaimDeliveries выбирает доставки, время которых пришло, и откладывает их на lease:
+// другие экземпляры сервера их не возьмут, а если отправитель упадет, доставка
+// повторится после lease.
+func (r *WebhooksPostgres) ClaimDeliveries(limit int, lease time.Duration) ([]entity.WebhookDelivery, error) {
+	deliveries := make([]entity.WebhookDelivery, 0)
+	query := `WITH due AS (
+				SELECT id FROM webhook_deliveries
+				WHERE status = 'pending' AND next_attempt_at <= NOW()
+				ORDER BY next_attempt_at LIMIT $1
+				FOR UPDATE SKIP LOCKED
+			), claimed AS (
+				UPDATE webhook_deliveries dl SET next_attempt_at = NOW() + make_interval(secs => $2)
+				FROM due WHERE dl.id = due.id
+				RETURNING ` + deliveryColumns + `
+			)
+			SELECT dl.*, w.url, w.secret, w.user_id FROM claimed dl
+			INNER JOIN webhooks w ON w.id = dl.webhook_id`
+	err := r.db.Select(&deliveries, query, limit, lease.Seconds())
+	return deliveries, err
+}
+
+func (r *WebhooksPostgres) MarkDelivered(id int64, statusCode int) error {
+	query := `UPDATE webhook_deliveries SET status=$2, attempts=attempts+1, last_status_code=$3,
+				last_error=NULL, delivered_at=NOW() WHERE id=$1`
+	_, err := r.db.Exec(query, id, entity.DeliveryDelivered, statusCode)
+	return err
+}
+
+// MarkFailed учитывает неудачную попытку; statusCode 0 - ответа не было.
+func (r *WebhooksPostgres) MarkFailed(id int64, statusCode int, errText string, next time.Time, dead bool) error {
+	status := entity.DeliveryPending
+	if dead {
+		status = entity.DeliveryDead
+	}
+	query := `UPDATE webhook_deliveries SET status=$2, attempts=attempts+1, last_status_code=NULLIF($3, 0),
+				last_error=$4, next_attempt_at=$5 WHERE id=$1`
+	_, err := r.db.Exec(query, id, status, statusCode, errText, next)
+	return err
+}
+
+// Redeliver ставит доставку в очередь заново с обнуленным счетчиком попыток.
+func (r *WebhooksPostgres) Redeliver(webhookID uuid.UUID, id int64) (*entity.WebhookDelivery, error) {
+	var d entity.WebhookDelivery
+	query := `UPDATE webhook_deliveries dl SET status=$3, attempts=0, next_attempt_at=NOW(), delivered_at=NULL
+				WHERE dl.webhook_id=$1 AND dl.id=$2
+				RETURNING ` + deliveryColumns
+	if err := r.db.Get(&d, query, webhookID, id, entity.DeliveryPending); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
@@ -0,0 +1,144 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	defaultClamdTimeout = 5 * time.Minute
+	clamdChunkSize      = 64 << 10
+)
+
+var (
+	// ErrClamd - clamd ответил ошибкой на этот файл (например, превышен StreamMaxLength).
+	ErrClamd = errors.New("clamd error")
+	// ErrUnavailable - clamd недоступен или не ответил вовремя; проверку стоит повторить.
+	ErrUnavailable = errors.New("clamd is unavailable")
+)
+
+// ClamdScanner передает файл демону ClamAV командой INSTREAM. Адрес задается как
+// "unix:/run/clamav/clamd.ctl" или "host:port".
+type ClamdScanner struct {
+	network string
+	addr    string
+	timeout time.Duration
+}
+
+func NewClamdScanner(addr string, timeout time.Duration) (*ClamdScanner, error) {
+	if addr == "" {
+		return nil, errors.New("clamd address is not set")
+	}
+	if timeout <= 0 {
+		timeout = defaultClamdTimeout
+	}
+
+	s := &ClamdScanner{network: "tcp", addr: addr, timeout: timeout}
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		s.network, s.addr = "unix", path
+	} else if host, ok := strings.CutPrefix(addr, "tcp://"); ok {
+		s.addr = host
+	}
+	return s, nil
+}
+
+func (s *ClamdScanner) Scan(ctx context.Context, r io.Reader) (Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, s.network, s.addr)
+	if err != nil {
+		return Result{}, fmt.Errorf("%w: connect: %v", ErrUnavailable, err)
+	}
+	defer conn.Close()
+
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+	// Отмена ctx прерывает чтение и запись
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return Result{}, unavailable(err)
+	}
+	if err := s.stream(conn, r); err != nil {
+		// clamd мог закрыть поток раньше (например, превышен StreamMaxLength) -
+		// тогда причина в его ответе
+		if reply, rerr := readReply(conn); rerr == nil {
+			return parseReply(reply)
+		}
+		return Result{}, err
+	}
+
+	reply, err := readReply(conn)
+	if err != nil {
+		return Result{}, err
+	}
+	return parseReply(reply)
+}
+
+// stream отправляет данные блоками: 4 байта длины (big endian), затем данные;
+// блок нулевой длины завершает поток. Ошибки чтения файла возвращаются как есть,
+// ошибки соединения - как ErrUnavailable.
+func (s *ClamdScanner) stream(conn net.Conn, r io.Reader) error {
+	buf := make([]byte, 4+clamdChunkSize)
+	for {
+		n, err := io.ReadFull(r, buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf[:4], uint32(n))
+			if _, werr := conn.Write(buf[:4+n]); werr != nil {
+				return unavailable(werr)
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	_, err := conn.Write([]byte{0, 0, 0, 0})
+	return unavailable(err)
+}
+
+func readReply(conn net.Conn) (string, error) {
+	reply, err := io.ReadAll(io.LimitReader(conn, 4096))
+	if err != nil && len(reply) == 0 {
+		return "", unavailable(err)
+	}
+	if len(reply) == 0 {
+		return "", fmt.Errorf("%w: empty reply", ErrUnavailable)
+	}
+	return string(bytes.TrimRight(reply, "\x00\n")), nil
+}
+
+func unavailable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("%w: %v", ErrUnavailable, err)
+}
+
+// parseReply разбирает ответ вида "stream: OK", "stream: <сигнатура> FOUND" или
+// "<сообщение> ERROR".
+func parseReply(reply string) (Result, error) {
+	_, status, ok := strings.Cut(reply, ": ")
+	if !ok {
+		status = reply
+	}
+	switch {
+	case status == "OK":
+		return Result{}, nil
+	case strings.HasSuffix(status, " FOUND"):
+		return Result{Infected: true, Signature: strings.TrimSuffix(status, " FOUND")}, nil
+	}
+	return Result{}, fmt.Errorf("%w: %s", ErrClamd, reply)
+}
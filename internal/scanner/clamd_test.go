@@ -0,0 +1,202 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// fakeClamd принимает одно соединение INSTREAM и отвечает reply(данные). Если
+// передано больше maxStream байт, отвечает ошибкой размера, как clamd.
+type fakeClamd struct {
+	ln        net.Listener
+	maxStream int
+	reply     func(data []byte) string
+	received  chan []byte
+}
+
+func startFakeClamd(t *testing.T, maxStream int, reply func(data []byte) string) *fakeClamd {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeClamd{ln: ln, maxStream: maxStream, reply: reply, received: make(chan []byte, 1)}
+	t.Cleanup(func() { ln.Close() })
+	go f.serve(t)
+	return f
+}
+
+func (f *fakeClamd) serve(t *testing.T) {
+	conn, err := f.ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	cmd := make([]byte, len("zINSTREAM\x00"))
+	if _, err := io.ReadFull(conn, cmd); err != nil || string(cmd) != "zINSTREAM\x00" {
+		t.Errorf("command = %q, %v; want zINSTREAM", cmd, err)
+		return
+	}
+
+	var data []byte
+	reply := ""
+	for {
+		var size uint32
+		if err := binary.Read(conn, binary.BigEndian, &size); err != nil {
+			t.Errorf("read chunk size: %v", err)
+			return
+		}
+		if size == 0 {
+			break
+		}
+		chunk := make([]byte, size)
+		if _, err := io.ReadFull(conn, chunk); err != nil {
+			t.Errorf("read chunk: %v", err)
+			return
+		}
+		data = append(data, chunk...)
+		if f.maxStream > 0 && len(data) > f.maxStream {
+			reply = "INSTREAM size limit exceeded. ERROR"
+			break
+		}
+	}
+	if reply == "" {
+		reply = f.reply(data)
+	}
+	f.received <- data
+
+	conn.Write([]byte(reply + "\x00"))
+	// Закрываем только запись и дочитываем остаток потока, чтобы ответ не потерялся
+	// из-за сброса соединения с непрочитанными данными
+	conn.(*net.TCPConn).CloseWrite()
+	io.Copy(io.Discard, conn)
+}
+
+func (f *fakeClamd) scanner(t *testing.T) *ClamdScanner {
+	t.Helper()
+	s, err := NewClamdScanner("tcp://"+f.ln.Addr().String(), 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestClamdScan(t *testing.T) {
+	eicar := []byte(`X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`)
+	reply := func(data []byte) string {
+		if bytes.Contains(data, eicar) {
+			return "stream: Eicar-Signature FOUND"
+		}
+		return "stream: OK"
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want Result
+	}{
+		{"clean", []byte("hello"), Result{}},
+		{"empty", nil, Result{}},
+		{"several chunks", bytes.Repeat([]byte{'a'}, 3*clamdChunkSize+17), Result{}},
+		{"infected", eicar, Result{Infected: true, Signature: "Eicar-Signature"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := startFakeClamd(t, 0, reply)
+			got, err := f.scanner(t).Scan(context.Background(), bytes.NewReader(tt.data))
+			if err != nil {
+				t.Fatalf("Scan: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Scan = %+v, want %+v", got, tt.want)
+			}
+			if data := <-f.received; !bytes.Equal(data, tt.data) {
+				t.Errorf("clamd received %d bytes, want %d", len(data), len(tt.data))
+			}
+		})
+	}
+}
+
+func TestClamdSizeLimit(t *testing.T) {
+	f := startFakeClamd(t, clamdChunkSize, func([]byte) string { return "stream: OK" })
+	data := bytes.Repeat([]byte{'a'}, 4*clamdChunkSize)
+
+	_, err := f.scanner(t).Scan(context.Background(), bytes.NewReader(data))
+	if !errors.Is(err, ErrClamd) {
+		t.Fatalf("Scan error = %v, want ErrClamd", err)
+	}
+	// Ответ clamd об ошибке - результат проверки файла, повторять ее бессмысленно
+	if errors.Is(err, ErrUnavailable) {
+		t.Errorf("size limit reported as ErrUnavailable: %v", err)
+	}
+}
+
+func TestClamdUnavailable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	s, err := NewClamdScanner(addr, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Scan(context.Background(), bytes.NewReader([]byte("hello")))
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Scan error = %v, want ErrUnavailable", err)
+	}
+}
+
+func TestClamdTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	// Сервер принимает соединение, но не отвечает
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.Copy(io.Discard, conn)
+	}()
+
+	s, err := NewClamdScanner(ln.Addr().String(), 200*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Scan(context.Background(), bytes.NewReader([]byte("hello")))
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Scan error = %v, want ErrUnavailable", err)
+	}
+}
+
+func TestParseReply(t *testing.T) {
+	tests := []struct {
+		reply   string
+		want    Result
+		wantErr bool
+	}{
+		{"stream: OK", Result{}, false},
+		{"stream: Win.Test.EICAR_HDB-1 FOUND", Result{Infected: true, Signature: "Win.Test.EICAR_HDB-1"}, false},
+		{"INSTREAM size limit exceeded. ERROR", Result{}, true},
+		{"stream: Can't allocate memory ERROR", Result{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseReply(tt.reply)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseReply(%q) = %+v, %v; want %+v, error %v", tt.reply, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Result - итог проверки файла.
+type Result struct {
+	Infected bool
+	// Signature - имя найденной сигнатуры
+	Signature string
+}
+
+// Scanner проверяет содержимое загруженного файла на вредоносный код.
+type Scanner interface {
+	Scan(ctx context.Context, r io.Reader) (Result, error)
+}
+
+// New возвращает реализацию по имени из конфигурации: "none" или "clamd".
+func New(kind, addr string, timeout time.Duration) (Scanner, error) {
+	switch kind {
+	case "", "none":
+		return NoopScanner{}, nil
+	case "clamd":
+		return NewClamdScanner(addr, timeout)
+	default:
+		return nil, fmt.Errorf("unknown scanner type: %s", kind)
+	}
+}
+
+// NoopScanner считает любой файл чистым. Используется, если антивирус не настроен.
+type NoopScanner struct{}
+
+func (NoopScanner) Scan(ctx context.Context, r io.Reader) (Result, error) {
+	return Result{}, nil
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/olenka-91/DocsServer/internal/entity"
+	"github.com/olenka-91/DocsServer/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+	// auditUserAgentSize - сколько символов User-Agent сохранять
+	auditUserAgentSize = 512
+)
+
+// AuditService ведет журнал действий с документами и учетными записями. Запись в журнал
+// не влияет на результат запроса: ошибка только логируется.
+type AuditService struct {
+	repo repository.Audit
+}
+
+func NewAuditService(r repository.Audit) *AuditService {
+	return &AuditService{repo: r}
+}
+
+// auditOutcome - результат действия по ошибке, которой оно завершилось.
+func auditOutcome(err error) string {
+	switch {
+	case err == nil:
+		return entity.AuditSuccess
+	case errors.Is(err, ErrForbidden), errors.Is(err, ErrUnauthorized), errors.Is(err, ErrInvalidCredentials),
+		errors.Is(err, ErrUserDisabled), errors.Is(err, ErrQuarantined), errors.Is(err, ErrInvalidResetToken),
+		errors.Is(err, ErrRetained), errors.Is(err, ErrLocked):
+		return entity.AuditDenied
+	}
+	return entity.AuditFailure
+}
+
+// Record пишет запись e с адресом и User-Agent запроса. Исполнитель, если не задан,
+// берется из токена запроса; результат, если не задан, определяется по err.
+func (s *AuditService) Record(ctx *gin.Context, e entity.AuditEntry, err error) {
+	if e.Outcome == "" {
+		e.Outcome = auditOutcome(err)
+	}
+	if err != nil {
+		if e.Details == nil {
+			e.Details = entity.JSONB{}
+		}
+		e.Details["error"] = err.Error()
+	}
+	if ctx != nil {
+		e.IP = ctx.ClientIP()
+		e.UserAgent = ctx.Request.UserAgent()
+		if len(e.UserAgent) > auditUserAgentSize {
+			e.UserAgent = e.UserAgent[:auditUserAgentSize]
+		}
+		// Заголовок может быть не в UTF-8, а Postgres не примет такой текст
+		e.UserAgent = strings.ToValidUTF8(e.UserAgent, "?")
+		if e.ActorLogin == "" {
+			if userID, ok := ctx.Get("user_id"); ok {
+				id := userID.(uuid.UUID)
+				e.ActorID = &id
+				e.ActorLogin = ctx.GetString("login")
+			}
+		}
+	}
+
+	if err := s.repo.InsertAuditEntry(&e); err != nil {
+		logrus.Errorf("Failed to write audit entry %s %s/%s: %v", e.Action, e.TargetType, e.TargetID, err)
+	}
+}
+
+// RecordDoc пишет действие с документом docID; doc - загруженный документ или nil.
+func (s *AuditService) RecordDoc(ctx *gin.Context, action string, docID uuid.UUID, doc *entity.Document, err error) {
+	e := entity.AuditEntry{
+		Action:     action,
+		TargetType: entity.AuditTargetDocument,
+		TargetID:   docID.String(),
+	}
+	if doc != nil {
+		e.TargetName = doc.Name
+	}
+	s.Record(ctx, e, err)
+}
+
+// csvSafe экранирует значения, которые табличный редактор принял бы за формулу:
+// имена документов и User-Agent задает пользователь.
+func csvSafe(v string) string {
+	if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+		return "'" + v
+	}
+	return v
+}
+
+// GetAuditLog возвращает страницу журнала, начиная с новых записей.
+func (s *AuditService) GetAuditLog(filter entity.AuditFilter) ([]entity.AuditEntry, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultAuditLimit
+	}
+	if filter.Limit > maxAuditLimit {
+		filter.Limit = maxAuditLimit
+	}
+	return s.repo.GetAuditEntries(filter)
+}
+
+// ExportAuditLog пишет в ответ все записи по фильтру в формате csv или jsonl.
+func (s *AuditService) ExportAuditLog(ctx *gin.Context, filter entity.AuditFilter, format string) error {
+	var write func(*entity.AuditEntry) error
+	var flush func() error
+
+	switch format {
+	case "csv":
+		ctx.Header("Content-Type", "text/csv; charset=utf-8")
+		w := csv.NewWriter(ctx.Writer)
+		w.Write([]string{"id", "created", "actor_id", "actor", "action", "target_type", "target_id",
+			"target_name", "ip", "user_agent", "outcome", "details"})
+		write = func(e *entity.AuditEntry) error {
+			actorID := ""
+			if e.ActorID != nil {
+				actorID = e.ActorID.String()
+			}
+			details := ""
+			if e.Details != nil {
+				b, _ := json.Marshal(e.Details)
+				details = string(b)
+			}
+			return w.Write([]string{strconv.FormatInt(e.ID, 10), e.Created.UTC().Format(time.RFC3339Nano),
+				actorID, csvSafe(e.ActorLogin), e.Action, e.TargetType, e.TargetID, csvSafe(e.TargetName),
+				e.IP, csvSafe(e.UserAgent), e.Outcome, details})
+		}
+		flush = func() error {
+			w.Flush()
+			return w.Error()
+		}
+	case "jsonl":
+		ctx.Header("Content-Type", "application/x-ndjson")
+		enc := json.NewEncoder(ctx.Writer)
+		write = func(e *entity.AuditEntry) error { return enc.Encode(e) }
+		flush = func() error { return nil }
+	default:
+		return ErrBadRequest
+	}
+
+	ctx.Header("Content-Disposition", `attachment; filename="audit.`+format+`"`)
+	ctx.Status(http.StatusOK)
+
+	if err := s.repo.ExportAuditEntries(filter, write); err != nil {
+		if !ctx.Writer.Written() {
+			ctx.Writer.Header().Del("Content-Disposition")
+			return err
+		}
+		// Ошибку посреди выгрузки клиенту уже не сообщить: ответ обрывается
+		logrus.Errorf("Failed to export audit log: %v", err)
+		return nil
+	}
+	if err := flush(); err != nil {
+		logrus.Errorf("Failed to export audit log: %v", err)
+	}
+	return nil
+}
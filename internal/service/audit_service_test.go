@@ -0,0 +1,272 @@
+package service
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/olenka-91/DocsServer/internal/entity"
+	"github.com/olenka-91/DocsServer/internal/repository"
+	"github.com/olenka-91/DocsServer/internal/utils"
+)
+
+// stubAudit запоминает записанные в журнал действия.
+type stubAudit struct {
+	repository.Audit
+	entries []entity.AuditEntry
+}
+
+func (s *stubAudit) InsertAuditEntry(e *entity.AuditEntry) error {
+	s.entries = append(s.entries, *e)
+	return nil
+}
+
+// last возвращает последнюю запись журнала.
+func (s *stubAudit) last(t *testing.T) entity.AuditEntry {
+	t.Helper()
+	if len(s.entries) == 0 {
+		t.Fatal("no audit entries")
+	}
+	return s.entries[len(s.entries)-1]
+}
+
+// stubUsers - пользователи по логину для repository.Authorization.
+type stubUsers struct {
+	repository.Authorization
+	users []*entity.User
+}
+
+func (s *stubUsers) GetUserByID(id uuid.UUID) (*entity.User, error) {
+	for _, u := range s.users {
+		if u.ID == id {
+			return u, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (s *stubUsers) GetUserByLogin(login string) (*entity.User, error) {
+	for _, u := range s.users {
+		if u.Login == login {
+			return u, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+type stubTransferDocs struct {
+	repository.Docs
+	doc entity.Document
+}
+
+func (s *stubTransferDocs) GetDoc(_ *gin.Context, docID uuid.UUID) (*entity.Document, error) {
+	if docID != s.doc.ID {
+		return nil, sql.ErrNoRows
+	}
+	doc := s.doc
+	return &doc, nil
+}
+
+type stubTransfers struct {
+	repository.Transfers
+	transfer entity.DocumentTransfer
+	moved    int64
+}
+
+func (s *stubTransfers) GetTransfer(id uuid.UUID) (*entity.DocumentTransfer, error) {
+	if id != s.transfer.ID {
+		return nil, sql.ErrNoRows
+	}
+	t := s.transfer
+	return &t, nil
+}
+
+func (s *stubTransfers) AcceptTransfer(uuid.UUID) error {
+	s.transfer.Status = "accepted"
+	return nil
+}
+
+func (s *stubTransfers) TransferAllDocs(uuid.UUID, uuid.UUID, string) (int64, error) {
+	return s.moved, nil
+}
+
+type stubProfiles struct {
+	repository.Users
+}
+
+func (s *stubProfiles) UpdateProfile(uuid.UUID, entity.UpdateProfileRequest) error { return nil }
+
+func (s *stubProfiles) GetProfile(uuid.UUID) (*entity.UserProfile, error) {
+	return &entity.UserProfile{}, nil
+}
+
+type stubGroups struct {
+	repository.Groups
+	// owners и members - роли пользователей в единственной группе
+	owners, members map[uuid.UUID]bool
+}
+
+func (s *stubGroups) GetMembership(_ uuid.UUID, userID uuid.UUID) (bool, bool, error) {
+	return s.members[userID] || s.owners[userID], s.owners[userID], nil
+}
+
+func (s *stubGroups) RemoveMember(uuid.UUID, uuid.UUID) error { return nil }
+func (s *stubGroups) DeleteGroup(uuid.UUID) error             { return nil }
+
+// auditContext - запрос пользователя user.
+func auditContext(user *entity.User) *gin.Context {
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest(http.MethodPost, "/", nil)
+	ctx.Request.Header.Set("User-Agent", "test")
+	ctx.Set("user_id", user.ID)
+	ctx.Set("login", user.Login)
+	return ctx
+}
+
+// checkEntry сравнивает действие, исполнителя, объект и результат записи.
+func checkEntry(t *testing.T, e entity.AuditEntry, action string, actor *entity.User, targetID, outcome string) {
+	t.Helper()
+	if e.Action != action || e.Outcome != outcome || e.TargetID != targetID {
+		t.Errorf("entry = %s %s/%s %s; want %s %s %s", e.Action, e.TargetType, e.TargetID, e.Outcome,
+			action, targetID, outcome)
+	}
+	if e.ActorID == nil || *e.ActorID != actor.ID || e.ActorLogin != actor.Login {
+		t.Errorf("actor = %v %q, want %s %q", e.ActorID, e.ActorLogin, actor.ID, actor.Login)
+	}
+}
+
+func TestTransferAudit(t *testing.T) {
+	alice := &entity.User{ID: uuid.New(), Login: "alice"}
+	bob := &entity.User{ID: uuid.New(), Login: "bob"}
+	eve := &entity.User{ID: uuid.New(), Login: "eve"}
+	doc := entity.Document{ID: uuid.New(), Name: "report.pdf", UserID: alice.ID}
+	transfers := &stubTransfers{
+		transfer: entity.DocumentTransfer{ID: uuid.New(), DocID: doc.ID, DocName: doc.Name,
+			FromUserID: &alice.ID, ToUserID: &bob.ID, FromLogin: "alice", ToLogin: "bob",
+			Status: entity.TransferPending},
+		moved: 3,
+	}
+	audit := &stubAudit{}
+	s := NewTransferService(transfers, &stubUsers{users: []*entity.User{alice, bob, eve}},
+		&stubTransferDocs{doc: doc}, nil, NewAuditService(audit))
+
+	// Передать чужой документ нельзя
+	if _, err := s.TransferDoc(auditContext(eve), doc.ID, eve.ID, entity.TransferDocRequest{To: "Eve"}); err != ErrForbidden {
+		t.Fatalf("TransferDoc by other user: %v, want ErrForbidden", err)
+	}
+	e := audit.last(t)
+	checkEntry(t, e, entity.AuditDocTransfer, eve, doc.ID.String(), entity.AuditDenied)
+	if e.TargetName != doc.Name || e.Details["to"] != "eve" {
+		t.Errorf("transfer entry = %q, details %v", e.TargetName, e.Details)
+	}
+
+	// Чужая заявка для пользователя не существует, но в журнале это отказ
+	if err := s.AcceptTransfer(auditContext(eve), eve.ID, transfers.transfer.ID); err != ErrNotFound {
+		t.Fatalf("AcceptTransfer by other user: %v, want ErrNotFound", err)
+	}
+	checkEntry(t, audit.last(t), entity.AuditDocAccept, eve, doc.ID.String(), entity.AuditDenied)
+
+	if err := s.CancelTransfer(auditContext(eve), eve.ID, uuid.New()); err != ErrNotFound {
+		t.Fatalf("CancelTransfer of unknown transfer: %v, want ErrNotFound", err)
+	}
+	checkEntry(t, audit.last(t), entity.AuditDocCancel, eve, "", entity.AuditFailure)
+
+	if err := s.AcceptTransfer(auditContext(bob), bob.ID, transfers.transfer.ID); err != nil {
+		t.Fatalf("AcceptTransfer: %v", err)
+	}
+	e = audit.last(t)
+	checkEntry(t, e, entity.AuditDocAccept, bob, doc.ID.String(), entity.AuditSuccess)
+	if e.Details["transfer_id"] != transfers.transfer.ID.String() || e.Details["from"] != "alice" {
+		t.Errorf("accept details = %v", e.Details)
+	}
+
+	// Ответ на уже принятую заявку
+	if err := s.DeclineTransfer(auditContext(bob), bob.ID, transfers.transfer.ID); err != ErrConflict {
+		t.Fatalf("DeclineTransfer after accept: %v, want ErrConflict", err)
+	}
+	checkEntry(t, audit.last(t), entity.AuditDocDecline, bob, doc.ID.String(), entity.AuditFailure)
+
+	n, err := s.BulkTransfer(auditContext(eve), eve.ID, entity.BulkTransferRequest{From: "Alice", To: "bob"})
+	if err != nil || n != 3 {
+		t.Fatalf("BulkTransfer = %d, %v", n, err)
+	}
+	e = audit.last(t)
+	checkEntry(t, e, entity.AuditBulkTransfer, eve, alice.ID.String(), entity.AuditSuccess)
+	if e.TargetType != entity.AuditTargetUser || e.Details["to"] != "bob" || e.Details["transferred"] != int64(3) {
+		t.Errorf("bulk transfer entry = %s, details %v", e.TargetType, e.Details)
+	}
+}
+
+func TestUserAudit(t *testing.T) {
+	hash, err := utils.HashPaasword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice := &entity.User{ID: uuid.New(), Login: "alice", Password: hash}
+	audit := &stubAudit{}
+	s := NewUserService(&stubProfiles{}, &stubUsers{users: []*entity.User{alice}}, nil, nil, NewAuditService(audit))
+
+	claims := &utils.JwtClaim{UserID: alice.ID, Login: alice.Login}
+	err = s.DeleteAccount(auditContext(alice), claims, entity.DeleteAccountRequest{
+		Password: "wrong", Mode: entity.DeleteModeTransfer, TransferTo: "Bob"})
+	if err != ErrInvalidCredentials {
+		t.Fatalf("DeleteAccount with wrong password: %v, want ErrInvalidCredentials", err)
+	}
+	e := audit.last(t)
+	checkEntry(t, e, entity.AuditUserDelete, alice, alice.ID.String(), entity.AuditDenied)
+	if e.TargetName != "alice" || e.Details["mode"] != entity.DeleteModeTransfer || e.Details["transfer_to"] != "bob" {
+		t.Errorf("delete entry = %q, details %v", e.TargetName, e.Details)
+	}
+
+	// В журнал попадают имена измененных полей, но не значения
+	email := "alice@example.com"
+	if _, err := s.UpdateProfile(auditContext(alice), alice.ID, entity.UpdateProfileRequest{Email: &email}); err != nil {
+		t.Fatalf("UpdateProfile: %v", err)
+	}
+	e = audit.last(t)
+	checkEntry(t, e, entity.AuditProfileEdit, alice, alice.ID.String(), entity.AuditSuccess)
+	if fields, _ := e.Details["fields"].([]string); len(fields) != 1 || fields[0] != "email" {
+		t.Errorf("profile update details = %v", e.Details)
+	}
+}
+
+func TestGroupAudit(t *testing.T) {
+	owner := &entity.User{ID: uuid.New(), Login: "owner"}
+	member := &entity.User{ID: uuid.New(), Login: "member"}
+	outsider := &entity.User{ID: uuid.New(), Login: "outsider"}
+	groupID := uuid.New()
+	audit := &stubAudit{}
+	s := NewGroupService(&stubGroups{
+		owners:  map[uuid.UUID]bool{owner.ID: true},
+		members: map[uuid.UUID]bool{member.ID: true},
+	}, &stubUsers{users: []*entity.User{owner, member, outsider}}, NewAuditService(audit))
+
+	err := s.AddMember(auditContext(member), member.ID, groupID, entity.AddGroupMemberRequest{Login: "Outsider"})
+	if err != ErrForbidden {
+		t.Fatalf("AddMember by member: %v, want ErrForbidden", err)
+	}
+	e := audit.last(t)
+	checkEntry(t, e, entity.AuditMemberAdd, member, groupID.String(), entity.AuditDenied)
+	if e.TargetType != entity.AuditTargetGroup || e.Details["member"] != "outsider" {
+		t.Errorf("add member entry = %s, details %v", e.TargetType, e.Details)
+	}
+
+	if err := s.RemoveMember(auditContext(outsider), outsider.ID, groupID, "member"); err != ErrNotFound {
+		t.Fatalf("RemoveMember by outsider: %v, want ErrNotFound", err)
+	}
+	checkEntry(t, audit.last(t), entity.AuditMemberRemove, outsider, groupID.String(), entity.AuditDenied)
+
+	// Участник может выйти из группы сам
+	if err := s.RemoveMember(auditContext(member), member.ID, groupID, "member"); err != nil {
+		t.Fatalf("RemoveMember self: %v", err)
+	}
+	checkEntry(t, audit.last(t), entity.AuditMemberRemove, member, groupID.String(), entity.AuditSuccess)
+
+	if err := s.DeleteGroup(auditContext(owner), owner.ID, groupID); err != nil {
+		t.Fatalf("DeleteGroup: %v", err)
+	}
+	checkEntry(t, audit.last(t), entity.AuditGroupDelete, owner, groupID.String(), entity.AuditSuccess)
+}
@@ -1,26 +1,70 @@
 package service
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 
+	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/olenka-91/DocsServer/internal/entity"
+	"github.com/olenka-91/DocsServer/internal/notifier"
 	"github.com/olenka-91/DocsServer/internal/repository"
 	"github.com/olenka-91/DocsServer/internal/utils"
+	"github.com/sirupsen/logrus"
 )
 
 type AuthService struct {
-	repo repository.Authorization
+	repo          repository.Authorization
+	revocation    *RevocationService
+	notifier      notifier.Notifier
+	audit         *AuditService
+	historySize   int
+	resetTokenTTL time.Duration
 }
 
-func NewAuthService(r repository.Authorization) *AuthService {
+func NewAuthService(r repository.Authorization, rs *RevocationService, n notifier.Notifier, audit *AuditService,
+	historySize int, resetTokenTTL time.Duration) *AuthService {
 	return &AuthService{
-		repo: r,
+		repo:          r,
+		revocation:    rs,
+		notifier:      n,
+		audit:         audit,
+		historySize:   historySize,
+		resetTokenTTL: resetTokenTTL,
 	}
 }
 
-func (a *AuthService) SignUp(name, password string) (map[string]string, error) {
-	existingUser, err := a.repo.GetUserByLogin(strings.ToLower(name))
+// record пишет в журнал действие над учетной записью login. Для запросов без токена
+// (вход, регистрация, сброс пароля) исполнителем считается сам пользователь.
+func (a *AuthService) record(ctx *gin.Context, action, login string, userID uuid.UUID, self bool, outcome string,
+	err error) {
+	e := entity.AuditEntry{
+		Action:     action,
+		TargetType: entity.AuditTargetUser,
+		TargetName: login,
+		Outcome:    outcome,
+	}
+	if userID != uuid.Nil {
+		e.TargetID = userID.String()
+	}
+	if self {
+		e.ActorLogin = login
+		if userID != uuid.Nil {
+			e.ActorID = &userID
+		}
+	}
+	a.audit.Record(ctx, e, err)
+}
+
+func (a *AuthService) SignUp(ctx *gin.Context, name, password string) (_ map[string]string, err error) {
+	login := strings.ToLower(name)
+	var ID uuid.UUID
+	defer func() { a.record(ctx, entity.AuditSignUp, login, ID, true, "", err) }()
+
+	existingUser, err := a.repo.GetUserByLogin(login)
 	if (err == nil) && (existingUser != nil) {
 		return nil, fmt.Errorf("user with this login already exists")
 	}
@@ -30,37 +74,60 @@ func (a *AuthService) SignUp(name, password string) (map[string]string, error) {
 		return nil, err
 	}
 
-	ID, err := a.repo.CreateUser(strings.ToLower(name), hashedPassword)
+	ID, err = a.repo.CreateUser(login, hashedPassword)
 	if err != nil {
 		return nil, err
 	}
 
-	return a.generateAndSaveTokens(ID, strings.ToLower(name))
+	return a.generateAndSaveTokens(ID, login)
 }
 
-func (a *AuthService) SignIn(name, password string) (map[string]string, error) {
-	existingUser, err := a.repo.GetUserByLogin(strings.ToLower(name))
+func (a *AuthService) SignIn(ctx *gin.Context, name, password string) (_ map[string]string, err error) {
+	login := strings.ToLower(name)
+	var userID uuid.UUID
+	// Неизвестный логин и неверный пароль - отказ, а не сбой
+	outcome := ""
+	defer func() { a.record(ctx, entity.AuditSignIn, login, userID, true, outcome, err) }()
+
+	existingUser, err := a.repo.GetUserByLogin(login)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			outcome = entity.AuditDenied
+		}
 		return nil, err
 	}
 
 	if existingUser == nil {
+		outcome = entity.AuditDenied
 		return nil, fmt.Errorf("user with this login doesnt exist")
 	}
+	userID = existingUser.ID
 
 	err = utils.CheckPasswordHash(password, existingUser.Password)
 	if err != nil {
+		outcome = entity.AuditDenied
 		return nil, fmt.Errorf("invalid credentials")
 	}
 
-	return a.generateAndSaveTokens(existingUser.ID, strings.ToLower(name))
+	if existingUser.Disabled {
+		return nil, ErrUserDisabled
+	}
+
+	return a.generateAndSaveTokens(existingUser.ID, login)
 }
 
-func (a *AuthService) RefreshToken(refreshToken string) (map[string]string, error) {
+func (a *AuthService) RefreshToken(ctx *gin.Context, refreshToken string) (_ map[string]string, err error) {
+	var login string
+	var userID uuid.UUID
+	// Недействительный токен - отказ, а не сбой
+	outcome := entity.AuditDenied
+	defer func() { a.record(ctx, entity.AuditRefresh, login, userID, true, outcome, err) }()
+
 	claims, err := utils.ValidateToken(refreshToken)
 	if err != nil {
 		return nil, err
 	}
+	login, userID = claims.Login, claims.UserID
 
 	if claims.Type != "refresh" {
 		return nil, fmt.Errorf("invalid token type")
@@ -71,26 +138,207 @@ func (a *AuthService) RefreshToken(refreshToken string) (map[string]string, erro
 		return nil, fmt.Errorf("user not found")
 	}
 
-	if user.Token != refreshToken {
+	if user.Disabled {
+		return nil, ErrUserDisabled
+	}
+
+	if user.Token != refreshToken || a.revocation.IsRevoked(claims) {
 		return nil, fmt.Errorf("invalid refresh token")
 	}
 
+	outcome = ""
 	return a.generateAndSaveTokens(claims.UserID, claims.Login)
 }
 
-func (a *AuthService) Logout(userID uuid.UUID) error {
-	return a.repo.UpdateUserToken(userID, "")
+func (a *AuthService) Logout(ctx *gin.Context, claims *utils.JwtClaim) (err error) {
+	defer func() { a.record(ctx, entity.AuditLogout, claims.Login, claims.UserID, false, "", err) }()
+
+	if err := a.repo.UpdateUserToken(claims.UserID, ""); err != nil {
+		return err
+	}
+	// Access токен иначе оставался бы действительным до истечения срока
+	return a.revocation.RevokeToken(claims)
+}
+
+func (a *AuthService) DisableUser(ctx *gin.Context, login string) (err error) {
+	login = strings.ToLower(login)
+	var userID uuid.UUID
+	defer func() { a.record(ctx, entity.AuditUserDisable, login, userID, false, "", err) }()
+
+	userID, err = a.repo.SetUserDisabled(login, true)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	return a.revocation.RevokeUser(userID)
+}
+
+func (a *AuthService) EnableUser(ctx *gin.Context, login string) (err error) {
+	login = strings.ToLower(login)
+	var userID uuid.UUID
+	defer func() { a.record(ctx, entity.AuditUserEnable, login, userID, false, "", err) }()
+
+	userID, err = a.repo.SetUserDisabled(login, false)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrUserNotFound
+	}
+	return err
+}
+
+func (a *AuthService) IsAdmin(userID uuid.UUID) (bool, error) {
+	user, err := a.repo.GetUserByID(userID)
+	if err != nil {
+		return false, err
+	}
+	return user.IsAdmin && !user.Disabled, nil
+}
+
+func (a *AuthService) ChangePassword(ctx *gin.Context, userID uuid.UUID, oldPassword,
+	newPassword string) (_ map[string]string, err error) {
+	defer func() { a.record(ctx, entity.AuditPasswordChg, ctx.GetString("login"), userID, false, "", err) }()
+
+	user, err := a.repo.GetUserByID(userID)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+
+	if err := utils.CheckPasswordHash(oldPassword, user.Password); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	if err := a.checkPasswordReuse(user.ID, user.Password, newPassword); err != nil {
+		return nil, err
+	}
+
+	hashedPassword, err := utils.HashPaasword(newPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	// Пароль меняется в одной транзакции с отзывом токенов
+	validAfter := time.Now()
+	if err := a.repo.UpdateUserPassword(user.ID, hashedPassword, validAfter); err != nil {
+		return nil, err
+	}
+	a.revocation.applyCutoff(user.ID, validAfter)
+
+	// Новая пара токенов для текущей сессии, все остальные токены отозваны
+	return a.generateAndSaveTokens(user.ID, user.Login)
+}
+
+func (a *AuthService) RequestPasswordReset(ctx *gin.Context, name string) (err error) {
+	login := strings.ToLower(name)
+	var userID uuid.UUID
+	defer func() { a.record(ctx, entity.AuditResetRequest, login, userID, true, "", err) }()
+
+	user, err := a.repo.GetUserByLogin(login)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		// Не раскрываем, существует ли пользователь
+		logrus.Debugf("Password reset requested for unknown login %s", name)
+		return nil
+	}
+	userID = user.ID
+
+	token, tokenHash, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return err
+	}
+
+	expiresAt := time.Now().Add(a.resetTokenTTL)
+	if err := a.repo.CreatePasswordReset(user.ID, tokenHash, expiresAt); err != nil {
+		return err
+	}
+
+	return a.notifier.SendPasswordReset(user.Login, token, expiresAt)
+}
+
+func (a *AuthService) ResetPassword(ctx *gin.Context, token, newPassword string) (err error) {
+	var user *entity.User
+	defer func() {
+		if user != nil {
+			a.record(ctx, entity.AuditReset, user.Login, user.ID, true, "", err)
+		} else {
+			a.record(ctx, entity.AuditReset, "", uuid.Nil, true, "", err)
+		}
+	}()
+
+	tokenHash := utils.HashOpaqueToken(token)
+
+	userID, err := a.repo.GetPasswordResetUser(tokenHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidResetToken
+		}
+		return err
+	}
+
+	user, err = a.repo.GetUserByID(userID)
+	if err != nil {
+		return ErrInvalidResetToken
+	}
+
+	if err := a.checkPasswordReuse(user.ID, user.Password, newPassword); err != nil {
+		return err
+	}
+
+	hashedPassword, err := utils.HashPaasword(newPassword)
+	if err != nil {
+		return err
+	}
+
+	// Токен погашается в одной транзакции со сменой пароля и отзывом токенов: он может
+	// быть использован только один раз и не пропадает, если пароль сменить не удалось
+	validAfter := time.Now()
+	if _, err := a.repo.ResetPassword(tokenHash, hashedPassword, validAfter); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidResetToken
+		}
+		return err
+	}
+
+	a.revocation.applyCutoff(user.ID, validAfter)
+	return nil
+}
+
+// checkPasswordReuse запрещает текущий пароль и последние historySize паролей пользователя.
+func (a *AuthService) checkPasswordReuse(userID uuid.UUID, currentHash, newPassword string) error {
+	if utils.CheckPasswordHash(newPassword, currentHash) == nil {
+		return ErrPasswordReused
+	}
+
+	if a.historySize <= 0 {
+		return nil
+	}
+
+	history, err := a.repo.GetPasswordHistory(userID, a.historySize)
+	if err != nil {
+		return err
+	}
+
+	for _, hash := range history {
+		if utils.CheckPasswordHash(newPassword, hash) == nil {
+			return ErrPasswordReused
+		}
+	}
+	return nil
 }
 
 func (a *AuthService) generateAndSaveTokens(userID uuid.UUID, login string) (map[string]string, error) {
+	issuedAt := a.revocation.issuedAt(userID)
+
 	// Генерируем access токен
-	accessToken, err := utils.GenerateToken(userID, login, "access")
+	accessToken, err := utils.GenerateToken(userID, login, "access", issuedAt)
 	if err != nil {
 		return nil, err
 	}
 
 	// Генерируем refresh токен
-	refreshToken, err := utils.GenerateToken(userID, login, "refresh")
+	refreshToken, err := utils.GenerateToken(userID, login, "refresh", issuedAt)
 	if err != nil {
 		return nil, err
 	}
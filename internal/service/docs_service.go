@@ -1,14 +1,21 @@
 package service
 
 import (
-	"mime/multipart"
+	"database/sql"
+	"errors"
+	"io"
 	"net/http"
+	"net/textproto"
+	"os"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/olenka-91/DocsServer/internal/entity"
+	"github.com/olenka-91/DocsServer/internal/metadata"
 	"github.com/olenka-91/DocsServer/internal/repository"
 	"github.com/olenka-91/DocsServer/internal/storage"
+	"github.com/olenka-91/DocsServer/internal/thumbnail"
 	"github.com/sirupsen/logrus"
 	log "github.com/sirupsen/logrus"
 )
@@ -18,17 +25,34 @@ const (
 )
 
 type DocsService struct {
-	repo    repository.Docs
-	storage *storage.FileStorage
+	repo      repository.Docs
+	storage   *storage.FileStorage
+	quotas    *QuotaService
+	policies  *MimePolicyService
+	scans     *ScanService
+	thumbs    *ThumbnailService
+	metadata  *MetadataService
+	scrubs    *ScrubPolicyService
+	audit     *AuditService
+	retention *RetentionService
+	locks     *LockService
 }
 
-func NewDocsService(r repository.Docs, fs *storage.FileStorage) *DocsService {
-	return &DocsService{repo: r, storage: fs}
+func NewDocsService(r repository.Docs, fs *storage.FileStorage, quotas *QuotaService, policies *MimePolicyService,
+	scans *ScanService, thumbs *ThumbnailService, meta *MetadataService, scrubs *ScrubPolicyService,
+	audit *AuditService, retention *RetentionService, locks *LockService) *DocsService {
+	return &DocsService{repo: r, storage: fs, quotas: quotas, policies: policies, scans: scans,
+		thumbs: thumbs, metadata: meta, scrubs: scrubs, audit: audit, retention: retention,
+		locks: locks}
 }
 
 func (s *DocsService) GetDocsList(ctx *gin.Context, input entity.LimitedDocsListInput) ([]entity.Document, error) {
 	log.Debugf("Fetching list of docs with limit: %+v", input)
 
+	if input.UserID == uuid.Nil {
+		return nil, ErrUnauthorized
+	}
+
 	limit := 10
 	if input.Limit <= 0 {
 		input.Limit = limit
@@ -46,9 +70,18 @@ func (s *DocsService) GetDocsList(ctx *gin.Context, input entity.LimitedDocsList
 	return docs, nil
 }
 
-func (s *DocsService) GetDoc(ctx *gin.Context, docID uuid.UUID, login string) (*entity.Document, error) {
+func (s *DocsService) GetDoc(ctx *gin.Context, docID uuid.UUID, login string) (_ *entity.Document, err error) {
 	log.Debugf("Fetching doc with ID: %+v by user %+v", docID, login)
-	doc, err := s.repo.GetDoc(ctx, docID)
+	var doc *entity.Document
+	defer func() {
+		action := entity.AuditDocView
+		if doc != nil && doc.File && ctx.Request.Method == http.MethodGet {
+			action = entity.AuditDocDownload
+		}
+		s.audit.RecordDoc(ctx, action, docID, doc, err)
+	}()
+
+	doc, err = s.repo.GetDoc(ctx, docID)
 	if err != nil {
 		return nil, err
 	}
@@ -65,9 +98,23 @@ func (s *DocsService) GetDoc(ctx *gin.Context, docID uuid.UUID, login string) (*
 		return nil, ErrForbidden
 	}
 
+	if doc.Lock, err = s.locks.Get(docID); err != nil {
+		return nil, err
+	}
+	setLockHeaders(ctx, doc.Lock)
+
 	if doc.File && (ctx.Request.Method == http.MethodGet || ctx.Request.Method == http.MethodHead) {
+		if doc.Checksum == "" {
+			s.backfillChecksum(ctx, doc)
+		}
 
 		if err := s.storage.ServeFile(ctx, doc); err != nil {
+			switch {
+			case errors.Is(err, storage.ErrQuarantined):
+				return nil, ErrQuarantined
+			case errors.Is(err, storage.ErrNotScanned):
+				return nil, ErrNotScanned
+			}
 			return nil, err
 		}
 		ctx.Abort()
@@ -78,26 +125,162 @@ func (s *DocsService) GetDoc(ctx *gin.Context, docID uuid.UUID, login string) (*
 
 }
 
+// GetThumbnail отдает миниатюру изображения размера size. Пока файл ждет проверки
+// или миниатюра строится, возвращает ErrPreviewPending.
+func (s *DocsService) GetThumbnail(ctx *gin.Context, docID uuid.UUID, login string, size int) (err error) {
+	var doc *entity.Document
+	defer func() {
+		// Миниатюры запрашиваются часто: в журнал попадают только показы и отказы в доступе
+		if auditOutcome(err) != entity.AuditFailure {
+			s.audit.RecordDoc(ctx, entity.AuditDocPreview, docID, doc, err)
+		}
+	}()
+
+	doc, err = s.repo.GetDoc(ctx, docID)
+	if err != nil {
+		return err
+	}
+	if doc == nil {
+		return ErrNotFound
+	}
+	if login == "" {
+		return ErrUnauthorized
+	}
+	if !s.canAccess(ctx, doc, login) {
+		return ErrForbidden
+	}
+
+	if !doc.File || !thumbnail.Supported(doc.Mime) {
+		return ErrPreviewUnavailable
+	}
+	switch doc.ScanStatus {
+	case entity.ScanInfected:
+		return ErrQuarantined
+	case entity.ScanError:
+		return ErrNotScanned
+	case entity.ScanPending:
+		return ErrPreviewPending
+	}
+	return s.thumbs.Serve(ctx, doc, size)
+}
+
+// GetOriginal отдает владельцу оригинал изображения, сохраненный до удаления метаданных.
+func (s *DocsService) GetOriginal(ctx *gin.Context, docID uuid.UUID, login string) (err error) {
+	var doc *entity.Document
+	defer func() {
+		e := entity.AuditEntry{
+			Action:     entity.AuditDocDownload,
+			TargetType: entity.AuditTargetDocument,
+			TargetID:   docID.String(),
+			Details:    entity.JSONB{"original": true},
+		}
+		if doc != nil {
+			e.TargetName = doc.Name
+		}
+		s.audit.Record(ctx, e, err)
+	}()
+
+	doc, err = s.repo.GetDoc(ctx, docID)
+	if err != nil {
+		return err
+	}
+	if doc == nil || doc.OriginalSize == 0 {
+		return ErrNotFound
+	}
+	if login == "" {
+		return ErrUnauthorized
+	}
+	if login != s.repo.GetLoginByUserID(ctx, doc.UserID) {
+		return ErrForbidden
+	}
+
+	if err := s.storage.ServeOriginal(ctx, doc); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrQuarantined):
+			return ErrQuarantined
+		case errors.Is(err, storage.ErrNotScanned):
+			return ErrNotScanned
+		case errors.Is(err, os.ErrNotExist):
+			return ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// GetDocHistory возвращает владельцу журнал действий с документом, начиная с новых записей.
+func (s *DocsService) GetDocHistory(ctx *gin.Context, docID uuid.UUID, login string, beforeID int64,
+	limit int) ([]entity.AuditEntry, error) {
+	doc, err := s.repo.GetDoc(ctx, docID)
+	if err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return nil, ErrNotFound
+	}
+	if login == "" {
+		return nil, ErrUnauthorized
+	}
+	if login != s.repo.GetLoginByUserID(ctx, doc.UserID) {
+		return nil, ErrForbidden
+	}
+
+	return s.audit.GetAuditLog(entity.AuditFilter{
+		TargetType: entity.AuditTargetDocument,
+		TargetID:   docID.String(),
+		BeforeID:   beforeID,
+		Limit:      limit,
+	})
+}
+
+// backfillChecksum считает и сохраняет SHA-256 для документов, загруженных до появления
+// колонки sha256. Ошибка не мешает отдаче файла - просто не будет ETag.
+func (s *DocsService) backfillChecksum(ctx *gin.Context, doc *entity.Document) {
+	checksum, size, err := s.storage.Checksum(doc)
+	if err != nil {
+		log.Errorf("Failed to compute checksum for doc %s: %v", doc.ID, err)
+		return
+	}
+	if err := s.repo.UpdateDocChecksum(ctx, doc.ID, checksum, size); err != nil {
+		log.Errorf("Failed to store checksum for doc %s: %v", doc.ID, err)
+	}
+	doc.Checksum = checksum
+	doc.Size = size
+}
+
+// canAccess учитывает владельца, личные доступы и доступы через группы (проверка в SQL).
 func (s *DocsService) canAccess(ctx *gin.Context, doc *entity.Document, login string) bool {
 	if doc.Public {
 		return true
 	}
-	if login == s.repo.GetLoginByUserID(ctx, doc.UserID) {
-		return true
+
+	userID := s.repo.GetUserIDByLogin(ctx, login)
+	if userID == uuid.Nil {
+		return false
 	}
-	for _, l := range doc.Grant {
-		if l == login {
-			return true
-		}
+
+	ok, err := s.repo.HasAccess(ctx, doc.ID, userID)
+	if err != nil {
+		log.Errorf("Failed to check access to doc %s: %v", doc.ID, err)
+		return false
 	}
-	return false
+	return ok
+}
+
+// UploadFile - содержимое части file тела запроса и заголовки части (в них - ожидаемые суммы).
+type UploadFile struct {
+	Body   io.Reader
+	Header textproto.MIMEHeader
 }
 
 func (s *DocsService) PostDoc(ctx *gin.Context, login string, meta entity.UploadMeta,
-	jsonData entity.JSONB, fileHeader *multipart.FileHeader) (*entity.Document, error) {
+	jsonData entity.JSONB, file *UploadFile) (_ *entity.Document, err error) {
 	logrus.Debugf("Posting doc to storage.")
 
-	if meta.File && fileHeader == nil {
+	if meta.File && file == nil {
+		return nil, ErrBadRequest
+	}
+	if meta.ExpiresAt != nil && !meta.ExpiresAt.After(time.Now()) {
 		return nil, ErrBadRequest
 	}
 
@@ -110,51 +293,155 @@ func (s *DocsService) PostDoc(ctx *gin.Context, login string, meta entity.Upload
 		File:     meta.File,
 		Public:   meta.Public,
 		Grant:    meta.Grant,
+		Groups:   meta.GrantGroups,
 		JSONData: jsonData,
 	}
+	defer func() {
+		s.audit.RecordDoc(ctx, entity.AuditDocUpload, doc.ID, &doc, err)
+		if err == nil && (doc.Public || len(doc.Grant) > 0 || len(doc.Groups) > 0) {
+			s.audit.Record(ctx, entity.AuditEntry{
+				Action:     entity.AuditDocShare,
+				TargetType: entity.AuditTargetDocument,
+				TargetID:   doc.ID.String(),
+				TargetName: doc.Name,
+				Details:    entity.JSONB{"public": doc.Public, "users": doc.Grant, "groups": doc.Groups},
+			}, nil)
+		}
+	}()
+
+	// Группы проверяются до приема файла, окончательно - при сохранении документа
+	if err := s.repo.CheckGroupGrants(ctx, userID, doc.Groups); err != nil {
+		if errors.Is(err, repository.ErrInvalidGroupGrant) {
+			logrus.Debugf("Rejected upload: %v", err)
+			return nil, ErrInvalidGroup
+		}
+		return nil, err
+	}
+
+	quota, available, err := s.quotas.Check(userID)
+	if err != nil {
+		return nil, err
+	}
 
-	var storedMime, filePath string
 	if doc.File {
-		file, err := fileHeader.Open()
+		// Ожидаемые суммы передаются в заголовках части file
+		digests, err := storage.ParseDigests(file.Header)
+		if err != nil {
+			return nil, ErrBadRequest
+		}
+
+		// Файл читается из тела запроса по мере записи и не буферизуется целиком.
+		// Ограничение размера зависит от типа и задается после определения типа.
+		upload := newUploadReader(file.Body, 0, available)
+		sniffed, body, err := storage.SniffMime(upload)
 		if err != nil {
+			if upload.err != nil {
+				return nil, upload.err
+			}
+			return nil, err
+		}
+		mimeType, err := s.policies.Resolve(userID, meta.Name, meta.Mime, sniffed)
+		if err != nil {
+			return nil, err
+		}
+		upload.maxSize = s.quotas.FileSizeLimit(quota, mimeType)
+		scrubMode, keepOriginal, err := s.scrubs.Resolve(userID, meta, mimeType)
+		if err != nil {
+			return nil, err
+		}
+
+		// Регистрируем загрузку до записи файла: если процесс упадет между записью файла
+		// и вставкой документа, сверка найдет и удалит файл по этой записи.
+		if err := s.repo.CreatePendingUpload(ctx, doc.ID, s.storage.BlobKey(&doc)); err != nil {
+			logrus.Errorf("Failed to register pending upload: %v", err)
 			return nil, err
 		}
-		defer file.Close()
 
-		_, storedMime, filePath, err = s.storage.SaveFile(doc.ID, file, meta.Name)
+		saved, err := s.storage.SaveScrubbedFile(doc.ID, body, meta.Name, mimeType, digests,
+			metadata.Scrubber(mimeType, scrubMode), keepOriginal)
 		if err != nil {
+			s.cancelUpload(ctx, &doc, false)
+			if upload.err != nil {
+				return nil, upload.err
+			}
+			if errors.Is(err, storage.ErrDigestMismatch) {
+				return nil, ErrDigestMismatch
+			}
+			if errors.Is(err, metadata.ErrMalformed) {
+				return nil, ErrBadRequest
+			}
 			logrus.Errorf("Failed to save file: %v", err)
 			return nil, err
 		}
 
-		logrus.Debugf("Doc saved at:%s", filePath)
-	}
-
-	if storedMime != "" {
-		doc.Mime = storedMime
+		logrus.Debugf("Doc saved at:%s", saved.Path)
+		doc.Mime = saved.Mime
+		doc.Path = saved.Path
+		doc.Checksum = saved.Checksum
+		doc.Size = saved.Size
+		doc.KeyID = saved.KeyID
+		doc.WrappedKey = saved.WrappedKey
+		doc.Encoding = saved.Encoding
+		doc.OriginalSize = saved.OriginalSize
+		doc.ScanStatus = s.scans.InitialStatus()
 	}
 
-	if filePath != "" {
-		doc.Path = filePath
+	doc.ExpiresAt, doc.RetentionLocked, err = s.retention.Resolve(doc.Mime, meta.ExpiresAt)
+	if err != nil {
+		if doc.File {
+			s.cancelUpload(ctx, &doc, true)
+		}
+		return nil, err
 	}
 
-	if err := s.repo.CreateDocument(ctx, &doc); err != nil {
+	if err := s.repo.CreateDocument(ctx, &doc, quota); err != nil {
+		if doc.File {
+			s.cancelUpload(ctx, &doc, true)
+		}
+		if errors.Is(err, repository.ErrQuotaExceeded) {
+			return nil, ErrQuotaExceeded
+		}
+		if errors.Is(err, repository.ErrInvalidGroupGrant) {
+			return nil, ErrInvalidGroup
+		}
 		logrus.Errorf("Failed to create document: %v", err)
-		s.storage.DeleteFile(doc.ID, doc.Name)
 		return nil, err
 	}
+	switch doc.ScanStatus {
+	case entity.ScanPending:
+		s.scans.Notify()
+	case entity.ScanClean:
+		s.thumbs.Enqueue(&doc)
+		s.metadata.Notify()
+	}
 
 	return &doc, nil
 }
 
-func (s *DocsService) DeleteDoc(ctx *gin.Context, docID uuid.UUID, login string) (*entity.DelResponse, error) {
+// cancelUpload откатывает незавершенную загрузку. Если файл удалить не удалось,
+// запись о загрузке остается, и файл удалит сверка.
+func (s *DocsService) cancelUpload(ctx *gin.Context, doc *entity.Document, removeFile bool) {
+	if removeFile {
+		if err := s.storage.DeleteFile(doc.ID, doc.Name); err != nil {
+			logrus.Errorf("Failed to remove file of failed upload %s: %v", doc.ID, err)
+			return
+		}
+	}
+	if err := s.repo.CancelPendingUpload(ctx, doc.ID); err != nil {
+		logrus.Errorf("Failed to cancel pending upload %s: %v", doc.ID, err)
+	}
+}
+
+func (s *DocsService) DeleteDoc(ctx *gin.Context, docID uuid.UUID, login string) (_ *entity.DelResponse, err error) {
 	log.Debugf("Deleting doc with ID: %+v", docID)
+	var doc *entity.Document
+	defer func() { s.audit.RecordDoc(ctx, entity.AuditDocDelete, docID, doc, err) }()
 
 	if login == "" {
 		return nil, ErrUnauthorized
 	}
 
-	doc, err := s.repo.GetDoc(ctx, docID)
+	doc, err = s.repo.GetDoc(ctx, docID)
 	if err != nil {
 		return nil, err
 	}
@@ -167,16 +454,163 @@ func (s *DocsService) DeleteDoc(ctx *gin.Context, docID uuid.UUID, login string)
 		return nil, ErrForbidden
 	}
 
+	// Сначала удаляем запись: если файл удалить не получится, останется лишний файл,
+	// который найдет сверка, а не документ, ссылающийся на несуществующий файл.
+	if _, err := s.repo.DeleteDoc(ctx, docID, doc.UserID); err != nil {
+		if errors.Is(err, repository.ErrDocRetained) {
+			return nil, ErrRetained
+		}
+		return nil, s.locks.lockedError(ctx, docID, err)
+	}
+
 	if doc.File {
 		if err := s.storage.DeleteFile(docID, doc.Name); err != nil {
+			logrus.Errorf("Failed to remove file of deleted doc %s: %v", docID, err)
+		}
+	}
+
+	return &entity.DelResponse{docID: true}, nil
+
+}
+
+// UpdateDoc меняет данные документа (владелец и пользователи с доступом) и доступ к нему
+// (только владелец). Документ, заблокированный другим пользователем, не меняется.
+func (s *DocsService) UpdateDoc(ctx *gin.Context, docID uuid.UUID, login string,
+	input entity.UpdateDocRequest) (_ *entity.Document, err error) {
+	var doc *entity.Document
+	defer func() {
+		s.audit.RecordDoc(ctx, entity.AuditDocUpdate, docID, doc, err)
+		if err == nil && (input.Public != nil || input.Grant != nil || input.Groups != nil) {
+			s.audit.Record(ctx, entity.AuditEntry{
+				Action:     entity.AuditDocShare,
+				TargetType: entity.AuditTargetDocument,
+				TargetID:   doc.ID.String(),
+				TargetName: doc.Name,
+				Details:    entity.JSONB{"public": doc.Public, "users": doc.Grant, "groups": doc.Groups},
+			}, nil)
+		}
+	}()
+
+	if login == "" {
+		return nil, ErrUnauthorized
+	}
+
+	doc, err = s.repo.GetDoc(ctx, docID)
+	if errors.Is(err, sql.ErrNoRows) || doc == nil {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	userID := s.repo.GetUserIDByLogin(ctx, login)
+	if !s.canEdit(ctx, doc, userID) {
+		return nil, ErrForbidden
+	}
+	owner := doc.UserID == userID
+	if !owner && (input.Public != nil || input.Grant != nil || input.Groups != nil) {
+		return nil, ErrForbidden
+	}
+	if input.Groups != nil {
+		if err := s.repo.CheckGroupGrants(ctx, doc.UserID, *input.Groups); err != nil {
+			if errors.Is(err, repository.ErrInvalidGroupGrant) {
+				return nil, ErrInvalidGroup
+			}
 			return nil, err
 		}
 	}
 
-	if _, err := s.repo.DeleteDoc(ctx, docID); err != nil {
+	if err := s.repo.UpdateDoc(ctx, docID, userID, input); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		case errors.Is(err, repository.ErrInvalidGroupGrant):
+			return nil, ErrInvalidGroup
+		}
+		return nil, s.locks.lockedError(ctx, docID, err)
+	}
+
+	if doc, err = s.repo.GetDoc(ctx, docID); err != nil {
+		return nil, err
+	}
+	if doc.Lock, err = s.locks.Get(docID); err != nil {
 		return nil, err
 	}
+	setLockHeaders(ctx, doc.Lock)
+	return doc, nil
+}
+
+// canEdit - может ли пользователь изменять документ: владелец, личный или групповой доступ.
+// Публичный документ читают все, но изменять его это не дает.
+func (s *DocsService) canEdit(ctx *gin.Context, doc *entity.Document, userID uuid.UUID) bool {
+	if userID == uuid.Nil {
+		return false
+	}
+	ok, err := s.repo.HasAccess(ctx, doc.ID, userID)
+	if err != nil {
+		log.Errorf("Failed to check access to doc %s: %v", doc.ID, err)
+		return false
+	}
+	return ok
+}
 
-	return &entity.DelResponse{docID: true}, nil
+// LockDoc открывает документ на редактирование: блокирует его за пользователем на ttl
+// (0 - срок по умолчанию). Повторный вызов продлевает блокировку.
+func (s *DocsService) LockDoc(ctx *gin.Context, docID uuid.UUID, login string,
+	ttl time.Duration) (*entity.DocumentLock, error) {
+	if login == "" {
+		return nil, ErrUnauthorized
+	}
+
+	doc, err := s.repo.GetDoc(ctx, docID)
+	if errors.Is(err, sql.ErrNoRows) || doc == nil {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	userID := s.repo.GetUserIDByLogin(ctx, login)
+	if !s.canEdit(ctx, doc, userID) {
+		return nil, ErrForbidden
+	}
+	return s.locks.Acquire(ctx, doc, userID, ttl)
+}
+
+// UnlockDoc снимает блокировку пользователя. Чужую блокировку при force снимает владелец
+// документа.
+func (s *DocsService) UnlockDoc(ctx *gin.Context, docID uuid.UUID, login string, force bool) error {
+	if login == "" {
+		return ErrUnauthorized
+	}
+
+	doc, err := s.repo.GetDoc(ctx, docID)
+	if errors.Is(err, sql.ErrNoRows) || doc == nil {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	userID := s.repo.GetUserIDByLogin(ctx, login)
+	if force && userID != doc.UserID {
+		return ErrForbidden
+	}
+	return s.locks.Release(ctx, doc, &userID, force)
+}
+
+// BreakDocLock снимает любую блокировку документа по запросу администратора.
+func (s *DocsService) BreakDocLock(ctx *gin.Context, docID uuid.UUID) error {
+	doc, err := s.repo.GetDoc(ctx, docID)
+	if errors.Is(err, sql.ErrNoRows) || doc == nil {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return s.locks.Release(ctx, doc, nil, true)
+}
 
+func (s *DocsService) CacheStats() storage.CacheStatsResponse {
+	return s.storage.CacheStats()
 }
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/olenka-91/DocsServer/internal/entity"
+	"github.com/olenka-91/DocsServer/internal/repository"
+)
+
+// stubLockedDocs - документ с блокировкой редактирования в памяти. UpdateDoc
+// повторяет условие запроса: документ, заблокированный другим, не меняется.
+type stubLockedDocs struct {
+	repository.Docs
+	doc     entity.Document
+	logins  map[string]uuid.UUID
+	editors map[uuid.UUID]bool
+	lock    *entity.DocumentLock
+	updated int
+}
+
+func (s *stubLockedDocs) GetDoc(_ *gin.Context, docID uuid.UUID) (*entity.Document, error) {
+	if docID != s.doc.ID {
+		return nil, sql.ErrNoRows
+	}
+	doc := s.doc
+	return &doc, nil
+}
+
+func (s *stubLockedDocs) GetUserIDByLogin(_ *gin.Context, login string) uuid.UUID {
+	return s.logins[login]
+}
+
+func (s *stubLockedDocs) HasAccess(_ *gin.Context, _ uuid.UUID, userID uuid.UUID) (bool, error) {
+	return userID == s.doc.UserID || s.editors[userID], nil
+}
+
+func (s *stubLockedDocs) CheckGroupGrants(*gin.Context, uuid.UUID, []string) error { return nil }
+
+func (s *stubLockedDocs) UpdateDoc(_ *gin.Context, _, userID uuid.UUID, input entity.UpdateDocRequest) error {
+	if s.lock != nil && s.lock.UserID != userID {
+		return repository.ErrDocLocked
+	}
+	if input.JSON != nil {
+		s.doc.JSONData = input.JSON
+	}
+	s.updated++
+	return nil
+}
+
+type stubLocks struct {
+	repository.Locks
+	docs *stubLockedDocs
+}
+
+func (s *stubLocks) GetLock(uuid.UUID) (*entity.DocumentLock, error) { return s.docs.lock, nil }
+
+func TestUpdateLockedDoc(t *testing.T) {
+	owner := &entity.User{ID: uuid.New(), Login: "owner"}
+	editor := &entity.User{ID: uuid.New(), Login: "editor"}
+	docs := &stubLockedDocs{
+		doc:     entity.Document{ID: uuid.New(), UserID: owner.ID, Name: "plan.txt"},
+		logins:  map[string]uuid.UUID{"owner": owner.ID, "editor": editor.ID},
+		editors: map[uuid.UUID]bool{editor.ID: true},
+	}
+	audit := NewAuditService(&stubAudit{})
+	s := NewDocsService(docs, nil, nil, nil, nil, nil, nil, nil, audit, nil,
+		NewLockService(&stubLocks{docs: docs}, audit, 0, 0))
+
+	docs.lock = &entity.DocumentLock{DocID: docs.doc.ID, UserID: editor.ID, Owner: "editor",
+		Expires: time.Now().Add(time.Hour)}
+	update := entity.UpdateDocRequest{JSON: entity.JSONB{"status": "draft"}}
+
+	// Блокировка действует и против владельца документа
+	ctx := auditContext(owner)
+	if _, err := s.UpdateDoc(ctx, docs.doc.ID, "owner", update); err != ErrLocked {
+		t.Fatalf("UpdateDoc of doc locked by other user: %v, want ErrLocked", err)
+	}
+	if got := ctx.Writer.Header().Get("X-Lock-Owner"); got != "editor" {
+		t.Errorf("X-Lock-Owner = %q, want editor", got)
+	}
+	if docs.updated != 0 {
+		t.Fatal("locked doc is updated")
+	}
+
+	doc, err := s.UpdateDoc(auditContext(editor), docs.doc.ID, "editor", update)
+	if err != nil || doc.JSONData["status"] != "draft" {
+		t.Fatalf("UpdateDoc by lock owner = %v, %v", doc, err)
+	}
+
+	// Доступ меняет только владелец документа
+	public := true
+	if _, err := s.UpdateDoc(auditContext(editor), docs.doc.ID, "editor",
+		entity.UpdateDocRequest{Public: &public}); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("UpdateDoc access by editor: %v, want ErrForbidden", err)
+	}
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"github.com/google/uuid"
+	"github.com/olenka-91/DocsServer/internal/entity"
+	"github.com/olenka-91/DocsServer/internal/repository"
+	"github.com/olenka-91/DocsServer/internal/storage"
+	"github.com/sirupsen/logrus"
+)
+
+const keyRotationBatch = 500
+
+type EncryptionService struct {
+	repo    repository.Integrity
+	keyring *storage.Keyring
+}
+
+func NewEncryptionService(r repository.Integrity, keyring *storage.Keyring) *EncryptionService {
+	return &EncryptionService{repo: r, keyring: keyring}
+}
+
+func (s *EncryptionService) Status() (*entity.EncryptionStatus, error) {
+	counts, err := s.repo.CountDocsByKey()
+	if err != nil {
+		return nil, err
+	}
+
+	status := &entity.EncryptionStatus{
+		Enabled:   s.keyring != nil,
+		Plaintext: counts[""],
+		Documents: make(map[string]int64),
+	}
+	if s.keyring != nil {
+		status.ActiveKey = s.keyring.ActiveID()
+	}
+	for keyID, n := range counts {
+		if keyID != "" {
+			status.Documents[keyID] = n
+		}
+	}
+	return status, nil
+}
+
+// RotateKeys переоборачивает ключи данных всех документов активным мастер-ключом.
+// Файлы не перешифровываются. Документы, ключ которых нельзя развернуть (мастер-ключ
+// удален из конфигурации), пропускаются и учитываются в Failed.
+func (s *EncryptionService) RotateKeys() (*entity.KeyRotationResult, error) {
+	if s.keyring == nil {
+		return nil, ErrEncryptionDisabled
+	}
+
+	active := s.keyring.ActiveID()
+	result := &entity.KeyRotationResult{ActiveKey: active}
+	after := uuid.Nil
+
+	for {
+		docs, err := s.repo.GetDocKeysNotWrappedWith(active, after, keyRotationBatch)
+		if err != nil {
+			return nil, err
+		}
+		if len(docs) == 0 {
+			break
+		}
+
+		for _, doc := range docs {
+			after = doc.ID
+
+			keyID, wrapped, err := s.keyring.Rewrap(doc.KeyID, doc.WrappedKey, doc.ID)
+			if err != nil {
+				logrus.Errorf("Failed to rewrap data key of doc %s (key %s): %v", doc.ID, doc.KeyID, err)
+				result.Failed++
+				continue
+			}
+
+			ok, err := s.repo.UpdateDocKey(doc.ID, doc.KeyID, keyID, wrapped)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				result.Rewrapped++
+			}
+		}
+	}
+
+	logrus.Infof("Key rotation to %s finished: rewrapped=%d failed=%d", active, result.Rewrapped, result.Failed)
+	return result, nil
+}
@@ -9,4 +9,25 @@ var (
 	ErrNotFound             = errors.New("doc not found")         //http.StatusNotFound = 405
 	ErrInternalServerError  = errors.New("internal server error") //http.StatusInternalServerError = 500
 	ErrMethodNotImplemented = errors.New("not implemented")       //http.StatusMethodNotImplemented = 501
+
+	ErrInvalidCredentials = errors.New("invalid credentials")                             //http.StatusUnauthorized = 401
+	ErrPasswordReused     = errors.New("password was used recently")                      //http.StatusBadRequest = 400
+	ErrUserDisabled       = errors.New("user is disabled")                                //http.StatusForbidden = 403
+	ErrUserNotFound       = errors.New("user not found")                                  //http.StatusNotFound = 404
+	ErrConflict           = errors.New("conflict")                                        //http.StatusConflict = 409
+	ErrInvalidResetToken  = errors.New("reset token is invalid, expired or already used") //http.StatusBadRequest = 400
+	ErrDigestMismatch     = errors.New("uploaded file does not match content digest")     //http.StatusBadRequest = 400
+	ErrEncryptionDisabled = errors.New("encryption is not configured")                    //http.StatusConflict = 409
+	ErrFileTooLarge       = errors.New("file exceeds maximum upload size")                //http.StatusRequestEntityTooLarge = 413
+	ErrQuotaExceeded      = errors.New("storage quota exceeded")                          //http.StatusInsufficientStorage = 507
+	ErrMimeMismatch       = errors.New("file content does not match its declared type")   //http.StatusUnsupportedMediaType = 415
+	ErrMimeNotAllowed     = errors.New("file type is not allowed")                        //http.StatusUnsupportedMediaType = 415
+	ErrQuarantined        = errors.New("file is quarantined by malware scan")             //http.StatusForbidden = 403
+	ErrNotScanned         = errors.New("file has not passed malware scan yet")            //http.StatusConflict = 409
+	ErrPreviewUnavailable = errors.New("preview is not available for this document")      //http.StatusNotFound = 404
+	ErrPreviewPending     = errors.New("preview is being generated")                      //http.StatusAccepted = 202
+	ErrRetained           = errors.New("document is under legal hold or retention")       //http.StatusConflict = 409
+	ErrPartAfterFile      = errors.New("meta and json parts must precede file")           //http.StatusBadRequest = 400
+	ErrLocked             = errors.New("document is locked by another user")              //http.StatusLocked = 423
+	ErrInvalidGroup       = errors.New("unknown group or not a member of it")             //http.StatusBadRequest = 400
 )
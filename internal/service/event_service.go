@@ -0,0 +1,255 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"github.com/olenka-91/DocsServer/internal/entity"
+	"github.com/olenka-91/DocsServer/internal/repository"
+	"github.com/olenka-91/DocsServer/internal/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultEventPollInterval = time.Second
+	defaultEventHeartbeat    = 15 * time.Second
+	eventBatch               = 100
+	// eventWriteGrace - запас к интервалу keepalive при продлении срока записи в соединение
+	eventWriteGrace = 10 * time.Second
+	// eventRetry - через сколько браузер переподключается после обрыва, мс
+	eventRetry = 3000
+)
+
+// EventService отдает клиентам поток изменений документов (Server-Sent Events) из журнала
+// document_events. Новые записи обнаруживаются опросом журнала, поэтому поток видит
+// изменения, сделанные любым экземпляром сервера.
+type EventService struct {
+	repo         repository.Events
+	revocation   *RevocationService
+	pollInterval time.Duration
+	heartbeat    time.Duration
+	retention    time.Duration
+
+	mu          sync.Mutex
+	watermark   uint64
+	subscribers map[chan struct{}]struct{}
+	closed      chan struct{}
+	closeOnce   sync.Once
+}
+
+func NewEventService(r repository.Events, revocation *RevocationService, pollInterval, heartbeat,
+	retention time.Duration) *EventService {
+	if pollInterval <= 0 {
+		pollInterval = defaultEventPollInterval
+	}
+	if heartbeat <= 0 {
+		heartbeat = defaultEventHeartbeat
+	}
+	return &EventService{
+		repo:         r,
+		revocation:   revocation,
+		pollInterval: pollInterval,
+		heartbeat:    heartbeat,
+		retention:    retention,
+		subscribers:  make(map[chan struct{}]struct{}),
+		closed:       make(chan struct{}),
+	}
+}
+
+// Run следит за появлением новых событий и удаляет события старше retention
+// (0 - хранить все).
+func (s *EventService) Run(ctx context.Context) {
+	poll := time.NewTicker(s.pollInterval)
+	defer poll.Stop()
+	cleanup := time.NewTicker(time.Hour)
+	defer cleanup.Stop()
+
+	s.cleanup()
+	for {
+		select {
+		case <-ctx.Done():
+			s.Close()
+			return
+		case <-poll.C:
+			s.poll()
+		case <-cleanup.C:
+			s.cleanup()
+		}
+	}
+}
+
+// Close завершает открытые потоки, чтобы остановка сервера не ждала их до таймаута.
+func (s *EventService) Close() {
+	s.closeOnce.Do(func() { close(s.closed) })
+}
+
+func (s *EventService) poll() {
+	watermark, err := s.repo.GetEventsWatermark()
+	if err != nil {
+		logrus.Errorf("Failed to poll document events: %v", err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if watermark == s.watermark {
+		return
+	}
+	s.watermark = watermark
+	for ch := range s.subscribers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (s *EventService) cleanup() {
+	if s.retention <= 0 {
+		return
+	}
+	n, err := s.repo.DeleteEventsBefore(time.Now().Add(-s.retention))
+	if err != nil {
+		logrus.Errorf("Failed to delete old document events: %v", err)
+		return
+	}
+	if n > 0 {
+		logrus.Infof("Deleted %d old document events", n)
+	}
+}
+
+// resolveCursor возвращает позицию, с которой продолжить поток. Last-Event-ID - "<txid>-<id>";
+// числовой id прежнего формата ищется в журнале, а если событие уже удалено, поток
+// начинается с новых событий.
+func (s *EventService) resolveCursor(lastEventID string) (entity.EventCursor, error) {
+	if lastEventID == "" {
+		return s.repo.GetEventsHead()
+	}
+	if c, ok := entity.ParseEventCursor(lastEventID); ok {
+		return c, nil
+	}
+
+	id, err := strconv.ParseInt(lastEventID, 10, 64)
+	if err != nil || id < 0 {
+		return entity.EventCursor{}, ErrBadRequest
+	}
+	c, err := s.repo.GetEventCursor(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return s.repo.GetEventsHead()
+	}
+	return c, err
+}
+
+func (s *EventService) subscribe() chan struct{} {
+	ch := make(chan struct{}, 1)
+	s.mu.Lock()
+	s.subscribers[ch] = struct{}{}
+	s.mu.Unlock()
+	return ch
+}
+
+func (s *EventService) unsubscribe(ch chan struct{}) {
+	s.mu.Lock()
+	delete(s.subscribers, ch)
+	s.mu.Unlock()
+}
+
+// Stream пишет в ответ события документов, доступных пользователю, начиная после
+// lastEventID (пустой - только новые события). Поток завершается при отключении клиента,
+// истечении или отзыве токена; клиент переподключается с Last-Event-ID и ничего не теряет.
+func (s *EventService) Stream(ctx *gin.Context, claims *utils.JwtClaim, lastEventID string) error {
+	after, err := s.resolveCursor(lastEventID)
+	if err != nil {
+		return err
+	}
+
+	var expired <-chan time.Time
+	if claims.ExpiresAt != nil {
+		timer := time.NewTimer(time.Until(claims.ExpiresAt.Time))
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	wake := s.subscribe()
+	defer s.unsubscribe(wake)
+	heartbeat := time.NewTicker(s.heartbeat)
+	defer heartbeat.Stop()
+
+	w := ctx.Writer
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// Буферизующий прокси (nginx) задержал бы события
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	// У сервера общий WriteTimeout: без продления срока записи соединение оборвется через
+	// несколько секунд. Срок продлевается на интервал keepalive перед каждой записью.
+	rc := http.NewResponseController(w)
+	write := func(fn func(io.Writer) error) error {
+		if err := rc.SetWriteDeadline(time.Now().Add(s.heartbeat + eventWriteGrace)); err != nil &&
+			!errors.Is(err, http.ErrNotSupported) {
+			return err
+		}
+		if err := fn(w); err != nil {
+			return err
+		}
+		w.Flush()
+		return nil
+	}
+
+	if err := write(func(w io.Writer) error {
+		_, err := fmt.Fprintf(w, "retry: %d\n\n", eventRetry)
+		return err
+	}); err != nil {
+		return nil
+	}
+
+	for {
+		events, err := s.repo.GetEvents(claims.UserID, after, eventBatch)
+		if err != nil {
+			logrus.Errorf("Failed to read document events for %s: %v", claims.UserID, err)
+			return nil
+		}
+		for _, e := range events {
+			if err := write(func(w io.Writer) error {
+				return sse.Encode(w, sse.Event{Id: e.Cursor().String(), Event: e.Type, Data: e})
+			}); err != nil {
+				return nil
+			}
+			after = e.Cursor()
+		}
+		if len(events) == eventBatch {
+			continue
+		}
+
+		select {
+		case <-ctx.Request.Context().Done():
+			return nil
+		case <-s.closed:
+			return nil
+		case <-expired:
+			return nil
+		case <-wake:
+		case <-heartbeat.C:
+			if s.revocation.IsRevoked(claims) {
+				return nil
+			}
+			if err := write(func(w io.Writer) error {
+				_, err := io.WriteString(w, ": keepalive\n\n")
+				return err
+			}); err != nil {
+				return nil
+			}
+		}
+	}
+}
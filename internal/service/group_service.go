@@ -0,0 +1,156 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/olenka-91/DocsServer/internal/entity"
+	"github.com/olenka-91/DocsServer/internal/repository"
+)
+
+type GroupService struct {
+	repo  repository.Groups
+	auth  repository.Authorization
+	audit *AuditService
+}
+
+func NewGroupService(r repository.Groups, a repository.Authorization, audit *AuditService) *GroupService {
+	return &GroupService{repo: r, auth: a, audit: audit}
+}
+
+// record пишет в журнал действие с группой. Попытка изменить группу, в которой
+// пользователь не состоит, записывается как отказ.
+func (s *GroupService) record(ctx *gin.Context, action string, groupID uuid.UUID, details entity.JSONB,
+	denied bool, err error) {
+	e := entity.AuditEntry{
+		Action:     action,
+		TargetType: entity.AuditTargetGroup,
+		TargetID:   groupID.String(),
+		Details:    details,
+	}
+	if denied {
+		e.Outcome = entity.AuditDenied
+	}
+	s.audit.Record(ctx, e, err)
+}
+
+func (s *GroupService) CreateGroup(userID uuid.UUID, name string) (*entity.Group, error) {
+	group, err := s.repo.CreateGroup(strings.TrimSpace(name), userID)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == pgUniqueViolation {
+			return nil, ErrConflict
+		}
+		return nil, err
+	}
+	return group, nil
+}
+
+func (s *GroupService) GetUserGroups(userID uuid.UUID) ([]entity.Group, error) {
+	return s.repo.GetUserGroups(userID)
+}
+
+// GetGroup доступен только участникам группы.
+func (s *GroupService) GetGroup(userID, groupID uuid.UUID) (*entity.GroupDetails, error) {
+	isMember, isOwner, err := s.repo.GetMembership(groupID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !isMember {
+		return nil, ErrNotFound
+	}
+
+	group, err := s.repo.GetGroup(groupID)
+	if err != nil {
+		return nil, err
+	}
+	group.IsOwner = isOwner
+
+	members, err := s.repo.GetMembers(groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entity.GroupDetails{Group: *group, Members: members}, nil
+}
+
+func (s *GroupService) AddMember(ctx *gin.Context, userID, groupID uuid.UUID,
+	input entity.AddGroupMemberRequest) (err error) {
+	var denied bool
+	defer func() {
+		s.record(ctx, entity.AuditMemberAdd, groupID,
+			entity.JSONB{"member": strings.ToLower(input.Login), "owner": input.Owner}, denied, err)
+	}()
+
+	if denied, err = s.checkOwner(userID, groupID); err != nil {
+		return err
+	}
+
+	member, err := s.auth.GetUserByLogin(strings.ToLower(input.Login))
+	if err != nil {
+		return ErrUserNotFound
+	}
+
+	err = s.repo.AddMember(groupID, member.ID, input.Owner)
+	if errors.Is(err, repository.ErrLastGroupOwner) {
+		return ErrConflict
+	}
+	return err
+}
+
+// RemoveMember: владелец удаляет любого участника, участник может выйти из группы сам.
+func (s *GroupService) RemoveMember(ctx *gin.Context, userID, groupID uuid.UUID, login string) (err error) {
+	var denied bool
+	defer func() {
+		s.record(ctx, entity.AuditMemberRemove, groupID, entity.JSONB{"member": strings.ToLower(login)}, denied, err)
+	}()
+
+	member, err := s.auth.GetUserByLogin(strings.ToLower(login))
+	if err != nil {
+		return ErrUserNotFound
+	}
+
+	if member.ID != userID {
+		if denied, err = s.checkOwner(userID, groupID); err != nil {
+			return err
+		}
+	}
+
+	err = s.repo.RemoveMember(groupID, member.ID)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return ErrUserNotFound
+	case errors.Is(err, repository.ErrLastGroupOwner):
+		return ErrConflict
+	}
+	return err
+}
+
+func (s *GroupService) DeleteGroup(ctx *gin.Context, userID, groupID uuid.UUID) (err error) {
+	var denied bool
+	defer func() { s.record(ctx, entity.AuditGroupDelete, groupID, nil, denied, err) }()
+
+	if denied, err = s.checkOwner(userID, groupID); err != nil {
+		return err
+	}
+	return s.repo.DeleteGroup(groupID)
+}
+
+// checkOwner проверяет, что userID - владелец группы; denied - пользователь не владелец.
+func (s *GroupService) checkOwner(userID, groupID uuid.UUID) (denied bool, err error) {
+	isMember, isOwner, err := s.repo.GetMembership(groupID, userID)
+	if err != nil {
+		return false, err
+	}
+	if !isMember {
+		return true, ErrNotFound
+	}
+	if !isOwner {
+		return true, ErrForbidden
+	}
+	return false, nil
+}
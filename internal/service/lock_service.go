@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/olenka-91/DocsServer/internal/entity"
+	"github.com/olenka-91/DocsServer/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultLockTTL      = 30 * time.Minute
+	defaultLockMaxTTL   = 24 * time.Hour
+	lockCleanupInterval = 10 * time.Minute
+)
+
+// LockService ведет блокировки документов на время редактирования. Блокировки хранятся
+// в базе, поэтому действуют для всех экземпляров сервера; истекшая блокировка не действует.
+type LockService struct {
+	repo   repository.Locks
+	audit  *AuditService
+	ttl    time.Duration
+	maxTTL time.Duration
+}
+
+func NewLockService(r repository.Locks, audit *AuditService, ttl, maxTTL time.Duration) *LockService {
+	if ttl <= 0 {
+		ttl = defaultLockTTL
+	}
+	if maxTTL <= 0 {
+		maxTTL = defaultLockMaxTTL
+	}
+	if ttl > maxTTL {
+		ttl = maxTTL
+	}
+	return &LockService{repo: r, audit: audit, ttl: ttl, maxTTL: maxTTL}
+}
+
+// setLockHeaders добавляет в ответ владельца и срок блокировки документа.
+func setLockHeaders(ctx *gin.Context, lock *entity.DocumentLock) {
+	if lock == nil {
+		return
+	}
+	ctx.Header("X-Lock-Owner", lock.Owner)
+	ctx.Header("X-Lock-Expires", lock.Expires.UTC().Format(http.TimeFormat))
+}
+
+// Get возвращает действующую блокировку документа или nil.
+func (s *LockService) Get(docID uuid.UUID) (*entity.DocumentLock, error) {
+	return s.repo.GetLock(docID)
+}
+
+// lockedError переводит ErrDocLocked репозитория в ErrLocked и добавляет в заголовки
+// ответа сведения о блокировке, если она еще действует.
+func (s *LockService) lockedError(ctx *gin.Context, docID uuid.UUID, err error) error {
+	if !errors.Is(err, repository.ErrDocLocked) {
+		return err
+	}
+	if lock, lerr := s.repo.GetLock(docID); lerr == nil {
+		setLockHeaders(ctx, lock)
+	}
+	return ErrLocked
+}
+
+// Acquire блокирует документ за пользователем userID на ttl (0 - срок по умолчанию).
+// Повторный вызов владельцем блокировки продлевает ее. Если документ заблокирован
+// другим пользователем, возвращает его блокировку и ErrLocked.
+func (s *LockService) Acquire(ctx *gin.Context, doc *entity.Document, userID uuid.UUID,
+	ttl time.Duration) (lock *entity.DocumentLock, err error) {
+	defer func() {
+		s.audit.RecordDoc(ctx, entity.AuditDocLock, doc.ID, doc, err)
+	}()
+
+	if ttl <= 0 {
+		ttl = s.ttl
+	}
+	if ttl > s.maxTTL {
+		ttl = s.maxTTL
+	}
+
+	// Чужая блокировка может истечь между попытками, тогда вторая попытка ее перезапишет
+	for attempt := 0; attempt < 2; attempt++ {
+		lock, err = s.repo.AcquireLock(doc.ID, userID, ttl)
+		if err == nil {
+			setLockHeaders(ctx, lock)
+			return lock, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		if lock, err = s.repo.GetLock(doc.ID); err != nil {
+			return nil, err
+		}
+		if lock != nil {
+			setLockHeaders(ctx, lock)
+			return lock, ErrLocked
+		}
+	}
+	return nil, ErrLocked
+}
+
+// Release снимает блокировку документа. Блокировку пользователя userID снимает он сам;
+// чужую - только при force (владелец документа или администратор).
+func (s *LockService) Release(ctx *gin.Context, doc *entity.Document, userID *uuid.UUID,
+	force bool) (err error) {
+	var lock *entity.DocumentLock
+	defer func() {
+		e := entity.AuditEntry{
+			Action:     entity.AuditDocUnlock,
+			TargetType: entity.AuditTargetDocument,
+			TargetID:   doc.ID.String(),
+			TargetName: doc.Name,
+		}
+		if err == nil && (userID == nil || lock.UserID != *userID) {
+			e.Details = entity.JSONB{"forced": true, "lock_owner": lock.Owner}
+		}
+		s.audit.Record(ctx, e, err)
+	}()
+
+	if userID != nil {
+		lock, err = s.repo.ReleaseLock(doc.ID, userID)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if !force {
+			if lock, err = s.repo.GetLock(doc.ID); err != nil {
+				return err
+			}
+			if lock != nil {
+				setLockHeaders(ctx, lock)
+				return ErrLocked
+			}
+			return ErrNotFound
+		}
+	}
+
+	lock, err = s.repo.ReleaseLock(doc.ID, nil)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// Run периодически удаляет истекшие блокировки. Истекшая блокировка не действует
+// и без этого; очистка только не дает таблице расти.
+func (s *LockService) Run(ctx context.Context) {
+	ticker := time.NewTicker(lockCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		n, err := s.repo.DeleteExpiredLocks()
+		if err != nil {
+			logrus.Errorf("Failed to delete expired document locks: %v", err)
+			continue
+		}
+		if n > 0 {
+			logrus.Debugf("Deleted %d expired document locks", n)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/olenka-91/DocsServer/internal/entity"
+	"github.com/olenka-91/DocsServer/internal/metadata"
+	"github.com/olenka-91/DocsServer/internal/repository"
+	"github.com/olenka-91/DocsServer/internal/storage"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	metadataBatch = 100
+	// metadataTimeout ограничивает разбор одного файла; объем чтения ограничивает сам
+	// пакет metadata
+	metadataTimeout = 30 * time.Second
+)
+
+// MetadataService извлекает встроенные метаданные файлов в фоне. Файл разбирается
+// после проверки антивирусом, чтобы непроверенные данные не попадали в парсеры.
+type MetadataService struct {
+	repo    repository.Metadata
+	storage *storage.FileStorage
+	opts    metadata.Options
+	wake    chan struct{}
+}
+
+func NewMetadataService(r repository.Metadata, fs *storage.FileStorage, opts metadata.Options) *MetadataService {
+	return &MetadataService{repo: r, storage: fs, opts: opts, wake: make(chan struct{}, 1)}
+}
+
+// Notify сообщает о проверенных документах без метаданных.
+func (s *MetadataService) Notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run извлекает метаданные при запуске, чтобы подобрать документы, оставшиеся
+// необработанными после перезапуска, и после каждого Notify.
+func (s *MetadataService) Run(ctx context.Context) {
+	for {
+		if _, err := s.ExtractMissing(ctx); err != nil && ctx.Err() == nil {
+			logrus.Errorf("Failed to extract metadata: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		}
+	}
+}
+
+// extract читает метаданные сохраненного файла документа. Ошибка разбора, в том числе
+// превышение времени или объема чтения, дает пустые метаданные, и документ повторно не
+// обрабатывается. ok=false - сервис останавливается и результат сохранять не нужно.
+func (s *MetadataService) extract(ctx context.Context, doc *entity.Document) (meta entity.JSONB, ok bool) {
+	if !metadata.Supported(doc.Mime) {
+		return entity.JSONB{}, true
+	}
+
+	rc, err := s.storage.Open(doc)
+	if err != nil {
+		logrus.Warnf("Failed to open doc %s for metadata extraction: %v", doc.ID, err)
+		return entity.JSONB{}, true
+	}
+	defer rc.Close()
+
+	extractCtx, cancel := context.WithTimeout(ctx, metadataTimeout)
+	defer cancel()
+	meta, err = metadata.Extract(extractCtx, doc.Mime, rc, s.opts)
+	if ctx.Err() != nil {
+		return nil, false
+	}
+	if err != nil {
+		logrus.Warnf("Failed to extract metadata of doc %s: %v", doc.ID, err)
+		return entity.JSONB{}, true
+	}
+	return meta, true
+}
+
+// ExtractMissing извлекает метаданные проверенных документов, для которых их еще нет.
+func (s *MetadataService) ExtractMissing(ctx context.Context) (int64, error) {
+	var n int64
+	for {
+		docs, err := s.repo.GetDocsWithoutMetadata(metadataBatch)
+		if err != nil {
+			return n, err
+		}
+		for i := range docs {
+			meta, ok := s.extract(ctx, &docs[i])
+			if !ok {
+				return n, ctx.Err()
+			}
+			if err := s.repo.SetDocMetadata(docs[i].ID, meta); err != nil {
+				return n, err
+			}
+			n++
+		}
+		if len(docs) < metadataBatch {
+			if n > 0 {
+				logrus.Infof("Metadata extracted for %d documents", n)
+			}
+			return n, nil
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"mime"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/olenka-91/DocsServer/internal/entity"
+	"github.com/olenka-91/DocsServer/internal/repository"
+	"github.com/olenka-91/DocsServer/internal/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// Действие при расхождении типа, определенного по содержимому, с заявленным
+const (
+	MimeMismatchReject   = "reject"
+	MimeMismatchOverride = "override"
+	MimeMismatchWarn     = "warn"
+)
+
+type MimePolicyService struct {
+	repo     repository.UploadPolicies
+	mismatch string
+	policy   entity.MimePolicy
+}
+
+func NewMimePolicyService(r repository.UploadPolicies, mismatch string, policy entity.MimePolicy) *MimePolicyService {
+	return &MimePolicyService{repo: r, mismatch: mismatch, policy: policy}
+}
+
+// Resolve выбирает тип загружаемого файла по заявленному клиентом типу, расширению
+// имени и типу, определенному по содержимому, и проверяет его по спискам развертывания
+// и пользователя.
+func (s *MimePolicyService) Resolve(userID uuid.UUID, name, declared, sniffed string) (string, error) {
+	byName := mime.TypeByExtension(filepath.Ext(name))
+
+	// Как и раньше, в первую очередь используется тип по расширению
+	claimed := byName
+	if !storage.KnownMime(claimed) {
+		claimed = declared
+	}
+
+	mimeType := claimed
+	switch {
+	case !storage.KnownMime(claimed):
+		mimeType = sniffed
+	case !storage.MimeCompatible(byName, sniffed) || !storage.MimeCompatible(declared, sniffed):
+		switch s.mismatch {
+		case MimeMismatchReject:
+			logrus.Warnf("Rejected upload %q: declared %q, by name %q, content %q", name, declared, byName, sniffed)
+			return "", ErrMimeMismatch
+		case MimeMismatchOverride:
+			logrus.Infof("Upload %q: declared %q, by name %q, content %q - using content type", name, declared, byName, sniffed)
+			mimeType = sniffed
+		default:
+			logrus.Warnf("Upload %q: declared %q, by name %q, content %q", name, declared, byName, sniffed)
+		}
+	}
+
+	user, err := s.repo.GetUserMimePolicy(userID)
+	if err != nil {
+		return "", err
+	}
+
+	// Запреты проверяются для всех известных типов файла, разрешения - для итогового
+	// типа и типа по содержимому, чтобы файл нельзя было провести под чужим расширением.
+	for _, t := range []string{mimeType, sniffed, byName, declared} {
+		if storage.KnownMime(t) && (matchesAny(s.policy.Deny, t) || matchesAny(user.Deny, t)) {
+			logrus.Warnf("Rejected upload %q: type %q is denied", name, t)
+			return "", ErrMimeNotAllowed
+		}
+	}
+	for _, t := range []string{mimeType, sniffed} {
+		if t == sniffed && !storage.KnownMime(t) {
+			continue
+		}
+		if !allowed(s.policy.Allow, t) || !allowed(user.Allow, t) {
+			logrus.Warnf("Rejected upload %q: type %q is not allowed", name, t)
+			return "", ErrMimeNotAllowed
+		}
+	}
+	return mimeType, nil
+}
+
+func allowed(allow []string, mimeType string) bool {
+	return len(allow) == 0 || matchesAny(allow, mimeType)
+}
+
+func matchesAny(patterns []string, mimeType string) bool {
+	for _, pattern := range patterns {
+		if storage.MimeMatches(pattern, mimeType) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *MimePolicyService) SetMimePolicy(login string, input entity.MimePolicy) (*entity.MimePolicy, error) {
+	policy := entity.MimePolicy{}
+	var err error
+	if policy.Allow, err = normalizePatterns(input.Allow); err != nil {
+		return nil, err
+	}
+	if policy.Deny, err = normalizePatterns(input.Deny); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.repo.SetUserMimePolicy(strings.ToLower(login), policy); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return &policy, nil
+}
+
+func normalizePatterns(patterns []string) ([]string, error) {
+	result := []string{}
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == "" {
+			continue
+		}
+		if !strings.Contains(pattern, "/") && !strings.HasPrefix(pattern, "*") {
+			return nil, ErrBadRequest
+		}
+		result = append(result, pattern)
+	}
+	return result, nil
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/olenka-91/DocsServer/internal/entity"
+)
+
+// stubUploadPolicies отдает одну политику типов для всех пользователей.
+type stubUploadPolicies struct {
+	mime entity.MimePolicy
+}
+
+func (s *stubUploadPolicies) GetUserMimePolicy(uuid.UUID) (*entity.MimePolicy, error) {
+	return &s.mime, nil
+}
+
+func (s *stubUploadPolicies) SetUserMimePolicy(string, entity.MimePolicy) (uuid.UUID, error) {
+	return uuid.Nil, nil
+}
+
+func (s *stubUploadPolicies) GetUserScrubPolicy(uuid.UUID) (*entity.ScrubPolicy, error) {
+	return &entity.ScrubPolicy{}, nil
+}
+
+func (s *stubUploadPolicies) SetUserScrubPolicy(string, entity.ScrubPolicy) (uuid.UUID, error) {
+	return uuid.Nil, nil
+}
+
+func TestMimePolicyResolve(t *testing.T) {
+	const (
+		png = "image/png"
+		pdf = "application/pdf"
+		exe = "application/vnd.microsoft.portable-executable"
+	)
+	tests := []struct {
+		name     string
+		file     string
+		declared string
+		sniffed  string
+		mismatch string
+		policy   entity.MimePolicy
+		user     entity.MimePolicy
+		want     string
+		wantErr  error
+	}{
+		{name: "matching types", file: "a.png", declared: png, sniffed: png, mismatch: MimeMismatchReject, want: png},
+		{name: "type by name first", file: "a.png", declared: "application/octet-stream", sniffed: png,
+			mismatch: MimeMismatchReject, want: png},
+		{name: "unknown name and type", file: "data", sniffed: pdf, mismatch: MimeMismatchReject, want: pdf},
+		{name: "text subtype", file: "a.json", declared: "application/json", sniffed: "text/plain; charset=utf-8",
+			mismatch: MimeMismatchReject, want: "application/json"},
+
+		{name: "mismatch reject", file: "a.png", declared: png, sniffed: pdf, mismatch: MimeMismatchReject,
+			wantErr: ErrMimeMismatch},
+		{name: "mismatch override", file: "a.png", declared: png, sniffed: pdf, mismatch: MimeMismatchOverride,
+			want: pdf},
+		{name: "mismatch warn", file: "a.png", declared: png, sniffed: pdf, mismatch: MimeMismatchWarn, want: png},
+		// Заявленный тип расходится с содержимым, хотя расширение с ним совпадает
+		{name: "declared mismatch", file: "a.pdf", declared: png, sniffed: pdf, mismatch: MimeMismatchReject,
+			wantErr: ErrMimeMismatch},
+
+		{name: "denied by deployment", file: "a.pdf", declared: pdf, sniffed: pdf, mismatch: MimeMismatchWarn,
+			policy: entity.MimePolicy{Deny: []string{pdf}}, wantErr: ErrMimeNotAllowed},
+		{name: "denied by user", file: "a.png", declared: png, sniffed: png, mismatch: MimeMismatchWarn,
+			user: entity.MimePolicy{Deny: []string{"image/*"}}, wantErr: ErrMimeNotAllowed},
+		// Запрет по содержимому действует и при mismatch=warn под чужим расширением
+		{name: "denied content under other name", file: "a.pdf", declared: pdf, sniffed: exe,
+			mismatch: MimeMismatchWarn, policy: entity.MimePolicy{Deny: []string{exe}}, wantErr: ErrMimeNotAllowed},
+		{name: "allowed", file: "a.png", declared: png, sniffed: png, mismatch: MimeMismatchWarn,
+			policy: entity.MimePolicy{Allow: []string{"image/*"}}, want: png},
+		{name: "content not allowed", file: "a.png", declared: png, sniffed: pdf, mismatch: MimeMismatchWarn,
+			policy: entity.MimePolicy{Allow: []string{"image/*"}}, wantErr: ErrMimeNotAllowed},
+		{name: "not allowed by user", file: "a.pdf", declared: pdf, sniffed: pdf, mismatch: MimeMismatchWarn,
+			user: entity.MimePolicy{Allow: []string{"image/*"}}, wantErr: ErrMimeNotAllowed},
+		// Нераспознанное содержимое не проверяется по списку разрешенных
+		{name: "unknown content allowed", file: "a.png", declared: png, sniffed: "application/octet-stream",
+			mismatch: MimeMismatchReject, policy: entity.MimePolicy{Allow: []string{"image/*"}}, want: png},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewMimePolicyService(&stubUploadPolicies{mime: tt.user}, tt.mismatch, tt.policy)
+			got, err := s.Resolve(uuid.New(), tt.file, tt.declared, tt.sniffed)
+			if !errors.Is(err, tt.wantErr) || got != tt.want {
+				t.Errorf("Resolve = %q, %v; want %q, %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNormalizePatterns(t *testing.T) {
+	got, err := normalizePatterns([]string{" Image/* ", "", "*+XML"})
+	if err != nil || len(got) != 2 || got[0] != "image/*" || got[1] != "*+xml" {
+		t.Errorf("normalizePatterns = %q, %v", got, err)
+	}
+	if _, err := normalizePatterns([]string{"png"}); !errors.Is(err, ErrBadRequest) {
+		t.Errorf("normalizePatterns without slash error = %v, want ErrBadRequest", err)
+	}
+}
@@ -0,0 +1,195 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/olenka-91/DocsServer/internal/entity"
+	"github.com/olenka-91/DocsServer/internal/repository"
+	"github.com/olenka-91/DocsServer/internal/storage"
+	"github.com/sirupsen/logrus"
+)
+
+type QuotaService struct {
+	repo     repository.Quotas
+	storage  *storage.FileStorage
+	defaults entity.Quota
+	// typeLimits - ограничения размера файла по шаблонам MIME-типов
+	typeLimits map[string]int64
+}
+
+func NewQuotaService(r repository.Quotas, fs *storage.FileStorage, defaults entity.Quota,
+	typeLimits map[string]int64) *QuotaService {
+	return &QuotaService{repo: r, storage: fs, defaults: defaults, typeLimits: typeLimits}
+}
+
+// Effective возвращает действующую квоту пользователя: индивидуальные значения
+// заменяют значения по умолчанию по отдельности.
+func (s *QuotaService) Effective(userID uuid.UUID) (entity.Quota, bool, error) {
+	quota := s.defaults
+	custom, err := s.repo.GetUserQuota(userID)
+	if err != nil {
+		return quota, false, err
+	}
+	if custom.MaxBytes != nil {
+		quota.MaxBytes = *custom.MaxBytes
+	}
+	if custom.MaxDocs != nil {
+		quota.MaxDocs = *custom.MaxDocs
+	}
+	if custom.MaxFileSize != nil {
+		quota.MaxFileSize = *custom.MaxFileSize
+	}
+	return quota, custom.MaxBytes != nil || custom.MaxDocs != nil || custom.MaxFileSize != nil, nil
+}
+
+// Check проверяет, что пользователь может создать еще один документ, и возвращает
+// его квоту и оставшееся место (math.MaxInt64 - без ограничения). Окончательная
+// проверка выполняется при создании документа.
+func (s *QuotaService) Check(userID uuid.UUID) (entity.Quota, int64, error) {
+	quota, _, err := s.Effective(userID)
+	if err != nil {
+		return quota, 0, err
+	}
+
+	usage, err := s.repo.GetUsage(userID)
+	if err != nil {
+		return quota, 0, err
+	}
+	if quota.MaxDocs > 0 && usage.DocsCount+1 > quota.MaxDocs {
+		return quota, 0, ErrQuotaExceeded
+	}
+	if quota.MaxBytes == 0 {
+		return quota, math.MaxInt64, nil
+	}
+	return quota, max(quota.MaxBytes-usage.BytesUsed, 0), nil
+}
+
+// FileSizeLimit - наибольший допустимый размер файла такого типа (0 - без ограничения).
+// Действуют все подходящие ограничения: пользователя или MAX_FILE_SIZE, по типу и
+// общий объем квоты.
+func (s *QuotaService) FileSizeLimit(quota entity.Quota, mimeType string) int64 {
+	limit := minLimit(quota.MaxFileSize, quota.MaxBytes)
+	for pattern, size := range s.typeLimits {
+		if storage.MimeMatches(pattern, mimeType) {
+			limit = minLimit(limit, size)
+		}
+	}
+	return limit
+}
+
+// minLimit выбирает меньшее из ограничений, где 0 - отсутствие ограничения.
+func minLimit(a, b int64) int64 {
+	if a == 0 || (b > 0 && b < a) {
+		return b
+	}
+	return a
+}
+
+// uploadReader прерывает загрузку, как только файл превысит ограничение размера или
+// оставшееся место в квоте, и запоминает причину.
+type uploadReader struct {
+	r         io.Reader
+	n         int64
+	maxSize   int64 // 0 - без ограничения
+	available int64
+	err       error
+}
+
+func newUploadReader(r io.Reader, maxSize, available int64) *uploadReader {
+	return &uploadReader{r: r, maxSize: maxSize, available: available}
+}
+
+func (u *uploadReader) Read(p []byte) (int, error) {
+	if u.err != nil {
+		return 0, u.err
+	}
+	n, err := u.r.Read(p)
+	u.n += int64(n)
+
+	switch {
+	case u.maxSize > 0 && u.n > u.maxSize:
+		u.err = ErrFileTooLarge
+	case u.n > u.available:
+		u.err = ErrQuotaExceeded
+	case errors.Is(err, ErrPartAfterFile):
+		u.err = ErrPartAfterFile
+	case err != nil && err != io.EOF:
+		// Клиент оборвал загрузку или прислал некорректное тело запроса
+		logrus.Debugf("Upload body read failed: %v", err)
+		u.err = ErrBadRequest
+	}
+	if u.err != nil {
+		return 0, u.err
+	}
+	return n, err
+}
+
+func (s *QuotaService) GetUsage(userID uuid.UUID) (*entity.UsageResponse, error) {
+	usage, err := s.repo.GetUsage(userID)
+	if err != nil {
+		return nil, err
+	}
+	quota, custom, err := s.Effective(userID)
+	if err != nil {
+		return nil, err
+	}
+	return &entity.UsageResponse{Usage: *usage, Quota: quota, CustomQuota: custom}, nil
+}
+
+// SetQuota заменяет индивидуальную квоту целиком: поле, не переданное в запросе,
+// возвращает значение по умолчанию.
+func (s *QuotaService) SetQuota(login string, input entity.SetQuotaRequest) (*entity.UsageResponse, error) {
+	userID, err := s.repo.SetUserQuota(strings.ToLower(login), entity.UserQuota{
+		MaxBytes:    input.MaxBytes,
+		MaxDocs:     input.MaxDocs,
+		MaxFileSize: input.MaxFileSize,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return s.GetUsage(userID)
+}
+
+// RecomputeUsage заполняет размер документов, загруженных до учета размера, по
+// файлам в хранилище и пересчитывает учет всех пользователей.
+func (s *QuotaService) RecomputeUsage(ctx context.Context) (*entity.RecomputeUsageResult, error) {
+	docs, err := s.repo.GetDocsWithoutSize()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &entity.RecomputeUsageResult{}
+	for i := range docs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		size, err := s.storage.ContentSize(&docs[i])
+		if err != nil {
+			logrus.Warnf("Failed to get size of doc %s: %v", docs[i].ID, err)
+			continue
+		}
+		updated, err := s.repo.SetDocSize(docs[i].ID, size)
+		if err != nil {
+			return nil, err
+		}
+		if updated {
+			result.SizesFilled++
+		}
+	}
+
+	result.Users, err = s.repo.RecomputeUsage()
+	if err != nil {
+		return nil, err
+	}
+	logrus.Infof("Usage recomputed: %d users, %d document sizes filled", result.Users, result.SizesFilled)
+	return result, nil
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/olenka-91/DocsServer/internal/entity"
+)
+
+// stubQuotas хранит индивидуальную квоту одного пользователя.
+type stubQuotas struct {
+	userID uuid.UUID
+	quota  entity.UserQuota
+}
+
+func (s *stubQuotas) GetUsage(uuid.UUID) (*entity.Usage, error) { return &entity.Usage{}, nil }
+
+func (s *stubQuotas) GetUserQuota(uuid.UUID) (*entity.UserQuota, error) {
+	quota := s.quota
+	return &quota, nil
+}
+
+func (s *stubQuotas) SetUserQuota(_ string, quota entity.UserQuota) (uuid.UUID, error) {
+	s.quota = quota
+	return s.userID, nil
+}
+
+func (s *stubQuotas) GetDocsWithoutSize() ([]entity.Document, error) { return nil, nil }
+func (s *stubQuotas) SetDocSize(uuid.UUID, int64) (bool, error)      { return false, nil }
+func (s *stubQuotas) RecomputeUsage() (int64, error)                 { return 0, nil }
+
+func TestSetQuota(t *testing.T) {
+	defaults := entity.Quota{MaxBytes: 1 << 30, MaxDocs: 100, MaxFileSize: 10 << 20}
+	repo := &stubQuotas{userID: uuid.New()}
+	s := NewQuotaService(repo, nil, defaults, nil)
+
+	maxFileSize := int64(50 << 20)
+	got, err := s.SetQuota("Alice", entity.SetQuotaRequest{MaxFileSize: &maxFileSize})
+	if err != nil {
+		t.Fatalf("SetQuota: %v", err)
+	}
+	want := entity.Quota{MaxBytes: defaults.MaxBytes, MaxDocs: defaults.MaxDocs, MaxFileSize: maxFileSize}
+	if got.Quota != want || !got.CustomQuota {
+		t.Errorf("quota = %+v, custom %t; want %+v, custom", got.Quota, got.CustomQuota, want)
+	}
+
+	// Квота заменяется целиком: без max_file_size действует значение по умолчанию
+	maxDocs := int64(5)
+	if got, err = s.SetQuota("alice", entity.SetQuotaRequest{MaxDocs: &maxDocs}); err != nil {
+		t.Fatalf("SetQuota: %v", err)
+	}
+	want = entity.Quota{MaxBytes: defaults.MaxBytes, MaxDocs: maxDocs, MaxFileSize: defaults.MaxFileSize}
+	if got.Quota != want {
+		t.Errorf("quota after update without max_file_size = %+v, want %+v", got.Quota, want)
+	}
+
+	if got, err = s.SetQuota("alice", entity.SetQuotaRequest{}); err != nil || got.Quota != defaults || got.CustomQuota {
+		t.Errorf("reset quota = %+v, custom %t, %v; want defaults", got.Quota, got.CustomQuota, err)
+	}
+}
@@ -0,0 +1,220 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/olenka-91/DocsServer/internal/entity"
+	"github.com/olenka-91/DocsServer/internal/repository"
+	"github.com/olenka-91/DocsServer/internal/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// ReconcileService ищет расхождения между documents и хранилищем: файлы без
+// документа, документы без файла и зависшие загрузки. В отличие от ScrubService
+// содержимое файлов не читается, поэтому проверка дешевая.
+type ReconcileService struct {
+	repo     repository.Integrity
+	storage  *storage.FileStorage
+	interval time.Duration
+	grace    time.Duration
+	opts     entity.ReconcileOptions
+	// Больше стольких документов без файла (числом или долей) записи не удаляются:
+	// скорее не смонтировано хранилище, чем пропали файлы. 0 - без ограничения
+	maxMissing      int
+	maxMissingRatio float64
+}
+
+func NewReconcileService(r repository.Integrity, fs *storage.FileStorage, interval, grace time.Duration,
+	opts entity.ReconcileOptions, maxMissing int, maxMissingRatio float64) *ReconcileService {
+	return &ReconcileService{repo: r, storage: fs, interval: interval, grace: grace, opts: opts,
+		maxMissing: maxMissing, maxMissingRatio: maxMissingRatio}
+}
+
+// Run выполняет сверку по расписанию до отмены ctx; interval <= 0 отключает ее.
+func (s *ReconcileService) Run(ctx context.Context) {
+	if s.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.Reconcile(ctx, s.opts); err != nil {
+				logrus.Errorf("Reconciliation failed: %v", err)
+			}
+		}
+	}
+}
+
+// Reconcile находит расхождения и, если opts.Repair, исправляет их: удаляет лишние файлы
+// и зависшие загрузки. Записи документов, файл которых потерян, удаляются только
+// с opts.DeleteMissing и только если хранилище выглядит исправным (см. deleteMissing).
+//
+// Порядок чтения важен: сначала незавершенные загрузки, затем документы, затем файлы.
+// Загрузка, начатая после чтения списка, дает файл моложе grace, и он пропускается.
+func (s *ReconcileService) Reconcile(ctx context.Context, opts entity.ReconcileOptions) (*entity.ReconcileReport, error) {
+	repair := opts.Repair
+	report := &entity.ReconcileReport{
+		StartedAt:        time.Now(),
+		ReconcileOptions: opts,
+		OrphanedFiles:    []entity.ScrubIssue{},
+		MissingFiles:     []entity.ScrubIssue{},
+		StaleUploads:     []entity.ScrubIssue{},
+		Errors:           []entity.ScrubIssue{},
+	}
+	staleBefore := report.StartedAt.Add(-s.grace)
+
+	pending, err := s.repo.GetPendingUploads()
+	if err != nil {
+		return nil, err
+	}
+	docs, err := s.repo.GetFileDocs()
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[string]bool, len(docs)+len(pending))
+	// Миниатюры принадлежат файлу документа и лишние, только если документа нет
+	docIDs := make(map[uuid.UUID]bool, len(docs))
+	for _, p := range pending {
+		known[p.Key] = true
+		if p.Created.Before(staleBefore) {
+			s.reconcileStaleUpload(p, report)
+		}
+	}
+
+	for i := range docs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		doc := &docs[i]
+		known[s.storage.BlobKey(doc)] = true
+		docIDs[doc.ID] = true
+		s.reconcileDoc(doc, report)
+	}
+
+	// knownObjects - сколько файлов хранилища принадлежат известным документам и загрузкам
+	knownObjects := 0
+	err = s.storage.WalkBlobs(func(id uuid.UUID, key string, info storage.BlobInfo) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if known[key] {
+			knownObjects++
+			return nil
+		}
+		if (storage.IsDerivedKey(key) && docIDs[id]) || !info.ModTime.Before(staleBefore) {
+			return nil
+		}
+
+		issue := entity.ScrubIssue{DocID: id, Key: key}
+		logrus.Warnf("Reconcile: orphaned file %s", key)
+		if repair {
+			issue.Error = errString(s.storage.RemoveBlob(key))
+			issue.Repaired = issue.Error == ""
+		}
+		report.OrphanedFiles = append(report.OrphanedFiles, issue)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if repair && opts.DeleteMissing {
+		s.deleteMissing(report, len(docs), knownObjects)
+	}
+
+	report.FinishedAt = time.Now()
+	logrus.Infof("Reconciliation finished: orphaned=%d missing=%d stale=%d errors=%d repair=%t",
+		len(report.OrphanedFiles), len(report.MissingFiles), len(report.StaleUploads),
+		len(report.Errors), repair)
+	return report, nil
+}
+
+func (s *ReconcileService) reconcileStaleUpload(p entity.PendingUpload, report *entity.ReconcileReport) {
+	// Документ мог быть создан, а запись о загрузке осталась - тогда файл трогать нельзя
+	exists, err := s.repo.DocExists(p.DocID)
+	if err != nil {
+		report.Errors = append(report.Errors, entity.ScrubIssue{DocID: p.DocID, Key: p.Key, Error: err.Error()})
+		return
+	}
+
+	issue := entity.ScrubIssue{DocID: p.DocID, Key: p.Key}
+	logrus.Warnf("Reconcile: stale upload %s", p.DocID)
+	if report.Repair {
+		if !exists {
+			issue.Error = errString(s.storage.RemoveBlob(p.Key))
+		}
+		if issue.Error == "" {
+			issue.Error = errString(s.repo.DeletePendingUpload(p.DocID))
+		}
+		issue.Repaired = issue.Error == ""
+	}
+	report.StaleUploads = append(report.StaleUploads, issue)
+}
+
+func (s *ReconcileService) reconcileDoc(doc *entity.Document, report *entity.ReconcileReport) {
+	issue := entity.ScrubIssue{DocID: doc.ID, Name: doc.Name, Key: s.storage.BlobKey(doc)}
+
+	ok, err := s.storage.Exists(doc)
+	if err != nil {
+		issue.Error = err.Error()
+		report.Errors = append(report.Errors, issue)
+		return
+	}
+	if ok {
+		return
+	}
+
+	// Документ мог быть удален во время сверки
+	if exists, err := s.repo.DocExists(doc.ID); err == nil && !exists {
+		return
+	}
+
+	logrus.Warnf("Reconcile: file of doc %s is missing", doc.ID)
+	report.MissingFiles = append(report.MissingFiles, issue)
+}
+
+// deleteMissing удаляет записи документов без файла, если хранилище выглядит исправным:
+// в нем есть хотя бы один файл известного документа, а пропавших файлов не больше
+// maxMissing и не больше доли maxMissingRatio от всех документов с файлом.
+func (s *ReconcileService) deleteMissing(report *entity.ReconcileReport, total, knownObjects int) {
+	missing := len(report.MissingFiles)
+	switch {
+	case missing == 0:
+		return
+	case knownObjects == 0:
+		report.MissingSkipped = "storage contains no files of known documents"
+	case s.maxMissing > 0 && missing > s.maxMissing:
+		report.MissingSkipped = fmt.Sprintf("%d documents with missing files exceed the limit of %d",
+			missing, s.maxMissing)
+	case s.maxMissingRatio > 0 && float64(missing) > s.maxMissingRatio*float64(total):
+		report.MissingSkipped = fmt.Sprintf("%d of %d documents with missing files exceed the ratio %g",
+			missing, total, s.maxMissingRatio)
+	}
+	if report.MissingSkipped != "" {
+		logrus.Errorf("Reconcile: not deleting documents with missing files: %s", report.MissingSkipped)
+		return
+	}
+
+	for i := range report.MissingFiles {
+		issue := &report.MissingFiles[i]
+		issue.Error = errString(s.repo.DeleteDocRecord(issue.DocID))
+		issue.Repaired = issue.Error == ""
+	}
+}
+
+func errString(err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return ""
+}
@@ -0,0 +1,227 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/olenka-91/DocsServer/internal/entity"
+	"github.com/olenka-91/DocsServer/internal/repository"
+	"github.com/olenka-91/DocsServer/internal/storage"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultRetentionInterval = time.Hour
+	retentionBatch           = 100
+	pgForeignKeyViolation    = "23503"
+)
+
+// RetentionService назначает документам срок хранения по политикам, удаляет документы
+// с истекшим сроком и ведет юридические блокировки, запрещающие удаление.
+type RetentionService struct {
+	repo     repository.Retention
+	storage  *storage.FileStorage
+	audit    *AuditService
+	interval time.Duration
+}
+
+func NewRetentionService(r repository.Retention, fs *storage.FileStorage, audit *AuditService,
+	interval time.Duration) *RetentionService {
+	if interval <= 0 {
+		interval = defaultRetentionInterval
+	}
+	return &RetentionService{repo: r, storage: fs, audit: audit, interval: interval}
+}
+
+// Resolve возвращает срок хранения нового документа типа mimeType. Политика с запретом
+// удаления важнее срока из запроса requested, срок из запроса - важнее обычной политики.
+// Из нескольких подходящих политик выбирается самый долгий срок.
+func (s *RetentionService) Resolve(mimeType string, requested *time.Time) (*time.Time, bool, error) {
+	policies, err := s.repo.GetRetentionPolicies()
+	if err != nil {
+		return nil, false, err
+	}
+
+	now := time.Now()
+	var best *entity.RetentionPolicy
+	for i := range policies {
+		p := &policies[i]
+		if !storage.MimeMatches(p.Mime, mimeType) {
+			continue
+		}
+		if best == nil || (p.Locked && !best.Locked) ||
+			(p.Locked == best.Locked && p.ExpiresAt(now).After(best.ExpiresAt(now))) {
+			best = p
+		}
+	}
+
+	switch {
+	case best != nil && best.Locked:
+		expiresAt := best.ExpiresAt(now)
+		return &expiresAt, true, nil
+	case requested != nil:
+		return requested, false, nil
+	case best != nil:
+		expiresAt := best.ExpiresAt(now)
+		return &expiresAt, false, nil
+	}
+	return nil, false, nil
+}
+
+func (s *RetentionService) CreateRetentionPolicy(input entity.RetentionPolicy) (*entity.RetentionPolicy, error) {
+	patterns, err := normalizePatterns([]string{input.Mime})
+	if err != nil || len(patterns) != 1 || input.Years+input.Days == 0 {
+		return nil, ErrBadRequest
+	}
+
+	p := &entity.RetentionPolicy{
+		ID:     uuid.New(),
+		Name:   input.Name,
+		Mime:   patterns[0],
+		Years:  input.Years,
+		Days:   input.Days,
+		Locked: input.Locked,
+	}
+	if err := s.repo.CreateRetentionPolicy(p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (s *RetentionService) GetRetentionPolicies() ([]entity.RetentionPolicy, error) {
+	return s.repo.GetRetentionPolicies()
+}
+
+// DeleteRetentionPolicy удаляет политику. Сроки уже созданных документов не меняются.
+func (s *RetentionService) DeleteRetentionPolicy(id uuid.UUID) error {
+	ok, err := s.repo.DeleteRetentionPolicy(id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// PlaceLegalHold запрещает удаление документа, в том числе по сроку хранения и владельцем.
+func (s *RetentionService) PlaceLegalHold(ctx *gin.Context, docID uuid.UUID,
+	input entity.CreateLegalHoldRequest) (_ *entity.LegalHold, err error) {
+	h := &entity.LegalHold{
+		ID:        uuid.New(),
+		DocID:     docID,
+		Reason:    input.Reason,
+		CreatedBy: ctx.GetString("login"),
+	}
+	defer func() { s.recordHold(ctx, entity.AuditDocHold, h, err) }()
+
+	if err := s.repo.CreateLegalHold(h); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == pgForeignKeyViolation {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return h, nil
+}
+
+func (s *RetentionService) GetLegalHolds(docID uuid.UUID) ([]entity.LegalHold, error) {
+	return s.repo.GetLegalHolds(docID)
+}
+
+// ReleaseLegalHold снимает блокировку. Если других блокировок нет и срок хранения истек,
+// документ будет удален при следующем проходе.
+func (s *RetentionService) ReleaseLegalHold(ctx *gin.Context, docID, holdID uuid.UUID) (err error) {
+	h := &entity.LegalHold{ID: holdID, DocID: docID}
+	defer func() { s.recordHold(ctx, entity.AuditDocRelease, h, err) }()
+
+	released, err := s.repo.ReleaseLegalHold(docID, holdID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	h = released
+	return nil
+}
+
+func (s *RetentionService) recordHold(ctx *gin.Context, action string, h *entity.LegalHold, err error) {
+	details := entity.JSONB{"hold_id": h.ID.String()}
+	if h.Reason != "" {
+		details["reason"] = h.Reason
+	}
+	s.audit.Record(ctx, entity.AuditEntry{
+		Action:     action,
+		TargetType: entity.AuditTargetDocument,
+		TargetID:   h.DocID.String(),
+		Details:    details,
+	}, err)
+}
+
+func (s *RetentionService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.purgeExpired(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// purgeExpired удаляет документы с истекшим сроком хранения тем же путем, что и удаление
+// владельцем: запись с событием и учетом места, затем файл.
+func (s *RetentionService) purgeExpired(ctx context.Context) {
+	for ctx.Err() == nil {
+		docs, err := s.repo.GetExpiredDocs(retentionBatch)
+		if err != nil {
+			logrus.Errorf("Failed to fetch expired documents: %v", err)
+			return
+		}
+
+		purged := 0
+		for i := range docs {
+			doc := &docs[i]
+			ok, err := s.repo.ExpireDoc(doc.ID)
+			if err != nil {
+				logrus.Errorf("Failed to delete expired doc %s: %v", doc.ID, err)
+				continue
+			}
+			if !ok {
+				continue
+			}
+			purged++
+
+			if doc.File {
+				if err := s.storage.DeleteFile(doc.ID, doc.Name); err != nil {
+					logrus.Errorf("Failed to remove file of expired doc %s: %v", doc.ID, err)
+				}
+			}
+			s.audit.Record(nil, entity.AuditEntry{
+				Action:     entity.AuditDocExpire,
+				TargetType: entity.AuditTargetDocument,
+				TargetID:   doc.ID.String(),
+				TargetName: doc.Name,
+				Details:    entity.JSONB{"expires_at": doc.ExpiresAt},
+			}, nil)
+		}
+		if purged > 0 {
+			logrus.Infof("Deleted %d expired documents", purged)
+		}
+
+		// Неудаленные документы снова попали бы в следующую выборку
+		if len(docs) < retentionBatch || purged == 0 {
+			return
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/olenka-91/DocsServer/internal/repository"
+	"github.com/olenka-91/DocsServer/internal/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultRevocationSyncInterval = 10 * time.Second
+	// Запас на расхождение часов приложения и БД при инкрементальной синхронизации
+	revocationSyncOverlap = time.Minute
+)
+
+// RevocationService хранит список отозванных токенов в памяти и периодически
+// синхронизирует его с Postgres, чтобы отзыв пережил рестарт и был виден всем инстансам.
+type RevocationService struct {
+	repo         repository.Revocation
+	syncInterval time.Duration
+
+	mu       sync.RWMutex
+	tokens   map[uuid.UUID]time.Time // jti -> expires_at
+	cutoffs  map[uuid.UUID]time.Time // user_id -> tokens_valid_after
+	lastSync time.Time
+}
+
+func NewRevocationService(r repository.Revocation, syncInterval time.Duration) *RevocationService {
+	if syncInterval <= 0 {
+		syncInterval = defaultRevocationSyncInterval
+	}
+	return &RevocationService{
+		repo:         r,
+		syncInterval: syncInterval,
+		tokens:       make(map[uuid.UUID]time.Time),
+		cutoffs:      make(map[uuid.UUID]time.Time),
+	}
+}
+
+// IsRevoked проверяет jti токена и момент выпуска относительно отзыва всех токенов пользователя.
+func (s *RevocationService) IsRevoked(claims *utils.JwtClaim) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if jti, err := uuid.Parse(claims.ID); err == nil {
+		if _, ok := s.tokens[jti]; ok {
+			return true
+		}
+	}
+
+	cutoff, ok := s.cutoffs[claims.UserID]
+	if !ok {
+		return false
+	}
+	if claims.IssuedAt == nil {
+		return true
+	}
+	return claims.IssuedAt.Time.Before(revocationCutoff(cutoff))
+}
+
+// revocationCutoff округляет момент отзыва вверх до целой секунды. iat хранится с точностью
+// до секунды, поэтому токены, выпущенные в ту же секунду, что и отзыв, считаются отозванными.
+func revocationCutoff(t time.Time) time.Time {
+	cutoff := t.Truncate(time.Second)
+	if cutoff.Before(t) {
+		cutoff = cutoff.Add(time.Second)
+	}
+	return cutoff
+}
+
+// issuedAt возвращает момент выпуска нового токена пользователя: не раньше отметки отзыва,
+// чтобы токены, выпущенные сразу после отзыва в ту же секунду, остались действительными.
+func (s *RevocationService) issuedAt(userID uuid.UUID) time.Time {
+	now := time.Now()
+
+	s.mu.RLock()
+	cutoff, ok := s.cutoffs[userID]
+	s.mu.RUnlock()
+	if ok {
+		if cutoff = revocationCutoff(cutoff); cutoff.After(now) {
+			return cutoff
+		}
+	}
+	return now
+}
+
+func (s *RevocationService) RevokeToken(claims *utils.JwtClaim) error {
+	jti, err := uuid.Parse(claims.ID)
+	if err != nil {
+		// Токены без jti выпущены до появления отзыва и истекут сами
+		return nil
+	}
+
+	expiresAt := time.Now()
+	if claims.ExpiresAt != nil {
+		expiresAt = claims.ExpiresAt.Time
+	}
+
+	if err := s.repo.RevokeToken(jti, claims.UserID, expiresAt); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.tokens[jti] = expiresAt
+	s.mu.Unlock()
+	return nil
+}
+
+// RevokeUser отзывает все ранее выпущенные access и refresh токены пользователя.
+func (s *RevocationService) RevokeUser(userID uuid.UUID) error {
+	validAfter := time.Now()
+	if err := s.repo.RevokeUserTokens(userID, validAfter); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.cutoffs[userID] = validAfter
+	s.mu.Unlock()
+	return nil
+}
+
+// applyCutoff запоминает отзыв токенов пользователя, уже записанный в БД вместе
+// с другим изменением (например, удалением учетной записи).
+func (s *RevocationService) applyCutoff(userID uuid.UUID, validAfter time.Time) {
+	s.mu.Lock()
+	if validAfter.After(s.cutoffs[userID]) {
+		s.cutoffs[userID] = validAfter
+	}
+	s.mu.Unlock()
+}
+
+// Run загружает список отзыва и поддерживает его актуальным до отмены ctx.
+func (s *RevocationService) Run(ctx context.Context) {
+	s.sync()
+
+	ticker := time.NewTicker(s.syncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.sync()
+			s.cleanup()
+		}
+	}
+}
+
+func (s *RevocationService) sync() {
+	started := time.Now()
+
+	s.mu.RLock()
+	since := s.lastSync
+	s.mu.RUnlock()
+	if !since.IsZero() {
+		since = since.Add(-revocationSyncOverlap)
+	}
+
+	tokens, err := s.repo.GetRevokedTokensSince(since)
+	if err != nil {
+		logrus.Errorf("Failed to sync revoked tokens: %v", err)
+		return
+	}
+
+	cutoffs, err := s.repo.GetUserCutoffsSince(since)
+	if err != nil {
+		logrus.Errorf("Failed to sync user token cutoffs: %v", err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range tokens {
+		s.tokens[t.JTI] = t.ExpiresAt
+	}
+	for _, c := range cutoffs {
+		if c.ValidAfter.After(s.cutoffs[c.UserID]) {
+			s.cutoffs[c.UserID] = c.ValidAfter
+		}
+	}
+	s.lastSync = started
+}
+
+func (s *RevocationService) cleanup() {
+	s.prune(time.Now())
+
+	if n, err := s.repo.DeleteExpiredTokens(); err != nil {
+		logrus.Errorf("Failed to delete expired revoked tokens: %v", err)
+	} else if n > 0 {
+		logrus.Debugf("Deleted %d expired revoked tokens", n)
+	}
+}
+
+// prune удаляет из памяти истекшие отозванные токены и отметки отзыва, старше которых
+// действующих токенов уже нет.
+func (s *RevocationService) prune(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for jti, expiresAt := range s.tokens {
+		if now.After(expiresAt) {
+			delete(s.tokens, jti)
+		}
+	}
+	for userID, validAfter := range s.cutoffs {
+		if now.Sub(validAfter) > utils.MaxTokenTTL {
+			delete(s.cutoffs, userID)
+		}
+	}
+}
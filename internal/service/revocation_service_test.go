@@ -0,0 +1,109 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/olenka-91/DocsServer/internal/utils"
+)
+
+func TestIsRevokedByCutoff(t *testing.T) {
+	s := NewRevocationService(nil, 0)
+	userID, otherID := uuid.New(), uuid.New()
+	// Отзыв в середине секунды
+	cutoff := time.Date(2024, 5, 1, 12, 0, 0, 500_000_000, time.UTC)
+	s.applyCutoff(userID, cutoff)
+
+	issued := func(user uuid.UUID, at time.Time) *utils.JwtClaim {
+		return &utils.JwtClaim{UserID: user, RegisteredClaims: jwt.RegisteredClaims{IssuedAt: jwt.NewNumericDate(at)}}
+	}
+	tests := []struct {
+		name   string
+		claims *utils.JwtClaim
+		want   bool
+	}{
+		{"issued earlier in the same second", issued(userID, cutoff.Add(-300*time.Millisecond)), true},
+		{"issued at the cutoff", issued(userID, cutoff), true},
+		// iat с точностью до секунды: порядок внутри секунды отзыва неизвестен
+		{"issued later in the same second", issued(userID, cutoff.Add(400*time.Millisecond)), true},
+		{"issued the next second", issued(userID, cutoff.Add(500*time.Millisecond)), false},
+		{"issued the second before", issued(userID, cutoff.Add(-time.Second)), true},
+		{"without iat", &utils.JwtClaim{UserID: userID}, true},
+		{"other user", issued(otherID, cutoff.Add(-time.Hour)), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.IsRevoked(tt.claims); got != tt.want {
+				t.Errorf("IsRevoked = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+// Токены, выпущенные сразу после отзыва, действительны, выпущенные до него - нет
+func TestIsRevokedSignedTokens(t *testing.T) {
+	s := NewRevocationService(nil, 0)
+	userID := uuid.New()
+
+	claims := func() *utils.JwtClaim {
+		token, err := utils.GenerateToken(userID, "user", "access", s.issuedAt(userID))
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := utils.ValidateToken(token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	before := claims()
+	s.applyCutoff(userID, time.Now())
+	after := claims()
+
+	if !s.IsRevoked(before) {
+		t.Error("token issued before the cutoff is not revoked")
+	}
+	if s.IsRevoked(after) {
+		t.Error("token issued after the cutoff is revoked")
+	}
+}
+
+func TestRevocationCutoff(t *testing.T) {
+	whole := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	if got := revocationCutoff(whole); !got.Equal(whole) {
+		t.Errorf("revocationCutoff(%v) = %v", whole, got)
+	}
+	if got := revocationCutoff(whole.Add(time.Microsecond)); !got.Equal(whole.Add(time.Second)) {
+		t.Errorf("revocationCutoff(%v) = %v", whole.Add(time.Microsecond), got)
+	}
+}
+
+func TestRevocationPrune(t *testing.T) {
+	s := NewRevocationService(nil, 0)
+	now := time.Now()
+	oldUser, recentUser := uuid.New(), uuid.New()
+	expired, active := uuid.New(), uuid.New()
+
+	s.applyCutoff(oldUser, now.Add(-utils.MaxTokenTTL-time.Minute))
+	s.applyCutoff(recentUser, now.Add(-time.Minute))
+	s.tokens[expired] = now.Add(-time.Minute)
+	s.tokens[active] = now.Add(time.Minute)
+
+	s.prune(now)
+
+	if _, ok := s.cutoffs[oldUser]; ok {
+		t.Error("cutoff older than any token is kept")
+	}
+	if _, ok := s.cutoffs[recentUser]; !ok {
+		t.Error("recent cutoff is pruned")
+	}
+	if _, ok := s.tokens[expired]; ok {
+		t.Error("expired token is kept")
+	}
+	if _, ok := s.tokens[active]; !ok {
+		t.Error("active revoked token is pruned")
+	}
+}
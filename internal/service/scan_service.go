@@ -0,0 +1,216 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/olenka-91/DocsServer/internal/entity"
+	"github.com/olenka-91/DocsServer/internal/repository"
+	"github.com/olenka-91/DocsServer/internal/scanner"
+	"github.com/olenka-91/DocsServer/internal/storage"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	scanBatch = 100
+	// Пауза перед повтором, если clamd недоступен: удваивается до scanRetryMax
+	scanRetryMin = 5 * time.Second
+	scanRetryMax = 5 * time.Minute
+)
+
+// unscannedStatus - в запросе повторной проверки обозначает документы, загруженные
+// до появления проверки.
+const unscannedStatus = "unscanned"
+
+// ScanService проверяет загруженные файлы антивирусом. Новый документ с файлом
+// получает статус pending и отдается только после проверки.
+type ScanService struct {
+	repo     repository.Scans
+	storage  *storage.FileStorage
+	scanner  scanner.Scanner
+	thumbs   *ThumbnailService
+	meta     *MetadataService
+	interval time.Duration
+	wake     chan struct{}
+}
+
+func NewScanService(r repository.Scans, fs *storage.FileStorage, sc scanner.Scanner, thumbs *ThumbnailService,
+	meta *MetadataService, interval time.Duration) *ScanService {
+	return &ScanService{
+		repo:     r,
+		storage:  fs,
+		scanner:  sc,
+		thumbs:   thumbs,
+		meta:     meta,
+		interval: interval,
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// InitialStatus - статус нового документа с файлом. Без антивируса проверять нечего,
+// и файл доступен сразу.
+func (s *ScanService) InitialStatus() string {
+	if _, ok := s.scanner.(scanner.NoopScanner); ok {
+		return entity.ScanClean
+	}
+	return entity.ScanPending
+}
+
+// Notify сообщает о новых документах в очереди, не дожидаясь следующего прохода.
+func (s *ScanService) Notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run обрабатывает очередь после загрузок и раз в interval, чтобы подобрать документы,
+// оставшиеся в очереди после перезапуска. Пока clamd недоступен, очередь не трогается,
+// а попытки повторяются с растущей паузой.
+func (s *ScanService) Run(ctx context.Context) {
+	var tick <-chan time.Time
+	if s.interval > 0 {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	var backoff time.Duration
+	for {
+		if s.scanPending(ctx) {
+			backoff = min(max(2*backoff, scanRetryMin), scanRetryMax)
+			logrus.Warnf("Malware scanner is unavailable, retrying in %s", backoff)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			continue
+		}
+		backoff = 0
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick:
+		case <-s.wake:
+		}
+	}
+}
+
+// scanPending проверяет документы из очереди. Возвращает true, если проход прерван
+// из-за недоступности антивируса и его нужно повторить.
+func (s *ScanService) scanPending(ctx context.Context) bool {
+	for ctx.Err() == nil {
+		docs, err := s.repo.GetPendingScans(scanBatch)
+		if err != nil {
+			logrus.Errorf("Failed to get documents to scan: %v", err)
+			return false
+		}
+		for i := range docs {
+			if ctx.Err() != nil {
+				return false
+			}
+			err := s.scanDoc(ctx, &docs[i])
+			if errors.Is(err, scanner.ErrUnavailable) {
+				logrus.Warnf("Failed to scan doc %s, it stays pending: %v", docs[i].ID, err)
+				return true
+			}
+			if err != nil {
+				logrus.Errorf("Failed to save scan result of doc %s: %v", docs[i].ID, err)
+				return false
+			}
+		}
+		if len(docs) < scanBatch {
+			return false
+		}
+	}
+	return false
+}
+
+func (s *ScanService) scanDoc(ctx context.Context, doc *entity.Document) error {
+	status, signature := entity.ScanClean, ""
+
+	result, err := s.scanFile(ctx, doc)
+	switch {
+	case ctx.Err() != nil:
+		// Остановка сервиса: документ останется в очереди
+		return nil
+	case errors.Is(err, scanner.ErrUnavailable):
+		// Сбой антивируса, а не файла: документ останется в очереди
+		return err
+	case err != nil:
+		logrus.Errorf("Failed to scan doc %s: %v", doc.ID, err)
+		status = entity.ScanError
+	case result.Infected:
+		logrus.Warnf("Doc %s is infected (%s), file quarantined", doc.ID, result.Signature)
+		status, signature = entity.ScanInfected, result.Signature
+	}
+	if err := s.repo.SetScanResult(doc.ID, status, signature); err != nil {
+		return err
+	}
+	// Миниатюры и метаданные извлекаются только из проверенных файлов
+	if status == entity.ScanClean {
+		s.thumbs.Enqueue(doc)
+		s.meta.Notify()
+	}
+	return nil
+}
+
+func (s *ScanService) scanFile(ctx context.Context, doc *entity.Document) (scanner.Result, error) {
+	rc, err := s.storage.Open(doc)
+	if err != nil {
+		return scanner.Result{}, err
+	}
+	defer rc.Close()
+	return s.scanner.Scan(ctx, rc)
+}
+
+func (s *ScanService) GetScanResult(docID uuid.UUID) (*entity.ScanResult, error) {
+	result, err := s.repo.GetScanResult(docID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return result, err
+}
+
+// Rescan ставит документ в очередь на повторную проверку. Файл не отдается, пока
+// проверка не завершится.
+func (s *ScanService) Rescan(docID uuid.UUID) (*entity.ScanResult, error) {
+	ok, err := s.repo.RequeueScan(docID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNotFound
+	}
+	s.Notify()
+	return s.GetScanResult(docID)
+}
+
+// RescanAll ставит в очередь документы с указанными статусами, по умолчанию - с
+// ошибкой проверки.
+func (s *ScanService) RescanAll(input entity.RescanRequest) (int64, error) {
+	statuses := input.Statuses
+	if len(statuses) == 0 {
+		statuses = []string{entity.ScanError}
+	}
+	for i, status := range statuses {
+		if status == unscannedStatus {
+			statuses[i] = ""
+		}
+	}
+
+	n, err := s.repo.RequeueScans(statuses)
+	if err != nil {
+		return 0, err
+	}
+	if n > 0 {
+		s.Notify()
+	}
+	logrus.Infof("Requeued %d documents for malware scan", n)
+	return n, nil
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/olenka-91/DocsServer/internal/entity"
+	"github.com/olenka-91/DocsServer/internal/metadata"
+	"github.com/olenka-91/DocsServer/internal/repository"
+)
+
+// ScrubPolicyService выбирает, какие метаданные удалить из загружаемого изображения.
+type ScrubPolicyService struct {
+	repo         repository.UploadPolicies
+	mode         string
+	keepOriginal bool
+}
+
+func NewScrubPolicyService(r repository.UploadPolicies, mode string, keepOriginal bool) *ScrubPolicyService {
+	return &ScrubPolicyService{repo: r, mode: mode, keepOriginal: keepOriginal}
+}
+
+// Resolve возвращает режим удаления метаданных и нужно ли сохранить оригинал. Режим
+// документа может быть только строже режима пользователя, а из публичных документов
+// координаты съемки удаляются всегда.
+func (s *ScrubPolicyService) Resolve(userID uuid.UUID, meta entity.UploadMeta, mimeType string) (string, bool, error) {
+	if meta.Scrub != "" && !metadata.ValidScrubMode(meta.Scrub) {
+		return "", false, ErrBadRequest
+	}
+	if !metadata.Scrubbable(mimeType) {
+		return metadata.ScrubKeep, false, nil
+	}
+
+	user, err := s.repo.GetUserScrubPolicy(userID)
+	if err != nil {
+		return "", false, err
+	}
+	mode, keepOriginal := s.mode, s.keepOriginal
+	if user.Mode != nil {
+		mode = *user.Mode
+	}
+	if user.KeepOriginal != nil {
+		keepOriginal = *user.KeepOriginal
+	}
+	if meta.KeepOriginal != nil {
+		keepOriginal = *meta.KeepOriginal
+	}
+
+	mode = metadata.StricterScrub(mode, meta.Scrub)
+	if meta.Public {
+		mode = metadata.StricterScrub(mode, metadata.ScrubGPS)
+	}
+	return mode, keepOriginal && mode != metadata.ScrubKeep, nil
+}
+
+func (s *ScrubPolicyService) SetScrubPolicy(login string, input entity.ScrubPolicy) (*entity.ScrubPolicy, error) {
+	if input.Mode != nil && !metadata.ValidScrubMode(*input.Mode) {
+		return nil, ErrBadRequest
+	}
+	if _, err := s.repo.SetUserScrubPolicy(strings.ToLower(login), input); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return &input, nil
+}
@@ -0,0 +1,202 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/olenka-91/DocsServer/internal/entity"
+	"github.com/olenka-91/DocsServer/internal/repository"
+	"github.com/olenka-91/DocsServer/internal/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// ScrubService периодически перечитывает файлы хранилища, сверяет их SHA-256 с
+// сохраненным при загрузке и ищет файлы без записи в documents и записи без файла.
+type ScrubService struct {
+	repo        repository.Integrity
+	storage     *storage.FileStorage
+	interval    time.Duration
+	orphanGrace time.Duration
+	trigger     chan struct{}
+
+	mu      sync.RWMutex
+	running bool
+	last    *entity.ScrubReport
+}
+
+func NewScrubService(r repository.Integrity, fs *storage.FileStorage, interval, orphanGrace time.Duration) *ScrubService {
+	return &ScrubService{
+		repo:        r,
+		storage:     fs,
+		interval:    interval,
+		orphanGrace: orphanGrace,
+		trigger:     make(chan struct{}, 1),
+	}
+}
+
+// Run выполняет проверку по расписанию (interval <= 0 - только по запросу) и по
+// запросу администратора до отмены ctx.
+func (s *ScrubService) Run(ctx context.Context) {
+	var tick <-chan time.Time
+	if s.interval > 0 {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick:
+		case <-s.trigger:
+		}
+		s.scrub(ctx)
+	}
+}
+
+// Start запрашивает внеочередную проверку.
+func (s *ScrubService) Start() error {
+	s.mu.RLock()
+	running := s.running
+	s.mu.RUnlock()
+	if running {
+		return ErrConflict
+	}
+
+	select {
+	case s.trigger <- struct{}{}:
+		return nil
+	default:
+		// Проверка уже запрошена и еще не началась
+		return ErrConflict
+	}
+}
+
+func (s *ScrubService) Status() entity.ScrubStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return entity.ScrubStatus{Running: s.running, Last: s.last}
+}
+
+func (s *ScrubService) scrub(ctx context.Context) {
+	s.mu.Lock()
+	s.running = true
+	s.mu.Unlock()
+
+	report := &entity.ScrubReport{
+		StartedAt: time.Now(),
+		Corrupted: []entity.ScrubIssue{},
+		Missing:   []entity.ScrubIssue{},
+		Orphaned:  []entity.ScrubIssue{},
+		Errors:    []entity.ScrubIssue{},
+	}
+	logrus.Info("Storage scrub started")
+
+	if err := s.scrubDocs(ctx, report); err != nil {
+		logrus.Errorf("Storage scrub failed: %v", err)
+		report.Errors = append(report.Errors, entity.ScrubIssue{Error: err.Error()})
+	}
+	report.Aborted = ctx.Err() != nil
+	report.FinishedAt = time.Now()
+
+	logrus.Infof("Storage scrub finished: checked=%d backfilled=%d corrupted=%d missing=%d orphaned=%d errors=%d",
+		report.Checked, report.Backfilled, len(report.Corrupted), len(report.Missing),
+		len(report.Orphaned), len(report.Errors))
+
+	s.mu.Lock()
+	s.running = false
+	s.last = report
+	s.mu.Unlock()
+}
+
+// scrubDocs сначала читает список документов, затем обходит хранилище: файл,
+// загруженный между этими шагами, моложе orphanGrace и не попадет в orphaned.
+func (s *ScrubService) scrubDocs(ctx context.Context, report *entity.ScrubReport) error {
+	pending, err := s.repo.GetPendingUploads()
+	if err != nil {
+		return err
+	}
+	docs, err := s.repo.GetFileDocs()
+	if err != nil {
+		return err
+	}
+
+	// Файлы незавершенных загрузок обрабатывает сверка, а не проверка целостности
+	known := make(map[string]bool, len(docs)+len(pending))
+	// Миниатюры принадлежат файлу документа и лишние, только если документа нет
+	docIDs := make(map[uuid.UUID]bool, len(docs))
+	for _, p := range pending {
+		known[p.Key] = true
+	}
+	for i := range docs {
+		if ctx.Err() != nil {
+			return nil
+		}
+		doc := &docs[i]
+		known[s.storage.BlobKey(doc)] = true
+		docIDs[doc.ID] = true
+		s.checkDoc(doc, report)
+	}
+
+	orphanBefore := report.StartedAt.Add(-s.orphanGrace)
+	return s.storage.WalkBlobs(func(id uuid.UUID, key string, info storage.BlobInfo) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if known[key] || (storage.IsDerivedKey(key) && docIDs[id]) || info.ModTime.After(orphanBefore) {
+			return nil
+		}
+		logrus.Warnf("Scrub: orphaned file %s", key)
+		report.Orphaned = append(report.Orphaned, entity.ScrubIssue{DocID: id, Key: key})
+		return nil
+	})
+}
+
+func (s *ScrubService) checkDoc(doc *entity.Document, report *entity.ScrubReport) {
+	issue := entity.ScrubIssue{DocID: doc.ID, Name: doc.Name, Key: s.storage.BlobKey(doc), Expected: doc.Checksum}
+
+	checksum, size, err := s.storage.Checksum(doc)
+	if errors.Is(err, os.ErrNotExist) {
+		// Документ мог быть удален во время проверки
+		if exists, existsErr := s.repo.DocExists(doc.ID); existsErr == nil && !exists {
+			return
+		}
+		logrus.Warnf("Scrub: file of doc %s is missing", doc.ID)
+		report.Missing = append(report.Missing, issue)
+		return
+	}
+	if errors.Is(err, storage.ErrCorrupted) {
+		// Зашифрованный файл не прошел проверку тега GCM
+		logrus.Errorf("Scrub: doc %s is corrupted: %v", doc.ID, err)
+		issue.Error = err.Error()
+		report.Corrupted = append(report.Corrupted, issue)
+		return
+	}
+	if err != nil {
+		issue.Error = err.Error()
+		report.Errors = append(report.Errors, issue)
+		return
+	}
+	report.Checked++
+
+	if doc.Checksum == "" {
+		if err := s.repo.SetDocChecksum(doc.ID, checksum, size); err != nil {
+			issue.Error = err.Error()
+			report.Errors = append(report.Errors, issue)
+			return
+		}
+		report.Backfilled++
+		return
+	}
+
+	if checksum != doc.Checksum || (doc.Size > 0 && size != doc.Size) {
+		logrus.Errorf("Scrub: doc %s is corrupted: expected sha256 %s, got %s", doc.ID, doc.Checksum, checksum)
+		issue.Actual = checksum
+		report.Corrupted = append(report.Corrupted, issue)
+	}
+}
@@ -1,35 +1,226 @@
 package service
 
 import (
-	"mime/multipart"
+	"context"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/olenka-91/DocsServer/internal/config"
 	"github.com/olenka-91/DocsServer/internal/entity"
+	"github.com/olenka-91/DocsServer/internal/metadata"
+	"github.com/olenka-91/DocsServer/internal/notifier"
 	"github.com/olenka-91/DocsServer/internal/repository"
+	"github.com/olenka-91/DocsServer/internal/scanner"
 	"github.com/olenka-91/DocsServer/internal/storage"
+	"github.com/olenka-91/DocsServer/internal/utils"
 )
 
 type Docs interface {
 	GetDocsList(ctx *gin.Context, s entity.LimitedDocsListInput) ([]entity.Document, error)
 	GetDoc(ctx *gin.Context, docID uuid.UUID, login string) (*entity.Document, error)
 	PostDoc(ctx *gin.Context, login string, meta entity.UploadMeta,
-		jsonData entity.JSONB, fileHeader *multipart.FileHeader) (*entity.Document, error)
+		jsonData entity.JSONB, file *UploadFile) (*entity.Document, error)
 	DeleteDoc(ctx *gin.Context, docID uuid.UUID, login string) (*entity.DelResponse, error)
+	UpdateDoc(ctx *gin.Context, docID uuid.UUID, login string, input entity.UpdateDocRequest) (*entity.Document, error)
+	GetThumbnail(ctx *gin.Context, docID uuid.UUID, login string, size int) error
+	GetOriginal(ctx *gin.Context, docID uuid.UUID, login string) error
+	GetDocHistory(ctx *gin.Context, docID uuid.UUID, login string, beforeID int64, limit int) ([]entity.AuditEntry, error)
+	LockDoc(ctx *gin.Context, docID uuid.UUID, login string, ttl time.Duration) (*entity.DocumentLock, error)
+	UnlockDoc(ctx *gin.Context, docID uuid.UUID, login string, force bool) error
+	BreakDocLock(ctx *gin.Context, docID uuid.UUID) error
+	CacheStats() storage.CacheStatsResponse
 }
 
 type Authorization interface {
-	SignUp(name, password string) (map[string]string, error)
-	SignIn(name, password string) (map[string]string, error)
-	RefreshToken(refreshToken string) (map[string]string, error)
-	Logout(userID uuid.UUID) error
+	SignUp(ctx *gin.Context, name, password string) (map[string]string, error)
+	SignIn(ctx *gin.Context, name, password string) (map[string]string, error)
+	RefreshToken(ctx *gin.Context, refreshToken string) (map[string]string, error)
+	Logout(ctx *gin.Context, claims *utils.JwtClaim) error
+	ChangePassword(ctx *gin.Context, userID uuid.UUID, oldPassword, newPassword string) (map[string]string, error)
+	RequestPasswordReset(ctx *gin.Context, name string) error
+	ResetPassword(ctx *gin.Context, token, newPassword string) error
+	DisableUser(ctx *gin.Context, login string) error
+	EnableUser(ctx *gin.Context, login string) error
+	IsAdmin(userID uuid.UUID) (bool, error)
+}
+
+type Revocation interface {
+	IsRevoked(claims *utils.JwtClaim) bool
+	RevokeToken(claims *utils.JwtClaim) error
+	RevokeUser(userID uuid.UUID) error
+	Run(ctx context.Context)
+}
+
+type Users interface {
+	GetProfile(userID uuid.UUID) (*entity.UserProfile, error)
+	UpdateProfile(ctx *gin.Context, userID uuid.UUID, input entity.UpdateProfileRequest) (*entity.UserProfile, error)
+	DeleteAccount(ctx *gin.Context, claims *utils.JwtClaim, input entity.DeleteAccountRequest) error
+}
+
+type Groups interface {
+	CreateGroup(userID uuid.UUID, name string) (*entity.Group, error)
+	GetUserGroups(userID uuid.UUID) ([]entity.Group, error)
+	GetGroup(userID, groupID uuid.UUID) (*entity.GroupDetails, error)
+	AddMember(ctx *gin.Context, userID, groupID uuid.UUID, input entity.AddGroupMemberRequest) error
+	RemoveMember(ctx *gin.Context, userID, groupID uuid.UUID, login string) error
+	DeleteGroup(ctx *gin.Context, userID, groupID uuid.UUID) error
+}
+
+type Transfers interface {
+	TransferDoc(ctx *gin.Context, docID uuid.UUID, userID uuid.UUID,
+		input entity.TransferDocRequest) (*entity.DocumentTransfer, error)
+	GetIncomingTransfers(userID uuid.UUID) ([]entity.DocumentTransfer, error)
+	GetDocTransfers(ctx *gin.Context, docID uuid.UUID, userID uuid.UUID) ([]entity.DocumentTransfer, error)
+	AcceptTransfer(ctx *gin.Context, userID, transferID uuid.UUID) error
+	DeclineTransfer(ctx *gin.Context, userID, transferID uuid.UUID) error
+	CancelTransfer(ctx *gin.Context, userID, transferID uuid.UUID) error
+	BulkTransfer(ctx *gin.Context, adminID uuid.UUID, input entity.BulkTransferRequest) (int64, error)
+}
+
+type Scrub interface {
+	Run(ctx context.Context)
+	Start() error
+	Status() entity.ScrubStatus
+}
+
+type Reconciliation interface {
+	Run(ctx context.Context)
+	Reconcile(ctx context.Context, opts entity.ReconcileOptions) (*entity.ReconcileReport, error)
+}
+
+type Encryption interface {
+	Status() (*entity.EncryptionStatus, error)
+	RotateKeys() (*entity.KeyRotationResult, error)
+}
+
+type Quotas interface {
+	GetUsage(userID uuid.UUID) (*entity.UsageResponse, error)
+	SetQuota(login string, input entity.SetQuotaRequest) (*entity.UsageResponse, error)
+	RecomputeUsage(ctx context.Context) (*entity.RecomputeUsageResult, error)
+}
+
+type MimePolicies interface {
+	SetMimePolicy(login string, input entity.MimePolicy) (*entity.MimePolicy, error)
+}
+
+type Metadata interface {
+	Run(ctx context.Context)
+	ExtractMissing(ctx context.Context) (int64, error)
+}
+
+type Thumbnails interface {
+	Run(ctx context.Context)
+}
+
+type ScrubPolicies interface {
+	SetScrubPolicy(login string, input entity.ScrubPolicy) (*entity.ScrubPolicy, error)
+}
+
+type Events interface {
+	Run(ctx context.Context)
+	Close()
+	Stream(ctx *gin.Context, claims *utils.JwtClaim, lastEventID string) error
+}
+
+type Webhooks interface {
+	Run(ctx context.Context)
+	CreateWebhook(owner *uuid.UUID, input entity.CreateWebhookRequest) (*entity.Webhook, error)
+	GetWebhooks(owner *uuid.UUID) ([]entity.Webhook, error)
+	DeleteWebhook(owner *uuid.UUID, id uuid.UUID) error
+	GetWebhookDeliveries(owner *uuid.UUID, id uuid.UUID) ([]entity.WebhookDelivery, error)
+	RedeliverWebhook(owner *uuid.UUID, id uuid.UUID, deliveryID int64) (*entity.WebhookDelivery, error)
+}
+
+type Audit interface {
+	GetAuditLog(filter entity.AuditFilter) ([]entity.AuditEntry, error)
+	ExportAuditLog(ctx *gin.Context, filter entity.AuditFilter, format string) error
+}
+
+type Retention interface {
+	Run(ctx context.Context)
+	CreateRetentionPolicy(input entity.RetentionPolicy) (*entity.RetentionPolicy, error)
+	GetRetentionPolicies() ([]entity.RetentionPolicy, error)
+	DeleteRetentionPolicy(id uuid.UUID) error
+	PlaceLegalHold(ctx *gin.Context, docID uuid.UUID, input entity.CreateLegalHoldRequest) (*entity.LegalHold, error)
+	GetLegalHolds(docID uuid.UUID) ([]entity.LegalHold, error)
+	ReleaseLegalHold(ctx *gin.Context, docID, holdID uuid.UUID) error
+}
+
+type Locks interface {
+	Run(ctx context.Context)
+}
+
+type Scans interface {
+	Run(ctx context.Context)
+	GetScanResult(docID uuid.UUID) (*entity.ScanResult, error)
+	Rescan(docID uuid.UUID) (*entity.ScanResult, error)
+	RescanAll(input entity.RescanRequest) (int64, error)
 }
 
 type Service struct {
 	Docs
 	Authorization
+	Revocation
+	Users
+	Groups
+	Transfers
+	Scrub
+	Reconciliation
+	Encryption
+	Quotas
+	MimePolicies
+	ScrubPolicies
+	Scans
+	Thumbnails
+	Metadata
+	Events
+	Webhooks
+	Audit
+	Retention
+	Locks
 }
 
-func NewService(r *repository.Repository, fs *storage.FileStorage) *Service {
-	return &Service{Docs: NewDocsService(r.Docs, fs), Authorization: NewAuthService(r.Authorization)}
+func NewService(r *repository.Repository, fs *storage.FileStorage, cfg *config.Config, n notifier.Notifier,
+	sc scanner.Scanner) *Service {
+	revocation := NewRevocationService(r.Revocation, cfg.RevocationSyncInterval)
+	quotas := NewQuotaService(r.Quotas, fs, entity.Quota{
+		MaxBytes:    cfg.QuotaDefaultBytes,
+		MaxDocs:     cfg.QuotaDefaultDocs,
+		MaxFileSize: cfg.MaxFileSize,
+	}, cfg.MaxFileSizeByType)
+	mimePolicies := NewMimePolicyService(r.UploadPolicies, cfg.MimeMismatchPolicy,
+		entity.MimePolicy{Allow: cfg.MimeAllow, Deny: cfg.MimeDeny})
+	thumbs := NewThumbnailService(fs)
+	meta := NewMetadataService(r.Metadata, fs, metadata.Options{IncludeGPS: cfg.MetadataIncludeGPS})
+	scrubs := NewScrubPolicyService(r.UploadPolicies, cfg.MetadataScrub, cfg.MetadataKeepOriginal)
+	scans := NewScanService(r.Scans, fs, sc, thumbs, meta, cfg.ScanInterval)
+	audit := NewAuditService(r.Audit)
+	retention := NewRetentionService(r.Retention, fs, audit, cfg.RetentionInterval)
+	locks := NewLockService(r.Locks, audit, cfg.LockTTL, cfg.LockMaxTTL)
+	return &Service{Docs: NewDocsService(r.Docs, fs, quotas, mimePolicies, scans, thumbs, meta, scrubs,
+		audit, retention, locks),
+		Authorization: NewAuthService(r.Authorization, revocation, n, audit, cfg.PasswordHistorySize, cfg.ResetTokenTTL),
+		Revocation:    revocation,
+		Users:         NewUserService(r.Users, r.Authorization, fs, revocation, audit),
+		Groups:        NewGroupService(r.Groups, r.Authorization, audit),
+		Transfers:     NewTransferService(r.Transfers, r.Authorization, r.Docs, locks, audit),
+		Scrub:         NewScrubService(r.Integrity, fs, cfg.ScrubInterval, cfg.ScrubOrphanGrace),
+		Reconciliation: NewReconcileService(r.Integrity, fs, cfg.ReconcileInterval, cfg.ReconcileGrace,
+			entity.ReconcileOptions{Repair: cfg.ReconcileRepair, DeleteMissing: cfg.ReconcileDeleteMissing},
+			cfg.ReconcileMaxMissing, cfg.ReconcileMaxMissingRatio),
+		Encryption:    NewEncryptionService(r.Integrity, fs.Keyring()),
+		Quotas:        quotas,
+		MimePolicies:  mimePolicies,
+		ScrubPolicies: scrubs,
+		Scans:         scans,
+		Thumbnails:    thumbs,
+		Metadata:      meta,
+		Events: NewEventService(r.Events, revocation,
+			cfg.EventsPollInterval, cfg.EventsHeartbeat, cfg.EventsRetention),
+		Webhooks: NewWebhookService(r.Webhooks,
+			cfg.WebhookInterval, cfg.WebhookTimeout, cfg.WebhookMaxAttempts, cfg.WebhookAllowPrivate),
+		Audit:     audit,
+		Retention: retention,
+		Locks:     locks}
 }
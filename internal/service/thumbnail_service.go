@@ -0,0 +1,173 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/olenka-91/DocsServer/internal/entity"
+	"github.com/olenka-91/DocsServer/internal/storage"
+	"github.com/olenka-91/DocsServer/internal/thumbnail"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	thumbnailQueueSize = 256
+	// thumbnailRetryAfter - через сколько документ с неудачной миниатюрой можно
+	// обработать снова, thumbnailMaxFailed - сколько таких документов помнить.
+	thumbnailRetryAfter = time.Hour
+	thumbnailMaxFailed  = 10000
+)
+
+// ThumbnailService строит миниатюры изображений в фоне. Очередь хранится в памяти:
+// документы, не попавшие в нее или потерянные при перезапуске, ставятся в очередь
+// при первом запросе миниатюры.
+type ThumbnailService struct {
+	storage *storage.FileStorage
+	queue   chan *entity.Document
+
+	mu     sync.Mutex
+	queued map[uuid.UUID]bool
+	// failed - документы, для которых миниатюру построить не удалось (битое или
+	// слишком большое изображение), и время ошибки. Повторно они обрабатываются
+	// не раньше чем через thumbnailRetryAfter.
+	failed map[uuid.UUID]time.Time
+}
+
+func NewThumbnailService(fs *storage.FileStorage) *ThumbnailService {
+	return &ThumbnailService{
+		storage: fs,
+		queue:   make(chan *entity.Document, thumbnailQueueSize),
+		queued:  make(map[uuid.UUID]bool),
+		failed:  make(map[uuid.UUID]time.Time),
+	}
+}
+
+// Enqueue ставит документ в очередь. Если очередь заполнена, миниатюра будет построена
+// при запросе.
+func (s *ThumbnailService) Enqueue(doc *entity.Document) {
+	if !doc.File || !thumbnail.Supported(doc.Mime) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.queued[doc.ID] || s.isFailed(doc.ID) {
+		return
+	}
+	select {
+	case s.queue <- doc:
+		s.queued[doc.ID] = true
+	default:
+		logrus.Debugf("Thumbnail queue is full, doc %s skipped", doc.ID)
+	}
+}
+
+func (s *ThumbnailService) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case doc := <-s.queue:
+			s.generate(doc)
+		}
+	}
+}
+
+func (s *ThumbnailService) generate(doc *entity.Document) (err error) {
+	defer func() {
+		// Паника декодера на испорченном файле не должна останавливать очередь
+		if r := recover(); r != nil {
+			err = fmt.Errorf("thumbnail panic: %v", r)
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.queued, doc.ID)
+		if err != nil {
+			logrus.Warnf("Failed to generate thumbnails of doc %s: %v", doc.ID, err)
+			if !errors.Is(err, os.ErrNotExist) {
+				s.markFailed(doc.ID)
+			}
+		}
+	}()
+
+	return s.generateThumbnails(doc)
+}
+
+// isFailed сообщает, что недавняя попытка построить миниатюру документа не удалась.
+// Вызывается под s.mu.
+func (s *ThumbnailService) isFailed(id uuid.UUID) bool {
+	at, ok := s.failed[id]
+	if !ok {
+		return false
+	}
+	if time.Since(at) >= thumbnailRetryAfter {
+		delete(s.failed, id)
+		return false
+	}
+	return true
+}
+
+// markFailed запоминает неудачную попытку. Устаревшие записи удаляются, а при
+// переполнении забывается самая старая. Вызывается под s.mu.
+func (s *ThumbnailService) markFailed(id uuid.UUID) {
+	if len(s.failed) >= thumbnailMaxFailed {
+		var oldestID uuid.UUID
+		var oldest time.Time
+		for failedID, at := range s.failed {
+			if time.Since(at) >= thumbnailRetryAfter {
+				delete(s.failed, failedID)
+			} else if oldest.IsZero() || at.Before(oldest) {
+				oldestID, oldest = failedID, at
+			}
+		}
+		if len(s.failed) >= thumbnailMaxFailed {
+			delete(s.failed, oldestID)
+		}
+	}
+	s.failed[id] = time.Now()
+}
+
+func (s *ThumbnailService) generateThumbnails(doc *entity.Document) error {
+	rc, err := s.storage.Open(doc)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	thumbs, err := thumbnail.Generate(rc)
+	if err != nil {
+		return err
+	}
+	for size, data := range thumbs {
+		if err := s.storage.SaveThumbnail(doc, size, data); err != nil {
+			return err
+		}
+	}
+	logrus.Debugf("Thumbnails of doc %s generated", doc.ID)
+	return nil
+}
+
+// Serve отдает готовую миниатюру. Если ее еще нет, ставит документ в очередь и
+// возвращает ErrPreviewPending.
+func (s *ThumbnailService) Serve(ctx *gin.Context, doc *entity.Document, size int) error {
+	err := s.storage.ServeThumbnail(ctx, doc, size)
+	if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	s.mu.Lock()
+	failed := s.isFailed(doc.ID)
+	s.mu.Unlock()
+	if failed {
+		return ErrPreviewUnavailable
+	}
+	s.Enqueue(doc)
+	return ErrPreviewPending
+}
@@ -0,0 +1,220 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/olenka-91/DocsServer/internal/entity"
+	"github.com/olenka-91/DocsServer/internal/repository"
+	log "github.com/sirupsen/logrus"
+)
+
+type TransferService struct {
+	repo  repository.Transfers
+	auth  repository.Authorization
+	docs  repository.Docs
+	locks *LockService
+	audit *AuditService
+}
+
+func NewTransferService(r repository.Transfers, a repository.Authorization, d repository.Docs,
+	locks *LockService, audit *AuditService) *TransferService {
+	return &TransferService{repo: r, auth: a, docs: d, locks: locks, audit: audit}
+}
+
+// TransferDoc передает документ другому пользователю. При RequireAccept создается заявка,
+// которую получатель должен принять.
+func (s *TransferService) TransferDoc(ctx *gin.Context, docID uuid.UUID, userID uuid.UUID,
+	input entity.TransferDocRequest) (_ *entity.DocumentTransfer, err error) {
+	log.Debugf("Transferring doc %s to %s", docID, input.To)
+
+	var doc *entity.Document
+	defer func() {
+		e := entity.AuditEntry{
+			Action:     entity.AuditDocTransfer,
+			TargetType: entity.AuditTargetDocument,
+			TargetID:   docID.String(),
+			Details:    entity.JSONB{"to": strings.ToLower(input.To), "require_accept": input.RequireAccept},
+		}
+		if doc != nil {
+			e.TargetName = doc.Name
+		}
+		s.audit.Record(ctx, e, err)
+	}()
+
+	doc, err = s.docs.GetDoc(ctx, docID)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	if doc.UserID != userID {
+		return nil, ErrForbidden
+	}
+	owner, err := s.auth.GetUserByID(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	recipient, err := s.auth.GetUserByLogin(strings.ToLower(input.To))
+	if err != nil || recipient.Disabled {
+		return nil, ErrUserNotFound
+	}
+	if recipient.ID == userID {
+		return nil, ErrBadRequest
+	}
+
+	if input.RequireAccept {
+		transfer, err := s.repo.CreatePendingTransfer(docID, userID, recipient.ID)
+		if err != nil {
+			return nil, transferError(s.locks.lockedError(ctx, docID, err))
+		}
+		return transfer, nil
+	}
+
+	if err := s.repo.TransferDoc(docID, userID, recipient.ID, owner.Login); err != nil {
+		return nil, transferError(s.locks.lockedError(ctx, docID, err))
+	}
+
+	transfers, err := s.repo.GetDocTransfers(docID)
+	if err != nil {
+		return nil, err
+	}
+	if len(transfers) == 0 {
+		return nil, ErrInternalServerError
+	}
+	return &transfers[len(transfers)-1], nil
+}
+
+func (s *TransferService) GetIncomingTransfers(userID uuid.UUID) ([]entity.DocumentTransfer, error) {
+	return s.repo.GetIncomingTransfers(userID)
+}
+
+// GetDocTransfers возвращает историю передач документа. Доступна текущему владельцу.
+func (s *TransferService) GetDocTransfers(ctx *gin.Context, docID uuid.UUID, userID uuid.UUID) ([]entity.DocumentTransfer, error) {
+	doc, err := s.docs.GetDoc(ctx, docID)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	if doc.UserID != userID {
+		return nil, ErrForbidden
+	}
+	return s.repo.GetDocTransfers(docID)
+}
+
+func (s *TransferService) AcceptTransfer(ctx *gin.Context, userID, transferID uuid.UUID) error {
+	return s.resolve(ctx, entity.AuditDocAccept, transferID, func(t *entity.DocumentTransfer) bool {
+		return t.ToUserID != nil && *t.ToUserID == userID
+	}, s.repo.AcceptTransfer)
+}
+
+func (s *TransferService) DeclineTransfer(ctx *gin.Context, userID, transferID uuid.UUID) error {
+	return s.resolve(ctx, entity.AuditDocDecline, transferID, func(t *entity.DocumentTransfer) bool {
+		return t.ToUserID != nil && *t.ToUserID == userID
+	}, func(id uuid.UUID) error {
+		return s.repo.ResolveTransfer(id, entity.TransferDeclined)
+	})
+}
+
+func (s *TransferService) CancelTransfer(ctx *gin.Context, userID, transferID uuid.UUID) error {
+	return s.resolve(ctx, entity.AuditDocCancel, transferID, func(t *entity.DocumentTransfer) bool {
+		return t.FromUserID != nil && *t.FromUserID == userID
+	}, func(id uuid.UUID) error {
+		return s.repo.ResolveTransfer(id, entity.TransferCancelled)
+	})
+}
+
+// BulkTransfer - принудительная передача администратором всех документов одного пользователя другому.
+func (s *TransferService) BulkTransfer(ctx *gin.Context, adminID uuid.UUID,
+	input entity.BulkTransferRequest) (n int64, err error) {
+	var from *entity.User
+	defer func() {
+		e := entity.AuditEntry{
+			Action:     entity.AuditBulkTransfer,
+			TargetType: entity.AuditTargetUser,
+			TargetName: strings.ToLower(input.From),
+			Details:    entity.JSONB{"to": strings.ToLower(input.To), "transferred": n},
+		}
+		if from != nil {
+			e.TargetID = from.ID.String()
+		}
+		s.audit.Record(ctx, e, err)
+	}()
+
+	admin, err := s.auth.GetUserByID(adminID)
+	if err != nil {
+		return 0, ErrUserNotFound
+	}
+
+	from, err = s.auth.GetUserByLogin(strings.ToLower(input.From))
+	if err != nil {
+		return 0, ErrUserNotFound
+	}
+
+	to, err := s.auth.GetUserByLogin(strings.ToLower(input.To))
+	if err != nil {
+		return 0, ErrUserNotFound
+	}
+	if from.ID == to.ID {
+		return 0, ErrBadRequest
+	}
+
+	n, err = s.repo.TransferAllDocs(from.ID, to.ID, admin.Login)
+	if err != nil {
+		return 0, transferError(err)
+	}
+
+	log.Infof("Admin %s transferred %d docs from %s to %s", admin.Login, n, from.Login, to.Login)
+	return n, nil
+}
+
+// resolve применяет apply к ожидающей заявке, если она адресована пользователю (allowed).
+// Чужая заявка для пользователя не существует, но в журнал попадает как отказ.
+func (s *TransferService) resolve(ctx *gin.Context, action string, transferID uuid.UUID,
+	allowed func(*entity.DocumentTransfer) bool, apply func(uuid.UUID) error) (err error) {
+	var t *entity.DocumentTransfer
+	var outcome string
+	defer func() {
+		e := entity.AuditEntry{
+			Action:  action,
+			Outcome: outcome,
+			Details: entity.JSONB{"transfer_id": transferID.String()},
+		}
+		if t != nil {
+			e.TargetType = entity.AuditTargetDocument
+			e.TargetID = t.DocID.String()
+			e.TargetName = t.DocName
+			e.Details["from"] = t.FromLogin
+			e.Details["to"] = t.ToLogin
+		}
+		s.audit.Record(ctx, e, err)
+	}()
+
+	t, err = s.repo.GetTransfer(transferID)
+	if err != nil {
+		return ErrNotFound
+	}
+	if !allowed(t) {
+		outcome = entity.AuditDenied
+		return ErrNotFound
+	}
+	if t.Status != entity.TransferPending {
+		return ErrConflict
+	}
+	return transferError(apply(transferID))
+}
+
+func transferError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, sql.ErrNoRows):
+		return ErrNotFound
+	case errors.Is(err, repository.ErrOwnerChanged):
+		return ErrConflict
+	case errors.Is(err, repository.ErrDocLocked):
+		return ErrLocked
+	}
+	return err
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/olenka-91/DocsServer/internal/entity"
+	"github.com/olenka-91/DocsServer/internal/repository"
+	"github.com/olenka-91/DocsServer/internal/storage"
+	"github.com/olenka-91/DocsServer/internal/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const pgUniqueViolation = "23505"
+
+type UserService struct {
+	repo       repository.Users
+	auth       repository.Authorization
+	storage    *storage.FileStorage
+	revocation *RevocationService
+	audit      *AuditService
+}
+
+func NewUserService(r repository.Users, a repository.Authorization, fs *storage.FileStorage,
+	rs *RevocationService, audit *AuditService) *UserService {
+	return &UserService{repo: r, auth: a, storage: fs, revocation: rs, audit: audit}
+}
+
+func (s *UserService) GetProfile(userID uuid.UUID) (*entity.UserProfile, error) {
+	profile, err := s.repo.GetProfile(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	return profile, nil
+}
+
+func (s *UserService) UpdateProfile(ctx *gin.Context, userID uuid.UUID,
+	input entity.UpdateProfileRequest) (_ *entity.UserProfile, err error) {
+	defer func() {
+		// Значения полей не записываются: это личные данные
+		var fields []string
+		if input.DisplayName != nil {
+			fields = append(fields, "display_name")
+		}
+		if input.Email != nil {
+			fields = append(fields, "email")
+		}
+		if input.Preferences != nil {
+			fields = append(fields, "preferences")
+		}
+		s.audit.Record(ctx, entity.AuditEntry{
+			Action:     entity.AuditProfileEdit,
+			TargetType: entity.AuditTargetUser,
+			TargetID:   userID.String(),
+			TargetName: ctx.GetString("login"),
+			Details:    entity.JSONB{"fields": fields},
+		}, err)
+	}()
+
+	if err := s.repo.UpdateProfile(userID, input); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == pgUniqueViolation {
+			return nil, ErrConflict
+		}
+		return nil, err
+	}
+	return s.GetProfile(userID)
+}
+
+// DeleteAccount удаляет пользователя. Его документы либо удаляются вместе с файлами,
+// либо передаются пользователю input.TransferTo.
+func (s *UserService) DeleteAccount(ctx *gin.Context, claims *utils.JwtClaim,
+	input entity.DeleteAccountRequest) (err error) {
+	defer func() {
+		e := entity.AuditEntry{
+			Action:     entity.AuditUserDelete,
+			TargetType: entity.AuditTargetUser,
+			TargetID:   claims.UserID.String(),
+			TargetName: claims.Login,
+			Details:    entity.JSONB{"mode": input.Mode},
+		}
+		if input.Mode == entity.DeleteModeTransfer {
+			e.Details["transfer_to"] = strings.ToLower(input.TransferTo)
+		}
+		s.audit.Record(ctx, e, err)
+	}()
+
+	user, err := s.auth.GetUserByID(claims.UserID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+
+	if err := utils.CheckPasswordHash(input.Password, user.Password); err != nil {
+		return ErrInvalidCredentials
+	}
+
+	// Токены отзываются в той же транзакции, что удаляет пользователя: удаленная учетная
+	// запись не может остаться с действующими токенами
+	validAfter := time.Now()
+	switch input.Mode {
+	case entity.DeleteModeTransfer:
+		if err := s.transferAndDelete(user, input.TransferTo, validAfter); err != nil {
+			return err
+		}
+	case entity.DeleteModeDelete:
+		if err := s.deleteWithDocs(user, validAfter); err != nil {
+			return err
+		}
+	default:
+		return ErrBadRequest
+	}
+
+	s.revocation.applyCutoff(user.ID, validAfter)
+	return nil
+}
+
+func (s *UserService) transferAndDelete(user *entity.User, transferTo string, validAfter time.Time) error {
+	newOwner, err := s.auth.GetUserByLogin(strings.ToLower(transferTo))
+	if err != nil {
+		return ErrUserNotFound
+	}
+	if newOwner.ID == user.ID || newOwner.Disabled {
+		return ErrBadRequest
+	}
+
+	return s.repo.TransferDocsAndDeleteUser(user.ID, newOwner.ID, validAfter)
+}
+
+func (s *UserService) deleteWithDocs(user *entity.User, validAfter time.Time) error {
+	docs, err := s.repo.GetOwnedDocs(user.ID)
+	if err != nil {
+		return err
+	}
+
+	// Сначала удаляем строки: если БД недоступна, файлы останутся на месте
+	if err := s.repo.DeleteUser(user.ID, validAfter); err != nil {
+		if errors.Is(err, repository.ErrDocRetained) {
+			return ErrRetained
+		}
+		return err
+	}
+
+	for _, doc := range docs {
+		if !doc.File {
+			continue
+		}
+		if err := s.storage.DeleteFile(doc.ID, doc.Name); err != nil {
+			logrus.Errorf("Failed to delete file of doc %s: %v", doc.ID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,325 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/olenka-91/DocsServer/internal/entity"
+	"github.com/olenka-91/DocsServer/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultWebhookInterval    = 5 * time.Second
+	defaultWebhookTimeout     = 10 * time.Second
+	defaultWebhookMaxAttempts = 8
+	webhookBatch              = 50
+	webhookWorkers            = 4
+	// Первая повторная попытка через webhookBackoff, дальше интервал удваивается
+	webhookBackoff    = 30 * time.Second
+	webhookMaxBackoff = time.Hour
+	// webhookDeliveriesLimit - сколько последних доставок показывать в истории
+	webhookDeliveriesLimit = 100
+	// webhookErrorSize - сколько байт ответа сохранять при ошибке
+	webhookErrorSize = 512
+)
+
+var errPrivateAddress = errors.New("webhook address is not public")
+
+// WebhookService доставляет события документов на webhooks. События ставятся в очередь
+// webhook_deliveries в транзакции, изменившей документ (outbox); сервис отправляет их
+// подписанными POST-запросами и повторяет неудачные с экспоненциальной задержкой.
+type WebhookService struct {
+	repo        repository.Webhooks
+	interval    time.Duration
+	timeout     time.Duration
+	maxAttempts int
+	// allowPrivate - webhooks пользователей могут указывать на внутренние адреса
+	allowPrivate bool
+
+	// client - для webhooks администратора, publicClient - для webhooks пользователей:
+	// соединяется только с публичными адресами, чтобы через webhook нельзя было
+	// обратиться к внутренней сети
+	client       *http.Client
+	publicClient *http.Client
+	wake         chan struct{}
+}
+
+func NewWebhookService(r repository.Webhooks, interval, timeout time.Duration, maxAttempts int,
+	allowPrivate bool) *WebhookService {
+	if interval <= 0 {
+		interval = defaultWebhookInterval
+	}
+	if timeout <= 0 {
+		timeout = defaultWebhookTimeout
+	}
+	if maxAttempts <= 0 {
+		maxAttempts = defaultWebhookMaxAttempts
+	}
+
+	// Перенаправления не выполняются: 3xx считается ошибкой доставки
+	noRedirect := func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	publicClient := &http.Client{Timeout: timeout, CheckRedirect: noRedirect}
+	if !allowPrivate {
+		dialer := &net.Dialer{Timeout: timeout, Control: dialPublicOnly}
+		publicClient.Transport = &http.Transport{DialContext: dialer.DialContext}
+	}
+
+	return &WebhookService{
+		repo:         r,
+		interval:     interval,
+		timeout:      timeout,
+		maxAttempts:  maxAttempts,
+		allowPrivate: allowPrivate,
+		client:       &http.Client{Timeout: timeout, CheckRedirect: noRedirect},
+		publicClient: publicClient,
+		wake:         make(chan struct{}, 1),
+	}
+}
+
+// dialPublicOnly проверяет адрес уже после разрешения имени, так что запрет не обойти
+// DNS-записью, указывающей на внутренний адрес.
+func dialPublicOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+		return errPrivateAddress
+	}
+	return nil
+}
+
+func isPublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast())
+}
+
+// CreateWebhook регистрирует webhook пользователя owner; owner == nil - webhook
+// администратора, получающий все события.
+func (s *WebhookService) CreateWebhook(owner *uuid.UUID, input entity.CreateWebhookRequest) (*entity.Webhook, error) {
+	u, err := url.Parse(input.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, ErrBadRequest
+	}
+	if ip := net.ParseIP(u.Hostname()); owner != nil && !s.allowPrivate && ip != nil && !isPublicIP(ip) {
+		return nil, ErrBadRequest
+	}
+
+	secret := input.Secret
+	if secret == "" {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		secret = hex.EncodeToString(buf)
+	}
+
+	w := &entity.Webhook{
+		ID:     uuid.New(),
+		UserID: owner,
+		URL:    input.URL,
+		Secret: secret,
+		Events: input.Events,
+	}
+	if w.Events == nil {
+		w.Events = []string{}
+	}
+	if err := s.repo.CreateWebhook(w); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (s *WebhookService) GetWebhooks(owner *uuid.UUID) ([]entity.Webhook, error) {
+	return s.repo.GetWebhooks(owner)
+}
+
+func (s *WebhookService) DeleteWebhook(owner *uuid.UUID, id uuid.UUID) error {
+	if _, err := s.getWebhook(owner, id); err != nil {
+		return err
+	}
+	return s.repo.DeleteWebhook(id)
+}
+
+// GetWebhookDeliveries возвращает историю последних доставок webhook.
+func (s *WebhookService) GetWebhookDeliveries(owner *uuid.UUID, id uuid.UUID) ([]entity.WebhookDelivery, error) {
+	if _, err := s.getWebhook(owner, id); err != nil {
+		return nil, err
+	}
+	return s.repo.GetDeliveries(id, webhookDeliveriesLimit)
+}
+
+// RedeliverWebhook отправляет доставку заново, в том числе уже доставленную или
+// исчерпавшую попытки.
+func (s *WebhookService) RedeliverWebhook(owner *uuid.UUID, id uuid.UUID, deliveryID int64) (*entity.WebhookDelivery, error) {
+	if _, err := s.getWebhook(owner, id); err != nil {
+		return nil, err
+	}
+	d, err := s.repo.Redeliver(id, deliveryID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.notify()
+	return d, nil
+}
+
+// getWebhook возвращает webhook, если он принадлежит owner. Чужие webhooks
+// неотличимы от несуществующих.
+func (s *WebhookService) getWebhook(owner *uuid.UUID, id uuid.UUID) (*entity.Webhook, error) {
+	w, err := s.repo.GetWebhook(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if (owner == nil) != (w.UserID == nil) || (owner != nil && *owner != *w.UserID) {
+		return nil, ErrNotFound
+	}
+	return w, nil
+}
+
+func (s *WebhookService) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *WebhookService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.deliverDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+	}
+}
+
+func (s *WebhookService) deliverDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		// Доставка не должна начаться повторно, пока не отправлена вся пачка
+		lease := time.Duration(webhookBatch/webhookWorkers+1)*s.timeout + time.Minute
+		deliveries, err := s.repo.ClaimDeliveries(webhookBatch, lease)
+		if err != nil {
+			logrus.Errorf("Failed to fetch webhook deliveries: %v", err)
+			return
+		}
+
+		jobs := make(chan *entity.WebhookDelivery)
+		var wg sync.WaitGroup
+		for i := 0; i < webhookWorkers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for d := range jobs {
+					s.deliver(ctx, d)
+				}
+			}()
+		}
+		for i := range deliveries {
+			jobs <- &deliveries[i]
+		}
+		close(jobs)
+		wg.Wait()
+
+		if len(deliveries) < webhookBatch {
+			return
+		}
+	}
+}
+
+func (s *WebhookService) deliver(ctx context.Context, d *entity.WebhookDelivery) {
+	statusCode, err := s.send(ctx, d)
+	if err == nil {
+		if err := s.repo.MarkDelivered(d.ID, statusCode); err != nil {
+			logrus.Errorf("Failed to mark webhook delivery %d as delivered: %v", d.ID, err)
+		}
+		return
+	}
+	if ctx.Err() != nil {
+		// Остановка сервера - не ошибка получателя, доставка повторится после lease
+		return
+	}
+
+	attempts := d.Attempts + 1
+	dead := attempts >= s.maxAttempts
+	backoff := webhookBackoff << (attempts - 1)
+	if backoff > webhookMaxBackoff || backoff <= 0 {
+		backoff = webhookMaxBackoff
+	}
+	if dead {
+		logrus.Warnf("Webhook delivery %d to %s failed after %d attempts: %v", d.ID, d.URL, attempts, err)
+	} else {
+		logrus.Debugf("Webhook delivery %d to %s failed, retry in %s: %v", d.ID, d.URL, backoff, err)
+	}
+	// Ответ получателя может быть не в UTF-8, а Postgres не примет такой текст
+	errText := strings.ToValidUTF8(strings.ReplaceAll(err.Error(), "\x00", ""), "?")
+	if err := s.repo.MarkFailed(d.ID, statusCode, errText, time.Now().Add(backoff), dead); err != nil {
+		logrus.Errorf("Failed to record webhook delivery %d failure: %v", d.ID, err)
+	}
+}
+
+// send отправляет событие. Подпись - HMAC-SHA256 от "<timestamp>.<тело>" с ключом
+// webhook: получатель проверяет и подпись, и свежесть метки времени.
+func (s *WebhookService) send(ctx context.Context, d *entity.WebhookDelivery) (int, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(d.Secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(d.Payload)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "DocsServer-Webhook")
+	req.Header.Set("X-Webhook-Event", d.EventType)
+	req.Header.Set("X-Webhook-Delivery", strconv.FormatInt(d.ID, 10))
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+
+	client := s.client
+	if d.UserID != nil {
+		client = s.publicClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, webhookErrorSize))
+		return resp.StatusCode, nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, webhookErrorSize))
+	return resp.StatusCode, fmt.Errorf("unexpected status %s: %s", resp.Status, bytes.TrimSpace(body))
+}
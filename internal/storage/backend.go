@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type BlobInfo struct {
+	Size    int64
+	ModTime time.Time
+}
+
+// Backend - место физического хранения файлов. Ключ - относительный путь файла.
+type Backend interface {
+	Save(key string, r io.Reader) (int64, error)
+	// Open возвращает содержимое файла; локальные реализации возвращают io.ReadSeekCloser.
+	Open(key string) (io.ReadCloser, error)
+	Stat(key string) (BlobInfo, error)
+	Remove(key string) error
+	// List вызывает fn для каждого сохраненного файла; незавершенные записи пропускаются.
+	List(fn func(key string, info BlobInfo) error) error
+	// Location - путь файла, который сохраняется в documents.path.
+	Location(key string) string
+	// Remote сообщает, что чтение из backend дорогое и перед ним нужен дисковый кеш.
+	Remote() bool
+}
+
+// LocalBackend хранит файлы в каталоге. Каталог может быть сетевым (NFS, SMB) -
+// тогда remote=true включает локальный дисковый кеш.
+type LocalBackend struct {
+	root   string
+	remote bool
+}
+
+func NewLocalBackend(root string, remote bool) (*LocalBackend, error) {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	return &LocalBackend{root: root, remote: remote}, nil
+}
+
+func (b *LocalBackend) Location(key string) string {
+	return filepath.Join(b.root, key)
+}
+
+func (b *LocalBackend) Remote() bool {
+	return b.remote
+}
+
+func (b *LocalBackend) Save(key string, r io.Reader) (int64, error) {
+	filePath := b.Location(key)
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return 0, fmt.Errorf("failed to create subdirectory: %w", err)
+	}
+	tmpPath := filePath + ".tmp"
+
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create file: %w", err)
+	}
+	defer file.Close()
+
+	size, err := io.Copy(file, r)
+	if err != nil {
+		os.Remove(tmpPath)
+		return 0, fmt.Errorf("failed to write file: %w", err)
+	}
+
+	if err := file.Sync(); err != nil {
+		os.Remove(tmpPath)
+		return 0, fmt.Errorf("failed to sync file: %w", err)
+	}
+	file.Close()
+
+	// Переименовываем временный файл в постоянный
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		os.Remove(tmpPath)
+		return 0, fmt.Errorf("failed to rename file: %w", err)
+	}
+
+	return size, nil
+}
+
+func (b *LocalBackend) Open(key string) (io.ReadCloser, error) {
+	file, err := os.Open(b.Location(key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, os.ErrNotExist
+		}
+		return nil, err
+	}
+	return file, nil
+}
+
+func (b *LocalBackend) Stat(key string) (BlobInfo, error) {
+	info, err := os.Stat(b.Location(key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return BlobInfo{}, os.ErrNotExist
+		}
+		return BlobInfo{}, err
+	}
+	return BlobInfo{Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+func (b *LocalBackend) Remove(key string) error {
+	if err := os.Remove(b.Location(key)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (b *LocalBackend) List(fn func(key string, info BlobInfo) error) error {
+	return filepath.WalkDir(b.root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasSuffix(path, ".tmp") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			// Файл удален во время обхода
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		key, err := filepath.Rel(b.root, path)
+		if err != nil {
+			return err
+		}
+		return fn(key, BlobInfo{Size: info.Size(), ModTime: info.ModTime()})
+	})
+}
@@ -0,0 +1,438 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Формат сжатого файла: независимо сжатые блоки по compChunkSize байт открытого текста
+// (члены gzip или кадры zstd), за которыми идет индекс:
+//
+//	размеры блоков (uint32 каждый) | uint32 размер блока | uint64 размер файла | uint32 число блоков | "DSZ1"
+//
+// Склеенные члены gzip и кадры zstd - корректный поток своего формата, поэтому данные
+// без индекса можно отдавать клиенту как есть с Content-Encoding. Индекс позволяет
+// распаковывать только блоки, попавшие в Range.
+const (
+	EncodingGzip = "gzip"
+	EncodingZstd = "zstd"
+
+	compMagic       = "DSZ1"
+	compChunkSize   = 256 * 1024
+	compTrailerTail = 20
+)
+
+// defaultCompressibleTypes - типы, которые хорошо сжимаются. Форматы OOXML и ODF (docx,
+// xlsx, odt) уже являются zip-архивами и по умолчанию не сжимаются.
+var defaultCompressibleTypes = []string{
+	"text/*",
+	"application/json",
+	"application/xml",
+	"application/javascript",
+	"application/x-ndjson",
+	"application/x-yaml",
+	"application/yaml",
+	"application/rtf",
+	"application/msword",
+	"application/vnd.ms-excel",
+	"application/vnd.ms-powerpoint",
+	"application/x-sql",
+	"image/svg+xml",
+	"*+xml",
+	"*+json",
+}
+
+type CompressionConfig struct {
+	// Encoding - gzip или zstd; пусто - сжатие выключено
+	Encoding string
+	// MimeTypes - шаблоны сжимаемых типов: "text/*", "*+xml" или точный тип
+	MimeTypes []string
+}
+
+func (c CompressionConfig) withDefaults() CompressionConfig {
+	if len(c.MimeTypes) == 0 {
+		c.MimeTypes = defaultCompressibleTypes
+	}
+	return c
+}
+
+func (c CompressionConfig) validate() error {
+	switch c.Encoding {
+	case "", EncodingGzip, EncodingZstd:
+		return nil
+	}
+	return fmt.Errorf("unsupported compression %q", c.Encoding)
+}
+
+// Compressible сообщает, нужно ли сжимать файл такого типа.
+func (c CompressionConfig) Compressible(mimeType string) bool {
+	if c.Encoding == "" {
+		return false
+	}
+	for _, pattern := range c.MimeTypes {
+		if MimeMatches(pattern, mimeType) {
+			return true
+		}
+	}
+	return false
+}
+
+func compChunkCount(size int64) int64 {
+	if size == 0 {
+		return 1
+	}
+	return (size + compChunkSize - 1) / compChunkSize
+}
+
+// compTrailerSize - размер индекса сжатого файла с исходным размером size.
+func compTrailerSize(size int64) int64 {
+	return compTrailerTail + 4*compChunkCount(size)
+}
+
+type codec interface {
+	compress(dst, src []byte) ([]byte, error)
+	decompress(dst, src []byte) ([]byte, error)
+	stream(r io.Reader) (io.ReadCloser, error)
+}
+
+func codecFor(encoding string) (codec, error) {
+	switch encoding {
+	case EncodingGzip:
+		return gzipCodec{}, nil
+	case EncodingZstd:
+		return zstdCodec{}, nil
+	}
+	return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+}
+
+var gzipWriters = sync.Pool{New: func() interface{} {
+	return gzip.NewWriter(nil)
+}}
+
+type gzipCodec struct{}
+
+func (gzipCodec) compress(dst, src []byte) ([]byte, error) {
+	buf := bytes.NewBuffer(dst)
+	w := gzipWriters.Get().(*gzip.Writer)
+	defer gzipWriters.Put(w)
+
+	w.Reset(buf)
+	if _, err := w.Write(src); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gzipCodec) decompress(dst, src []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+	r.Multistream(false)
+	buf := bytes.NewBuffer(dst)
+	if _, err := io.Copy(buf, r); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gzipCodec) stream(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+// Кодер и декодер zstd потокобезопасны для EncodeAll/DecodeAll
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+type zstdCodec struct{}
+
+func (zstdCodec) compress(dst, src []byte) ([]byte, error) {
+	return zstdEncoder.EncodeAll(src, dst), nil
+}
+
+func (zstdCodec) decompress(dst, src []byte) ([]byte, error) {
+	return zstdDecoder.DecodeAll(src, dst)
+}
+
+func (zstdCodec) stream(r io.Reader) (io.ReadCloser, error) {
+	d, err := zstd.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return d.IOReadCloser(), nil
+}
+
+// compressReader сжимает поток по мере чтения и дописывает индекс в конце.
+type compressReader struct {
+	codec codec
+	src   *bufio.Reader
+	plain []byte
+	out   []byte
+	sizes []uint32
+	total int64
+	done  bool
+}
+
+func newCompressReader(src io.Reader, c codec) *compressReader {
+	return &compressReader{
+		codec: c,
+		src:   bufio.NewReaderSize(src, compChunkSize),
+		plain: make([]byte, compChunkSize),
+	}
+}
+
+func (c *compressReader) Read(p []byte) (int, error) {
+	if len(c.out) == 0 {
+		if c.done {
+			return 0, io.EOF
+		}
+		if err := c.compressNext(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, c.out)
+	c.out = c.out[n:]
+	return n, nil
+}
+
+func (c *compressReader) compressNext() error {
+	n, err := io.ReadFull(c.src, c.plain)
+	last := false
+	switch {
+	case err == io.EOF || err == io.ErrUnexpectedEOF:
+		last = true
+	case err != nil:
+		return err
+	default:
+		if _, err := c.src.Peek(1); err == io.EOF {
+			last = true
+		} else if err != nil {
+			return err
+		}
+	}
+
+	out, err := c.codec.compress(c.out[:0], c.plain[:n])
+	if err != nil {
+		return err
+	}
+	c.sizes = append(c.sizes, uint32(len(out)))
+	c.total += int64(n)
+
+	if last {
+		for _, size := range c.sizes {
+			out = binary.BigEndian.AppendUint32(out, size)
+		}
+		out = binary.BigEndian.AppendUint32(out, compChunkSize)
+		out = binary.BigEndian.AppendUint64(out, uint64(c.total))
+		out = binary.BigEndian.AppendUint32(out, uint32(len(c.sizes)))
+		out = append(out, compMagic...)
+		c.done = true
+	}
+	c.out = out
+	return nil
+}
+
+// decompressReader распаковывает блоки по индексу, поддерживая Seek.
+type decompressReader struct {
+	src       io.ReadSeeker
+	codec     codec
+	chunkSize int64
+	size      int64
+	offsets   []int64 // offsets[i] - начало блока i, последний элемент - конец данных
+
+	pos      int64
+	chunkIdx int
+	chunk    []byte
+	packed   []byte
+}
+
+func newDecompressReader(src io.ReadSeeker, c codec, storedSize int64) (*decompressReader, error) {
+	if storedSize < compTrailerTail {
+		return nil, ErrCorrupted
+	}
+	tail := make([]byte, compTrailerTail)
+	if _, err := src.Seek(storedSize-compTrailerTail, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(src, tail); err != nil {
+		return nil, err
+	}
+	if string(tail[16:]) != compMagic {
+		return nil, ErrCorrupted
+	}
+	chunkSize := int64(binary.BigEndian.Uint32(tail[0:]))
+	size := int64(binary.BigEndian.Uint64(tail[4:]))
+	count := int64(binary.BigEndian.Uint32(tail[12:]))
+	indexSize := 4 * count
+	if chunkSize == 0 || count == 0 || storedSize < compTrailerTail+indexSize {
+		return nil, ErrCorrupted
+	}
+
+	index := make([]byte, indexSize)
+	if _, err := src.Seek(storedSize-compTrailerTail-indexSize, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(src, index); err != nil {
+		return nil, err
+	}
+
+	offsets := make([]int64, count+1)
+	for i := int64(0); i < count; i++ {
+		offsets[i+1] = offsets[i] + int64(binary.BigEndian.Uint32(index[4*i:]))
+	}
+	if offsets[count] != storedSize-compTrailerTail-indexSize {
+		return nil, ErrCorrupted
+	}
+
+	return &decompressReader{
+		src:       src,
+		codec:     c,
+		chunkSize: chunkSize,
+		size:      size,
+		offsets:   offsets,
+		chunkIdx:  -1,
+	}, nil
+}
+
+func (d *decompressReader) Size() int64 {
+	return d.size
+}
+
+func (d *decompressReader) Read(p []byte) (int, error) {
+	if d.pos >= d.size {
+		return 0, io.EOF
+	}
+
+	idx := int(d.pos / d.chunkSize)
+	if idx != d.chunkIdx {
+		if err := d.loadChunk(idx); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, d.chunk[d.pos-int64(idx)*d.chunkSize:])
+	d.pos += int64(n)
+	return n, nil
+}
+
+func (d *decompressReader) loadChunk(idx int) error {
+	if idx+1 >= len(d.offsets) {
+		return ErrCorrupted
+	}
+	start, end := d.offsets[idx], d.offsets[idx+1]
+	if int64(cap(d.packed)) < end-start {
+		d.packed = make([]byte, end-start)
+	}
+	packed := d.packed[:end-start]
+
+	if _, err := d.src.Seek(start, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := io.ReadFull(d.src, packed); err != nil {
+		return err
+	}
+
+	chunk, err := d.codec.decompress(d.chunk[:0], packed)
+	if err != nil {
+		d.chunkIdx = -1
+		if errors.Is(err, ErrCorrupted) {
+			return err
+		}
+		return fmt.Errorf("%w: %v", ErrCorrupted, err)
+	}
+
+	expected := d.chunkSize
+	if rest := d.size - int64(idx)*d.chunkSize; rest < expected {
+		expected = rest
+	}
+	if int64(len(chunk)) != expected {
+		d.chunkIdx = -1
+		return ErrCorrupted
+	}
+	d.chunk = chunk
+	d.chunkIdx = idx
+	return nil
+}
+
+func (d *decompressReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += d.pos
+	case io.SeekEnd:
+		offset += d.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	d.pos = offset
+	return offset, nil
+}
+
+func (d *decompressReader) Close() error {
+	if c, ok := d.src.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// prefixReadSeeker ограничивает ReadSeeker первыми n байтами - сжатые данные без индекса.
+type prefixReadSeeker struct {
+	src io.ReadSeeker
+	n   int64
+	pos int64
+}
+
+func (p *prefixReadSeeker) Read(b []byte) (int, error) {
+	if p.pos >= p.n {
+		return 0, io.EOF
+	}
+	if rest := p.n - p.pos; int64(len(b)) > rest {
+		b = b[:rest]
+	}
+	if _, err := p.src.Seek(p.pos, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, err := p.src.Read(b)
+	p.pos += int64(n)
+	return n, err
+}
+
+func (p *prefixReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += p.pos
+	case io.SeekEnd:
+		offset += p.n
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	p.pos = offset
+	return offset, nil
+}
+
+func (p *prefixReadSeeker) Close() error {
+	if c, ok := p.src.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// textData - сжимаемый текст размером size.
+func textData(size int) []byte {
+	var buf bytes.Buffer
+	for i := 0; buf.Len() < size; i++ {
+		fmt.Fprintf(&buf, "line %d of the test document\n", i)
+	}
+	return buf.Bytes()[:size]
+}
+
+func compressBlob(t *testing.T, c codec, data []byte) []byte {
+	t.Helper()
+	blob, err := io.ReadAll(newCompressReader(bytes.NewReader(data), c))
+	if err != nil {
+		t.Fatalf("compress: %v", err)
+	}
+	return blob
+}
+
+func TestCompressTrailer(t *testing.T) {
+	sizes := []int{0, 1, compChunkSize, compChunkSize + 1, 2*compChunkSize + compChunkSize/2}
+	for _, encoding := range []string{EncodingGzip, EncodingZstd} {
+		c, err := codecFor(encoding)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, size := range sizes {
+			t.Run(fmt.Sprintf("%s/%d", encoding, size), func(t *testing.T) {
+				data := textData(size)
+				blob := compressBlob(t, c, data)
+
+				tail := blob[len(blob)-compTrailerTail:]
+				if string(tail[16:]) != compMagic {
+					t.Fatalf("trailer magic = %q", tail[16:])
+				}
+				chunkSize := binary.BigEndian.Uint32(tail)
+				total := binary.BigEndian.Uint64(tail[4:])
+				count := int64(binary.BigEndian.Uint32(tail[12:]))
+				if chunkSize != compChunkSize || total != uint64(size) || count != compChunkCount(int64(size)) {
+					t.Errorf("trailer = chunk %d, size %d, %d chunks", chunkSize, total, count)
+				}
+				if want := compTrailerSize(int64(size)); int64(compTrailerTail)+4*count != want {
+					t.Errorf("trailer size = %d, want %d", compTrailerTail+4*count, want)
+				}
+
+				// Данные без индекса - обычный поток gzip или zstd
+				sr, err := c.stream(bytes.NewReader(blob[:int64(len(blob))-compTrailerSize(int64(size))]))
+				if err != nil {
+					t.Fatalf("stream: %v", err)
+				}
+				defer sr.Close()
+				if got, err := io.ReadAll(sr); err != nil || !bytes.Equal(got, data) {
+					t.Errorf("stream without trailer: %v, data matches: %t", err, bytes.Equal(got, data))
+				}
+
+				dr, err := newDecompressReader(bytes.NewReader(blob), c, int64(len(blob)))
+				if err != nil {
+					t.Fatalf("newDecompressReader: %v", err)
+				}
+				if dr.Size() != int64(size) {
+					t.Errorf("Size = %d, want %d", dr.Size(), size)
+				}
+				if got, err := io.ReadAll(dr); err != nil || !bytes.Equal(got, data) {
+					t.Errorf("decompress: %v, data matches: %t", err, bytes.Equal(got, data))
+				}
+			})
+		}
+	}
+}
+
+func TestDecompressCorrupted(t *testing.T) {
+	c := gzipCodec{}
+	blob := compressBlob(t, c, textData(2*compChunkSize))
+	modify := func(fn func(b []byte)) []byte {
+		b := bytes.Clone(blob)
+		fn(b)
+		return b
+	}
+	indexStart := len(blob) - int(compTrailerSize(2*compChunkSize))
+
+	tests := []struct {
+		name string
+		blob []byte
+	}{
+		{"bad magic", modify(func(b []byte) { b[len(b)-1] ^= 1 })},
+		{"zero chunk count", modify(func(b []byte) { binary.BigEndian.PutUint32(b[len(b)-8:], 0) })},
+		{"index does not match data", modify(func(b []byte) { b[indexStart+3]++ })},
+		{"truncated", blob[:len(blob)-1]},
+		{"damaged chunk", modify(func(b []byte) { b[20] ^= 0xFF })},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dr, err := newDecompressReader(bytes.NewReader(tt.blob), c, int64(len(tt.blob)))
+			if err == nil {
+				_, err = io.ReadAll(dr)
+			}
+			if !errors.Is(err, ErrCorrupted) {
+				t.Errorf("error = %v, want ErrCorrupted", err)
+			}
+		})
+	}
+}
+
+func TestServeCompressed(t *testing.T) {
+	keyring, err := LoadKeyring(testMasterKey(t, "k1"), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := textData(2*compChunkSize + 1000)
+
+	tests := []struct {
+		name     string
+		encoding string
+		keyring  *Keyring
+	}{
+		{"gzip", EncodingGzip, nil},
+		{"zstd", EncodingZstd, nil},
+		{"gzip encrypted", EncodingGzip, keyring},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := newTestStorage(t, tt.keyring, CompressionConfig{Encoding: tt.encoding})
+			doc := saveTestDoc(t, fs, "a.txt", "text/plain", data)
+			if doc.Encoding != tt.encoding {
+				t.Fatalf("Encoding = %q, want %q", doc.Encoding, tt.encoding)
+			}
+			plainETag := strongETag(doc.Checksum)
+			encodedETag := strongETag(doc.Checksum + "-" + tt.encoding)
+
+			// Range считается по распакованному содержимому
+			start, end := compChunkSize-10, 2*compChunkSize+20
+			w := serveTestDoc(t, fs, doc, http.MethodGet, map[string]string{"Range": fmt.Sprintf("bytes=%d-%d", start, end)})
+			if w.Code != http.StatusPartialContent || !bytes.Equal(w.Body.Bytes(), data[start:end+1]) {
+				t.Errorf("range = %d, body matches: %t", w.Code, bytes.Equal(w.Body.Bytes(), data[start:end+1]))
+			}
+			checkRepresentation(t, w, "", plainETag)
+
+			w = serveTestDoc(t, fs, doc, http.MethodGet, nil)
+			if !bytes.Equal(w.Body.Bytes(), data) {
+				t.Error("plain body differs")
+			}
+			checkRepresentation(t, w, "", plainETag)
+
+			// Сжатые данные отдаются как есть, без индекса
+			w = serveTestDoc(t, fs, doc, http.MethodGet, map[string]string{"Accept-Encoding": tt.encoding})
+			checkRepresentation(t, w, tt.encoding, encodedETag)
+			c, _ := codecFor(tt.encoding)
+			sr, err := c.stream(bytes.NewReader(w.Body.Bytes()))
+			if err != nil {
+				t.Fatalf("stream: %v", err)
+			}
+			if got, err := io.ReadAll(sr); err != nil || !bytes.Equal(got, data) {
+				t.Errorf("encoded body: %v, data matches: %t", err, bytes.Equal(got, data))
+			}
+			sr.Close()
+			encoded := w.Body.Bytes()
+
+			// Range сжатого представления считается по сжатым байтам
+			w = serveTestDoc(t, fs, doc, http.MethodGet, map[string]string{
+				"Accept-Encoding": tt.encoding, "Range": "bytes=10-109"})
+			if w.Code != http.StatusPartialContent || !bytes.Equal(w.Body.Bytes(), encoded[10:110]) {
+				t.Errorf("encoded range = %d, body matches: %t", w.Code, bytes.Equal(w.Body.Bytes(), encoded[10:110]))
+			}
+			if got, want := w.Header().Get("Content-Range"), fmt.Sprintf("bytes 10-109/%d", len(encoded)); got != want {
+				t.Errorf("encoded Content-Range = %q, want %q", got, want)
+			}
+			checkRepresentation(t, w, tt.encoding, encodedETag)
+
+			w = serveTestDoc(t, fs, doc, http.MethodHead, map[string]string{"Accept-Encoding": tt.encoding})
+			checkRepresentation(t, w, tt.encoding, encodedETag)
+
+			// Валидатор одного представления не подходит другому
+			w = serveTestDoc(t, fs, doc, http.MethodGet, map[string]string{
+				"Accept-Encoding": tt.encoding, "If-None-Match": encodedETag})
+			if w.Code != http.StatusNotModified {
+				t.Errorf("encoded If-None-Match status = %d, want 304", w.Code)
+			}
+			w = serveTestDoc(t, fs, doc, http.MethodGet, map[string]string{
+				"Accept-Encoding": tt.encoding, "If-None-Match": plainETag})
+			if w.Code != http.StatusOK {
+				t.Errorf("plain ETag on encoded response status = %d, want 200", w.Code)
+			}
+		})
+	}
+}
+
+// checkRepresentation проверяет Content-Encoding, ETag и Vary ответа.
+func checkRepresentation(t *testing.T, w *httptest.ResponseRecorder, encoding, etag string) {
+	t.Helper()
+	if got := w.Header().Get("Content-Encoding"); got != encoding {
+		t.Errorf("Content-Encoding = %q, want %q", got, encoding)
+	}
+	if got := w.Header().Get("ETag"); got != etag {
+		t.Errorf("ETag = %s, want %s", got, etag)
+	}
+	if got := w.Header().Values("Vary"); !strings.Contains(strings.Join(got, ","), "Accept-Encoding") {
+		t.Errorf("Vary = %q, want Accept-Encoding", got)
+	}
+}
+
+func TestAcceptsEncoding(t *testing.T) {
+	tests := []struct {
+		header   string
+		encoding string
+		want     bool
+	}{
+		{"", EncodingGzip, false},
+		{"gzip", EncodingGzip, true},
+		{"GZIP", EncodingGzip, true},
+		{"deflate, gzip;q=0.5", EncodingGzip, true},
+		{"gzip;q=0", EncodingGzip, false},
+		{"gzip; q=0.0", EncodingGzip, false},
+		{"br", EncodingGzip, false},
+		{"x-gzip", EncodingGzip, true},
+		{"*", EncodingGzip, true},
+		{"*", EncodingZstd, true},
+		{"*;q=0", EncodingGzip, false},
+		// Явно указанное кодирование важнее "*" в любом порядке
+		{"gzip;q=0, *", EncodingGzip, false},
+		{"*, gzip;q=0", EncodingGzip, false},
+		{"*;q=0, gzip", EncodingGzip, true},
+		{"br, *;q=0.1", EncodingZstd, true},
+		{"identity", EncodingGzip, false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Accept-Encoding", tt.header)
+		if got := acceptsEncoding(r, tt.encoding); got != tt.want {
+			t.Errorf("acceptsEncoding(%q, %s) = %t, want %t", tt.header, tt.encoding, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+func strongETag(checksum string) string {
+	return `"` + checksum + `"`
+}
+
+// sizeOnlyContent - содержимое известного размера без данных. Нужно, чтобы HEAD
+// проходил через http.ServeContent с теми же проверками условий, что и GET.
+type sizeOnlyContent struct {
+	size   int64
+	offset int64
+}
+
+func (c *sizeOnlyContent) Read(p []byte) (int, error) {
+	return 0, io.EOF
+}
+
+func (c *sizeOnlyContent) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += c.offset
+	case io.SeekEnd:
+		offset += c.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	c.offset = offset
+	return offset, nil
+}
+
+// checkConditions - упрощенная проверка условных заголовков для ответов, которые
+// отдаются без http.ServeContent. Возвращает статус и true, если тело отдавать не нужно.
+func checkConditions(r *http.Request, etag string, modTime time.Time) (int, bool) {
+	if im := r.Header.Get("If-Match"); im != "" && etag != "" && !etagListMatch(im, etag) {
+		return http.StatusPreconditionFailed, true
+	}
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" && etag != "" {
+		if etagListMatch(inm, etag) {
+			return http.StatusNotModified, true
+		}
+		return 0, false
+	}
+
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" {
+		if t, err := http.ParseTime(ims); err == nil && !modTime.Truncate(time.Second).After(t) {
+			return http.StatusNotModified, true
+		}
+	}
+	return 0, false
+}
+
+func etagListMatch(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
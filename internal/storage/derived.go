@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"path/filepath"
+
+	"github.com/google/uuid"
+	"github.com/olenka-91/DocsServer/internal/entity"
+	"github.com/olenka-91/DocsServer/internal/thumbnail"
+)
+
+// Производные файлы документа (миниатюры, оригинал до удаления метаданных) лежат рядом
+// с файлом документа. После ID вместо '_' стоит '.', поэтому ключи не пересекаются с blobKey.
+
+// IsDerivedKey - ключ производного файла документа, а не самого файла.
+func IsDerivedKey(key string) bool {
+	name := filepath.Base(key)
+	return len(name) > 36 && name[36] == '.'
+}
+
+// derivedAEAD - шифр производного файла. Ключ выводится из ключа данных документа:
+// тот же ключ с теми же nonce чанков использовать для другого содержимого нельзя.
+func derivedAEAD(dataKey []byte, purpose string) (cipher.AEAD, error) {
+	mac := hmac.New(sha256.New, dataKey)
+	mac.Write([]byte(purpose))
+	return newAEAD(mac.Sum(nil))
+}
+
+// docDerivedAEAD - шифр производного файла зашифрованного документа, nil - документ
+// не зашифрован.
+func (fs *FileStorage) docDerivedAEAD(doc *entity.Document, purpose string) (cipher.AEAD, error) {
+	if doc.KeyID == "" {
+		return nil, nil
+	}
+	if fs.keyring == nil {
+		return nil, ErrKeyNotFound
+	}
+	dataKey, err := fs.keyring.unwrap(doc.KeyID, doc.WrappedKey, doc.ID)
+	if err != nil {
+		return nil, err
+	}
+	return derivedAEAD(dataKey, purpose)
+}
+
+// removeDerived удаляет все производные файлы документа. Вызывается под блокировкой файла.
+func (fs *FileStorage) removeDerived(id uuid.UUID) error {
+	keys := []string{originalKey(id)}
+	for _, size := range thumbnail.Sizes {
+		keys = append(keys, thumbnailKey(id, size))
+	}
+	for _, key := range keys {
+		if err := fs.backend.Remove(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"hash"
+	"net/textproto"
+	"strings"
+)
+
+var (
+	ErrInvalidDigest  = errors.New("invalid content digest")
+	ErrDigestMismatch = errors.New("content digest mismatch")
+)
+
+// Digest - ожидаемая контрольная сумма файла, переданная клиентом.
+type Digest struct {
+	Algorithm string // sha-256 | sha-512 | md5
+	Value     []byte
+}
+
+func (d Digest) newHash() hash.Hash {
+	switch d.Algorithm {
+	case "sha-256":
+		return sha256.New()
+	case "sha-512":
+		return sha512.New()
+	case "md5":
+		return md5.New()
+	}
+	return nil
+}
+
+// ParseDigests читает Content-Digest (RFC 9530, sha-256 и sha-512) и Content-MD5 из
+// заголовков части multipart. Неизвестные алгоритмы пропускаются.
+func ParseDigests(h textproto.MIMEHeader) ([]Digest, error) {
+	var digests []Digest
+
+	if header := h.Get("Content-Digest"); header != "" {
+		for _, item := range strings.Split(header, ",") {
+			alg, value, ok := strings.Cut(strings.TrimSpace(item), "=")
+			if !ok {
+				return nil, ErrInvalidDigest
+			}
+			alg = strings.ToLower(strings.TrimSpace(alg))
+			if alg != "sha-256" && alg != "sha-512" {
+				continue
+			}
+			value = strings.TrimSpace(value)
+			if len(value) < 2 || value[0] != ':' || value[len(value)-1] != ':' {
+				return nil, ErrInvalidDigest
+			}
+			raw, err := base64.StdEncoding.DecodeString(value[1 : len(value)-1])
+			if err != nil {
+				return nil, ErrInvalidDigest
+			}
+			digests = append(digests, Digest{Algorithm: alg, Value: raw})
+		}
+	}
+
+	if header := h.Get("Content-MD5"); header != "" {
+		raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(header))
+		if err != nil || len(raw) != md5.Size {
+			return nil, ErrInvalidDigest
+		}
+		digests = append(digests, Digest{Algorithm: "md5", Value: raw})
+	}
+
+	return digests, nil
+}
+
+// digestVerifier считает все ожидаемые суммы за один проход по данным.
+type digestVerifier struct {
+	expected []Digest
+	hashes   []hash.Hash
+}
+
+func newDigestVerifier(expected []Digest) *digestVerifier {
+	v := &digestVerifier{expected: expected}
+	for _, d := range expected {
+		v.hashes = append(v.hashes, d.newHash())
+	}
+	return v
+}
+
+func (v *digestVerifier) Write(p []byte) (int, error) {
+	for _, h := range v.hashes {
+		h.Write(p)
+	}
+	return len(p), nil
+}
+
+func (v *digestVerifier) verify() error {
+	for i, d := range v.expected {
+		if !bytes.Equal(v.hashes[i].Sum(nil), d.Value) {
+			return ErrDigestMismatch
+		}
+	}
+	return nil
+}
@@ -0,0 +1,380 @@
+package storage
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultDiskCacheDir      = "./cache"
+	defaultDiskCacheMaxBytes = 1024 * 1024 * 1024 // 1GB
+	defaultDiskCacheFileSize = 256 * 1024 * 1024  // 256MB
+	diskCacheIndexFile       = "index.json"
+	diskCacheFlushInterval   = 5 * time.Second
+)
+
+type DiskCacheConfig struct {
+	Dir         string
+	MaxBytes    int64
+	MaxFileSize int64
+}
+
+func (c DiskCacheConfig) withDefaults() DiskCacheConfig {
+	if c.Dir == "" {
+		c.Dir = defaultDiskCacheDir
+	}
+	if c.MaxBytes <= 0 {
+		c.MaxBytes = defaultDiskCacheMaxBytes
+	}
+	if c.MaxFileSize <= 0 {
+		c.MaxFileSize = defaultDiskCacheFileSize
+	}
+	return c
+}
+
+type DiskCacheStats struct {
+	Hits           uint64 `json:"hits"`
+	Misses         uint64 `json:"misses"`
+	Evictions      uint64 `json:"evictions"`
+	ChecksumErrors uint64 `json:"checksum_errors"`
+	Entries        int    `json:"entries"`
+	Bytes          int64  `json:"bytes"`
+	MaxBytes       int64  `json:"max_bytes"`
+	MaxFileSize    int64  `json:"max_file_size"`
+}
+
+type diskEntry struct {
+	ID       uuid.UUID `json:"id"`
+	Size     int64     `json:"size"`
+	Checksum string    `json:"sha256"`
+	verified bool
+}
+
+// DiskCache - LRU кеш файлов на локальном диске между кешем в памяти и удаленным backend.
+// Индекс хранится в index.json и перезаписывается атомарно (tmp + rename), поэтому после
+// падения кеш восстанавливается: файлы кеша вне индекса удаляются, а записи индекса
+// проверяются по размеру и SHA-256 при первом чтении. Чужие файлы в каталоге не трогаются.
+type DiskCache struct {
+	cfg DiskCacheConfig
+
+	mu        sync.Mutex
+	items     map[uuid.UUID]*list.Element
+	lru       *list.List // в начале - недавно использованные
+	totalSize int64
+	dirty     bool
+
+	hits           atomic.Uint64
+	misses         atomic.Uint64
+	evictions      atomic.Uint64
+	checksumErrors atomic.Uint64
+}
+
+func NewDiskCache(cfg DiskCacheConfig) (*DiskCache, error) {
+	cfg = cfg.withDefaults()
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create disk cache directory: %w", err)
+	}
+
+	dc := &DiskCache{
+		cfg:   cfg,
+		items: make(map[uuid.UUID]*list.Element),
+		lru:   list.New(),
+	}
+	if err := dc.load(); err != nil {
+		return nil, err
+	}
+	return dc, nil
+}
+
+func (dc *DiskCache) Accepts(size int64) bool {
+	return size <= dc.cfg.MaxFileSize && size <= dc.cfg.MaxBytes
+}
+
+// Open возвращает закешированный файл. Файл, загруженный из индекса после рестарта,
+// при первом обращении сверяется с контрольной суммой.
+func (dc *DiskCache) Open(id uuid.UUID) (*os.File, bool) {
+	dc.mu.Lock()
+	el, ok := dc.items[id]
+	if !ok {
+		dc.mu.Unlock()
+		dc.misses.Add(1)
+		return nil, false
+	}
+	entry := el.Value.(*diskEntry)
+	dc.lru.MoveToFront(el)
+	dc.dirty = true
+	needVerify := !entry.verified
+	dc.mu.Unlock()
+
+	file, err := os.Open(dc.filePath(id))
+	if err != nil {
+		dc.Remove(id)
+		dc.misses.Add(1)
+		return nil, false
+	}
+
+	if needVerify {
+		if err := verifyChecksum(file, entry.Checksum); err != nil {
+			logrus.Warnf("Disk cache entry %s is corrupted: %v", id, err)
+			file.Close()
+			dc.checksumErrors.Add(1)
+			dc.Remove(id)
+			dc.misses.Add(1)
+			return nil, false
+		}
+		dc.mu.Lock()
+		entry.verified = true
+		dc.mu.Unlock()
+	}
+
+	dc.hits.Add(1)
+	return file, true
+}
+
+// Fill копирует r в кеш и возвращает открытый закешированный файл.
+func (dc *DiskCache) Fill(id uuid.UUID, r io.Reader, expectedSize int64) (*os.File, error) {
+	filePath := dc.filePath(id)
+	tmp, err := os.CreateTemp(dc.cfg.Dir, id.String()+"-*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create disk cache file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	defer tmp.Close()
+
+	hasher := sha256.New()
+	size, err := io.Copy(tmp, io.TeeReader(r, hasher))
+	if err == nil && expectedSize >= 0 && size != expectedSize {
+		err = fmt.Errorf("size mismatch: expected %d, got %d", expectedSize, size)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return nil, fmt.Errorf("failed to fill disk cache: %w", err)
+	}
+	tmp.Close()
+
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		os.Remove(tmpPath)
+		return nil, fmt.Errorf("failed to fill disk cache: %w", err)
+	}
+
+	dc.mu.Lock()
+	if el, ok := dc.items[id]; ok {
+		dc.removeElementLocked(el, false)
+	}
+	for dc.lru.Len() > 0 && dc.totalSize+size > dc.cfg.MaxBytes {
+		dc.removeElementLocked(dc.lru.Back(), true)
+		dc.evictions.Add(1)
+	}
+	dc.items[id] = dc.lru.PushFront(&diskEntry{
+		ID:       id,
+		Size:     size,
+		Checksum: hex.EncodeToString(hasher.Sum(nil)),
+		verified: true,
+	})
+	dc.totalSize += size
+	dc.dirty = true
+	dc.mu.Unlock()
+
+	return os.Open(filePath)
+}
+
+func (dc *DiskCache) Remove(id uuid.UUID) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	if el, ok := dc.items[id]; ok {
+		dc.removeElementLocked(el, true)
+		dc.dirty = true
+	}
+}
+
+func (dc *DiskCache) Stats() DiskCacheStats {
+	dc.mu.Lock()
+	entries, bytes := dc.lru.Len(), dc.totalSize
+	dc.mu.Unlock()
+
+	return DiskCacheStats{
+		Hits:           dc.hits.Load(),
+		Misses:         dc.misses.Load(),
+		Evictions:      dc.evictions.Load(),
+		ChecksumErrors: dc.checksumErrors.Load(),
+		Entries:        entries,
+		Bytes:          bytes,
+		MaxBytes:       dc.cfg.MaxBytes,
+		MaxFileSize:    dc.cfg.MaxFileSize,
+	}
+}
+
+// RunFlusher периодически сохраняет индекс на диск и сохраняет его при остановке.
+func (dc *DiskCache) RunFlusher(ctx context.Context) {
+	ticker := time.NewTicker(diskCacheFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := dc.flush(); err != nil {
+				logrus.Errorf("Failed to save disk cache index: %v", err)
+			}
+			return
+		case <-ticker.C:
+			if err := dc.flush(); err != nil {
+				logrus.Errorf("Failed to save disk cache index: %v", err)
+			}
+		}
+	}
+}
+
+func (dc *DiskCache) flush() error {
+	dc.mu.Lock()
+	if !dc.dirty {
+		dc.mu.Unlock()
+		return nil
+	}
+	entries := make([]diskEntry, 0, dc.lru.Len())
+	for el := dc.lru.Front(); el != nil; el = el.Next() {
+		entries = append(entries, *el.Value.(*diskEntry))
+	}
+	dc.dirty = false
+	dc.mu.Unlock()
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+
+	indexPath := filepath.Join(dc.cfg.Dir, diskCacheIndexFile)
+	tmpPath := indexPath + ".tmp"
+	if err := writeFileSync(tmpPath, data); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, indexPath)
+}
+
+func (dc *DiskCache) load() error {
+	indexed := make(map[uuid.UUID]bool)
+
+	data, err := os.ReadFile(filepath.Join(dc.cfg.Dir, diskCacheIndexFile))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read disk cache index: %w", err)
+	}
+
+	var entries []diskEntry
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &entries); err != nil {
+			// Испорченный индекс - начинаем с пустого кеша
+			logrus.Warnf("Disk cache index is corrupted, resetting: %v", err)
+			entries = nil
+		}
+	}
+
+	// Индекс упорядочен от недавно использованных к давним
+	for i := range entries {
+		entry := entries[i]
+		info, err := os.Stat(dc.filePath(entry.ID))
+		if err != nil || info.Size() != entry.Size || dc.totalSize+entry.Size > dc.cfg.MaxBytes {
+			continue
+		}
+		dc.items[entry.ID] = dc.lru.PushBack(&entry)
+		dc.totalSize += entry.Size
+		indexed[entry.ID] = true
+	}
+
+	// Удаляем файлы кеша, которых нет в индексе: незавершенные записи и вытесненные до падения
+	dirEntries, err := os.ReadDir(dc.cfg.Dir)
+	if err != nil {
+		return fmt.Errorf("failed to read disk cache directory: %w", err)
+	}
+	for _, de := range dirEntries {
+		name := de.Name()
+		if !de.Type().IsRegular() {
+			continue
+		}
+		id, temp, ok := diskCacheFile(name)
+		if !ok || !temp && indexed[id] {
+			continue
+		}
+		os.Remove(filepath.Join(dc.cfg.Dir, name))
+	}
+
+	dc.dirty = true
+	logrus.Debugf("Disk cache loaded: %d entries, %d bytes", dc.lru.Len(), dc.totalSize)
+	return nil
+}
+
+func (dc *DiskCache) removeElementLocked(el *list.Element, removeFile bool) {
+	entry := dc.lru.Remove(el).(*diskEntry)
+	delete(dc.items, entry.ID)
+	dc.totalSize -= entry.Size
+	if removeFile {
+		// Уже открытые дескрипторы продолжают читать удаленный файл
+		os.Remove(dc.filePath(entry.ID))
+	}
+}
+
+// diskCacheFile сообщает, создан ли файл name кешем: "<id>" - запись кеша, "<id>-*.tmp"
+// и "index.json.tmp" - незавершенная запись. Для записи возвращает ее id.
+func diskCacheFile(name string) (id uuid.UUID, temp, ok bool) {
+	if name == diskCacheIndexFile+".tmp" {
+		return uuid.Nil, true, true
+	}
+	if tmp, isTemp := strings.CutSuffix(name, ".tmp"); isTemp {
+		if len(tmp) <= 37 || tmp[36] != '-' {
+			return uuid.Nil, false, false
+		}
+		name, temp = tmp[:36], true
+	}
+	id, err := uuid.Parse(name)
+	if err != nil || name != id.String() {
+		return uuid.Nil, false, false
+	}
+	return id, temp, true
+}
+
+func (dc *DiskCache) filePath(id uuid.UUID) string {
+	return filepath.Join(dc.cfg.Dir, id.String())
+}
+
+func verifyChecksum(file *os.File, expected string) error {
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if actual := hex.EncodeToString(hasher.Sum(nil)); actual != expected {
+		return fmt.Errorf("checksum mismatch: expected %s, got %s", expected, actual)
+	}
+	return nil
+}
+
+func writeFileSync(path string, data []byte) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := file.Write(data); err != nil {
+		return err
+	}
+	return file.Sync()
+}
@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func fillDiskCache(t *testing.T, dc *DiskCache, id uuid.UUID, data string) {
+	t.Helper()
+	f, err := dc.Fill(id, strings.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("fill %s: %v", id, err)
+	}
+	f.Close()
+}
+
+func readDiskCache(t *testing.T, dc *DiskCache, id uuid.UUID) (string, bool) {
+	t.Helper()
+	f, ok := dc.Open(id)
+	if !ok {
+		return "", false
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatalf("read %s: %v", id, err)
+	}
+	return string(data), true
+}
+
+func TestDiskCacheEvictsByBytes(t *testing.T) {
+	dir := t.TempDir()
+	dc, err := NewDiskCache(DiskCacheConfig{Dir: dir, MaxBytes: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b, c := uuid.New(), uuid.New(), uuid.New()
+
+	fillDiskCache(t, dc, a, "aaaa")
+	fillDiskCache(t, dc, b, "bbbb")
+	// a использован недавно, поэтому вытесняется b
+	if _, ok := readDiskCache(t, dc, a); !ok {
+		t.Fatal("a is missing")
+	}
+	fillDiskCache(t, dc, c, "cccc")
+
+	if _, ok := readDiskCache(t, dc, b); ok {
+		t.Error("b was not evicted")
+	}
+	if _, err := os.Stat(filepath.Join(dir, b.String())); !os.IsNotExist(err) {
+		t.Errorf("file of evicted entry is left: %v", err)
+	}
+	if st := dc.Stats(); st.Entries != 2 || st.Bytes != 8 || st.Evictions != 1 {
+		t.Errorf("stats = %+v, want 2 entries, 8 bytes and 1 eviction", st)
+	}
+	if dc.Accepts(11) {
+		t.Error("Accepts a file larger than the cache")
+	}
+}
+
+func TestDiskCacheReload(t *testing.T) {
+	dir := t.TempDir()
+	dc, err := NewDiskCache(DiskCacheConfig{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept, corrupted, unindexed := uuid.New(), uuid.New(), uuid.New()
+	fillDiskCache(t, dc, kept, "kept")
+	fillDiskCache(t, dc, corrupted, "data")
+	if err := dc.flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+
+	// Файл той же длины, но с другим содержимым, и файлы, которых нет в индексе
+	write := func(name, data string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(corrupted.String(), "DATA")
+	write(unindexed.String(), "lost")
+	write(kept.String()+"-123.tmp", "partial")
+	write(diskCacheIndexFile+".tmp", "[]")
+	foreign := []string{"notes.txt", strings.ToUpper(uuid.NewString()), "backup.tmp"}
+	for _, name := range foreign {
+		write(name, "not a cache file")
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	dc, err = NewDiskCache(DiskCacheConfig{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, ok := readDiskCache(t, dc, kept); !ok || data != "kept" {
+		t.Errorf("kept = %q, %v; want %q", data, ok, "kept")
+	}
+	if _, ok := readDiskCache(t, dc, corrupted); ok {
+		t.Error("corrupted entry was returned")
+	}
+	if st := dc.Stats(); st.ChecksumErrors != 1 || st.Entries != 1 {
+		t.Errorf("stats = %+v, want 1 checksum error and 1 entry", st)
+	}
+
+	for _, name := range []string{unindexed.String(), kept.String() + "-123.tmp", diskCacheIndexFile + ".tmp"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("stale cache file %s is left: %v", name, err)
+		}
+	}
+	for _, name := range append(foreign, "sub") {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("foreign file %s was removed: %v", name, err)
+		}
+	}
+}
+
+func TestDiskCacheFile(t *testing.T) {
+	id := uuid.New()
+	tests := []struct {
+		name     string
+		temp, ok bool
+	}{
+		{id.String(), false, true},
+		{id.String() + "-4021.tmp", true, true},
+		{diskCacheIndexFile + ".tmp", true, true},
+		{diskCacheIndexFile, false, false},
+		{strings.ToUpper(id.String()), false, false},
+		{"{" + id.String() + "}", false, false},
+		{id.String() + ".tmp", false, false},
+		{"report.pdf", false, false},
+	}
+	for _, tt := range tests {
+		gotID, temp, ok := diskCacheFile(tt.name)
+		if temp != tt.temp || ok != tt.ok {
+			t.Errorf("diskCacheFile(%q) = %v, %v; want %v, %v", tt.name, temp, ok, tt.temp, tt.ok)
+		}
+		if ok && tt.name != diskCacheIndexFile+".tmp" && gotID != id {
+			t.Errorf("diskCacheFile(%q) id = %s, want %s", tt.name, gotID, id)
+		}
+	}
+}
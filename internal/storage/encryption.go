@@ -0,0 +1,243 @@
+package storage
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// Формат зашифрованного файла:
+//
+//	"DSE1" | uint32 размер блока | блок 0 | блок 1 | ... | последний блок
+//
+// Каждый блок - AES-GCM(открытый текст размером до chunkSize) с тегом 16 байт.
+// Nonce = номер блока (8 байт) | 3 нулевых байта | признак последнего блока, поэтому
+// перестановка и обрезка блоков обнаруживаются. AAD - ID документа. Ключ данных
+// уникален для документа, так что nonce не повторяются.
+// Блоки расшифровываются независимо - это позволяет отдавать Range запросы.
+const (
+	encMagic      = "DSE1"
+	encHeaderSize = 8
+	encChunkSize  = 64 * 1024
+	encTagSize    = 16
+)
+
+var ErrCorrupted = errors.New("encrypted file is corrupted")
+
+func chunkNonce(idx uint64, last bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce, idx)
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+// encryptedPlainSize - размер открытого текста по размеру зашифрованного файла.
+func encryptedPlainSize(blobSize int64, chunkSize int64) (size int64, chunks int64, err error) {
+	body := blobSize - encHeaderSize
+	if body < encTagSize {
+		return 0, 0, ErrCorrupted
+	}
+	full, rem := body/(chunkSize+encTagSize), body%(chunkSize+encTagSize)
+	if rem == 0 {
+		return full * chunkSize, full, nil
+	}
+	if rem < encTagSize {
+		return 0, 0, ErrCorrupted
+	}
+	return full*chunkSize + rem - encTagSize, full + 1, nil
+}
+
+// encryptReader шифрует поток по мере чтения.
+type encryptReader struct {
+	aead  cipher.AEAD
+	src   *bufio.Reader
+	aad   []byte
+	idx   uint64
+	plain []byte
+	out   []byte
+	done  bool
+}
+
+func newEncryptReader(src io.Reader, aead cipher.AEAD, aad []byte) *encryptReader {
+	header := make([]byte, encHeaderSize, encHeaderSize+encChunkSize+encTagSize)
+	copy(header, encMagic)
+	binary.BigEndian.PutUint32(header[4:], encChunkSize)
+
+	return &encryptReader{
+		aead:  aead,
+		src:   bufio.NewReaderSize(src, encChunkSize),
+		aad:   aad,
+		plain: make([]byte, encChunkSize),
+		out:   header,
+	}
+}
+
+func (e *encryptReader) Read(p []byte) (int, error) {
+	if len(e.out) == 0 {
+		if e.done {
+			return 0, io.EOF
+		}
+		if err := e.sealNext(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, e.out)
+	e.out = e.out[n:]
+	return n, nil
+}
+
+func (e *encryptReader) sealNext() error {
+	n, err := io.ReadFull(e.src, e.plain)
+	last := false
+	switch {
+	case err == io.EOF || err == io.ErrUnexpectedEOF:
+		last = true
+	case err != nil:
+		return err
+	default:
+		// Полный блок - последний, только если за ним ничего нет
+		if _, err := e.src.Peek(1); err == io.EOF {
+			last = true
+		} else if err != nil {
+			return err
+		}
+	}
+
+	e.out = e.aead.Seal(e.out[:0], chunkNonce(e.idx, last), e.plain[:n], e.aad)
+	e.idx++
+	e.done = last
+	return nil
+}
+
+// decryptReader расшифровывает файл поблочно. Seek поддерживается, если источник
+// поддерживает Seek; без него возможно только последовательное чтение.
+type decryptReader struct {
+	src       io.Reader
+	aead      cipher.AEAD
+	aad       []byte
+	blobSize  int64
+	chunkSize int64
+	size      int64
+	chunks    int64
+
+	pos      int64
+	srcPos   int64
+	chunkIdx int64
+	chunk    []byte
+	sealed   []byte
+}
+
+// newDecryptReader читает заголовок из src, который должен стоять в начале файла.
+func newDecryptReader(src io.Reader, blobSize int64, aead cipher.AEAD, aad []byte) (*decryptReader, error) {
+	header := make([]byte, encHeaderSize)
+	if _, err := io.ReadFull(src, header); err != nil {
+		return nil, ErrCorrupted
+	}
+	if string(header[:4]) != encMagic {
+		return nil, ErrCorrupted
+	}
+	chunkSize := int64(binary.BigEndian.Uint32(header[4:]))
+	if chunkSize == 0 {
+		return nil, ErrCorrupted
+	}
+
+	size, chunks, err := encryptedPlainSize(blobSize, chunkSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return &decryptReader{
+		src:       src,
+		aead:      aead,
+		aad:       aad,
+		blobSize:  blobSize,
+		chunkSize: chunkSize,
+		size:      size,
+		chunks:    chunks,
+		srcPos:    encHeaderSize,
+		chunkIdx:  -1,
+		sealed:    make([]byte, chunkSize+encTagSize),
+	}, nil
+}
+
+func (d *decryptReader) Size() int64 {
+	return d.size
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	if d.pos >= d.size {
+		return 0, io.EOF
+	}
+
+	idx := d.pos / d.chunkSize
+	if idx != d.chunkIdx {
+		if err := d.loadChunk(idx); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, d.chunk[d.pos-idx*d.chunkSize:])
+	d.pos += int64(n)
+	return n, nil
+}
+
+func (d *decryptReader) loadChunk(idx int64) error {
+	offset := encHeaderSize + idx*(d.chunkSize+encTagSize)
+	if offset != d.srcPos {
+		seeker, ok := d.src.(io.Seeker)
+		if !ok {
+			return errNotSeekable
+		}
+		if _, err := seeker.Seek(offset, io.SeekStart); err != nil {
+			return err
+		}
+		d.srcPos = offset
+	}
+
+	length := d.chunkSize + encTagSize
+	if rest := d.blobSize - offset; rest < length {
+		length = rest
+	}
+	sealed := d.sealed[:length]
+	if _, err := io.ReadFull(d.src, sealed); err != nil {
+		return err
+	}
+	d.srcPos += length
+
+	chunk, err := d.aead.Open(d.chunk[:0], chunkNonce(uint64(idx), idx == d.chunks-1), sealed, d.aad)
+	if err != nil {
+		d.chunkIdx = -1
+		return ErrCorrupted
+	}
+	d.chunk = chunk
+	d.chunkIdx = idx
+	return nil
+}
+
+func (d *decryptReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += d.pos
+	case io.SeekEnd:
+		offset += d.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	d.pos = offset
+	return offset, nil
+}
+
+func (d *decryptReader) Close() error {
+	if c, ok := d.src.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,230 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func testAEAD(t *testing.T) cipher.AEAD {
+	t.Helper()
+	key := make([]byte, dataKeySize)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return aead
+}
+
+func testData(size int) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	return data
+}
+
+func encryptBlob(t *testing.T, aead cipher.AEAD, aad, data []byte) []byte {
+	t.Helper()
+	blob, err := io.ReadAll(newEncryptReader(bytes.NewReader(data), aead, aad))
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	return blob
+}
+
+func decryptBlob(aead cipher.AEAD, aad, blob []byte) ([]byte, error) {
+	dr, err := newDecryptReader(bytes.NewReader(blob), int64(len(blob)), aead, aad)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(dr)
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	aead := testAEAD(t)
+	aad := uuid.New()
+
+	sizes := []int{0, 1, encChunkSize - 1, encChunkSize, encChunkSize + 1, 3*encChunkSize + 17}
+	for _, size := range sizes {
+		t.Run(fmt.Sprint(size), func(t *testing.T) {
+			data := testData(size)
+			blob := encryptBlob(t, aead, aad[:], data)
+
+			plainSize, chunks, err := encryptedPlainSize(int64(len(blob)), encChunkSize)
+			if err != nil || plainSize != int64(size) {
+				t.Fatalf("encryptedPlainSize = %d, %v; want %d", plainSize, err, size)
+			}
+			if want := max(int64(size+encChunkSize-1)/encChunkSize, 1); chunks != want {
+				t.Errorf("chunks = %d, want %d", chunks, want)
+			}
+
+			got, err := decryptBlob(aead, aad[:], blob)
+			if err != nil {
+				t.Fatalf("decrypt: %v", err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Error("decrypted data differs")
+			}
+		})
+	}
+}
+
+func TestDecryptDetectsTampering(t *testing.T) {
+	aead := testAEAD(t)
+	aad := uuid.New()
+	blob := encryptBlob(t, aead, aad[:], testData(2*encChunkSize+100))
+	sealedChunk := encChunkSize + encTagSize
+	other := uuid.New()
+
+	modify := func(fn func(b []byte) []byte) []byte {
+		return fn(bytes.Clone(blob))
+	}
+	tests := []struct {
+		name string
+		blob []byte
+		aad  []byte
+	}{
+		{"flipped byte in header", modify(func(b []byte) []byte { b[0] ^= 1; return b }), aad[:]},
+		{"flipped byte in chunk", modify(func(b []byte) []byte { b[encHeaderSize+sealedChunk+10] ^= 1; return b }), aad[:]},
+		{"flipped byte in tag", modify(func(b []byte) []byte { b[len(b)-1] ^= 1; return b }), aad[:]},
+		{"last chunk dropped", blob[:encHeaderSize+2*sealedChunk], aad[:]},
+		{"truncated inside chunk", blob[:len(blob)-10], aad[:]},
+		{"truncated to header", blob[:encHeaderSize], aad[:]},
+		{"chunks reordered", modify(func(b []byte) []byte {
+			first := bytes.Clone(b[encHeaderSize : encHeaderSize+sealedChunk])
+			copy(b[encHeaderSize:], b[encHeaderSize+sealedChunk:encHeaderSize+2*sealedChunk])
+			copy(b[encHeaderSize+sealedChunk:], first)
+			return b
+		}), aad[:]},
+		{"wrong document id", blob, other[:]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decryptBlob(aead, tt.aad, tt.blob); !errors.Is(err, ErrCorrupted) {
+				t.Errorf("decrypt error = %v, want ErrCorrupted", err)
+			}
+		})
+	}
+}
+
+func TestDecryptSeek(t *testing.T) {
+	aead := testAEAD(t)
+	aad := uuid.New()
+	data := testData(3*encChunkSize + 17)
+	blob := encryptBlob(t, aead, aad[:], data)
+
+	tests := []struct {
+		offset, length int64
+	}{
+		{0, 10},
+		{encChunkSize - 5, 10},
+		{2 * encChunkSize, encChunkSize},
+		{3*encChunkSize + 10, 7},
+		{100, 2*encChunkSize + 50},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d+%d", tt.offset, tt.length), func(t *testing.T) {
+			dr, err := newDecryptReader(bytes.NewReader(blob), int64(len(blob)), aead, aad[:])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := dr.Seek(tt.offset, io.SeekStart); err != nil {
+				t.Fatal(err)
+			}
+			got := make([]byte, tt.length)
+			if _, err := io.ReadFull(dr, got); err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			if !bytes.Equal(got, data[tt.offset:tt.offset+tt.length]) {
+				t.Error("data after Seek differs")
+			}
+		})
+	}
+}
+
+func TestKeyringRewrap(t *testing.T) {
+	k := &Keyring{keys: make(map[string]cipher.AEAD)}
+	if _, err := k.add(testMasterKey(t, "old")); err != nil {
+		t.Fatal(err)
+	}
+	k.active = "old"
+	docID := uuid.New()
+
+	dataKey, keyID, wrapped, err := k.newDataKey(docID)
+	if err != nil || keyID != "old" {
+		t.Fatalf("newDataKey = %q, %v", keyID, err)
+	}
+
+	// Ротация: новый активный ключ, старый остается для чтения
+	if _, err := k.add(testMasterKey(t, "new")); err != nil {
+		t.Fatal(err)
+	}
+	k.active = "new"
+	newID, rewrapped, err := k.Rewrap(keyID, wrapped, docID)
+	if err != nil || newID != "new" {
+		t.Fatalf("Rewrap = %q, %v", newID, err)
+	}
+	got, err := k.unwrap(newID, rewrapped, docID)
+	if err != nil || !bytes.Equal(got, dataKey) {
+		t.Fatalf("unwrap after Rewrap = %v, data key changed: %t", err, !bytes.Equal(got, dataKey))
+	}
+
+	if _, err := k.unwrap("old", rewrapped, docID); !errors.Is(err, ErrCorrupted) {
+		t.Errorf("unwrap with old key error = %v, want ErrCorrupted", err)
+	}
+	if _, err := k.unwrap(newID, rewrapped, uuid.New()); !errors.Is(err, ErrCorrupted) {
+		t.Errorf("unwrap with other doc ID error = %v, want ErrCorrupted", err)
+	}
+	if _, _, err := k.Rewrap("missing", wrapped, docID); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Rewrap with unknown key error = %v, want ErrKeyNotFound", err)
+	}
+}
+
+func TestServeEncryptedRange(t *testing.T) {
+	keyring, err := LoadKeyring(testMasterKey(t, "k1"), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs := newTestStorage(t, keyring, CompressionConfig{})
+	data := testData(2*encChunkSize + 300)
+	doc := saveTestDoc(t, fs, "a.bin", "application/octet-stream", data)
+	if doc.KeyID != "k1" {
+		t.Fatalf("KeyID = %q, want k1", doc.KeyID)
+	}
+
+	// Диапазон через границу блоков и хвост файла
+	start, end := int64(encChunkSize-10), int64(2*encChunkSize+20)
+	w := serveTestDoc(t, fs, doc, http.MethodGet, map[string]string{"Range": fmt.Sprintf("bytes=%d-%d", start, end)})
+	if w.Code != http.StatusPartialContent {
+		t.Fatalf("status = %d, want 206", w.Code)
+	}
+	if !bytes.Equal(w.Body.Bytes(), data[start:end+1]) {
+		t.Error("range body differs")
+	}
+	want := fmt.Sprintf("bytes %d-%d/%d", start, end, len(data))
+	if got := w.Header().Get("Content-Range"); got != want {
+		t.Errorf("Content-Range = %q, want %q", got, want)
+	}
+
+	w = serveTestDoc(t, fs, doc, http.MethodGet, map[string]string{"Range": "bytes=-100"})
+	if w.Code != http.StatusPartialContent || !bytes.Equal(w.Body.Bytes(), data[len(data)-100:]) {
+		t.Errorf("suffix range = %d, body matches: %t", w.Code, bytes.Equal(w.Body.Bytes(), data[len(data)-100:]))
+	}
+
+	w = serveTestDoc(t, fs, doc, http.MethodHead, nil)
+	if got := w.Header().Get("Content-Length"); got != fmt.Sprint(len(data)) {
+		t.Errorf("HEAD Content-Length = %s, want %d", got, len(data))
+	}
+}
@@ -2,14 +2,17 @@ package storage
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
-	"mime"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -19,126 +22,413 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-const (
-	cacheTTL             = 5 * time.Minute
-	maxMemoryCacheSize   = 100 * 1024 * 1024 // 100MB
-	maxMemoryCachedFiles = 100
+var (
+	ErrQuarantined = errors.New("file is quarantined by malware scan")
+	ErrNotScanned  = errors.New("file has not passed malware scan yet")
 )
 
-type Cache struct {
-	memoryCache *MemoryCache
+type StorageConfig struct {
+	BasePath  string
+	Remote    bool
+	Cache     CacheConfig
+	DiskCache DiskCacheConfig
+	// Keyring - мастер-ключи шифрования; nil - файлы хранятся открытыми
+	Keyring     *Keyring
+	Compression CompressionConfig
 }
 
-type MemoryCache struct {
-	sync.RWMutex
-	files      map[uuid.UUID]*CachedFile
-	totalSize  int64
-	maxSize    int64
-	maxEntries int
+type Cache struct {
+	memoryCache *MemoryCache
+	diskCache   *DiskCache // только для удаленного backend
 }
 
-type CachedFile struct {
-	data    []byte
-	size    int64
-	mime    string
-	etag    string
-	created time.Time
+type CacheStatsResponse struct {
+	Memory CacheStats      `json:"memory"`
+	Disk   *DiskCacheStats `json:"disk,omitempty"`
 }
 
 type FileStorage struct {
-	basePath  string
+	backend   Backend
 	cache     *Cache
 	fileLocks *sync.Map
+	// Одновременные промахи кеша по одному файлу выполняют одну загрузку
+	memoryLoads *flightGroup
+	diskLoads   *flightGroup
+	keyring     *Keyring
+	compression CompressionConfig
 }
 
-func NewFileStorage(basePath string) *FileStorage {
-	if err := os.MkdirAll(basePath, 0755); err != nil {
-		logrus.Fatalf("Failed to create storage directory: %v", err)
+func NewFileStorage(cfg StorageConfig) *FileStorage {
+	backend, err := NewLocalBackend(cfg.BasePath, cfg.Remote)
+	if err != nil {
+		logrus.Fatalf("Failed to create storage backend: %v", err)
+	}
+	if err := cfg.Compression.validate(); err != nil {
+		logrus.Fatalf("Invalid compression config: %v", err)
+	}
+
+	cache := &Cache{memoryCache: NewMemoryCache(cfg.Cache)}
+	if backend.Remote() {
+		cache.diskCache, err = NewDiskCache(cfg.DiskCache)
+		if err != nil {
+			logrus.Fatalf("Failed to create disk cache: %v", err)
+		}
 	}
 
 	return &FileStorage{
-		basePath: basePath,
-		cache: &Cache{
-			memoryCache: &MemoryCache{
-				files:      make(map[uuid.UUID]*CachedFile),
-				maxSize:    maxMemoryCacheSize,
-				maxEntries: maxMemoryCachedFiles,
-			},
-		},
-		fileLocks: &sync.Map{},
+		backend:     backend,
+		cache:       cache,
+		fileLocks:   &sync.Map{},
+		memoryLoads: newFlightGroup(),
+		diskLoads:   newFlightGroup(),
+		keyring:     cfg.Keyring,
+		compression: cfg.Compression.withDefaults(),
+	}
+}
+
+// RunCacheJanitor запускает фоновое обслуживание кешей: очистку просроченных записей
+// в памяти и сохранение индекса дискового кеша.
+func (fs *FileStorage) RunCacheJanitor(ctx context.Context) {
+	if fs.cache.diskCache != nil {
+		go fs.cache.diskCache.RunFlusher(ctx)
 	}
+	fs.cache.memoryCache.RunJanitor(ctx)
 }
 
-func (fs *FileStorage) getFileLock(id uuid.UUID) *sync.Mutex {
-	lock, _ := fs.fileLocks.LoadOrStore(id, &sync.Mutex{})
-	return lock.(*sync.Mutex)
+func (fs *FileStorage) CacheStats() CacheStatsResponse {
+	stats := CacheStatsResponse{Memory: fs.cache.memoryCache.Stats()}
+	if fs.cache.diskCache != nil {
+		disk := fs.cache.diskCache.Stats()
+		stats.Disk = &disk
+	}
+	return stats
 }
 
-func (fs *FileStorage) getFilePath(id uuid.UUID, filename string) string {
-	// Создаем поддиректории для распределения файлов
-	subDir := id.String()[0:2]
-	dirPath := filepath.Join(fs.basePath, subDir)
+// getFileLock: SaveFile и DeleteFile берут блокировку на запись, чтение - разделяемую.
+func (fs *FileStorage) getFileLock(id uuid.UUID) *sync.RWMutex {
+	lock, _ := fs.fileLocks.LoadOrStore(id, &sync.RWMutex{})
+	return lock.(*sync.RWMutex)
+}
 
-	if err := os.MkdirAll(dirPath, 0755); err != nil {
-		logrus.Errorf("Failed to create subdirectory: %v", err)
-		return filepath.Join(fs.basePath, id.String()+"_"+filename)
+// blobKey - относительный путь файла в backend. Поддиректории распределяют файлы.
+func blobKey(id uuid.UUID, filename string) string {
+	return filepath.Join(id.String()[0:2], id.String()+"_"+filename)
+}
+
+// blobID извлекает ID документа из ключа, построенного blobKey или thumbnailKey.
+func blobID(key string) uuid.UUID {
+	name := filepath.Base(key)
+	if len(name) < 37 || (name[36] != '_' && name[36] != '.') {
+		return uuid.Nil
+	}
+	id, err := uuid.Parse(name[:36])
+	if err != nil || filepath.Dir(key) != id.String()[0:2] {
+		return uuid.Nil
 	}
+	return id
+}
+
+// SavedFile - результат сохранения файла.
+type SavedFile struct {
+	Size     int64
+	Mime     string
+	Path     string
+	Checksum string // SHA-256 содержимого, используется как ETag
+	// KeyID и WrappedKey заданы, если файл зашифрован
+	KeyID      string
+	WrappedKey []byte
+	// Encoding - сжатие, в котором хранится файл (gzip, zstd), пусто - без сжатия
+	Encoding string
+	// OriginalSize - размер сохраненного оригинала до удаления метаданных, 0 - оригинала нет
+	OriginalSize int64
+}
 
-	return filepath.Join(dirPath, id.String()+"_"+filename)
+// SaveFile сохраняет файл и, если клиент передал ожидаемые суммы, сверяет их с
+// фактическими. При несовпадении файл удаляется и возвращается ErrDigestMismatch.
+// Пустой mimeType определяется по расширению filename.
+func (fs *FileStorage) SaveFile(id uuid.UUID, r io.Reader, filename, mimeType string, expected []Digest) (*SavedFile, error) {
+	return fs.SaveScrubbedFile(id, r, filename, mimeType, expected, nil, false)
 }
 
-func (fs *FileStorage) SaveFile(id uuid.UUID, r io.Reader, filename string) (int64, string, string, error) {
+// SaveScrubbedFile сохраняет файл, пропущенный через scrub (удаление метаданных; nil -
+// без изменений). Ожидаемые суммы сверяются с загруженным, а не сохраненным содержимым.
+// С keepOriginal загруженный файл сохраняется отдельно как оригинал.
+func (fs *FileStorage) SaveScrubbedFile(id uuid.UUID, r io.Reader, filename, mimeType string, expected []Digest,
+	scrub func(io.Reader) io.Reader, keepOriginal bool) (*SavedFile, error) {
 	logrus.Debugf("Saving file with ID: %+v", id)
 	lock := fs.getFileLock(id)
 	lock.Lock()
 	defer lock.Unlock()
 
-	filePath := fs.getFilePath(id, filename)
-	tmpPath := filePath + ".tmp"
+	key := blobKey(id, filename)
+
+	if mimeType == "" {
+		mimeType = MimeByName(filename)
+	}
+
+	var dataKey, wrapped []byte
+	var keyID string
+	if fs.keyring != nil {
+		var err error
+		if dataKey, keyID, wrapped, err = fs.keyring.newDataKey(id); err != nil {
+			return nil, err
+		}
+	}
+
+	verifier := newDigestVerifier(expected)
+	var src io.Reader = io.TeeReader(r, verifier)
+
+	var originalSize int64
+	if keepOriginal && scrub != nil {
+		// Оригинал записывается первым и проверяется, затем читается для очистки
+		size, err := fs.saveOriginal(id, src, dataKey)
+		if err != nil {
+			return nil, err
+		}
+		if err := verifier.verify(); err != nil {
+			logrus.Warnf("Digest mismatch for uploaded file %s", id)
+			fs.removeBlob(originalKey(id))
+			return nil, err
+		}
+		rc, err := fs.openOriginal(id, dataKey)
+		if err != nil {
+			fs.removeBlob(originalKey(id))
+			return nil, err
+		}
+		defer rc.Close()
+		src, originalSize = rc, size
+	}
+	if scrub != nil {
+		src = scrub(src)
+	}
 
-	file, err := os.Create(tmpPath)
+	saved, err := fs.writeBlob(key, src, id, mimeType, dataKey)
+	if err == nil && originalSize == 0 {
+		if err = verifier.verify(); err != nil {
+			logrus.Warnf("Digest mismatch for uploaded file %s", id)
+			fs.removeBlob(key)
+		}
+	}
 	if err != nil {
-		return 0, "", "", fmt.Errorf("failed to create file: %w", err)
+		if originalSize > 0 {
+			fs.removeBlob(originalKey(id))
+		}
+		return nil, err
 	}
-	defer file.Close()
 
+	saved.KeyID, saved.WrappedKey = keyID, wrapped
+	saved.OriginalSize = originalSize
+	return saved, nil
+}
+
+// writeBlob сжимает (если тип сжимаемый) и шифрует (если задан dataKey) содержимое
+// и сохраняет его в backend. Сумма и размер считаются по исходному содержимому.
+func (fs *FileStorage) writeBlob(key string, r io.Reader, id uuid.UUID, mimeType string, dataKey []byte) (*SavedFile, error) {
 	hasher := sha256.New()
-	tee := io.TeeReader(r, hasher)
-	size, err := io.Copy(file, tee)
+	counter := &countingWriter{}
+	var src io.Reader = io.TeeReader(r, io.MultiWriter(hasher, counter))
+
+	// Порядок слоев: сжатие, затем шифрование - зашифрованные данные не сжимаются
+	saved := &SavedFile{}
+	if fs.compression.Compressible(mimeType) {
+		c, err := codecFor(fs.compression.Encoding)
+		if err != nil {
+			return nil, err
+		}
+		src = newCompressReader(src, c)
+		saved.Encoding = fs.compression.Encoding
+	}
+	if dataKey != nil {
+		aead, err := newAEAD(dataKey)
+		if err != nil {
+			return nil, err
+		}
+		src = newEncryptReader(src, aead, id[:])
+	}
+
+	if _, err := fs.backend.Save(key, src); err != nil {
+		return nil, err
+	}
+
+	saved.Size = counter.n
+	saved.Mime = mimeType
+	saved.Path = fs.backend.Location(key)
+	saved.Checksum = hex.EncodeToString(hasher.Sum(nil))
+	return saved, nil
+}
+
+// removeBlob удаляет файл отмененной загрузки, ошибку только записывает в журнал:
+// оставшийся файл найдет сверка.
+func (fs *FileStorage) removeBlob(key string) {
+	if err := fs.backend.Remove(key); err != nil {
+		logrus.Errorf("Failed to remove rejected file %s: %v", key, err)
+	}
+}
+
+type countingWriter struct {
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
+
+// Keyring - мастер-ключи хранилища; nil, если шифрование выключено.
+func (fs *FileStorage) Keyring() *Keyring {
+	return fs.keyring
+}
+
+// decryptedReader снимает шифрование, если документ зашифрован, и возвращает размер
+// хранимых данных - для сжатого файла это сжатый поток вместе с индексом.
+func (fs *FileStorage) decryptedReader(doc *entity.Document, rc io.ReadCloser, blobSize int64) (io.ReadCloser, int64, error) {
+	if doc.KeyID == "" {
+		return rc, blobSize, nil
+	}
+	if fs.keyring == nil {
+		rc.Close()
+		return nil, 0, ErrKeyNotFound
+	}
+
+	dataKey, err := fs.keyring.unwrap(doc.KeyID, doc.WrappedKey, doc.ID)
+	if err != nil {
+		rc.Close()
+		return nil, 0, err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		rc.Close()
+		return nil, 0, err
+	}
+	dr, err := newDecryptReader(rc, blobSize, aead, doc.ID[:])
 	if err != nil {
-		os.Remove(tmpPath)
-		return 0, "", "", fmt.Errorf("failed to write file: %w", err)
+		rc.Close()
+		return nil, 0, err
 	}
+	return dr, dr.Size(), nil
+}
+
+// plainReader возвращает исходное содержимое файла и его размер: расшифровывает и
+// распаковывает. Результат поддерживает Seek, если его поддерживает rc.
+func (fs *FileStorage) plainReader(doc *entity.Document, rc io.ReadCloser, blobSize int64) (io.ReadCloser, int64, error) {
+	_, seekable := rc.(io.Seeker)
 
-	if err := file.Sync(); err != nil {
-		os.Remove(tmpPath)
-		return 0, "", "", fmt.Errorf("failed to sync file: %w", err)
+	rc, storedSize, err := fs.decryptedReader(doc, rc, blobSize)
+	if err != nil || doc.Encoding == "" {
+		return rc, storedSize, err
 	}
-	file.Close()
 
-	// Переименовываем временный файл в постоянный
-	if err := os.Rename(tmpPath, filePath); err != nil {
-		os.Remove(tmpPath)
-		return 0, "", "", fmt.Errorf("failed to rename file: %w", err)
+	c, err := codecFor(doc.Encoding)
+	if err != nil {
+		rc.Close()
+		return nil, 0, err
 	}
 
-	// Определяем MIME-тип
-	mimeType := mime.TypeByExtension(filepath.Ext(filename))
-	if mimeType == "" {
-		mimeType = "application/octet-stream"
+	if seekable {
+		dr, err := newDecompressReader(rc.(io.ReadSeeker), c, storedSize)
+		if err != nil {
+			rc.Close()
+			return nil, 0, err
+		}
+		return dr, dr.Size(), nil
+	}
+
+	// Без Seek индекс недоступен: распаковываем поток целиком, отрезав индекс по размеру
+	sr, err := c.stream(io.LimitReader(rc, storedSize-compTrailerSize(doc.Size)))
+	if err != nil {
+		rc.Close()
+		return nil, 0, ErrCorrupted
+	}
+	return &stackedReadCloser{Reader: sr, closers: []io.Closer{sr, rc}}, doc.Size, nil
+}
+
+type stackedReadCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (s *stackedReadCloser) Close() error {
+	var first error
+	for _, c := range s.closers {
+		if err := c.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// storedSize - размер хранимых данных после расшифровки, без чтения файла.
+func (fs *FileStorage) storedSize(doc *entity.Document, blobSize int64) int64 {
+	if doc.KeyID == "" {
+		return blobSize
+	}
+	size, _, _ := encryptedPlainSize(blobSize, encChunkSize)
+	return size
+}
+
+// plainSize - размер исходного содержимого без чтения файла.
+func (fs *FileStorage) plainSize(doc *entity.Document, blobSize int64) int64 {
+	if doc.Encoding != "" {
+		return doc.Size
+	}
+	return fs.storedSize(doc, blobSize)
+}
+
+// encodedSize - размер сжатых данных без индекса, которые отдаются клиенту как есть.
+func (fs *FileStorage) encodedSize(doc *entity.Document, blobSize int64) int64 {
+	return fs.storedSize(doc, blobSize) - compTrailerSize(doc.Size)
+}
+
+// ContentSize - размер исходного содержимого файла документа по размеру хранимого файла.
+func (fs *FileStorage) ContentSize(doc *entity.Document) (int64, error) {
+	info, err := fs.backend.Stat(blobKey(doc.ID, doc.Name))
+	if err != nil {
+		return 0, err
 	}
+	return fs.plainSize(doc, info.Size), nil
+}
 
-	//Обновляем кэш
-	//	etag := hex.EncodeToString(hasher.Sum(nil))
-	// fs.cache.memoryCache.Store(id, &CachedFile{
-	// 	data:    nil, // Не кэшируем данные при сохранении
-	// 	size:    size,
-	// 	mime:    mimeType,
-	// 	etag:    etag,
-	// 	created: time.Now(),
-	// })
+// BlobKey - ключ файла документа в backend.
+func (fs *FileStorage) BlobKey(doc *entity.Document) string {
+	return blobKey(doc.ID, doc.Name)
+}
 
-	return size, mimeType, filePath, nil
+// WalkBlobs обходит все файлы хранилища, включая миниатюры. id - документ, которому
+// принадлежит файл (uuid.Nil, если имя файла не соответствует схеме ключей).
+func (fs *FileStorage) WalkBlobs(fn func(id uuid.UUID, key string, info BlobInfo) error) error {
+	return fs.backend.List(func(key string, info BlobInfo) error {
+		return fn(blobID(key), key, info)
+	})
+}
+
+// Checksum вычисляет SHA-256 сохраненного файла - для документов, загруженных
+// до того, как контрольная сумма стала сохраняться в БД.
+func (fs *FileStorage) Checksum(doc *entity.Document) (string, int64, error) {
+	lock := fs.getFileLock(doc.ID)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	key := blobKey(doc.ID, doc.Name)
+	info, err := fs.backend.Stat(key)
+	if err != nil {
+		return "", 0, err
+	}
+	rc, err := fs.backend.Open(key)
+	if err != nil {
+		return "", 0, err
+	}
+	rc, _, err = fs.plainReader(doc, rc, info.Size)
+	if err != nil {
+		return "", 0, err
+	}
+	defer rc.Close()
+
+	hasher := sha256.New()
+	size, err := io.Copy(hasher, rc)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), size, nil
 }
 
 func (fs *FileStorage) DeleteFile(id uuid.UUID, filename string) error {
@@ -146,176 +436,392 @@ func (fs *FileStorage) DeleteFile(id uuid.UUID, filename string) error {
 	lock.Lock()
 	defer lock.Unlock()
 
-	filePath := fs.getFilePath(id, filename)
-	if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+	if err := fs.backend.Remove(blobKey(id, filename)); err != nil {
+		return err
+	}
+	if err := fs.removeDerived(id); err != nil {
+		logrus.Errorf("Failed to remove thumbnails and original of %s: %v", id, err)
+	}
+
+	fs.cache.memoryCache.Delete(id)
+	if fs.cache.diskCache != nil {
+		fs.cache.diskCache.Remove(id)
+	}
+	return nil
+}
+
+// RemoveBlob удаляет файл по ключу backend - для файлов, у которых нет записи в documents.
+func (fs *FileStorage) RemoveBlob(key string) error {
+	id := blobID(key)
+	if id == uuid.Nil {
+		return fs.backend.Remove(key)
+	}
+
+	lock := fs.getFileLock(id)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if err := fs.backend.Remove(key); err != nil {
 		return err
 	}
 
 	fs.cache.memoryCache.Delete(id)
+	if fs.cache.diskCache != nil {
+		fs.cache.diskCache.Remove(id)
+	}
 	return nil
 }
 
+// Exists проверяет наличие файла документа в backend.
+func (fs *FileStorage) Exists(doc *entity.Document) (bool, error) {
+	lock := fs.getFileLock(doc.ID)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	_, err := fs.backend.Stat(blobKey(doc.ID, doc.Name))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// ServeFile отдает файл с валидаторами ETag (SHA-256 содержимого) и Last-Modified (время
+// изменения документа). http.ServeContent обрабатывает If-None-Match, If-Match,
+// If-Modified-Since, If-Unmodified-Since и If-Range.
 func (fs *FileStorage) ServeFile(ctx *gin.Context, doc *entity.Document) error {
-	if ctx.Request.Method == http.MethodHead {
-		return fs.serveFileHead(ctx.Writer, doc)
+	if err := scanError(doc); err != nil {
+		return err
+	}
+
+	w, r := ctx.Writer, ctx.Request
+	w.Header().Set("Content-Type", docMime(doc))
+	if doc.Checksum != "" {
+		w.Header().Set("ETag", strongETag(doc.Checksum))
+	}
+
+	if doc.Encoding != "" {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if acceptsEncoding(r, doc.Encoding) {
+			return fs.serveEncoded(w, r, doc)
+		}
+	}
+
+	if r.Method == http.MethodHead {
+		return fs.serveFileHead(w, r, doc)
 	}
 
 	if cached, ok := fs.cache.Get(doc.ID); ok && cached.data != nil {
 		logrus.Debugf("Serving file %s from cache", doc.ID)
-		ctx.Writer.Header().Set("Content-Type", cached.mime)
-		ctx.Writer.Header().Set("ETag", cached.etag)
-		//ctx.Writer.Header().Set("Content-Length", fmt.Sprintf("%d", cached.size))
-		http.ServeContent(ctx.Writer, ctx.Request, doc.Name, time.Now(), bytes.NewReader(cached.data))
+		http.ServeContent(w, r, doc.Name, doc.ModTime(), bytes.NewReader(cached.data))
 		return nil
 	}
 
-	return fs.serveFileFromDisk(ctx.Writer, ctx.Request, doc)
+	return fs.serveFileFromDisk(w, r, doc)
+}
+
+// scanError: файл отдается только после антивирусной проверки, зараженный остается
+// в карантине до повторной проверки или удаления.
+func scanError(doc *entity.Document) error {
+	switch doc.ScanStatus {
+	case entity.ScanInfected:
+		return ErrQuarantined
+	case entity.ScanPending, entity.ScanError:
+		return ErrNotScanned
+	}
+	return nil
 }
 
-func (fs *FileStorage) serveFileHead(w http.ResponseWriter, doc *entity.Document) error {
-	filePath := fs.getFilePath(doc.ID, doc.Name)
+// serveEncoded отдает сжатые данные как есть с Content-Encoding, без распаковки на сервере.
+// Это другое представление ресурса, поэтому у него свой ETag, а Range считается по сжатым байтам.
+func (fs *FileStorage) serveEncoded(w http.ResponseWriter, r *http.Request, doc *entity.Document) error {
+	if doc.Checksum != "" {
+		w.Header().Set("ETag", strongETag(doc.Checksum+"-"+doc.Encoding))
+	}
+	w.Header().Set("Content-Encoding", doc.Encoding)
 
-	// Проверяем кэш
-	if cached, ok := fs.cache.Get(doc.ID); ok {
-		w.Header().Set("Content-Type", cached.mime)
-		w.Header().Set("ETag", cached.etag)
-		w.Header().Set("Content-Length", fmt.Sprintf("%d", cached.size))
-		w.WriteHeader(http.StatusOK)
+	lock := fs.getFileLock(doc.ID)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	if r.Method == http.MethodHead {
+		info, err := fs.backend.Stat(blobKey(doc.ID, doc.Name))
+		if err != nil {
+			return err
+		}
+		http.ServeContent(w, r, doc.Name, doc.ModTime(), &sizeOnlyContent{size: fs.encodedSize(doc, info.Size)})
 		return nil
 	}
 
-	// Получаем метаданные с диска
-	info, err := os.Stat(filePath)
+	file, info, err := fs.openForRead(doc)
+	if errors.Is(err, errNotSeekable) {
+		return fs.streamFile(w, r, doc, info, true)
+	}
 	if err != nil {
-		if os.IsNotExist(err) {
-			return os.ErrNotExist
-		}
 		return err
 	}
 
-	mimeType := doc.Mime
-	if mimeType == "" {
-		mimeType = mime.TypeByExtension(filepath.Ext(doc.Name))
+	rc, _, err := fs.decryptedReader(doc, file, info.Size)
+	if err != nil {
+		return err
 	}
-	if mimeType == "" {
-		mimeType = "application/octet-stream"
+	encoded := &prefixReadSeeker{src: rc.(io.ReadSeeker), n: fs.encodedSize(doc, info.Size)}
+	defer rc.Close()
+
+	http.ServeContent(w, r, doc.Name, doc.ModTime(), encoded)
+	return nil
+}
+
+// acceptsEncoding сообщает, принимает ли клиент представление в encoding (RFC 9110,
+// 12.5.3): явно указанное кодирование важнее "*", вес q=0 запрещает кодирование.
+func acceptsEncoding(r *http.Request, encoding string) bool {
+	wildcard := false
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.TrimSpace(name)
+		// x-gzip - устаревшее имя gzip, которое получатель должен считать равным ему
+		if encoding == EncodingGzip && strings.EqualFold(name, "x-gzip") {
+			name = encoding
+		}
+		switch {
+		case strings.EqualFold(name, encoding):
+			return nonZeroWeight(params)
+		case name == "*":
+			wildcard = nonZeroWeight(params)
+		}
 	}
+	return wildcard
+}
 
-	w.Header().Set("Content-Type", mimeType)
-	w.Header().Set("Content-Length", fmt.Sprintf("%d", info.Size()))
-	w.WriteHeader(http.StatusOK)
+// nonZeroWeight - в параметрах элемента Accept-Encoding нет веса q=0.
+func nonZeroWeight(params string) bool {
+	for _, param := range strings.Split(params, ";") {
+		name, value, _ := strings.Cut(param, "=")
+		if strings.EqualFold(strings.TrimSpace(name), "q") {
+			q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			return err != nil || q > 0
+		}
+	}
+	return true
+}
+
+func (fs *FileStorage) serveFileHead(w http.ResponseWriter, r *http.Request, doc *entity.Document) error {
+	size := doc.Size
+	if cached, ok := fs.cache.Get(doc.ID); ok {
+		size = cached.size
+	} else {
+		// Проверяем, что файл существует, и берем фактический размер
+		info, err := fs.backend.Stat(blobKey(doc.ID, doc.Name))
+		if err != nil {
+			return err
+		}
+		size = fs.plainSize(doc, info.Size)
+	}
+
+	// ServeContent не читает тело для HEAD, но проверяет условия и выставляет заголовки
+	http.ServeContent(w, r, doc.Name, doc.ModTime(), &sizeOnlyContent{size: size})
 	return nil
 }
 
 func (fs *FileStorage) serveFileFromDisk(w http.ResponseWriter, r *http.Request, doc *entity.Document) error {
 	lock := fs.getFileLock(doc.ID)
-	lock.Lock()
-	defer lock.Unlock()
+	lock.RLock()
+	defer lock.RUnlock()
 
-	filePath := fs.getFilePath(doc.ID, doc.Name)
+	key := blobKey(doc.ID, doc.Name)
+	logrus.Debugf("Trying to get file: %v", key)
 
-	logrus.Debug("Trying to get file: %v", filePath)
-	file, err := os.Open(filePath)
+	info, err := fs.backend.Stat(key)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return os.ErrNotExist
-		}
 		return err
 	}
-	defer file.Close()
 
-	info, err := file.Stat()
-	if err != nil {
-		return err
+	if fs.cache.memoryCache.Accepts(fs.plainSize(doc, info.Size)) {
+		cached, err := fs.loadToMemory(doc)
+		if err != nil {
+			return err
+		}
+		http.ServeContent(w, r, doc.Name, doc.ModTime(), bytes.NewReader(cached.data))
+		return nil
 	}
 
-	mimeType := doc.Mime
-	if mimeType == "" {
-		mimeType = mime.TypeByExtension(filepath.Ext(doc.Name))
+	file, info, err := fs.openPlain(doc)
+	if errors.Is(err, errNotSeekable) {
+		return fs.streamFile(w, r, doc, info, false)
 	}
-	if mimeType == "" {
-		mimeType = "application/octet-stream"
+	if err != nil {
+		return err
 	}
+	defer file.Close()
 
-	if info.Size() < 2*1024*1024 { // 2MB
-		data, err := io.ReadAll(file)
+	http.ServeContent(w, r, doc.Name, doc.ModTime(), file)
+	return nil
+}
+
+// loadToMemory читает небольшой файл в кеш памяти. Конкурентные читатели того же файла
+// ждут единственную загрузку вместо повторного чтения с диска.
+func (fs *FileStorage) loadToMemory(doc *entity.Document) (*CachedFile, error) {
+	val, shared, err := fs.memoryLoads.Do(doc.ID, func() (interface{}, error) {
+		// Файл мог попасть в кеш, пока мы ждали своей очереди
+		if cached, ok := fs.cache.Get(doc.ID); ok && cached.data != nil {
+			return cached, nil
+		}
+
+		file, _, err := fs.openPlain(doc)
 		if err != nil {
-			return err
+			return nil, err
 		}
+		defer file.Close()
 
-		hasher := sha256.New()
-		hasher.Write(data)
-		etag := hex.EncodeToString(hasher.Sum(nil))
+		data, err := io.ReadAll(file)
+		if err != nil {
+			return nil, err
+		}
 
-		fs.cache.memoryCache.Store(doc.ID, &CachedFile{
+		cached := &CachedFile{
 			data:    data,
-			size:    info.Size(),
-			mime:    mimeType,
-			etag:    etag,
+			size:    int64(len(data)),
+			mime:    docMime(doc),
 			created: time.Now(),
-		})
+		}
+		fs.cache.memoryCache.Store(doc.ID, cached)
+		return cached, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if shared {
+		logrus.Debugf("Coalesced load of file %s", doc.ID)
 	}
+	return val.(*CachedFile), nil
+}
 
-	http.ServeContent(w, r, doc.Name, info.ModTime(), file)
-	return nil
+var errNotSeekable = errors.New("blob does not support seeking")
+
+// Open открывает исходное содержимое файла документа для чтения.
+func (fs *FileStorage) Open(doc *entity.Document) (io.ReadSeekCloser, error) {
+	rc, _, err := fs.openPlain(doc)
+	return rc, err
 }
 
-func (c *Cache) Get(id uuid.UUID) (*CachedFile, bool) {
-	return c.memoryCache.Get(id)
+// openPlain открывает файл через openForRead и расшифровывает его при необходимости.
+// BlobInfo описывает файл в хранилище (для зашифрованного - размер шифртекста).
+func (fs *FileStorage) openPlain(doc *entity.Document) (io.ReadSeekCloser, BlobInfo, error) {
+	file, info, err := fs.openForRead(doc)
+	if err != nil {
+		return nil, info, err
+	}
+	rc, _, err := fs.plainReader(doc, file, info.Size)
+	if err != nil {
+		return nil, info, err
+	}
+	return rc.(io.ReadSeekCloser), info, nil
 }
 
-func (mc *MemoryCache) Get(id uuid.UUID) (*CachedFile, bool) {
-	mc.RLock()
-	defer mc.RUnlock()
+// openForRead открывает файл с поддержкой Seek (нужен для Range запросов).
+// Файлы удаленного backend сначала копируются в дисковый кеш.
+func (fs *FileStorage) openForRead(doc *entity.Document) (io.ReadSeekCloser, BlobInfo, error) {
+	key := blobKey(doc.ID, doc.Name)
+	disk := fs.cache.diskCache
 
-	if file, ok := mc.files[id]; ok {
-		if time.Since(file.created) < cacheTTL {
-			return file, true
+	if disk != nil {
+		if file, info, ok := fs.openFromDiskCache(doc.ID); ok {
+			return file, info, nil
 		}
-		go mc.Delete(id)
 	}
-	return nil, false
-}
-
-func (mc *MemoryCache) Store(id uuid.UUID, file *CachedFile) {
-	mc.Lock()
-	defer mc.Unlock()
 
-	if int64(len(file.data)) > mc.maxSize {
-		return
+	info, err := fs.backend.Stat(key)
+	if err != nil {
+		return nil, BlobInfo{}, err
 	}
 
-	for len(mc.files) >= mc.maxEntries || mc.totalSize+int64(len(file.data)) > mc.maxSize {
-		var oldestKey uuid.UUID
-		var oldestTime time.Time
-		for key, f := range mc.files {
-			if oldestTime.IsZero() || f.created.Before(oldestTime) {
-				oldestKey = key
-				oldestTime = f.created
+	if disk != nil && disk.Accepts(info.Size) {
+		// Заполнение дискового кеша тоже выполняется один раз на файл
+		_, _, err := fs.diskLoads.Do(doc.ID, func() (interface{}, error) {
+			rc, err := fs.backend.Open(key)
+			if err != nil {
+				return nil, err
+			}
+			defer rc.Close()
+
+			file, err := disk.Fill(doc.ID, rc, info.Size)
+			if err != nil {
+				return nil, err
 			}
+			return nil, file.Close()
+		})
+		if err != nil {
+			return nil, BlobInfo{}, err
 		}
-		if !oldestTime.IsZero() {
-			mc.deleteLocked(oldestKey)
+		if file, _, ok := fs.openFromDiskCache(doc.ID); ok {
+			return file, info, nil
 		}
 	}
 
-	if file.data != nil {
-		mc.totalSize += int64(len(file.data))
+	rc, err := fs.backend.Open(key)
+	if err != nil {
+		return nil, BlobInfo{}, err
 	}
-	mc.files[id] = file
+	if rs, ok := rc.(io.ReadSeekCloser); ok {
+		return rs, info, nil
+	}
+	rc.Close()
+	return nil, info, errNotSeekable
 }
 
-func (mc *MemoryCache) Delete(id uuid.UUID) {
-	mc.Lock()
-	defer mc.Unlock()
-	mc.deleteLocked(id)
+func (fs *FileStorage) openFromDiskCache(id uuid.UUID) (io.ReadSeekCloser, BlobInfo, bool) {
+	file, ok := fs.cache.diskCache.Open(id)
+	if !ok {
+		return nil, BlobInfo{}, false
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, BlobInfo{}, false
+	}
+
+	logrus.Debugf("Serving file %s from disk cache", id)
+	return file, BlobInfo{Size: stat.Size(), ModTime: stat.ModTime()}, true
 }
 
-func (mc *MemoryCache) deleteLocked(id uuid.UUID) {
-	if file, exists := mc.files[id]; exists {
-		if file.data != nil {
-			mc.totalSize -= int64(len(file.data))
-		}
-		delete(mc.files, id)
+// streamFile отдает файл целиком без поддержки Range - для больших файлов
+// удаленного backend, которые не помещаются в дисковый кеш. encoded - отдать сжатые данные как есть.
+func (fs *FileStorage) streamFile(w http.ResponseWriter, r *http.Request, doc *entity.Document, info BlobInfo, encoded bool) error {
+	if status, done := checkConditions(r, w.Header().Get("ETag"), doc.ModTime()); done {
+		w.WriteHeader(status)
+		return nil
 	}
+
+	rc, err := fs.backend.Open(blobKey(doc.ID, doc.Name))
+	if err != nil {
+		return err
+	}
+	var size int64
+	if encoded {
+		rc, _, err = fs.decryptedReader(doc, rc, info.Size)
+		size = fs.encodedSize(doc, info.Size)
+	} else {
+		rc, size, err = fs.plainReader(doc, rc, info.Size)
+	}
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	w.Header().Set("Last-Modified", doc.ModTime().UTC().Format(http.TimeFormat))
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", size))
+	w.WriteHeader(http.StatusOK)
+	_, err = io.CopyN(w, rc, size)
+	return err
+}
+
+func docMime(doc *entity.Document) string {
+	if doc.Mime != "" {
+		return doc.Mime
+	}
+	return MimeByName(doc.Name)
+}
+
+func (c *Cache) Get(id uuid.UUID) (*CachedFile, bool) {
+	return c.memoryCache.Get(id)
 }
@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/olenka-91/DocsServer/internal/entity"
+)
+
+// testMasterKey - запись ключа "id:base64" со случайным ключом.
+func testMasterKey(t *testing.T, id string) string {
+	t.Helper()
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	return id + ":" + base64.StdEncoding.EncodeToString(key)
+}
+
+// newTestStorage - хранилище во временном каталоге. Кеш в памяти не принимает файлы,
+// поэтому они читаются с диска через Seek, как большие файлы.
+func newTestStorage(t *testing.T, keyring *Keyring, compression CompressionConfig) *FileStorage {
+	t.Helper()
+	return NewFileStorage(StorageConfig{
+		BasePath:    t.TempDir(),
+		Cache:       CacheConfig{MaxFileSize: 1},
+		Keyring:     keyring,
+		Compression: compression,
+	})
+}
+
+// saveTestDoc сохраняет data и возвращает документ, каким его записал бы сервис.
+func saveTestDoc(t *testing.T, fs *FileStorage, name, mimeType string, data []byte) *entity.Document {
+	t.Helper()
+	doc := &entity.Document{ID: uuid.New(), Name: name, File: true, Created: time.Now()}
+	saved, err := fs.SaveFile(doc.ID, bytes.NewReader(data), name, mimeType, nil)
+	if err != nil {
+		t.Fatalf("SaveFile: %v", err)
+	}
+	doc.Mime = saved.Mime
+	doc.Path = saved.Path
+	doc.Checksum = saved.Checksum
+	doc.Size = saved.Size
+	doc.KeyID = saved.KeyID
+	doc.WrappedKey = saved.WrappedKey
+	doc.Encoding = saved.Encoding
+	return doc
+}
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// serveTestDoc выполняет запрос к ServeFile с заданными заголовками.
+func serveTestDoc(t *testing.T, fs *FileStorage, doc *entity.Document, method string, header map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Request = httptest.NewRequest(method, "/", nil)
+	for k, v := range header {
+		ctx.Request.Header.Set(k, v)
+	}
+	if err := fs.ServeFile(ctx, doc); err != nil {
+		t.Fatalf("ServeFile: %v", err)
+	}
+	// gin записывает статус ответа без тела после обработчика
+	ctx.Writer.WriteHeaderNow()
+	return w
+}
+
+func TestServeFileRange(t *testing.T) {
+	fs := newTestStorage(t, nil, CompressionConfig{})
+	data := []byte("0123456789abcdef")
+	doc := saveTestDoc(t, fs, "a.bin", "application/octet-stream", data)
+
+	w := serveTestDoc(t, fs, doc, http.MethodGet, map[string]string{"Range": "bytes=4-9"})
+	if w.Code != http.StatusPartialContent || w.Body.String() != "456789" {
+		t.Errorf("Range = %d %q, want 206 %q", w.Code, w.Body.String(), "456789")
+	}
+	if got := w.Header().Get("Content-Range"); got != "bytes 4-9/16" {
+		t.Errorf("Content-Range = %q", got)
+	}
+
+	w = serveTestDoc(t, fs, doc, http.MethodGet, map[string]string{"If-None-Match": strongETag(doc.Checksum)})
+	if w.Code != http.StatusNotModified {
+		t.Errorf("If-None-Match status = %d, want 304", w.Code)
+	}
+}
@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// flightGroup объединяет одновременные загрузки одного файла: функция выполняется один раз,
+// остальные вызовы с тем же id ждут и получают ее результат.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[uuid.UUID]*flightCall
+}
+
+type flightCall struct {
+	wg  sync.WaitGroup
+	val interface{}
+	err error
+}
+
+func newFlightGroup() *flightGroup {
+	return &flightGroup{calls: make(map[uuid.UUID]*flightCall)}
+}
+
+// errFlightPanic получают ожидающие вызовы, если fn запаниковала.
+var errFlightPanic = errors.New("shared load panicked")
+
+// Do возвращает результат fn и признак того, что результат получен от чужого вызова.
+// Если fn паникует, паника передается вызвавшему ее, а ожидающие получают errFlightPanic.
+func (g *flightGroup) Do(id uuid.UUID, fn func() (interface{}, error)) (interface{}, bool, error) {
+	g.mu.Lock()
+	if call, ok := g.calls[id]; ok {
+		g.mu.Unlock()
+		call.wg.Wait()
+		return call.val, true, call.err
+	}
+	call := &flightCall{err: errFlightPanic}
+	call.wg.Add(1)
+	g.calls[id] = call
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, id)
+		g.mu.Unlock()
+		call.wg.Done()
+	}()
+
+	call.val, call.err = fn()
+	return call.val, false, call.err
+}
@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestFlightGroupShares(t *testing.T) {
+	g := newFlightGroup()
+	id := uuid.New()
+	release := make(chan struct{})
+	calls := 0
+
+	var wg sync.WaitGroup
+	results := make([]bool, 5)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			val, shared, err := g.Do(id, func() (interface{}, error) {
+				calls++
+				<-release
+				return 42, nil
+			})
+			if err != nil || val != 42 {
+				t.Errorf("Do = %v, %v; want 42, nil", val, err)
+			}
+			results[i] = shared
+		}(i)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Fatalf("fn called %d times, want 1", calls)
+	}
+	owners := 0
+	for _, shared := range results {
+		if !shared {
+			owners++
+		}
+	}
+	if owners != 1 {
+		t.Fatalf("%d callers ran fn themselves, want 1", owners)
+	}
+}
+
+func TestFlightGroupPanic(t *testing.T) {
+	g := newFlightGroup()
+	id := uuid.New()
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	go func() {
+		defer func() { recover() }()
+		g.Do(id, func() (interface{}, error) {
+			close(started)
+			<-release
+			panic("decoder crashed")
+		})
+	}()
+	<-started
+
+	waiter := make(chan error)
+	go func() {
+		_, _, err := g.Do(id, func() (interface{}, error) { return nil, nil })
+		waiter <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	select {
+	case err := <-waiter:
+		if !errors.Is(err, errFlightPanic) {
+			t.Fatalf("waiter got %v, want errFlightPanic", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waiter blocked after panic")
+	}
+
+	// Ключ не остается занятым
+	val, shared, err := g.Do(id, func() (interface{}, error) { return "ok", nil })
+	if val != "ok" || shared || err != nil {
+		t.Fatalf("Do after panic = %v, %v, %v", val, shared, err)
+	}
+}
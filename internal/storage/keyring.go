@@ -0,0 +1,170 @@
+package storage
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+var ErrKeyNotFound = errors.New("encryption key not found")
+
+const dataKeySize = 32
+
+// Keyring - мастер-ключи, которыми шифруются ключи данных документов. Новые
+// документы шифруются активным ключом, остальные нужны для чтения старых.
+type Keyring struct {
+	keys   map[string]cipher.AEAD
+	active string
+}
+
+// LoadKeyring собирает ключи из строки "id:base64" и файла с такими строками.
+// Активный ключ - activeID, иначе последний ключ файла, иначе ключ из строки.
+// Если ключи не заданы, возвращает nil - шифрование выключено.
+func LoadKeyring(key, keyFile, activeID string) (*Keyring, error) {
+	k := &Keyring{keys: make(map[string]cipher.AEAD)}
+
+	if key != "" {
+		id, err := k.add(key)
+		if err != nil {
+			return nil, fmt.Errorf("ENCRYPTION_KEY: %w", err)
+		}
+		k.active = id
+	}
+
+	if keyFile != "" {
+		file, err := os.Open(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open key file: %w", err)
+		}
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		for line := 1; scanner.Scan(); line++ {
+			text := strings.TrimSpace(scanner.Text())
+			if text == "" || strings.HasPrefix(text, "#") {
+				continue
+			}
+			id, err := k.add(text)
+			if err != nil {
+				return nil, fmt.Errorf("key file line %d: %w", line, err)
+			}
+			k.active = id
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(k.keys) == 0 {
+		return nil, nil
+	}
+	if activeID != "" {
+		if _, ok := k.keys[activeID]; !ok {
+			return nil, fmt.Errorf("active key %q: %w", activeID, ErrKeyNotFound)
+		}
+		k.active = activeID
+	}
+	return k, nil
+}
+
+func (k *Keyring) add(entry string) (string, error) {
+	id, encoded, ok := strings.Cut(entry, ":")
+	id = strings.TrimSpace(id)
+	if !ok || id == "" {
+		return "", errors.New(`expected "id:base64key"`)
+	}
+	if _, exists := k.keys[id]; exists {
+		return "", fmt.Errorf("duplicate key id %q", id)
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return "", fmt.Errorf("invalid base64: %w", err)
+	}
+	if len(raw) != 32 {
+		return "", errors.New("master key must be 32 bytes (AES-256)")
+	}
+
+	aead, err := newAEAD(raw)
+	if err != nil {
+		return "", err
+	}
+	k.keys[id] = aead
+	return id, nil
+}
+
+func (k *Keyring) ActiveID() string {
+	return k.active
+}
+
+// newDataKey создает ключ данных документа и возвращает его вместе с обернутой
+// активным мастер-ключом копией, которая хранится в БД.
+func (k *Keyring) newDataKey(docID uuid.UUID) (dataKey []byte, keyID string, wrapped []byte, err error) {
+	dataKey = make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, "", nil, err
+	}
+	wrapped, err = k.wrap(k.active, dataKey, docID)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	return dataKey, k.active, wrapped, nil
+}
+
+// wrap шифрует ключ данных мастер-ключом: nonce || AES-GCM(dataKey), AAD - ID документа.
+func (k *Keyring) wrap(keyID string, dataKey []byte, docID uuid.UUID) ([]byte, error) {
+	aead, ok := k.keys[keyID]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, dataKey, docID[:]), nil
+}
+
+func (k *Keyring) unwrap(keyID string, wrapped []byte, docID uuid.UUID) ([]byte, error) {
+	aead, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrKeyNotFound, keyID)
+	}
+	if len(wrapped) < aead.NonceSize() {
+		return nil, ErrCorrupted
+	}
+	nonce, sealed := wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():]
+	dataKey, err := aead.Open(nil, nonce, sealed, docID[:])
+	if err != nil {
+		return nil, ErrCorrupted
+	}
+	return dataKey, nil
+}
+
+// Rewrap перешифровывает ключ данных активным мастер-ключом. Сам файл не меняется.
+func (k *Keyring) Rewrap(keyID string, wrapped []byte, docID uuid.UUID) (string, []byte, error) {
+	dataKey, err := k.unwrap(keyID, wrapped, docID)
+	if err != nil {
+		return "", nil, err
+	}
+	newWrapped, err := k.wrap(k.active, dataKey, docID)
+	if err != nil {
+		return "", nil, err
+	}
+	return k.active, newWrapped, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,215 @@
+package storage
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultCacheTTL      = 5 * time.Minute
+	defaultCacheMaxBytes = 100 * 1024 * 1024 // 100MB
+	defaultCacheEntries  = 100
+	defaultCacheFileSize = 2 * 1024 * 1024 // 2MB
+)
+
+// CacheConfig - ограничения кеша в памяти. Нулевые значения заменяются значениями по умолчанию.
+type CacheConfig struct {
+	MaxBytes    int64
+	MaxEntries  int
+	MaxFileSize int64
+	TTL         time.Duration
+}
+
+func (c CacheConfig) withDefaults() CacheConfig {
+	if c.MaxBytes <= 0 {
+		c.MaxBytes = defaultCacheMaxBytes
+	}
+	if c.MaxEntries <= 0 {
+		c.MaxEntries = defaultCacheEntries
+	}
+	if c.MaxFileSize <= 0 {
+		c.MaxFileSize = defaultCacheFileSize
+	}
+	if c.TTL <= 0 {
+		c.TTL = defaultCacheTTL
+	}
+	return c
+}
+
+type CacheStats struct {
+	Hits        uint64 `json:"hits"`
+	Misses      uint64 `json:"misses"`
+	Evictions   uint64 `json:"evictions"`
+	Expirations uint64 `json:"expirations"`
+	Entries     int    `json:"entries"`
+	Bytes       int64  `json:"bytes"`
+	MaxEntries  int    `json:"max_entries"`
+	MaxBytes    int64  `json:"max_bytes"`
+	MaxFileSize int64  `json:"max_file_size"`
+	TTLSeconds  int64  `json:"ttl_seconds"`
+}
+
+type CachedFile struct {
+	data    []byte
+	size    int64
+	mime    string
+	created time.Time
+}
+
+type cacheEntry struct {
+	id   uuid.UUID
+	file *CachedFile
+}
+
+// MemoryCache - LRU кеш с ограничением по числу записей и суммарному размеру.
+// Все операции O(1): список хранит порядок использования, map - быстрый доступ.
+type MemoryCache struct {
+	mu        sync.Mutex
+	cfg       CacheConfig
+	items     map[uuid.UUID]*list.Element
+	lru       *list.List // в начале - недавно использованные
+	totalSize int64
+
+	hits        atomic.Uint64
+	misses      atomic.Uint64
+	evictions   atomic.Uint64
+	expirations atomic.Uint64
+}
+
+func NewMemoryCache(cfg CacheConfig) *MemoryCache {
+	return &MemoryCache{
+		cfg:   cfg.withDefaults(),
+		items: make(map[uuid.UUID]*list.Element),
+		lru:   list.New(),
+	}
+}
+
+// Accepts сообщает, помещается ли файл такого размера в кеш.
+func (mc *MemoryCache) Accepts(size int64) bool {
+	return size <= mc.cfg.MaxFileSize && size <= mc.cfg.MaxBytes
+}
+
+func (mc *MemoryCache) Get(id uuid.UUID) (*CachedFile, bool) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	el, ok := mc.items[id]
+	if !ok {
+		mc.misses.Add(1)
+		return nil, false
+	}
+
+	entry := el.Value.(*cacheEntry)
+	if time.Since(entry.file.created) >= mc.cfg.TTL {
+		mc.removeElement(el)
+		mc.expirations.Add(1)
+		mc.misses.Add(1)
+		return nil, false
+	}
+
+	mc.lru.MoveToFront(el)
+	mc.hits.Add(1)
+	return entry.file, true
+}
+
+func (mc *MemoryCache) Store(id uuid.UUID, file *CachedFile) {
+	size := int64(len(file.data))
+	if !mc.Accepts(size) {
+		return
+	}
+
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	if el, ok := mc.items[id]; ok {
+		mc.removeElement(el)
+	}
+
+	for mc.lru.Len() > 0 && (mc.lru.Len() >= mc.cfg.MaxEntries || mc.totalSize+size > mc.cfg.MaxBytes) {
+		mc.removeElement(mc.lru.Back())
+		mc.evictions.Add(1)
+	}
+
+	mc.items[id] = mc.lru.PushFront(&cacheEntry{id: id, file: file})
+	mc.totalSize += size
+}
+
+func (mc *MemoryCache) Delete(id uuid.UUID) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	if el, ok := mc.items[id]; ok {
+		mc.removeElement(el)
+	}
+}
+
+func (mc *MemoryCache) Stats() CacheStats {
+	mc.mu.Lock()
+	entries, bytes := mc.lru.Len(), mc.totalSize
+	mc.mu.Unlock()
+
+	return CacheStats{
+		Hits:        mc.hits.Load(),
+		Misses:      mc.misses.Load(),
+		Evictions:   mc.evictions.Load(),
+		Expirations: mc.expirations.Load(),
+		Entries:     entries,
+		Bytes:       bytes,
+		MaxEntries:  mc.cfg.MaxEntries,
+		MaxBytes:    mc.cfg.MaxBytes,
+		MaxFileSize: mc.cfg.MaxFileSize,
+		TTLSeconds:  int64(mc.cfg.TTL / time.Second),
+	}
+}
+
+// RunJanitor периодически удаляет просроченные записи до отмены ctx.
+func (mc *MemoryCache) RunJanitor(ctx context.Context) {
+	interval := mc.cfg.TTL / 2
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if n := mc.removeExpired(); n > 0 {
+				logrus.Debugf("Cache janitor removed %d expired entries", n)
+			}
+		}
+	}
+}
+
+func (mc *MemoryCache) removeExpired() int {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	removed := 0
+	now := time.Now()
+	// Порядок в списке - по использованию, а не по созданию, поэтому проходим весь список
+	for el := mc.lru.Back(); el != nil; {
+		prev := el.Prev()
+		if now.Sub(el.Value.(*cacheEntry).file.created) >= mc.cfg.TTL {
+			mc.removeElement(el)
+			removed++
+		}
+		el = prev
+	}
+	mc.expirations.Add(uint64(removed))
+	return removed
+}
+
+func (mc *MemoryCache) removeElement(el *list.Element) {
+	entry := mc.lru.Remove(el).(*cacheEntry)
+	delete(mc.items, entry.id)
+	mc.totalSize -= int64(len(entry.file.data))
+}
@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func cachedFile(size int) *CachedFile {
+	return &CachedFile{data: bytes.Repeat([]byte{'a'}, size), size: int64(size), created: time.Now()}
+}
+
+func TestMemoryCacheEvictsByEntries(t *testing.T) {
+	mc := NewMemoryCache(CacheConfig{MaxEntries: 2})
+	a, b, c := uuid.New(), uuid.New(), uuid.New()
+
+	mc.Store(a, cachedFile(1))
+	mc.Store(b, cachedFile(1))
+	// a использован недавно, поэтому вытесняется b
+	if _, ok := mc.Get(a); !ok {
+		t.Fatal("a is missing")
+	}
+	mc.Store(c, cachedFile(1))
+
+	if _, ok := mc.Get(b); ok {
+		t.Error("b was not evicted")
+	}
+	for _, id := range []uuid.UUID{a, c} {
+		if _, ok := mc.Get(id); !ok {
+			t.Errorf("%s was evicted", id)
+		}
+	}
+	if st := mc.Stats(); st.Entries != 2 || st.Evictions != 1 {
+		t.Errorf("stats = %+v, want 2 entries and 1 eviction", st)
+	}
+}
+
+func TestMemoryCacheEvictsByBytes(t *testing.T) {
+	mc := NewMemoryCache(CacheConfig{MaxBytes: 10, MaxFileSize: 10})
+	a, b := uuid.New(), uuid.New()
+
+	mc.Store(a, cachedFile(6))
+	mc.Store(b, cachedFile(6))
+	if _, ok := mc.Get(a); ok {
+		t.Error("a was not evicted")
+	}
+	if _, ok := mc.Get(b); !ok {
+		t.Error("b is missing")
+	}
+	if st := mc.Stats(); st.Bytes != 6 {
+		t.Errorf("bytes = %d, want 6", st.Bytes)
+	}
+
+	// Файл больше MaxFileSize не кешируется и ничего не вытесняет
+	mc.Store(uuid.New(), cachedFile(11))
+	if _, ok := mc.Get(b); !ok {
+		t.Error("b was evicted by a file that does not fit")
+	}
+}
+
+func TestMemoryCacheTTL(t *testing.T) {
+	mc := NewMemoryCache(CacheConfig{TTL: time.Minute})
+	fresh, stale, janitor := uuid.New(), uuid.New(), uuid.New()
+
+	mc.Store(fresh, cachedFile(1))
+	old := cachedFile(1)
+	old.created = time.Now().Add(-time.Hour)
+	mc.Store(stale, old)
+	mc.Store(janitor, old)
+
+	if _, ok := mc.Get(stale); ok {
+		t.Error("expired entry was returned")
+	}
+	if n := mc.removeExpired(); n != 1 {
+		t.Errorf("removeExpired = %d, want 1", n)
+	}
+	if _, ok := mc.Get(fresh); !ok {
+		t.Error("fresh entry is missing")
+	}
+	if st := mc.Stats(); st.Entries != 1 || st.Expirations != 2 {
+		t.Errorf("stats = %+v, want 1 entry and 2 expirations", st)
+	}
+}
@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"path/filepath"
+	"strings"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+const defaultMime = "application/octet-stream"
+
+// sniffLen - сколько первых байт файла читается для определения типа по содержимому.
+const sniffLen = 3072
+
+// mimeAliases - распространенные нестандартные написания типов.
+var mimeAliases = map[string]string{
+	"image/jpg":   "image/jpeg",
+	"image/pjpeg": "image/jpeg",
+	"image/x-png": "image/png",
+}
+
+// MimeByName определяет MIME-тип файла по расширению имени.
+func MimeByName(filename string) string {
+	mimeType := mime.TypeByExtension(filepath.Ext(filename))
+	if mimeType == "" {
+		mimeType = defaultMime
+	}
+	return mimeType
+}
+
+// MimeMatches сообщает, подходит ли тип под шаблон: "text/*", "*+xml" или точный тип.
+// Параметры типа (charset и т.п.) не учитываются.
+func MimeMatches(pattern, mimeType string) bool {
+	mimeType = baseMime(mimeType)
+	pattern = strings.ToLower(pattern)
+
+	switch {
+	case pattern == "*" || pattern == "*/*":
+		return true
+	case strings.HasPrefix(pattern, "*"):
+		return strings.HasSuffix(mimeType, pattern[1:])
+	case strings.HasSuffix(pattern, "/*"):
+		return strings.HasPrefix(mimeType, pattern[:len(pattern)-1])
+	}
+	return pattern == mimeType
+}
+
+// KnownMime сообщает, что тип задан и не является типом по умолчанию для неизвестных данных.
+func KnownMime(mimeType string) bool {
+	mimeType = baseMime(mimeType)
+	return mimeType != "" && mimeType != defaultMime
+}
+
+// SniffMime определяет тип по первым байтам содержимого и возвращает reader, который
+// отдает содержимое целиком, включая уже прочитанное начало.
+func SniffMime(r io.Reader) (string, io.Reader, error) {
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", nil, err
+	}
+	head = head[:n]
+	return mimetype.Detect(head).String(), io.MultiReader(bytes.NewReader(head), r), nil
+}
+
+// MimeCompatible сообщает, не противоречит ли заявленный тип типу, определенному по
+// содержимому. Общий тип совместим с уточняющим: text/plain с text/csv, application/zip
+// с docx. Неизвестный тип совместим с любым.
+func MimeCompatible(claimed, sniffed string) bool {
+	if !KnownMime(claimed) || !KnownMime(sniffed) {
+		return true
+	}
+	claimed = baseMime(claimed)
+
+	if m := mimetype.Lookup(sniffed); m != nil {
+		for ; m != nil; m = m.Parent() {
+			if m.Is(claimed) {
+				return true
+			}
+		}
+	}
+	if m := mimetype.Lookup(claimed); m != nil {
+		for ; m != nil; m = m.Parent() {
+			if m.Is(sniffed) && KnownMime(m.String()) {
+				return true
+			}
+		}
+	}
+	// Текстовые форматы, которые не различаются по содержимому (text/markdown и т.п.)
+	return baseMime(sniffed) == "text/plain" && textualMime(claimed)
+}
+
+func textualMime(mimeType string) bool {
+	switch {
+	case strings.HasPrefix(mimeType, "text/"),
+		strings.HasSuffix(mimeType, "+json"),
+		strings.HasSuffix(mimeType, "+xml"):
+		return true
+	}
+	switch mimeType {
+	case "application/json", "application/xml", "application/javascript",
+		"application/yaml", "application/x-yaml", "application/sql":
+		return true
+	}
+	return false
+}
+
+// baseMime - тип без параметров, в нижнем регистре и с учетом нестандартных написаний.
+func baseMime(mimeType string) string {
+	mimeType, _, _ = strings.Cut(strings.ToLower(mimeType), ";")
+	mimeType = strings.TrimSpace(mimeType)
+	if alias, ok := mimeAliases[mimeType]; ok {
+		return alias
+	}
+	return mimeType
+}
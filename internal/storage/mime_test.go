@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestSniffMime(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"png", "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR", "image/png"},
+		{"jpeg", "\xff\xd8\xff\xe0\x00\x10JFIF\x00", "image/jpeg"},
+		{"pdf", "%PDF-1.4\n", "application/pdf"},
+		{"zip", "PK\x03\x04", "application/zip"},
+		{"executable", "MZ\x90\x00", "application/vnd.microsoft.portable-executable"},
+		{"json", `{"a":1}`, "application/json"},
+		{"text", "hello\n", "text/plain; charset=utf-8"},
+		{"binary", "\x00\x01\x02\x03", defaultMime},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := SniffMime(strings.NewReader(tt.data))
+			if err != nil || got != tt.want {
+				t.Errorf("SniffMime = %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSniffMimeKeepsContent(t *testing.T) {
+	// Содержимое длиннее прочитанного для определения типа начала
+	data := append([]byte("%PDF-1.4\n"), bytes.Repeat([]byte{'x'}, 2*sniffLen)...)
+	mimeType, r, err := SniffMime(bytes.NewReader(data))
+	if err != nil || mimeType != "application/pdf" {
+		t.Fatalf("SniffMime = %q, %v", mimeType, err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Errorf("content after sniffing: %v, matches: %t", err, bytes.Equal(got, data))
+	}
+}
+
+func TestMimeCompatible(t *testing.T) {
+	docx := "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	tests := []struct {
+		claimed, sniffed string
+		want             bool
+	}{
+		{"image/png", "image/png", true},
+		{"image/jpg", "image/jpeg", true},
+		{"IMAGE/PNG; foo=bar", "image/png", true},
+		{"text/csv", "text/plain; charset=utf-8", true},
+		{"text/markdown", "text/plain", true},
+		{"application/json", "text/plain", true},
+		// Общий тип совместим с уточняющим в обе стороны
+		{docx, "application/zip", true},
+		{"application/zip", docx, true},
+		{"image/png", "image/jpeg", false},
+		{"application/pdf", "application/vnd.microsoft.portable-executable", false},
+		{"text/plain", "image/png", false},
+		{"image/png", "text/plain", false},
+		// Неизвестный тип совместим с любым
+		{"", "image/png", true},
+		{defaultMime, "application/pdf", true},
+		{"image/png", defaultMime, true},
+	}
+	for _, tt := range tests {
+		if got := MimeCompatible(tt.claimed, tt.sniffed); got != tt.want {
+			t.Errorf("MimeCompatible(%q, %q) = %t, want %t", tt.claimed, tt.sniffed, got, tt.want)
+		}
+	}
+}
+
+func TestMimeMatches(t *testing.T) {
+	tests := []struct {
+		pattern, mimeType string
+		want              bool
+	}{
+		{"*", "image/png", true},
+		{"*/*", "application/pdf", true},
+		{"image/*", "image/png", true},
+		{"image/*", "application/pdf", false},
+		{"*+xml", "image/svg+xml", true},
+		{"*+xml", "application/xml", false},
+		{"text/plain", "text/plain; charset=utf-8", true},
+		{"Image/JPEG", "image/jpg", true},
+		{"image/png", "image/pngx", false},
+	}
+	for _, tt := range tests {
+		if got := MimeMatches(tt.pattern, tt.mimeType); got != tt.want {
+			t.Errorf("MimeMatches(%q, %q) = %t, want %t", tt.pattern, tt.mimeType, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"io"
+	"net/http"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/olenka-91/DocsServer/internal/entity"
+)
+
+const originalPurpose = "original"
+
+// originalKey - ключ оригинала файла, сохраненного до удаления метаданных.
+func originalKey(id uuid.UUID) string {
+	return filepath.Join(id.String()[0:2], id.String()+".original")
+}
+
+// saveOriginal сохраняет файл как есть (без сжатия) и возвращает его размер.
+func (fs *FileStorage) saveOriginal(id uuid.UUID, r io.Reader, dataKey []byte) (int64, error) {
+	counter := &countingWriter{}
+	var src io.Reader = io.TeeReader(r, counter)
+	if dataKey != nil {
+		aead, err := derivedAEAD(dataKey, originalPurpose)
+		if err != nil {
+			return 0, err
+		}
+		src = newEncryptReader(src, aead, id[:])
+	}
+	if _, err := fs.backend.Save(originalKey(id), src); err != nil {
+		return 0, err
+	}
+	return counter.n, nil
+}
+
+func (fs *FileStorage) openOriginal(id uuid.UUID, dataKey []byte) (io.ReadSeekCloser, error) {
+	key := originalKey(id)
+	info, err := fs.backend.Stat(key)
+	if err != nil {
+		return nil, err
+	}
+	rc, err := fs.backend.Open(key)
+	if err != nil {
+		return nil, err
+	}
+	file, ok := rc.(io.ReadSeekCloser)
+	if !ok {
+		rc.Close()
+		return nil, errNotSeekable
+	}
+	if dataKey == nil {
+		return file, nil
+	}
+
+	aead, err := derivedAEAD(dataKey, originalPurpose)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	dr, err := newDecryptReader(file, info.Size, aead, id[:])
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return dr, nil
+}
+
+// ServeOriginal отдает оригинал файла, сохраненный до удаления метаданных. Если
+// оригинал не сохранялся, возвращает os.ErrNotExist.
+func (fs *FileStorage) ServeOriginal(ctx *gin.Context, doc *entity.Document) error {
+	if err := scanError(doc); err != nil {
+		return err
+	}
+
+	var dataKey []byte
+	if doc.KeyID != "" {
+		if fs.keyring == nil {
+			return ErrKeyNotFound
+		}
+		var err error
+		if dataKey, err = fs.keyring.unwrap(doc.KeyID, doc.WrappedKey, doc.ID); err != nil {
+			return err
+		}
+	}
+
+	lock := fs.getFileLock(doc.ID)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	rc, err := fs.openOriginal(doc.ID, dataKey)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	ctx.Header("Content-Type", docMime(doc))
+	http.ServeContent(ctx.Writer, ctx.Request, doc.Name, doc.ModTime(), rc)
+	return nil
+}
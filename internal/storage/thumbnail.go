@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/olenka-91/DocsServer/internal/entity"
+	"github.com/olenka-91/DocsServer/internal/thumbnail"
+)
+
+// thumbnailKey - ключ миниатюры в backend.
+func thumbnailKey(id uuid.UUID, size int) string {
+	return filepath.Join(id.String()[0:2], fmt.Sprintf("%s.thumb%d", id, size))
+}
+
+func (fs *FileStorage) thumbnailAEAD(doc *entity.Document, size int) (cipher.AEAD, error) {
+	return fs.docDerivedAEAD(doc, fmt.Sprintf("thumbnail-%d", size))
+}
+
+// SaveThumbnail сохраняет миниатюру документа, шифруя ее, если зашифрован сам файл.
+func (fs *FileStorage) SaveThumbnail(doc *entity.Document, size int, data []byte) error {
+	lock := fs.getFileLock(doc.ID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	aead, err := fs.thumbnailAEAD(doc, size)
+	if err != nil {
+		return err
+	}
+	var src io.Reader = bytes.NewReader(data)
+	if aead != nil {
+		src = newEncryptReader(src, aead, doc.ID[:])
+	}
+	_, err = fs.backend.Save(thumbnailKey(doc.ID, size), src)
+	return err
+}
+
+// ServeThumbnail отдает миниатюру с ETag, производным от SHA-256 файла. Если миниатюры
+// еще нет, возвращает os.ErrNotExist.
+func (fs *FileStorage) ServeThumbnail(ctx *gin.Context, doc *entity.Document, size int) error {
+	data, info, err := fs.readThumbnail(doc, size)
+	if err != nil {
+		return err
+	}
+
+	w := ctx.Writer
+	w.Header().Set("Content-Type", thumbnail.ContentType)
+	if doc.Checksum != "" {
+		w.Header().Set("ETag", strongETag(fmt.Sprintf("%s-thumb%d", doc.Checksum, size)))
+	}
+	http.ServeContent(w, ctx.Request, "", info.ModTime, bytes.NewReader(data))
+	return nil
+}
+
+func (fs *FileStorage) readThumbnail(doc *entity.Document, size int) ([]byte, BlobInfo, error) {
+	lock := fs.getFileLock(doc.ID)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	key := thumbnailKey(doc.ID, size)
+	info, err := fs.backend.Stat(key)
+	if err != nil {
+		return nil, info, err
+	}
+	aead, err := fs.thumbnailAEAD(doc, size)
+	if err != nil {
+		return nil, info, err
+	}
+	rc, err := fs.backend.Open(key)
+	if err != nil {
+		return nil, info, err
+	}
+	defer rc.Close()
+
+	var r io.Reader = rc
+	if aead != nil {
+		if r, err = newDecryptReader(rc, info.Size, aead, doc.ID[:]); err != nil {
+			return nil, info, err
+		}
+	}
+	data, err := io.ReadAll(r)
+	return data, info, err
+}
@@ -0,0 +1,136 @@
+package thumbnail
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"strconv"
+	"sync"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// Размеры миниатюр: наибольшая сторона в пикселях
+var Sizes = map[string]int{
+	"small":  128,
+	"medium": 256,
+	"large":  512,
+}
+
+const DefaultSize = "medium"
+
+// maxPixels ограничивает размер исходного изображения: небольшой файл может
+// распаковаться в гигабайты пикселей.
+const maxPixels = 50_000_000
+
+const jpegQuality = 80
+
+var ErrTooLarge = errors.New("image is too large for preview")
+
+// ContentType - тип всех миниатюр. Прозрачные изображения накладываются на белый фон.
+const ContentType = "image/jpeg"
+
+// Supported - для файлов этого типа строится миниатюра.
+func Supported(mimeType string) bool {
+	switch mimeType {
+	case "image/jpeg", "image/png", "image/gif", "image/webp":
+		return true
+	}
+	return false
+}
+
+// ParseSize принимает имя размера (small, medium, large) или его значение в пикселях.
+// Пустая строка - размер по умолчанию.
+func ParseSize(value string) (int, bool) {
+	if value == "" {
+		value = DefaultSize
+	}
+	if size, ok := Sizes[value]; ok {
+		return size, true
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, false
+	}
+	for _, size := range Sizes {
+		if size == n {
+			return size, true
+		}
+	}
+	return 0, false
+}
+
+// Generate декодирует изображение один раз и строит миниатюры всех размеров.
+// Изображение меньше миниатюры не увеличивается.
+func Generate(r io.Reader) (map[int][]byte, error) {
+	// Размеры читаются из заголовка до декодирования всего изображения
+	var head bytes.Buffer
+	cfg, _, err := image.DecodeConfig(io.TeeReader(r, &head))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > maxPixels {
+		return nil, ErrTooLarge
+	}
+
+	src, _, err := image.Decode(io.MultiReader(&head, r))
+	if err != nil {
+		return nil, err
+	}
+
+	thumbs := make(map[int][]byte, len(Sizes))
+	for _, size := range Sizes {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, scale(src, size), &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return nil, err
+		}
+		thumbs[size] = buf.Bytes()
+	}
+	return thumbs, nil
+}
+
+// scale вписывает изображение в квадрат size x size с сохранением пропорций.
+func scale(src image.Image, size int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w > size || h > size {
+		if w >= h {
+			w, h = size, max(h*size/w, 1)
+		} else {
+			w, h = max(w*size/h, 1), size
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, b, draw.Over, nil)
+	return dst
+}
+
+var (
+	placeholderMu sync.Mutex
+	placeholders  = map[int][]byte{}
+)
+
+// Placeholder - серый квадрат размера size в PNG, отдается, пока миниатюра не готова.
+func Placeholder(size int) []byte {
+	placeholderMu.Lock()
+	defer placeholderMu.Unlock()
+
+	if data, ok := placeholders[size]; ok {
+		return data
+	}
+	img := image.NewPaletted(image.Rect(0, 0, size, size), color.Palette{color.Gray{Y: 0xe0}})
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil
+	}
+	placeholders[size] = buf.Bytes()
+	return placeholders[size]
+}
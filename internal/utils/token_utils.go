@@ -1,6 +1,9 @@
 package utils
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"time"
 
@@ -11,6 +14,9 @@ import (
 const (
 	accessTokenTTL  = 60 * time.Minute
 	refreshTokenTTL = 7 * 24 * time.Hour
+
+	// MaxTokenTTL - срок жизни самого долгоживущего токена
+	MaxTokenTTL = refreshTokenTTL
 )
 
 var jwtKey = []byte("SecretKey")
@@ -22,7 +28,8 @@ type JwtClaim struct {
 	jwt.RegisteredClaims
 }
 
-func GenerateToken(userID uuid.UUID, login string, tokenType string) (string, error) {
+// GenerateToken выпускает токен с моментом выпуска issuedAt.
+func GenerateToken(userID uuid.UUID, login string, tokenType string, issuedAt time.Time) (string, error) {
 	var expiredTime time.Time
 	if tokenType == "access" {
 		expiredTime = time.Now().Add(accessTokenTTL)
@@ -35,6 +42,8 @@ func GenerateToken(userID uuid.UUID, login string, tokenType string) (string, er
 		Login:  login,
 		Type:   tokenType,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			IssuedAt:  jwt.NewNumericDate(issuedAt),
 			ExpiresAt: jwt.NewNumericDate(expiredTime),
 		},
 	}
@@ -63,3 +72,18 @@ func ValidateToken(tokenString string) (*JwtClaim, error) {
 
 	return claims, nil
 }
+
+// GenerateOpaqueToken возвращает случайный токен и его SHA-256 хеш для хранения в БД.
+func GenerateOpaqueToken() (string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	token := hex.EncodeToString(buf)
+	return token, HashOpaqueToken(token), nil
+}
+
+func HashOpaqueToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,2 @@
+DROP TABLE PASSWORD_HISTORY;
+DROP TABLE PASSWORD_RESETS;
@@ -0,0 +1,17 @@
+CREATE TABLE PASSWORD_RESETS (
+    TOKEN_HASH TEXT PRIMARY KEY,
+    USER_ID    UUID NOT NULL REFERENCES USERS(ID) ON DELETE CASCADE,
+    EXPIRES_AT TIMESTAMPTZ NOT NULL,
+    USED_AT    TIMESTAMPTZ,
+    CREATED_AT TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX ON PASSWORD_RESETS (USER_ID);
+
+CREATE TABLE PASSWORD_HISTORY (
+    USER_ID    UUID NOT NULL REFERENCES USERS(ID) ON DELETE CASCADE,
+    PASSWORD   TEXT NOT NULL,
+    CREATED_AT TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX ON PASSWORD_HISTORY (USER_ID, CREATED_AT);
@@ -0,0 +1,6 @@
+ALTER TABLE USERS
+  DROP COLUMN TOKENS_VALID_AFTER,
+  DROP COLUMN DISABLED,
+  DROP COLUMN IS_ADMIN;
+
+DROP TABLE REVOKED_TOKENS;
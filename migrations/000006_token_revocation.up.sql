@@ -0,0 +1,14 @@
+CREATE TABLE REVOKED_TOKENS (
+    JTI        UUID PRIMARY KEY,
+    USER_ID    UUID NOT NULL REFERENCES USERS(ID) ON DELETE CASCADE,
+    EXPIRES_AT TIMESTAMPTZ NOT NULL,
+    CREATED_AT TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX ON REVOKED_TOKENS (CREATED_AT);
+CREATE INDEX ON REVOKED_TOKENS (EXPIRES_AT);
+
+ALTER TABLE USERS
+  ADD COLUMN TOKENS_VALID_AFTER TIMESTAMPTZ,
+  ADD COLUMN DISABLED           BOOLEAN NOT NULL DEFAULT FALSE,
+  ADD COLUMN IS_ADMIN           BOOLEAN NOT NULL DEFAULT FALSE;
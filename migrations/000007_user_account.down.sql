@@ -0,0 +1,23 @@
+ALTER TABLE USERS
+  ADD COLUMN TOKENS_VALID_AFTER TIMESTAMPTZ;
+
+UPDATE USERS u SET TOKENS_VALID_AFTER = c.VALID_AFTER
+  FROM USER_TOKEN_CUTOFFS c WHERE c.USER_ID = u.ID;
+
+DROP TABLE USER_TOKEN_CUTOFFS;
+
+DELETE FROM REVOKED_TOKENS r WHERE NOT EXISTS (SELECT 1 FROM USERS u WHERE u.ID = r.USER_ID);
+
+ALTER TABLE REVOKED_TOKENS
+  ADD CONSTRAINT REVOKED_TOKENS_USER_ID_FKEY FOREIGN KEY (USER_ID) REFERENCES USERS(ID) ON DELETE CASCADE;
+
+ALTER TABLE DOCUMENTS
+  DROP CONSTRAINT DOCUMENTS_USER_ID_FKEY,
+  ADD CONSTRAINT DOCUMENTS_USER_ID_FKEY FOREIGN KEY (USER_ID) REFERENCES USERS(ID);
+
+DROP INDEX USERS_EMAIL_KEY;
+
+ALTER TABLE USERS
+  DROP COLUMN DISPLAY_NAME,
+  DROP COLUMN EMAIL,
+  DROP COLUMN PREFERENCES;
@@ -0,0 +1,27 @@
+ALTER TABLE USERS
+  ADD COLUMN DISPLAY_NAME TEXT,
+  ADD COLUMN EMAIL        TEXT,
+  ADD COLUMN PREFERENCES  JSONB;
+
+CREATE UNIQUE INDEX USERS_EMAIL_KEY ON USERS (LOWER(EMAIL));
+
+-- Документы удаляются вместе с владельцем, если их не передали другому пользователю
+ALTER TABLE DOCUMENTS
+  DROP CONSTRAINT DOCUMENTS_USER_ID_FKEY,
+  ADD CONSTRAINT DOCUMENTS_USER_ID_FKEY FOREIGN KEY (USER_ID) REFERENCES USERS(ID) ON DELETE CASCADE;
+
+-- Отзыв токенов должен пережить удаление пользователя, иначе его access токены
+-- оставались бы действительными до истечения срока
+ALTER TABLE REVOKED_TOKENS
+  DROP CONSTRAINT REVOKED_TOKENS_USER_ID_FKEY;
+
+CREATE TABLE USER_TOKEN_CUTOFFS (
+    USER_ID     UUID PRIMARY KEY,
+    VALID_AFTER TIMESTAMPTZ NOT NULL
+);
+
+INSERT INTO USER_TOKEN_CUTOFFS (USER_ID, VALID_AFTER)
+SELECT ID, TOKENS_VALID_AFTER FROM USERS WHERE TOKENS_VALID_AFTER IS NOT NULL;
+
+ALTER TABLE USERS
+  DROP COLUMN TOKENS_VALID_AFTER;
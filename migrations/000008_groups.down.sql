@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS DOCUMENT_GRANTS_USER_ID_IDX;
+DROP TABLE DOCUMENT_GROUP_GRANTS;
+DROP TABLE GROUP_MEMBERS;
+DROP TABLE GROUPS;
@@ -0,0 +1,25 @@
+CREATE TABLE GROUPS (
+    ID         UUID PRIMARY KEY,
+    NAME       TEXT UNIQUE NOT NULL,
+    CREATED_BY UUID REFERENCES USERS(ID) ON DELETE SET NULL,
+    CREATED_AT TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE TABLE GROUP_MEMBERS (
+    GROUP_ID UUID REFERENCES GROUPS(ID) ON DELETE CASCADE,
+    USER_ID  UUID REFERENCES USERS(ID) ON DELETE CASCADE,
+    IS_OWNER BOOLEAN NOT NULL DEFAULT FALSE,
+    PRIMARY KEY (GROUP_ID, USER_ID)
+);
+
+-- Поиск групп пользователя при проверке доступа
+CREATE INDEX ON GROUP_MEMBERS (USER_ID, GROUP_ID);
+
+CREATE TABLE DOCUMENT_GROUP_GRANTS (
+    DOC_ID   UUID REFERENCES DOCUMENTS(ID) ON DELETE CASCADE,
+    GROUP_ID UUID REFERENCES GROUPS(ID) ON DELETE CASCADE,
+    PRIMARY KEY (DOC_ID, GROUP_ID)
+);
+
+CREATE INDEX ON DOCUMENT_GROUP_GRANTS (GROUP_ID);
+CREATE INDEX ON DOCUMENT_GRANTS (USER_ID);
@@ -0,0 +1,15 @@
+CREATE TABLE DOCUMENT_TRANSFERS (
+    ID           UUID PRIMARY KEY,
+    DOC_ID       UUID REFERENCES DOCUMENTS(ID) ON DELETE CASCADE,
+    FROM_USER_ID UUID REFERENCES USERS(ID) ON DELETE SET NULL,
+    TO_USER_ID   UUID REFERENCES USERS(ID) ON DELETE SET NULL,
+    FROM_LOGIN   TEXT NOT NULL,
+    TO_LOGIN     TEXT NOT NULL,
+    INITIATED_BY TEXT NOT NULL,
+    STATUS       TEXT NOT NULL,
+    CREATED_AT   TIMESTAMPTZ DEFAULT NOW(),
+    RESOLVED_AT  TIMESTAMPTZ
+);
+
+CREATE INDEX ON DOCUMENT_TRANSFERS (DOC_ID, CREATED_AT);
+CREATE INDEX ON DOCUMENT_TRANSFERS (TO_USER_ID) WHERE STATUS = 'pending';
@@ -0,0 +1,4 @@
+ALTER TABLE DOCUMENTS
+  DROP COLUMN SHA256,
+  DROP COLUMN SIZE,
+  DROP COLUMN UPDATED_AT;
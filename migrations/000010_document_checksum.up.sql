@@ -0,0 +1,9 @@
+ALTER TABLE DOCUMENTS
+  ADD COLUMN SHA256     TEXT,
+  ADD COLUMN SIZE       BIGINT,
+  ADD COLUMN UPDATED_AT TIMESTAMPTZ;
+
+UPDATE DOCUMENTS SET UPDATED_AT = CREATED_AT;
+
+ALTER TABLE DOCUMENTS
+  ALTER COLUMN UPDATED_AT SET DEFAULT NOW();
@@ -0,0 +1,7 @@
+-- Загрузка регистрируется до записи файла и снимается в транзакции вставки документа.
+-- Файлы с записью здесь не считаются лишними, пока запись не устареет.
+CREATE TABLE PENDING_UPLOADS (
+    DOC_ID     UUID PRIMARY KEY,
+    BLOB_KEY   TEXT NOT NULL,
+    CREATED_AT TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,3 @@
+ALTER TABLE DOCUMENTS
+  DROP COLUMN ENC_KEY_ID,
+  DROP COLUMN ENC_DATA_KEY;
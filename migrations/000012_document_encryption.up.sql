@@ -0,0 +1,6 @@
+-- Ключ данных документа, обернутый мастер-ключом ENC_KEY_ID. NULL - файл хранится открытым.
+ALTER TABLE DOCUMENTS
+  ADD COLUMN ENC_KEY_ID   TEXT,
+  ADD COLUMN ENC_DATA_KEY BYTEA;
+
+CREATE INDEX ON DOCUMENTS (ENC_KEY_ID) WHERE ENC_KEY_ID IS NOT NULL;
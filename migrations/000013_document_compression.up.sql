@@ -0,0 +1,2 @@
+-- Сжатие, в котором хранится файл (gzip, zstd). NULL - файл хранится без сжатия.
+ALTER TABLE DOCUMENTS ADD COLUMN CONTENT_ENCODING TEXT;
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS USER_USAGE;
+
+ALTER TABLE USERS
+  DROP COLUMN QUOTA_BYTES,
+  DROP COLUMN QUOTA_DOCS;
@@ -0,0 +1,18 @@
+-- Индивидуальные квоты; NULL - квота по умолчанию из конфигурации, 0 - без ограничения
+ALTER TABLE USERS
+  ADD COLUMN QUOTA_BYTES BIGINT,
+  ADD COLUMN QUOTA_DOCS  BIGINT;
+
+-- Учет занятого места обновляется в тех же транзакциях, что создают, удаляют
+-- и передают документы
+CREATE TABLE USER_USAGE (
+    USER_ID    UUID PRIMARY KEY REFERENCES USERS(ID) ON DELETE CASCADE,
+    BYTES_USED BIGINT NOT NULL DEFAULT 0,
+    DOCS_COUNT BIGINT NOT NULL DEFAULT 0,
+    UPDATED_AT TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO USER_USAGE (USER_ID, BYTES_USED, DOCS_COUNT)
+SELECT U.ID, COALESCE(SUM(D.SIZE), 0), COUNT(D.ID)
+FROM USERS U LEFT JOIN DOCUMENTS D ON D.USER_ID = U.ID
+GROUP BY U.ID;
@@ -0,0 +1,2 @@
+-- Индивидуальное ограничение размера загружаемого файла; NULL - MAX_FILE_SIZE из конфигурации
+ALTER TABLE USERS ADD COLUMN MAX_FILE_SIZE BIGINT;
@@ -0,0 +1,3 @@
+ALTER TABLE USERS
+  DROP COLUMN MIME_ALLOW,
+  DROP COLUMN MIME_DENY;
@@ -0,0 +1,5 @@
+-- Индивидуальные списки разрешенных и запрещенных типов файлов; действуют вместе
+-- со списками из конфигурации
+ALTER TABLE USERS
+  ADD COLUMN MIME_ALLOW TEXT[],
+  ADD COLUMN MIME_DENY  TEXT[];
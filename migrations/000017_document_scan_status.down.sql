@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS IDX_DOCUMENTS_SCAN_PENDING;
+
+ALTER TABLE DOCUMENTS
+  DROP COLUMN SCAN_STATUS,
+  DROP COLUMN SCAN_SIGNATURE,
+  DROP COLUMN SCANNED_AT;
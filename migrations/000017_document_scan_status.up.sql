@@ -0,0 +1,7 @@
+-- Результат антивирусной проверки; NULL - документ загружен до появления проверки
+ALTER TABLE DOCUMENTS
+  ADD COLUMN SCAN_STATUS    TEXT,
+  ADD COLUMN SCAN_SIGNATURE TEXT,
+  ADD COLUMN SCANNED_AT     TIMESTAMPTZ;
+
+CREATE INDEX IDX_DOCUMENTS_SCAN_PENDING ON DOCUMENTS (CREATED_AT) WHERE SCAN_STATUS = 'pending';
@@ -0,0 +1,2 @@
+ALTER TABLE DOCUMENTS
+  DROP COLUMN EXTRACTED_METADATA;
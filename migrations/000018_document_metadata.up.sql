@@ -0,0 +1,4 @@
+-- Метаданные, извлеченные из файла (EXIF, PDF Info, свойства Office); NULL - файл
+-- загружен до появления извлечения и еще не обработан
+ALTER TABLE DOCUMENTS
+  ADD COLUMN EXTRACTED_METADATA JSONB;
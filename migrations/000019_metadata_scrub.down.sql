@@ -0,0 +1,6 @@
+ALTER TABLE DOCUMENTS
+  DROP COLUMN ORIGINAL_SIZE;
+
+ALTER TABLE USERS
+  DROP COLUMN SCRUB_MODE,
+  DROP COLUMN KEEP_ORIGINAL;
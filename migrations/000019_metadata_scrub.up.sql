@@ -0,0 +1,8 @@
+-- Удаление метаданных из изображений при загрузке; NULL - значения из конфигурации
+ALTER TABLE USERS
+  ADD COLUMN SCRUB_MODE    TEXT,
+  ADD COLUMN KEEP_ORIGINAL BOOLEAN;
+
+-- Размер оригинала, сохраненного до удаления метаданных; NULL - оригинала нет
+ALTER TABLE DOCUMENTS
+  ADD COLUMN ORIGINAL_SIZE BIGINT;
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS DOCUMENT_EVENTS;
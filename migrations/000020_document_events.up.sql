@@ -0,0 +1,15 @@
+-- Журнал изменений документов для потока событий. Получатели сохраняются в момент
+-- события: после удаления документа его доступы уже не восстановить.
+CREATE TABLE DOCUMENT_EVENTS (
+    ID         BIGSERIAL PRIMARY KEY,
+    EVENT_TYPE TEXT NOT NULL,
+    DOC_ID     UUID NOT NULL,
+    FILENAME   TEXT NOT NULL DEFAULT '',
+    MIME       TEXT NOT NULL DEFAULT '',
+    IS_PUBLIC  BOOLEAN NOT NULL DEFAULT FALSE,
+    RECIPIENTS UUID[] NOT NULL,
+    CREATED_AT TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Удаление устаревших событий
+CREATE INDEX ON DOCUMENT_EVENTS (CREATED_AT);
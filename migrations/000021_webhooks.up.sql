@@ -0,0 +1,32 @@
+-- Webhooks пользователей; USER_ID IS NULL - webhook администратора, получает все события
+CREATE TABLE WEBHOOKS (
+    ID         UUID PRIMARY KEY,
+    USER_ID    UUID REFERENCES USERS(ID) ON DELETE CASCADE,
+    URL        TEXT NOT NULL,
+    SECRET     TEXT NOT NULL,
+    -- Пустой список - все типы событий
+    EVENTS     TEXT[] NOT NULL DEFAULT '{}',
+    CREATED_AT TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX ON WEBHOOKS (USER_ID);
+
+-- Очередь доставки (outbox): строки добавляются в транзакции, изменившей документ.
+-- Тело запроса хранится целиком, чтобы не зависеть от очистки журнала событий.
+CREATE TABLE WEBHOOK_DELIVERIES (
+    ID               BIGSERIAL PRIMARY KEY,
+    WEBHOOK_ID       UUID NOT NULL REFERENCES WEBHOOKS(ID) ON DELETE CASCADE,
+    EVENT_ID         BIGINT NOT NULL,
+    EVENT_TYPE       TEXT NOT NULL,
+    PAYLOAD          JSONB NOT NULL,
+    STATUS           TEXT NOT NULL DEFAULT 'pending',
+    ATTEMPTS         INT NOT NULL DEFAULT 0,
+    NEXT_ATTEMPT_AT  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    LAST_STATUS_CODE INT,
+    LAST_ERROR       TEXT,
+    CREATED_AT       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    DELIVERED_AT     TIMESTAMPTZ
+);
+
+CREATE INDEX IDX_WEBHOOK_DELIVERIES_DUE ON WEBHOOK_DELIVERIES (NEXT_ATTEMPT_AT) WHERE STATUS = 'pending';
+CREATE INDEX ON WEBHOOK_DELIVERIES (WEBHOOK_ID, ID);
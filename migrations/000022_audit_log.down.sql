@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS AUDIT_LOG;
+DROP FUNCTION IF EXISTS AUDIT_LOG_APPEND_ONLY();
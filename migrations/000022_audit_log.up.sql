@@ -0,0 +1,32 @@
+-- Журнал действий с документами и учетными записями. Без внешних ключей: записи
+-- переживают удаление пользователей и документов.
+CREATE TABLE AUDIT_LOG (
+    ID          BIGSERIAL PRIMARY KEY,
+    CREATED_AT  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    ACTOR_ID    UUID,
+    ACTOR_LOGIN TEXT NOT NULL DEFAULT '',
+    ACTION      TEXT NOT NULL,
+    TARGET_TYPE TEXT NOT NULL DEFAULT '',
+    TARGET_ID   TEXT NOT NULL DEFAULT '',
+    TARGET_NAME TEXT NOT NULL DEFAULT '',
+    IP          TEXT NOT NULL DEFAULT '',
+    USER_AGENT  TEXT NOT NULL DEFAULT '',
+    OUTCOME     TEXT NOT NULL,
+    DETAILS     JSONB
+);
+
+CREATE INDEX ON AUDIT_LOG (CREATED_AT);
+CREATE INDEX ON AUDIT_LOG (ACTOR_LOGIN, ID);
+CREATE INDEX ON AUDIT_LOG (TARGET_TYPE, TARGET_ID, ID);
+
+-- Журнал только дополняется: изменение и удаление записей запрещены
+CREATE FUNCTION AUDIT_LOG_APPEND_ONLY() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER AUDIT_LOG_NO_UPDATE BEFORE UPDATE OR DELETE ON AUDIT_LOG
+    FOR EACH ROW EXECUTE FUNCTION AUDIT_LOG_APPEND_ONLY();
+CREATE TRIGGER AUDIT_LOG_NO_TRUNCATE BEFORE TRUNCATE ON AUDIT_LOG
+    FOR EACH STATEMENT EXECUTE FUNCTION AUDIT_LOG_APPEND_ONLY();
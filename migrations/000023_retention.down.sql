@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS LEGAL_HOLDS;
+DROP TABLE IF EXISTS RETENTION_POLICIES;
+DROP TRIGGER IF EXISTS DOCUMENTS_RETENTION_LOCK ON DOCUMENTS;
+DROP FUNCTION IF EXISTS DOCUMENTS_RETENTION_LOCK();
+ALTER TABLE DOCUMENTS
+    DROP COLUMN IF EXISTS RETENTION_LOCKED,
+    DROP COLUMN IF EXISTS EXPIRES_AT;
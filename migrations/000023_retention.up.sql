@@ -0,0 +1,43 @@
+-- Срок хранения документа. RETENTION_LOCKED - документ нельзя удалить до EXPIRES_AT
+ALTER TABLE DOCUMENTS
+    ADD COLUMN EXPIRES_AT       TIMESTAMPTZ,
+    ADD COLUMN RETENTION_LOCKED BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX ON DOCUMENTS (EXPIRES_AT) WHERE EXPIRES_AT IS NOT NULL;
+
+-- Запрет удаления документа со сроком обязательного хранения действует на любом пути
+-- удаления, включая удаление владельца
+CREATE FUNCTION DOCUMENTS_RETENTION_LOCK() RETURNS TRIGGER AS $$
+BEGIN
+    IF OLD.RETENTION_LOCKED AND OLD.EXPIRES_AT > NOW() THEN
+        RAISE EXCEPTION 'document % is retained until %', OLD.ID, OLD.EXPIRES_AT;
+    END IF;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER DOCUMENTS_RETENTION_LOCK BEFORE DELETE ON DOCUMENTS
+    FOR EACH ROW EXECUTE FUNCTION DOCUMENTS_RETENTION_LOCK();
+
+-- Политики хранения: срок для новых документов подходящего типа
+CREATE TABLE RETENTION_POLICIES (
+    ID         UUID PRIMARY KEY,
+    NAME       TEXT NOT NULL,
+    MIME       TEXT NOT NULL,
+    YEARS      INTEGER NOT NULL DEFAULT 0,
+    DAYS       INTEGER NOT NULL DEFAULT 0,
+    LOCKED     BOOLEAN NOT NULL DEFAULT FALSE,
+    CREATED_AT TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Юридические блокировки. Снятая блокировка удаляется (история - в журнале аудита);
+-- ON DELETE RESTRICT не дает удалить документ под блокировкой никаким путем
+CREATE TABLE LEGAL_HOLDS (
+    ID         UUID PRIMARY KEY,
+    DOC_ID     UUID NOT NULL REFERENCES DOCUMENTS(ID) ON DELETE RESTRICT,
+    REASON     TEXT NOT NULL,
+    CREATED_BY TEXT NOT NULL,
+    CREATED_AT TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX ON LEGAL_HOLDS (DOC_ID);
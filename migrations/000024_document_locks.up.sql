@@ -0,0 +1,10 @@
+-- Блокировки документов на время редактирования. У документа не больше одной блокировки;
+-- истекшая блокировка не действует и перезаписывается следующей
+CREATE TABLE DOCUMENT_LOCKS (
+    DOC_ID     UUID PRIMARY KEY REFERENCES DOCUMENTS(ID) ON DELETE CASCADE,
+    USER_ID    UUID NOT NULL REFERENCES USERS(ID) ON DELETE CASCADE,
+    CREATED_AT TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    EXPIRES_AT TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX ON DOCUMENT_LOCKS (EXPIRES_AT);
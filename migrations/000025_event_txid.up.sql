@@ -0,0 +1,6 @@
+-- Транзакция, записавшая событие. Id событий выдаются при вставке, а видны они становятся
+-- при фиксации, поэтому поток читает события по порядку транзакций: только из уже
+-- завершенных транзакций (TXID меньше xmin текущего снимка), упорядоченные по (TXID, ID)
+ALTER TABLE DOCUMENT_EVENTS ADD COLUMN TXID XID8 NOT NULL DEFAULT pg_current_xact_id();
+
+CREATE INDEX ON DOCUMENT_EVENTS (TXID, ID);
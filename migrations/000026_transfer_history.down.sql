@@ -0,0 +1,4 @@
+DELETE FROM DOCUMENT_TRANSFERS t WHERE NOT EXISTS (SELECT 1 FROM DOCUMENTS d WHERE d.ID = t.DOC_ID);
+
+ALTER TABLE DOCUMENT_TRANSFERS ADD CONSTRAINT DOCUMENT_TRANSFERS_DOC_ID_FKEY
+    FOREIGN KEY (DOC_ID) REFERENCES DOCUMENTS(ID) ON DELETE CASCADE;
@@ -0,0 +1,8 @@
+-- История передач хранится и после удаления документа: DOC_ID остается без внешнего ключа
+ALTER TABLE DOCUMENT_TRANSFERS DROP CONSTRAINT DOCUMENT_TRANSFERS_DOC_ID_FKEY;
+
+-- Повторные записи, которые добавлялись при принятии заявки рядом с ней самой
+DELETE FROM DOCUMENT_TRANSFERS c USING DOCUMENT_TRANSFERS p
+WHERE c.STATUS = 'completed' AND p.STATUS = 'completed' AND c.ID <> p.ID
+  AND c.DOC_ID = p.DOC_ID AND c.FROM_USER_ID = p.FROM_USER_ID AND c.TO_USER_ID = p.TO_USER_ID
+  AND c.RESOLVED_AT = p.RESOLVED_AT AND c.CREATED_AT = c.RESOLVED_AT AND p.CREATED_AT < p.RESOLVED_AT;